@@ -0,0 +1,494 @@
+package pixelcanvas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall/js"
+
+	"github.com/faiface/pixel"
+)
+
+// ErrNoStorage is returned when the browser's localStorage is unavailable,
+// e.g. disabled by privacy settings
+var ErrNoStorage = errors.New("pixelcanvas: localStorage not available")
+
+// InputKind is the device a Binding comes from
+type InputKind int
+
+// Input kinds
+const (
+	InputKey     InputKind = iota // Keyboard key, by KeyboardEvent.code (layout independent, e.g. "KeyW")
+	InputMouse                    // Mouse button: 0 left, 1 middle, 2 right
+	InputGamepad                  // Gamepad button, by standard mapping index, on any pad
+	InputTouch                    // Touch (or pen) on a named TouchZone
+)
+
+var inputNames = [...]string{"key", "mouse", "pad", "touch"}
+
+// Binding is one physical input that can trigger an action
+type Binding struct {
+	Kind   InputKind
+	Code   string // Key code for InputKey, zone name for InputTouch
+	Button int    // Button index for InputMouse and InputGamepad
+}
+
+// Key binds a keyboard key by KeyboardEvent.code, e.g. "Space" or "KeyZ"
+func Key(code string) Binding {
+	return Binding{Kind: InputKey, Code: code}
+}
+
+// MouseButton binds a mouse button
+func MouseButton(button int) Binding {
+	return Binding{Kind: InputMouse, Button: button}
+}
+
+// PadButton binds a gamepad button by its standard mapping index, e.g. 0
+// for the bottom face button
+func PadButton(button int) Binding {
+	return Binding{Kind: InputGamepad, Button: button}
+}
+
+// Touch binds a touch zone, see ActionMap.SetTouchZone
+func Touch(zone string) Binding {
+	return Binding{Kind: InputTouch, Code: zone}
+}
+
+// String formats the binding as "key:KeyW", "mouse:0", "pad:3" or
+// "touch:jump", the form bindings are saved in
+func (b Binding) String() string {
+	if b.Kind < 0 || int(b.Kind) >= len(inputNames) {
+		return "unknown"
+	}
+	if b.Kind == InputKey || b.Kind == InputTouch {
+		return inputNames[b.Kind] + ":" + b.Code
+	}
+	return inputNames[b.Kind] + ":" + strconv.Itoa(b.Button)
+}
+
+// ParseBinding parses the form String produces
+func ParseBinding(s string) (Binding, error) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return Binding{}, fmt.Errorf("pixelcanvas: bad binding %q", s)
+	}
+	kind, arg := s[:i], s[i+1:]
+	for k, name := range inputNames {
+		if name != kind {
+			continue
+		}
+		b := Binding{Kind: InputKind(k)}
+		if b.Kind == InputKey || b.Kind == InputTouch {
+			b.Code = arg
+			return b, nil
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return Binding{}, fmt.Errorf("pixelcanvas: bad binding %q", s)
+		}
+		b.Button = n
+		return b, nil
+	}
+	return Binding{}, fmt.Errorf("pixelcanvas: bad binding %q", s)
+}
+
+// ActionMap binds logical actions ("jump", "fire", "undo") to keys, mouse
+// buttons, gamepad buttons and touch zones, so the game asks about actions
+// rather than devices and players can rebind them.
+//
+// Call Update once at the start of every frame (e.g. first thing in the
+// RenderFunc): it polls gamepads and works out which actions went down or
+// up since the last frame. A press and release that both happen between
+// frames still counts as a press for that frame.
+type ActionMap struct {
+	c        *Canvasp
+	bindings map[string][]Binding
+	zones    map[string]pixel.Rect
+
+	held    map[Binding]bool
+	latched map[Binding]bool // Went down since the last Update
+	touches map[int]string   // Pointer id to the zone it went down on
+	pads    map[Binding]bool // Gamepad buttons held at the last poll
+
+	down, prev map[string]bool
+
+	injected  map[string]bool // Actions held by a MacroPlayer
+	injectHit map[string]bool // Actions a MacroPlayer pressed since the last Update
+	recorder  *MacroRecorder  // Records action changes, see NewMacroRecorder
+
+	capture   func(Binding) // Rebinding in progress
+	listeners []*listener
+}
+
+// NewActionMap starts listening for input on the page and the canvas
+func (c *Canvasp) NewActionMap() *ActionMap {
+	m := &ActionMap{
+		c:         c,
+		bindings:  make(map[string][]Binding),
+		zones:     make(map[string]pixel.Rect),
+		held:      make(map[Binding]bool),
+		latched:   make(map[Binding]bool),
+		touches:   make(map[int]string),
+		pads:      make(map[Binding]bool),
+		down:      make(map[string]bool),
+		prev:      make(map[string]bool),
+		injected:  make(map[string]bool),
+		injectHit: make(map[string]bool),
+	}
+	m.listeners = []*listener{
+		c.listen(c.window, "keydown", m.keyDown),
+		c.listen(c.window, "keyup", func(e js.Value) { m.release(Key(e.Get("code").String())) }),
+		c.listen(c.window, "blur", func(js.Value) { m.releaseAll() }), // Keyups are lost while unfocused
+		c.listen(c.canvas, "pointerdown", m.pointerDown),
+		c.listen(c.canvas, "pointerup", m.pointerUp),
+		c.listen(c.canvas, "pointercancel", m.pointerUp),
+	}
+	return m
+}
+
+// Bind adds bindings to action
+func (m *ActionMap) Bind(action string, bindings ...Binding) {
+	for _, b := range bindings {
+		if !m.bound(action, b) {
+			m.bindings[action] = append(m.bindings[action], b)
+		}
+	}
+}
+
+// SetBindings replaces action's bindings. No bindings leaves the action
+// defined but unbound.
+func (m *ActionMap) SetBindings(action string, bindings ...Binding) {
+	m.bindings[action] = append([]Binding{}, bindings...)
+}
+
+// Unbind removes one binding from action
+func (m *ActionMap) Unbind(action string, b Binding) {
+	list := m.bindings[action]
+	for i, o := range list {
+		if o == b {
+			m.bindings[action] = append(list[:i:i], list[i+1:]...)
+			return
+		}
+	}
+}
+
+// Bindings returns action's bindings
+func (m *ActionMap) Bindings(action string) []Binding {
+	return append([]Binding(nil), m.bindings[action]...)
+}
+
+// Actions returns the names of every action, sorted
+func (m *ActionMap) Actions() []string {
+	names := make([]string, 0, len(m.bindings))
+	for a := range m.bindings {
+		names = append(names, a)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Conflicts returns the other actions that b is also bound to, for a
+// rebinding screen to warn about
+func (m *ActionMap) Conflicts(action string, b Binding) []string {
+	var others []string
+	for _, a := range m.Actions() {
+		if a != action && m.bound(a, b) {
+			others = append(others, a)
+		}
+	}
+	return others
+}
+
+// SetTouchZone defines (or with the zero Rect removes) a named area of the
+// canvas, in logical coordinates (see SetCoordinates), that touch bindings
+// refer to, e.g. on-screen buttons
+func (m *ActionMap) SetTouchZone(name string, r pixel.Rect) {
+	if r == (pixel.Rect{}) {
+		delete(m.zones, name)
+		return
+	}
+	m.zones[name] = r.Norm()
+}
+
+// Update polls gamepads and advances the action states by one frame
+func (m *ActionMap) Update() {
+	m.pollPads()
+	m.prev, m.down = m.down, m.prev
+	for a := range m.down {
+		delete(m.down, a)
+	}
+	for a, list := range m.bindings {
+		for _, b := range list {
+			if m.held[b] || m.latched[b] {
+				m.down[a] = true
+				break
+			}
+		}
+	}
+	for a := range m.injected {
+		m.down[a] = true
+	}
+	for a := range m.injectHit {
+		m.down[a] = true
+		delete(m.injectHit, a)
+	}
+	for b := range m.latched {
+		delete(m.latched, b)
+	}
+
+	if m.recorder != nil {
+		for _, a := range m.Actions() {
+			if m.down[a] != m.prev[a] {
+				m.recorder.add(MacroStep{Action: a, Down: m.down[a]})
+			}
+		}
+	}
+}
+
+// inject presses or releases action on behalf of a MacroPlayer, alongside
+// whatever the devices are doing
+func (m *ActionMap) inject(action string, down bool) {
+	if down {
+		m.injected[action] = true
+		m.injectHit[action] = true
+		return
+	}
+	delete(m.injected, action)
+}
+
+// Pressed reports whether action is held this frame
+func (m *ActionMap) Pressed(action string) bool {
+	return m.down[action]
+}
+
+// JustPressed reports whether action went down this frame
+func (m *ActionMap) JustPressed(action string) bool {
+	return m.down[action] && !m.prev[action]
+}
+
+// JustReleased reports whether action went up this frame
+func (m *ActionMap) JustReleased(action string) bool {
+	return !m.down[action] && m.prev[action]
+}
+
+// Rebind waits for the next key, mouse button, gamepad button or touch zone
+// press and makes it action's only binding, then calls done (which may be
+// nil) with it. Escape cancels, calling done with ok false. The captured
+// press doesn't trigger any action.
+func (m *ActionMap) Rebind(action string, done func(b Binding, ok bool)) {
+	m.capture = func(b Binding) {
+		m.capture = nil
+		ok := b != Key("Escape")
+		if ok {
+			m.SetBindings(action, b)
+		}
+		if done != nil {
+			done(b, ok)
+		}
+	}
+}
+
+// Rebinding reports whether a Rebind is waiting for input
+func (m *ActionMap) Rebinding() bool {
+	return m.capture != nil
+}
+
+// MarshalJSON encodes the bindings as an object of action names to lists
+// of binding strings
+func (m *ActionMap) MarshalJSON() ([]byte, error) {
+	out := make(map[string][]string, len(m.bindings))
+	for a, list := range m.bindings {
+		s := make([]string, len(list))
+		for i, b := range list {
+			s[i] = b.String()
+		}
+		out[a] = s
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON replaces the bindings of the actions in data, leaving other
+// actions as they are, so saved bindings can be loaded over the defaults
+func (m *ActionMap) UnmarshalJSON(data []byte) error {
+	var in map[string][]string
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	parsed := make(map[string][]Binding, len(in))
+	for a, list := range in {
+		bs := make([]Binding, 0, len(list))
+		for _, s := range list {
+			b, err := ParseBinding(s)
+			if err != nil {
+				return err
+			}
+			bs = append(bs, b)
+		}
+		parsed[a] = bs
+	}
+	for a, bs := range parsed {
+		m.bindings[a] = bs
+	}
+	return nil
+}
+
+// Save stores the bindings in localStorage under key
+func (m *ActionMap) Save(key string) error {
+	data, err := m.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	store := js.Global().Get("localStorage")
+	if store.IsUndefined() || store.IsNull() {
+		return ErrNoStorage
+	}
+	store.Call("setItem", key, string(data))
+	return nil
+}
+
+// Load replaces bindings with those saved under key, see UnmarshalJSON.
+// Nothing saved yet is not an error; the current bindings are kept.
+func (m *ActionMap) Load(key string) error {
+	store := js.Global().Get("localStorage")
+	if store.IsUndefined() || store.IsNull() {
+		return ErrNoStorage
+	}
+	v := store.Call("getItem", key)
+	if v.IsNull() {
+		return nil
+	}
+	return m.UnmarshalJSON([]byte(v.String()))
+}
+
+// Remove stops listening for input
+func (m *ActionMap) Remove() {
+	for _, l := range m.listeners {
+		m.c.unlisten(l)
+	}
+	m.listeners = nil
+	m.releaseAll()
+}
+
+func (m *ActionMap) bound(action string, b Binding) bool {
+	for _, o := range m.bindings[action] {
+		if o == b {
+			return true
+		}
+	}
+	return false
+}
+
+// press records b going down, or hands it to a Rebind in progress
+func (m *ActionMap) press(b Binding) {
+	if m.capture != nil {
+		m.capture(b)
+		return
+	}
+	m.held[b] = true
+	m.latched[b] = true
+}
+
+func (m *ActionMap) release(b Binding) {
+	delete(m.held, b)
+}
+
+func (m *ActionMap) releaseAll() {
+	for b := range m.held {
+		delete(m.held, b)
+	}
+	for id := range m.touches {
+		delete(m.touches, id)
+	}
+}
+
+func (m *ActionMap) keyDown(e js.Value) {
+	if e.Get("repeat").Bool() {
+		return
+	}
+	b := Key(e.Get("code").String())
+	if m.capture != nil || m.boundAnywhere(b) {
+		e.Call("preventDefault") // Keep bound keys from scrolling the page
+	}
+	m.press(b)
+}
+
+// boundAnywhere reports whether any action uses b
+func (m *ActionMap) boundAnywhere(b Binding) bool {
+	for a := range m.bindings {
+		if m.bound(a, b) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *ActionMap) pointerDown(e js.Value) {
+	if e.Get("pointerType").String() == "mouse" {
+		m.press(MouseButton(e.Get("button").Int()))
+		return
+	}
+	at := m.c.FromClient(e.Get("clientX").Float(), e.Get("clientY").Float())
+	for name, r := range m.zones {
+		if r.Contains(at) {
+			m.touches[e.Get("pointerId").Int()] = name
+			m.press(Touch(name))
+			return
+		}
+	}
+}
+
+func (m *ActionMap) pointerUp(e js.Value) {
+	if e.Get("pointerType").String() == "mouse" {
+		m.release(MouseButton(e.Get("button").Int()))
+		return
+	}
+	id := e.Get("pointerId").Int()
+	name, ok := m.touches[id]
+	if !ok {
+		return
+	}
+	delete(m.touches, id)
+	for _, other := range m.touches {
+		if other == name {
+			return // Another finger is still on the zone
+		}
+	}
+	m.release(Touch(name))
+}
+
+// pollPads reads the gamepad buttons and turns changes into presses and
+// releases. Buttons held on several pads count once.
+func (m *ActionMap) pollPads() {
+	nav := js.Global().Get("navigator")
+	if nav.Get("getGamepads").IsUndefined() {
+		return
+	}
+	now := make(map[Binding]bool)
+	pads := nav.Call("getGamepads")
+	for i, n := 0, pads.Length(); i < n; i++ {
+		pad := pads.Index(i)
+		if pad.IsNull() || pad.IsUndefined() {
+			continue
+		}
+		buttons := pad.Get("buttons")
+		for j, nb := 0, buttons.Length(); j < nb; j++ {
+			if buttons.Index(j).Get("pressed").Bool() {
+				now[PadButton(j)] = true
+			}
+		}
+	}
+	for b := range now {
+		if !m.pads[b] {
+			m.press(b)
+		}
+	}
+	for b := range m.pads {
+		if !now[b] {
+			m.release(b)
+		}
+	}
+	m.pads = now
+}
@@ -0,0 +1,275 @@
+package pixelcanvas
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"syscall/js"
+	"time"
+)
+
+// Analytics
+//
+// StartAnalytics collects structured events (the session starting and
+// ending, frame drop episodes from the watchdog, feature use and errors
+// reported by the app) and hands them in batches to an Analytics sink,
+// which sends them to the app's backend. A batch goes out Interval after
+// its first event, as soon as it reaches MaxBatch events, and when the page
+// is hidden or unloaded, which is the last chance a page reliably gets.
+
+// Analytics event types sent by the package. Apps may send their own.
+const (
+	EventSessionStart = "session_start"
+	EventSessionEnd   = "session_end"
+	EventFrameDrops   = "frame_drops" // A watchdog jank report
+	EventFeature      = "feature"
+	EventError        = "error"
+)
+
+// Analytics defaults
+const (
+	DefaultAnalyticsInterval = 10 * time.Second
+	DefaultAnalyticsBatch    = 50
+)
+
+// AnalyticsEvent is one event
+type AnalyticsEvent struct {
+	Type    string                 `json:"type"`
+	Time    time.Time              `json:"time"`
+	Session string                 `json:"session"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// Analytics receives batches of events. Send may be called while the page
+// is being hidden or unloaded, from the event handler, so it must not wait
+// on a promise: use navigator.sendBeacon or a keepalive fetch, as
+// BeaconAnalytics does.
+type Analytics interface {
+	Send(events []AnalyticsEvent) error
+}
+
+// AnalyticsFunc adapts a function to Analytics
+type AnalyticsFunc func(events []AnalyticsEvent) error
+
+// Send implements Analytics
+func (f AnalyticsFunc) Send(events []AnalyticsEvent) error {
+	return f(events)
+}
+
+// BeaconAnalytics posts each batch to URL as a JSON array, with
+// navigator.sendBeacon, or a keepalive fetch if the browser lacks it or
+// refuses the beacon (e.g. for being too large)
+type BeaconAnalytics struct {
+	URL string
+}
+
+// Send implements Analytics
+func (b BeaconAnalytics) Send(events []AnalyticsEvent) error {
+	data, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	beacon(b.URL, data)
+	return nil
+}
+
+// beacon posts JSON data to url without waiting for an answer, in a way
+// that survives the page unloading
+func beacon(url string, data []byte) {
+	g := js.Global()
+	nav := g.Get("navigator")
+	if !nav.Get("sendBeacon").IsUndefined() {
+		opts := g.Get("Object").New()
+		opts.Set("type", "application/json")
+		blob := g.Get("Blob").New([]interface{}{string(data)}, opts)
+		if nav.Call("sendBeacon", url, blob).Bool() {
+			return
+		}
+	}
+	g.Call("fetch", url, map[string]interface{}{
+		"method":    "POST",
+		"body":      string(data),
+		"keepalive": true,
+		"headers":   map[string]interface{}{"Content-Type": "application/json"},
+	})
+}
+
+// AnalyticsTracker batches a canvas's events for an Analytics sink
+type AnalyticsTracker struct {
+	Interval time.Duration
+	MaxBatch int
+
+	c       *Canvasp
+	sink    Analytics
+	session string
+	unwatch func() // Stops flushing on unload
+
+	mu      sync.Mutex
+	pending []AnalyticsEvent
+	timer   *time.Timer
+	sent    uint64 // Events handed to the sink, see Stats
+	failed  uint64 // Events in batches the sink returned an error for
+}
+
+// StartAnalytics starts a session sending events to sink, replacing any
+// tracker already running, and records EventSessionStart with the
+// browser's details
+func (c *Canvasp) StartAnalytics(sink Analytics) *AnalyticsTracker {
+	if c.analytics != nil {
+		c.analytics.Stop()
+	}
+	t := &AnalyticsTracker{
+		Interval: DefaultAnalyticsInterval,
+		MaxBatch: DefaultAnalyticsBatch,
+		c:        c,
+		sink:     sink,
+		session:  sessionID(),
+	}
+	t.unwatch = c.OnUnload(t.Flush)
+	c.analytics = t
+
+	caps := c.Capabilities()
+	nav := js.Global().Get("navigator")
+	t.Track(EventSessionStart,
+		"userAgent", nav.Get("userAgent").String(),
+		"language", nav.Get("language").String(),
+		"width", c.width,
+		"height", c.height,
+		"pixelRatio", c.window.Get("devicePixelRatio").Float(),
+		"webgl2", caps.WebGL2,
+		"offscreenCanvas", caps.OffscreenCanvas,
+	)
+	c.log().Debug("analytics started", "session", t.session)
+	return t
+}
+
+// Analytics returns the running tracker, or nil
+func (c *Canvasp) Analytics() *AnalyticsTracker {
+	return c.analytics
+}
+
+// Track records an event of type typ, with data given as alternating keys
+// and values like the logger's. It does nothing if analytics isn't running.
+func (c *Canvasp) Track(typ string, keyvals ...interface{}) {
+	if c.analytics != nil {
+		c.analytics.Track(typ, keyvals...)
+	}
+}
+
+// TrackFeature records use of the named feature
+func (c *Canvasp) TrackFeature(name string, keyvals ...interface{}) {
+	c.Track(EventFeature, append([]interface{}{"name", name}, keyvals...)...)
+}
+
+// TrackError records an error the app has handled or is about to report
+func (c *Canvasp) TrackError(err error, keyvals ...interface{}) {
+	if err == nil {
+		return
+	}
+	c.Track(EventError, append([]interface{}{"message", err.Error()}, keyvals...)...)
+}
+
+// Session returns the session ID sent with every event
+func (t *AnalyticsTracker) Session() string {
+	return t.session
+}
+
+// Track records an event, see Canvasp.Track
+func (t *AnalyticsTracker) Track(typ string, keyvals ...interface{}) {
+	e := AnalyticsEvent{Type: typ, Time: time.Now(), Session: t.session}
+	if len(keyvals) > 1 {
+		e.Data = make(map[string]interface{}, len(keyvals)/2)
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			if k, ok := keyvals[i].(string); ok {
+				e.Data[k] = keyvals[i+1]
+			}
+		}
+	}
+
+	t.mu.Lock()
+	t.pending = append(t.pending, e)
+	full := t.MaxBatch > 0 && len(t.pending) >= t.MaxBatch
+	if !full && t.timer == nil {
+		t.timer = time.AfterFunc(t.Interval, t.Flush)
+	}
+	t.mu.Unlock()
+
+	if full {
+		t.Flush()
+	}
+}
+
+// Pending returns the number of events waiting to be sent
+func (t *AnalyticsTracker) Pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending)
+}
+
+// Stats returns how many events have been handed to the sink, and how
+// many of those were in batches it failed to send. Failed batches are
+// dropped, not retried.
+func (t *AnalyticsTracker) Stats() (sent, failed uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sent, t.failed
+}
+
+// Flush sends the pending events now
+func (t *AnalyticsTracker) Flush() {
+	t.mu.Lock()
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	batch := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	err := t.sink.Send(batch)
+	t.mu.Lock()
+	t.sent += uint64(len(batch))
+	if err != nil {
+		t.failed += uint64(len(batch))
+	}
+	t.mu.Unlock()
+	if err != nil {
+		t.c.log().Warn("analytics send failed", "events", len(batch), "err", err)
+	}
+}
+
+// Stop records EventSessionEnd, sends everything pending and stops
+// tracking
+func (t *AnalyticsTracker) Stop() {
+	t.Track(EventSessionEnd, "frames", t.c.watchdog.stats.Frames)
+	t.Flush()
+	t.unwatch()
+	if t.c.analytics == t {
+		t.c.analytics = nil
+	}
+}
+
+// trackJank records a watchdog report as a frame drop episode
+func (t *AnalyticsTracker) trackJank(r JankReport) {
+	t.Track(EventFrameDrops,
+		"overruns", r.Overruns,
+		"window", r.Window,
+		"budgetMs", r.Budget.Seconds()*1000,
+		"renderMs", r.AvgRender.Seconds()*1000,
+		"copyMs", r.AvgCopy.Seconds()*1000,
+		"skipped", t.c.SkippedFrames(),
+	)
+}
+
+// sessionID returns a random 128 bit ID in hex
+func sessionID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b[:])
+}
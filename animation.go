@@ -0,0 +1,391 @@
+package pixelcanvas
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"time"
+
+	"github.com/lwayneh/pixelcanvas/colors"
+)
+
+// AnimationFrame is one frame of an AnimationDocument
+type AnimationFrame struct {
+	Pix   *Region
+	Delay time.Duration // How long the frame shows. 0 for the document's FPS
+}
+
+// OnionSkin configures ghosting of neighbouring frames while editing
+type OnionSkin struct {
+	Before, After int     // Frames shown either side of the current one. 0 for none
+	Opacity       float64 // Of the nearest ghost, 0-1. Each further frame is half as strong
+
+	BeforeTint, AfterTint color.Color // Multiplied into earlier and later ghosts. nil for none
+}
+
+// DefaultOnionOpacity is the nearest ghost's opacity for a new document
+const DefaultOnionOpacity = 0.3
+
+// AnimationDocument is a frame-by-frame pixel animation edited on a canvas.
+// The current frame lives on the shadow canvas, so every drawing tool works
+// on it unchanged, and is stored back when switching frames. Neighbouring
+// frames are ghosted on an overlay, which never reaches the frames
+// themselves. Each frame has its own undo History. Frames follow the
+// canvas when it is resized, their contents carried over according to the
+// ResizeMode.
+type AnimationDocument struct {
+	Frames []*AnimationFrame
+	FPS    float64
+	Loop   bool // Playback and GIF export repeat forever
+
+	Onion OnionSkin
+
+	c         *Canvasp
+	current   int
+	overlay   *Overlay
+	histories map[*AnimationFrame]*History
+	unhook    func() // Stops following Resize
+
+	playing bool
+	shown   int           // Frame on screen during playback
+	elapsed time.Duration // Time into the shown frame
+}
+
+// NewAnimationDocument creates a document with the given number of frames.
+// The first frame starts with the current canvas contents, the rest blank.
+func (c *Canvasp) NewAnimationDocument(frames int, fps float64) *AnimationDocument {
+	if frames < 1 {
+		frames = 1
+	}
+	d := &AnimationDocument{
+		FPS:       fps,
+		Loop:      true,
+		Onion:     OnionSkin{Before: 1, After: 1, Opacity: DefaultOnionOpacity, BeforeTint: color.RGBA{255, 80, 80, 255}, AfterTint: color.RGBA{80, 160, 255, 255}},
+		c:         c,
+		histories: make(map[*AnimationFrame]*History),
+	}
+	for i := 0; i < frames; i++ {
+		d.Frames = append(d.Frames, &AnimationFrame{Pix: NewRegion(c.width, c.height)})
+	}
+	d.Frames[0].Pix.Pix = c.image.Pixels()
+	d.overlay = c.AddOverlay(d.drawOnion)
+	d.unhook = c.OnResize(d.resize)
+	return d
+}
+
+// resize brings every frame to the canvas's new size. Resize has already
+// carried the frame being edited over on the canvas itself.
+func (d *AnimationDocument) resize(width, height int) {
+	for i, f := range d.Frames {
+		if i == d.current && !d.playing {
+			f.Pix = &Region{Width: width, Height: height, Pix: d.c.image.Pixels()}
+			continue
+		}
+		f.Pix = &Region{Width: width, Height: height, Pix: resizePixels(f.Pix.Pix, f.Pix.Width, f.Pix.Height, width, height, d.c.resizeMode)}
+	}
+	if d.playing {
+		d.c.image.SetPixels(d.Frames[d.shown].Pix.Pix)
+	}
+	d.overlay.Invalidate()
+}
+
+// Current returns the index of the frame being edited
+func (d *AnimationDocument) Current() int {
+	return d.current
+}
+
+// SetCurrent stores the frame being edited and loads frame i onto the canvas
+func (d *AnimationDocument) SetCurrent(i int) {
+	if i < 0 || i >= len(d.Frames) || i == d.current {
+		return
+	}
+	d.store()
+	d.current = i
+	d.load(i)
+}
+
+// History returns the undo history of the frame being edited
+func (d *AnimationDocument) History() *History {
+	f := d.Frames[d.current]
+	h, ok := d.histories[f]
+	if !ok {
+		h = d.c.NewHistory(0)
+		d.histories[f] = h
+	}
+	return h
+}
+
+// InsertFrame adds a frame at index at, blank or a copy of the frame being
+// edited, and makes it current
+func (d *AnimationDocument) InsertFrame(at int, duplicate bool) {
+	at = clampInt(at, 0, len(d.Frames))
+	d.store()
+	f := &AnimationFrame{Pix: NewRegion(d.c.width, d.c.height)}
+	if duplicate {
+		cur := d.Frames[d.current]
+		copy(f.Pix.Pix, cur.Pix.Pix)
+		f.Delay = cur.Delay
+	}
+	d.Frames = append(d.Frames, nil)
+	copy(d.Frames[at+1:], d.Frames[at:])
+	d.Frames[at] = f
+	d.current = at
+	d.load(at)
+}
+
+// RemoveFrame deletes frame i. The last remaining frame can't be removed.
+func (d *AnimationDocument) RemoveFrame(i int) {
+	if len(d.Frames) <= 1 || i < 0 || i >= len(d.Frames) {
+		return
+	}
+	d.store()
+	delete(d.histories, d.Frames[i])
+	d.Frames = append(d.Frames[:i], d.Frames[i+1:]...)
+	if d.current >= len(d.Frames) || d.current > i {
+		d.current--
+	}
+	if d.current < 0 {
+		d.current = 0
+	}
+	d.load(d.current)
+}
+
+// MoveFrame moves frame from to index to, keeping it current if it was
+func (d *AnimationDocument) MoveFrame(from, to int) {
+	if from < 0 || from >= len(d.Frames) || to < 0 || to >= len(d.Frames) || from == to {
+		return
+	}
+	d.store()
+	editing := d.Frames[d.current]
+	f := d.Frames[from]
+	d.Frames = append(d.Frames[:from], d.Frames[from+1:]...)
+	d.Frames = append(d.Frames, nil)
+	copy(d.Frames[to+1:], d.Frames[to:])
+	d.Frames[to] = f
+	for i, g := range d.Frames {
+		if g == editing {
+			d.current = i
+		}
+	}
+	d.overlay.Invalidate()
+}
+
+// Play starts previewing the animation on the canvas. Onion skins are
+// hidden while playing. Use RenderFunc so playback advances.
+func (d *AnimationDocument) Play() {
+	if d.playing {
+		return
+	}
+	d.store()
+	d.playing = true
+	d.shown = d.current
+	d.elapsed = 0
+	d.overlay.Show(false)
+}
+
+// Stop ends playback and puts the frame being edited back on the canvas
+func (d *AnimationDocument) Stop() {
+	if !d.playing {
+		return
+	}
+	d.playing = false
+	d.load(d.current)
+	d.overlay.Show(true)
+}
+
+// Playing reports whether the animation is being previewed
+func (d *AnimationDocument) Playing() bool {
+	return d.playing
+}
+
+// RenderFunc returns a RenderFunc for Canvasp.Start which plays the
+// animation while playing and otherwise calls edit (which may be nil).
+func (d *AnimationDocument) RenderFunc(edit RenderFunc) RenderFunc {
+	return func(gc *Canvas) bool {
+		if d.playing {
+			return d.advance(d.c.Delta())
+		}
+		if edit == nil {
+			return false
+		}
+		return edit(gc)
+	}
+}
+
+// delay returns how long frame i shows
+func (d *AnimationDocument) delay(i int) time.Duration {
+	if dl := d.Frames[i].Delay; dl > 0 {
+		return dl
+	}
+	if d.FPS <= 0 {
+		return time.Second / 10
+	}
+	return time.Duration(float64(time.Second) / d.FPS)
+}
+
+// advance moves playback on by dt, reporting whether the frame shown changed
+func (d *AnimationDocument) advance(dt time.Duration) bool {
+	d.elapsed += dt
+	shown := d.shown
+	for d.elapsed >= d.delay(d.shown) {
+		d.elapsed -= d.delay(d.shown)
+		if d.shown+1 < len(d.Frames) {
+			d.shown++
+		} else if d.Loop {
+			d.shown = 0
+		} else {
+			d.elapsed = 0
+			break
+		}
+	}
+	if d.shown == shown {
+		return false
+	}
+	d.c.image.SetPixels(d.Frames[d.shown].Pix.Pix)
+	return true
+}
+
+// store copies the canvas into the frame being edited
+func (d *AnimationDocument) store() {
+	if d.playing {
+		return
+	}
+	d.Frames[d.current].Pix.Pix = d.c.image.Pixels()
+}
+
+// load puts frame i on the canvas
+func (d *AnimationDocument) load(i int) {
+	d.c.image.SetPixels(d.Frames[i].Pix.Pix)
+	d.overlay.Invalidate()
+}
+
+// drawOnion paints the ghosts of neighbouring frames, furthest first
+func (d *AnimationDocument) drawOnion(o *Overlay) {
+	on := d.Onion
+	ghost := func(i int, opacity float64, tint color.Color) {
+		if i < 0 || i >= len(d.Frames) || opacity <= 0 {
+			return
+		}
+		src := d.Frames[i].Pix.Pix
+		if len(src) != len(o.Pix) {
+			return
+		}
+		tr, tg, tb := uint32(255), uint32(255), uint32(255)
+		if tint != nil {
+			t := rgba8(tint)
+			tr, tg, tb = uint32(t[0]), uint32(t[1]), uint32(t[2])
+		}
+		k := uint32(opacity * 255)
+		var px [4]uint8
+		for p := 0; p < len(src); p += 4 {
+			if src[p+3] == 0 {
+				continue
+			}
+			px[0] = uint8(uint32(src[p]) * tr / 255 * k / 255)
+			px[1] = uint8(uint32(src[p+1]) * tg / 255 * k / 255)
+			px[2] = uint8(uint32(src[p+2]) * tb / 255 * k / 255)
+			px[3] = uint8(uint32(src[p+3]) * k / 255)
+			blendOver(o.Pix[p:p+4], px[:])
+		}
+	}
+
+	for k := on.Before; k >= 1; k-- {
+		ghost(d.current-k, on.Opacity/float64(uint(1)<<uint(k-1)), on.BeforeTint)
+	}
+	for k := on.After; k >= 1; k-- {
+		ghost(d.current+k, on.Opacity/float64(uint(1)<<uint(k-1)), on.AfterTint)
+	}
+}
+
+// SetOnion changes the onion skin settings
+func (d *AnimationDocument) SetOnion(on OnionSkin) {
+	d.Onion = on
+	d.overlay.Invalidate()
+}
+
+// FrameImage returns frame i as a top-down image.RGBA
+func (d *AnimationDocument) FrameImage(i int) *image.RGBA {
+	d.store()
+	return d.Frames[i].Pix.Image()
+}
+
+// EncodeGIF writes the animation as a GIF. Colours are reduced to p (nil for
+// the web-safe palette) using the given dithering; pixels less than half
+// opaque become transparent.
+func (d *AnimationDocument) EncodeGIF(w io.Writer, p *colors.Palette, dither colors.Dither) error {
+	d.store()
+	if p == nil {
+		p = &colors.Palette{Name: "Web safe"}
+		for _, col := range palette.WebSafe {
+			p.Colors = append(p.Colors, color.NRGBAModel.Convert(col).(color.NRGBA))
+		}
+	}
+	if len(p.Colors) > 255 { // Leave room for the transparent index
+		p = &colors.Palette{Name: p.Name, Colors: p.Colors[:255]}
+	}
+
+	anim := &gif.GIF{LoopCount: -1}
+	if d.Loop {
+		anim.LoopCount = 0
+	}
+	for i := range d.Frames {
+		img := d.Frames[i].Pix.Image()
+		pal := colors.Quantize(img, p, dither)
+		transparent := uint8(len(pal.Palette))
+		pal.Palette = append(pal.Palette, color.RGBA{})
+		for y := 0; y < img.Rect.Dy(); y++ {
+			for x := 0; x < img.Rect.Dx(); x++ {
+				if img.Pix[img.PixOffset(x, y)+3] < 128 {
+					pal.SetColorIndex(x, y, transparent)
+				}
+			}
+		}
+		anim.Image = append(anim.Image, pal)
+		anim.Delay = append(anim.Delay, int(d.delay(i)/(10*time.Millisecond)))
+		anim.Disposal = append(anim.Disposal, gif.DisposalBackground)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// SpriteSheet lays the frames out in a grid cols wide (0 for a single row)
+// and returns the sheet with each frame's rectangle on it
+func (d *AnimationDocument) SpriteSheet(cols int) (*image.RGBA, []image.Rectangle) {
+	d.store()
+	n := len(d.Frames)
+	if cols <= 0 || cols > n {
+		cols = n
+	}
+	rows := (n + cols - 1) / cols
+	fw, fh := d.c.width, d.c.height
+
+	sheet := image.NewRGBA(image.Rect(0, 0, cols*fw, rows*fh))
+	rects := make([]image.Rectangle, n)
+	for i, f := range d.Frames {
+		r := image.Rect(0, 0, fw, fh).Add(image.Pt((i%cols)*fw, (i/cols)*fh))
+		draw.Draw(sheet, r, f.Pix.Image(), image.Point{}, draw.Src)
+		rects[i] = r
+	}
+	return sheet, rects
+}
+
+// EncodeSpriteSheetPNG is SpriteSheet encoded as a PNG
+func (d *AnimationDocument) EncodeSpriteSheetPNG(cols int) ([]byte, []image.Rectangle, error) {
+	sheet, rects := d.SpriteSheet(cols)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, sheet); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), rects, nil
+}
+
+// Close removes the onion skin overlay. The canvas keeps the current frame.
+func (d *AnimationDocument) Close() {
+	d.Stop()
+	d.c.RemoveOverlay(d.overlay)
+	d.unhook()
+}
@@ -0,0 +1,93 @@
+package pixelcanvas
+
+import (
+	"syscall/js"
+	"time"
+)
+
+// Politeness is how urgently a screen reader speaks an announcement
+type Politeness int
+
+// Politeness levels, matching aria-live
+const (
+	AnnouncePolite    Politeness = iota // Spoken when the reader is idle, e.g. a score change
+	AnnounceAssertive                   // Interrupts what is being spoken, e.g. an error
+)
+
+func (p Politeness) String() string {
+	if p == AnnounceAssertive {
+		return "assertive"
+	}
+	return "polite"
+}
+
+// announceDelay is how long a live region is left empty before new text
+// goes in. Screen readers only speak changes, and some miss a region's
+// first change if it was only just added to the page.
+const announceDelay = 100 * time.Millisecond
+
+type announceState struct {
+	regions [2]js.Value // By Politeness
+	seq     [2]int      // Announcements made, so a superseded one is dropped
+}
+
+// Announce has screen readers speak text, for what the canvas shows but
+// assistive technology can't see: a tool switch, a score, a game over. It
+// writes to a visually hidden ARIA live region the package adds to the page
+// on first use. Announcing the same text again speaks it again; an
+// announcement made within 100ms of another at the same level replaces it.
+func (c *Canvasp) Announce(text string, politeness Politeness) {
+	if politeness != AnnounceAssertive {
+		politeness = AnnouncePolite
+	}
+	a := &c.announce
+	region := a.regions[politeness]
+	if region.IsUndefined() {
+		region = c.newLiveRegion(politeness)
+		a.regions[politeness] = region
+	}
+	a.seq[politeness]++
+	seq := a.seq[politeness]
+	region.Set("textContent", "")
+	time.AfterFunc(announceDelay, func() {
+		if a.seq[politeness] == seq {
+			region.Set("textContent", text)
+		}
+	})
+}
+
+// newLiveRegion adds a live region hidden visually but not from assistive
+// technology (display: none or visibility: hidden would hide it from both)
+func (c *Canvasp) newLiveRegion(politeness Politeness) js.Value {
+	el := c.doc.Call("createElement", "div")
+	if politeness == AnnounceAssertive {
+		el.Call("setAttribute", "role", "alert")
+	} else {
+		el.Call("setAttribute", "role", "status")
+	}
+	el.Call("setAttribute", "aria-live", politeness.String())
+	el.Call("setAttribute", "aria-atomic", "true")
+	style := el.Get("style")
+	style.Set("position", "absolute")
+	style.Set("width", "1px")
+	style.Set("height", "1px")
+	style.Set("margin", "-1px")
+	style.Set("padding", "0")
+	style.Set("border", "0")
+	style.Set("overflow", "hidden")
+	style.Set("clip", "rect(0 0 0 0)")
+	style.Set("clipPath", "inset(50%)")
+	style.Set("whiteSpace", "nowrap")
+	c.body.Call("appendChild", el)
+	return el
+}
+
+// removeLiveRegions takes the live regions off the page
+func (c *Canvasp) removeLiveRegions() {
+	for i, region := range c.announce.regions {
+		if !region.IsUndefined() {
+			region.Call("remove")
+			c.announce.regions[i] = js.Undefined()
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Package automata runs cellular automata, Conway's Game of Life and any
+// other rule over a small number of cell states, on a double-buffered grid
+// that renders straight into a pixelcanvas pixel buffer.
+//
+// A generation reads only the current grid and writes only the next, so
+// rows are independent and Workers can split them between goroutines.
+// Go's WebAssembly port runs goroutines on one thread, so that only speeds
+// things up natively (e.g. precomputing in a tool); in the browser leave
+// Workers at 1.
+package automata
+
+import (
+	"errors"
+	"image/color"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Neighbours holds the states of a cell's eight neighbours, clockwise from
+// the one above-left
+type Neighbours [8]uint8
+
+// Count returns how many neighbours are in state s
+func (n *Neighbours) Count(s uint8) int {
+	c := 0
+	for _, v := range n {
+		if v == s {
+			c++
+		}
+	}
+	return c
+}
+
+// Alive returns how many neighbours are in any state other than 0
+func (n *Neighbours) Alive() int {
+	return 8 - n.Count(0)
+}
+
+// Rule returns a cell's next state from its current state and neighbours
+type Rule func(state uint8, n *Neighbours) uint8
+
+// ErrBadRule is returned for a rule string that isn't in B/S notation
+var ErrBadRule = errors.New("automata: rule must look like B3/S23")
+
+// Life returns the two state rule in B/S notation, e.g. "B3/S23" for
+// Conway's Game of Life or "B36/S23" for HighLife: a dead cell is born with
+// one of the B counts of live neighbours, a live one survives with one of
+// the S counts
+func Life(rule string) (Rule, error) {
+	var born, survive [9]bool
+	parts := strings.Split(strings.ToUpper(rule), "/")
+	if len(parts) != 2 {
+		return nil, ErrBadRule
+	}
+	for _, p := range parts {
+		var set *[9]bool
+		switch {
+		case strings.HasPrefix(p, "B"):
+			set = &born
+		case strings.HasPrefix(p, "S"):
+			set = &survive
+		default:
+			return nil, ErrBadRule
+		}
+		for _, d := range p[1:] {
+			n, err := strconv.Atoi(string(d))
+			if err != nil || n > 8 {
+				return nil, ErrBadRule
+			}
+			set[n] = true
+		}
+	}
+	return func(state uint8, n *Neighbours) uint8 {
+		alive := n.Alive()
+		if state == 0 && born[alive] || state != 0 && survive[alive] {
+			return 1
+		}
+		return 0
+	}, nil
+}
+
+// Conway is the Game of Life, B3/S23
+var Conway, _ = Life("B3/S23")
+
+// Automaton is a grid of cells stepped by a Rule
+type Automaton struct {
+	Width, Height int
+	Rule          Rule
+	Wrap          bool // Edges wrap around (a torus). Otherwise cells beyond the edge are state 0
+	Workers       int  // Goroutines splitting each generation. 0 or 1 for none
+
+	// Colors gives the colour of each state when rendering; states beyond
+	// it are drawn with the last colour. Defaults to transparent and white.
+	Colors []color.RGBA
+
+	cur, next  []uint8
+	generation uint64
+}
+
+// New creates an automaton with every cell in state 0
+func New(width, height int, rule Rule) *Automaton {
+	return &Automaton{
+		Width:  width,
+		Height: height,
+		Rule:   rule,
+		Colors: []color.RGBA{{}, {255, 255, 255, 255}},
+		cur:    make([]uint8, width*height),
+		next:   make([]uint8, width*height),
+	}
+}
+
+// Get returns the state of cell x, y, row 0 at the bottom. Cells outside
+// the grid read as 0, or wrap when Wrap is set.
+func (a *Automaton) Get(x, y int) uint8 {
+	if a.Wrap {
+		x, y = mod(x, a.Width), mod(y, a.Height)
+	} else if x < 0 || y < 0 || x >= a.Width || y >= a.Height {
+		return 0
+	}
+	return a.cur[y*a.Width+x]
+}
+
+// Set sets the state of cell x, y, ignoring cells outside the grid
+func (a *Automaton) Set(x, y int, state uint8) {
+	if x < 0 || y < 0 || x >= a.Width || y >= a.Height {
+		return
+	}
+	a.cur[y*a.Width+x] = state
+}
+
+// Cells returns the current grid, row 0 first, for direct editing
+func (a *Automaton) Cells() []uint8 {
+	return a.cur
+}
+
+// Randomize sets each cell to state 1 with probability density, from a
+// seed so patterns can be reproduced
+func (a *Automaton) Randomize(density float64, seed int64) {
+	rng := rand.New(rand.NewSource(seed))
+	for i := range a.cur {
+		a.cur[i] = 0
+		if rng.Float64() < density {
+			a.cur[i] = 1
+		}
+	}
+}
+
+// Clear sets every cell to state 0
+func (a *Automaton) Clear() {
+	for i := range a.cur {
+		a.cur[i] = 0
+	}
+}
+
+// Generation returns how many steps have run
+func (a *Automaton) Generation() uint64 {
+	return a.generation
+}
+
+// Step advances one generation
+func (a *Automaton) Step() {
+	workers := a.Workers
+	if workers <= 1 || a.Height < workers*2 {
+		a.rows(0, a.Height)
+	} else {
+		var wg sync.WaitGroup
+		band := (a.Height + workers - 1) / workers
+		for y0 := 0; y0 < a.Height; y0 += band {
+			y1 := y0 + band
+			if y1 > a.Height {
+				y1 = a.Height
+			}
+			wg.Add(1)
+			go func(y0, y1 int) {
+				defer wg.Done()
+				a.rows(y0, y1)
+			}(y0, y1)
+		}
+		wg.Wait()
+	}
+	a.cur, a.next = a.next, a.cur
+	a.generation++
+}
+
+// rows computes rows [y0, y1) of the next generation
+func (a *Automaton) rows(y0, y1 int) {
+	w := a.Width
+	var n Neighbours
+	for y := y0; y < y1; y++ {
+		for x := 0; x < w; x++ {
+			// Interior cells index directly; only the border needs Get
+			if x > 0 && y > 0 && x < w-1 && y < a.Height-1 {
+				up, mid, down := (y+1)*w+x, y*w+x, (y-1)*w+x
+				n = Neighbours{
+					a.cur[up-1], a.cur[up], a.cur[up+1], a.cur[mid+1],
+					a.cur[down+1], a.cur[down], a.cur[down-1], a.cur[mid-1],
+				}
+			} else {
+				n = Neighbours{
+					a.Get(x-1, y+1), a.Get(x, y+1), a.Get(x+1, y+1), a.Get(x+1, y),
+					a.Get(x+1, y-1), a.Get(x, y-1), a.Get(x-1, y-1), a.Get(x-1, y),
+				}
+			}
+			a.next[y*w+x] = a.Rule(a.cur[y*w+x], &n)
+		}
+	}
+}
+
+// Render draws the grid into pix, a width x height buffer in the shadow
+// canvas layout (premultiplied RGBA, rows bottom-up), with each cell
+// covering scale x scale pixels from the bottom left corner. Cells beyond
+// the buffer are skipped.
+func (a *Automaton) Render(pix []uint8, width, height, scale int) {
+	if scale < 1 {
+		scale = 1
+	}
+	lut := make([][4]uint8, len(a.Colors))
+	for i, c := range a.Colors {
+		// Premultiply so straight colours can be given
+		lut[i] = [4]uint8{
+			uint8(uint32(c.R) * uint32(c.A) / 255),
+			uint8(uint32(c.G) * uint32(c.A) / 255),
+			uint8(uint32(c.B) * uint32(c.A) / 255),
+			c.A,
+		}
+	}
+	if len(lut) == 0 {
+		lut = [][4]uint8{{}}
+	}
+	for py := 0; py < height && py/scale < a.Height; py++ {
+		cy := py / scale
+		row := pix[py*width*4 : (py+1)*width*4]
+		for px := 0; px < width && px/scale < a.Width; px++ {
+			s := int(a.cur[cy*a.Width+px/scale])
+			if s >= len(lut) {
+				s = len(lut) - 1
+			}
+			copy(row[px*4:px*4+4], lut[s][:])
+		}
+	}
+}
+
+func mod(a, b int) int {
+	a %= b
+	if a < 0 {
+		a += b
+	}
+	return a
+}
@@ -0,0 +1,173 @@
+package automata
+
+import (
+	"strings"
+	"testing"
+)
+
+// load builds an automaton from rows of '#' (state 1) and '.', top row first
+func load(rule Rule, wrap bool, rows []string) *Automaton {
+	a := New(len(rows[0]), len(rows), rule)
+	a.Wrap = wrap
+	for r, row := range rows {
+		for x, c := range row {
+			if c == '#' {
+				a.Set(x, len(rows)-1-r, 1)
+			}
+		}
+	}
+	return a
+}
+
+// dump is the inverse of load
+func dump(a *Automaton) string {
+	var b strings.Builder
+	for y := a.Height - 1; y >= 0; y-- {
+		for x := 0; x < a.Width; x++ {
+			if a.Get(x, y) != 0 {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func TestLife(t *testing.T) {
+	tests := []struct {
+		rule    string
+		wantErr bool
+	}{
+		{"B3/S23", false},
+		{"b36/s23", false},
+		{"S23/B3", false},
+		{"B2/S", false},
+		{"B3S23", true},
+		{"B3/S23/X", true},
+		{"B9/S23", true},
+		{"X3/S23", true},
+		{"B3/Sx", true},
+	}
+	for _, tt := range tests {
+		if _, err := Life(tt.rule); (err != nil) != tt.wantErr {
+			t.Errorf("Life(%q) error = %v, want error %v", tt.rule, err, tt.wantErr)
+		}
+	}
+}
+
+func TestStep(t *testing.T) {
+	highLife, _ := Life("B36/S23")
+	tests := []struct {
+		name   string
+		rule   Rule
+		wrap   bool
+		steps  int
+		before []string
+		after  []string
+	}{
+		{
+			name:   "blinker",
+			rule:   Conway,
+			steps:  1,
+			before: []string{".....", ".....", ".###.", ".....", "....."},
+			after:  []string{".....", "..#..", "..#..", "..#..", "....."},
+		},
+		{
+			name:   "block is still",
+			rule:   Conway,
+			steps:  5,
+			before: []string{"....", ".##.", ".##.", "...."},
+			after:  []string{"....", ".##.", ".##.", "...."},
+		},
+		{
+			name:   "glider moves down and right",
+			rule:   Conway,
+			steps:  4,
+			before: []string{".#....", "..#...", "###...", "......", "......", "......"},
+			after:  []string{"......", "..#...", "...#..", ".###..", "......", "......"},
+		},
+		{
+			name:   "glider wraps back to the start",
+			rule:   Conway,
+			wrap:   true,
+			steps:  20,
+			before: []string{".#...", "..#..", "###..", ".....", "....."},
+			after:  []string{".#...", "..#..", "###..", ".....", "....."},
+		},
+		{
+			name:   "edge cuts neighbours off",
+			rule:   Conway,
+			steps:  1,
+			before: []string{".....", "#....", "#....", "#....", "....."},
+			after:  []string{".....", ".....", "##...", ".....", "....."},
+		},
+		{
+			name:   "edge wraps neighbours",
+			rule:   Conway,
+			wrap:   true,
+			steps:  1,
+			before: []string{".....", "#....", "#....", "#....", "....."},
+			after:  []string{".....", ".....", "##..#", ".....", "....."},
+		},
+		{
+			name:   "HighLife births on six",
+			rule:   highLife,
+			steps:  1,
+			before: []string{"###", "...", "###"},
+			after:  []string{".#.", ".#.", ".#."},
+		},
+	}
+	for _, tt := range tests {
+		a := load(tt.rule, tt.wrap, tt.before)
+		for i := 0; i < tt.steps; i++ {
+			a.Step()
+		}
+		want := strings.Join(tt.after, "\n") + "\n"
+		if got := dump(a); got != want {
+			t.Errorf("%s: after %d steps\n%s\nwant\n%s", tt.name, tt.steps, got, want)
+		}
+		if a.Generation() != uint64(tt.steps) {
+			t.Errorf("%s: Generation() = %d, want %d", tt.name, a.Generation(), tt.steps)
+		}
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	for _, wrap := range []bool{false, true} {
+		a, b := New(37, 29, Conway), New(37, 29, Conway)
+		a.Wrap, b.Wrap = wrap, wrap
+		a.Randomize(0.35, 1)
+		b.Randomize(0.35, 1)
+		b.Workers = 4
+		for i := 0; i < 30; i++ {
+			a.Step()
+			b.Step()
+			if dump(a) != dump(b) {
+				t.Fatalf("wrap %v: generation %d differs with 4 workers", wrap, i+1)
+			}
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	a := load(Conway, false, []string{"#.", ".#"})
+	pix := make([]uint8, 4*4*4)
+	a.Render(pix, 4, 4, 2)
+	at := func(x, y int) uint8 { return pix[(y*4+x)*4+3] }
+	tests := []struct {
+		x, y int
+		want uint8
+	}{
+		{0, 0, 0}, {1, 1, 0}, // Bottom left cell is dead
+		{2, 0, 255}, {3, 1, 255}, // Bottom right alive
+		{0, 2, 255}, {1, 3, 255}, // Top left alive
+		{2, 2, 0}, {3, 3, 0},
+	}
+	for _, tt := range tests {
+		if got := at(tt.x, tt.y); got != tt.want {
+			t.Errorf("alpha at %d,%d = %d, want %d", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
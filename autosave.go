@@ -0,0 +1,399 @@
+package pixelcanvas
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"syscall/js"
+	"time"
+)
+
+// Autosave
+//
+// Autosave keeps the app's state (a LayerDocument, settings, anything that
+// marshals to bytes) in IndexedDB, so a refresh, crash or closed tab loses
+// at most an Interval of work. Each save is a new numbered slot and the
+// last Slots are kept, so a bad save can be rolled back. Start restores the
+// latest slot, then saves every Interval when something has changed, and
+// also when the page is hidden or unloaded (see OnUnload): the write is
+// started from the event handler itself, as nothing may wait there.
+
+// Autosave defaults
+const (
+	DefaultAutosaveInterval = 30 * time.Second
+	DefaultAutosaveSlots    = 5
+)
+
+// Autosave errors
+var (
+	ErrNoIndexedDB = errors.New("pixelcanvas: IndexedDB not available")
+	ErrNoSave      = errors.New("pixelcanvas: no autosave to restore")
+)
+
+// Saveable is state an Autosave keeps
+type Saveable interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// JSONState adapts a pointer to a JSON-encodable value (e.g. a settings
+// struct) to Saveable
+func JSONState(v interface{}) Saveable {
+	return jsonState{v}
+}
+
+type jsonState struct{ v interface{} }
+
+func (s jsonState) MarshalBinary() ([]byte, error) {
+	return json.Marshal(s.v)
+}
+
+func (s jsonState) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, s.v)
+}
+
+// SaveSlot describes a stored save
+type SaveSlot struct {
+	ID   int // Increases with each save
+	Time time.Time
+	Size int // Bytes stored
+}
+
+// Autosave saves tracked state to an IndexedDB database
+type Autosave struct {
+	Interval time.Duration
+	Slots    int // Saves kept
+
+	OnSave  func(SaveSlot) // Called when a save has been written
+	OnError func(error)    // Called when a periodic or unload save fails
+
+	c       *Canvasp
+	name    string
+	db      js.Value
+	keys    []string
+	states  map[string]Saveable
+	last    uint64 // Hash of the last save, to skip unchanged state
+	next    int    // ID of the next slot
+	timer   *time.Timer
+	unwatch func() // Stops saving on unload, nil when stopped
+}
+
+// NewAutosave creates an autosave for the IndexedDB database name. Track
+// the state to save, then Start it.
+func (c *Canvasp) NewAutosave(name string) *Autosave {
+	return &Autosave{
+		Interval: DefaultAutosaveInterval,
+		Slots:    DefaultAutosaveSlots,
+		c:        c,
+		name:     name,
+		states:   make(map[string]Saveable),
+	}
+}
+
+// Track adds state to save under key. Keys must stay the same between
+// versions of the app for restoring to find them.
+func (a *Autosave) Track(key string, s Saveable) {
+	if _, ok := a.states[key]; !ok {
+		a.keys = append(a.keys, key)
+	}
+	a.states[key] = s
+}
+
+// Start opens the database, restores the latest save into the tracked
+// state, and starts saving. It returns the slot restored, or ErrNoSave
+// (with saving started) if there was none. It blocks, so call it from a
+// goroutine.
+func (a *Autosave) Start() (SaveSlot, error) {
+	if err := a.open(); err != nil {
+		return SaveSlot{}, err
+	}
+	slot, err := a.Restore()
+	if err != nil && err != ErrNoSave {
+		return slot, err
+	}
+	a.unwatch = a.c.OnUnload(a.flush)
+	a.schedule()
+	a.c.log().Info("autosave started", "db", a.name, "restored", slot.ID)
+	return slot, err
+}
+
+// Stop stops saving. It doesn't save first; call Save for that.
+func (a *Autosave) Stop() {
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+	if a.unwatch != nil {
+		a.unwatch()
+		a.unwatch = nil
+	}
+}
+
+// Save writes the tracked state to a new slot now, unless it is unchanged
+// since the last save, and waits for the write. It blocks, so call it from
+// a goroutine.
+func (a *Autosave) Save() error {
+	if err := a.open(); err != nil {
+		return err
+	}
+	wait, err := a.write()
+	if err != nil || wait == nil {
+		return err
+	}
+	return wait()
+}
+
+// List returns the stored saves, oldest first. It blocks, so call it from
+// a goroutine.
+func (a *Autosave) List() ([]SaveSlot, error) {
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	tx := a.db.Call("transaction", "meta", "readonly")
+	v, err := idbWait(tx.Call("objectStore", "meta").Call("getAll"))
+	if err != nil {
+		return nil, err
+	}
+	slots := make([]SaveSlot, v.Length())
+	for i := range slots {
+		m := v.Index(i)
+		slots[i] = SaveSlot{
+			ID:   m.Get("id").Int(),
+			Time: time.Unix(0, int64(m.Get("time").Float()*1e6)),
+			Size: m.Get("size").Int(),
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].ID < slots[j].ID })
+	return slots, nil
+}
+
+// Restore loads the latest save into the tracked state. It blocks, so call
+// it from a goroutine.
+func (a *Autosave) Restore() (SaveSlot, error) {
+	slots, err := a.List()
+	if err != nil {
+		return SaveSlot{}, err
+	}
+	if len(slots) == 0 {
+		return SaveSlot{}, ErrNoSave
+	}
+	slot := slots[len(slots)-1]
+	return slot, a.RestoreSlot(slot.ID)
+}
+
+// RestoreSlot loads the save with the given ID into the tracked state.
+// Keys in the save that aren't tracked are ignored, and tracked state
+// missing from it is left alone. It blocks, so call it from a goroutine.
+func (a *Autosave) RestoreSlot(id int) error {
+	if err := a.open(); err != nil {
+		return err
+	}
+	tx := a.db.Call("transaction", "data", "readonly")
+	v, err := idbWait(tx.Call("objectStore", "data").Call("get", id))
+	if err != nil {
+		return err
+	}
+	if v.IsUndefined() {
+		return ErrNoSave
+	}
+	h := fnv.New64a()
+	for _, key := range a.keys {
+		arr := v.Get(key)
+		if arr.IsUndefined() {
+			continue
+		}
+		data := make([]byte, arr.Length())
+		js.CopyBytesToGo(data, arr)
+		if err := a.states[key].UnmarshalBinary(data); err != nil {
+			return fmt.Errorf("pixelcanvas: autosave %s: %v", key, err)
+		}
+		h.Write([]byte(key))
+		h.Write(data)
+	}
+	a.last = h.Sum64()
+	a.c.log().Info("autosave restored", "db", a.name, "slot", id)
+	return nil
+}
+
+// Clear deletes every save. It blocks, so call it from a goroutine.
+func (a *Autosave) Clear() error {
+	if err := a.open(); err != nil {
+		return err
+	}
+	tx := a.db.Call("transaction", []interface{}{"meta", "data"}, "readwrite")
+	tx.Call("objectStore", "meta").Call("clear")
+	tx.Call("objectStore", "data").Call("clear")
+	a.last = 0
+	return txWait(tx)
+}
+
+// schedule sets the timer for the next periodic save
+func (a *Autosave) schedule() {
+	a.timer = time.AfterFunc(a.Interval, func() {
+		if err := a.Save(); err != nil {
+			a.failed(err)
+		}
+		if a.unwatch != nil { // Not stopped meanwhile
+			a.schedule()
+		}
+	})
+}
+
+// flush starts a save without waiting for it, for page unload
+func (a *Autosave) flush() {
+	if a.db.IsUndefined() {
+		return
+	}
+	if _, err := a.write(); err != nil {
+		a.failed(err)
+	}
+}
+
+func (a *Autosave) failed(err error) {
+	a.c.log().Warn("autosave failed", "db", a.name, "err", err)
+	if a.OnError != nil {
+		a.OnError(err)
+	}
+}
+
+// write marshals the tracked state and starts writing it to a new slot,
+// pruning old ones, without blocking. It returns a func waiting for the
+// write, or nil if nothing has changed.
+func (a *Autosave) write() (func() error, error) {
+	h := fnv.New64a()
+	record := js.Global().Get("Object").New()
+	size := 0
+	for _, key := range a.keys {
+		data, err := a.states[key].MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("pixelcanvas: autosave %s: %v", key, err)
+		}
+		arr := js.Global().Get("Uint8Array").New(len(data))
+		js.CopyBytesToJS(arr, data)
+		record.Set(key, arr)
+		size += len(data)
+		h.Write([]byte(key))
+		h.Write(data)
+	}
+	sum := h.Sum64()
+	if sum == a.last {
+		return nil, nil
+	}
+
+	slot := SaveSlot{ID: a.next, Time: time.Now(), Size: size}
+	a.next++
+	meta := map[string]interface{}{
+		"id":   slot.ID,
+		"time": float64(slot.Time.UnixNano()) / 1e6,
+		"size": size,
+	}
+	tx := a.db.Call("transaction", []interface{}{"meta", "data"}, "readwrite")
+	metaStore, dataStore := tx.Call("objectStore", "meta"), tx.Call("objectStore", "data")
+	metaStore.Call("put", meta)
+	dataStore.Call("put", record, slot.ID)
+	if old := slot.ID - maxInt(a.Slots, 1); old >= 0 {
+		keys := js.Global().Get("IDBKeyRange").Call("upperBound", old)
+		metaStore.Call("delete", keys)
+		dataStore.Call("delete", keys)
+	}
+	a.last = sum
+	return func() error {
+		if err := txWait(tx); err != nil {
+			a.last = 0 // Try again next time
+			return err
+		}
+		a.c.log().Debug("autosaved", "db", a.name, "slot", slot.ID, "bytes", size)
+		if a.OnSave != nil {
+			a.OnSave(slot)
+		}
+		return nil
+	}, nil
+}
+
+// open opens the database if it isn't already, and finds the next slot ID
+func (a *Autosave) open() error {
+	if !a.db.IsUndefined() {
+		return nil
+	}
+	idb := js.Global().Get("indexedDB")
+	if idb.IsUndefined() || idb.IsNull() {
+		return ErrNoIndexedDB
+	}
+	req := idb.Call("open", a.name, 1)
+	upgrade := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		db := req.Get("result")
+		db.Call("createObjectStore", "meta", map[string]interface{}{"keyPath": "id"})
+		db.Call("createObjectStore", "data")
+		return nil
+	})
+	req.Set("onupgradeneeded", upgrade)
+	db, err := idbWait(req)
+	upgrade.Release()
+	if err != nil {
+		return err
+	}
+
+	tx := db.Call("transaction", "meta", "readonly")
+	keys, err := idbWait(tx.Call("objectStore", "meta").Call("getAllKeys"))
+	if err != nil {
+		return err
+	}
+	for i := 0; i < keys.Length(); i++ {
+		a.next = maxInt(a.next, keys.Index(i).Int()+1)
+	}
+	a.db = db
+	return nil
+}
+
+// idbWait blocks until an IndexedDB request succeeds, returning its
+// result, or fails. Like await, it must not be called from a JS callback.
+func idbWait(req js.Value) (js.Value, error) {
+	done := make(chan error, 1)
+	success := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		done <- nil
+		return nil
+	})
+	failure := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		done <- js.Error{Value: req.Get("error")}
+		return nil
+	})
+	req.Set("onsuccess", success)
+	req.Set("onerror", failure)
+	err := <-done
+	success.Release()
+	failure.Release()
+	if err != nil {
+		return js.Undefined(), err
+	}
+	return req.Get("result"), nil
+}
+
+// txWait blocks until an IndexedDB transaction commits or fails
+func txWait(tx js.Value) error {
+	done := make(chan error, 1)
+	complete := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		done <- nil
+		return nil
+	})
+	failure := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		var err error = js.Error{Value: tx.Get("error")}
+		if tx.Get("error").IsNull() || tx.Get("error").IsUndefined() {
+			err = errors.New("pixelcanvas: IndexedDB transaction aborted")
+		}
+		select {
+		case done <- err:
+		default: // An error is followed by abort; the first is reported
+		}
+		return nil
+	})
+	tx.Set("oncomplete", complete)
+	tx.Set("onerror", failure)
+	tx.Set("onabort", failure)
+	err := <-done
+	complete.Release()
+	failure.Release()
+	return err
+}
@@ -0,0 +1,199 @@
+package pixelcanvas
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Update batcher defaults
+const (
+	DefaultBatchInterval = 50 * time.Millisecond
+	DefaultBatchBytes    = 16 << 10
+)
+
+// UpdateBatcher collects outgoing pixel ops and sends them in batches, so a
+// collaborative app sends a few messages a second instead of one per pixel.
+// Writes to the same pixel before a flush coalesce into the last one, and
+// each batch is sent grouped by tile, which keeps related ops together for
+// compression and for servers that route by region.
+//
+// A batch goes out Interval after the first op in it, or as soon as it
+// reaches MaxBytes; larger flushes are split into messages of at most
+// MaxBytes. Ops stay queued until Send accepts the message holding them, so
+// nothing is lost while the connection is down: call Flush once it is back.
+type UpdateBatcher struct {
+	Interval time.Duration
+	MaxBytes int
+
+	// Send delivers one encoded message (see EncodeOps), returning an error
+	// if it couldn't, in which case the message's ops and those after it are
+	// queued again. It is called from the goroutine that added the op that
+	// filled the batch, or from a timer.
+	Send func(msg []byte) error
+
+	mu       sync.Mutex
+	tileSize int
+	pending  map[TileKey]map[point]PixelOp
+	count    int
+	timer    *time.Timer
+	sent     uint64 // Messages sent, see Stats
+	coalesce uint64 // Ops dropped by coalescing
+}
+
+// NewUpdateBatcher creates a batcher with the default interval and size
+// that groups ops by tiles of tileSize pixels
+func NewUpdateBatcher(tileSize int, send func(msg []byte) error) *UpdateBatcher {
+	return &UpdateBatcher{
+		Interval: DefaultBatchInterval,
+		MaxBytes: DefaultBatchBytes,
+		Send:     send,
+		tileSize: tileSize,
+		pending:  make(map[TileKey]map[point]PixelOp),
+	}
+}
+
+// Add queues ops, replacing any queued op for the same pixel
+func (b *UpdateBatcher) Add(ops ...PixelOp) {
+	b.mu.Lock()
+	for _, op := range ops {
+		b.queue(op)
+	}
+	full := b.count*docOpSize >= b.maxBytes()
+	if !full && b.count > 0 && b.timer == nil {
+		b.timer = time.AfterFunc(b.Interval, b.Flush)
+	}
+	b.mu.Unlock()
+
+	if full {
+		b.Flush()
+	}
+}
+
+// queue adds op, replacing any queued op for the same pixel. b.mu is held.
+func (b *UpdateBatcher) queue(op PixelOp) {
+	key := TileKey{floorDiv(op.X, b.tileSize), floorDiv(op.Y, b.tileSize)}
+	tile := b.pending[key]
+	if tile == nil {
+		tile = make(map[point]PixelOp)
+		b.pending[key] = tile
+	}
+	p := point{op.X, op.Y}
+	if _, ok := tile[p]; ok {
+		b.coalesce++
+	} else {
+		b.count++
+	}
+	tile[p] = op
+}
+
+// requeue puts back ops that failed to send, unless the pixel has been
+// written again since. b.mu is held.
+func (b *UpdateBatcher) requeue(ops []PixelOp) {
+	for _, op := range ops {
+		if _, ok := b.pending[TileKey{floorDiv(op.X, b.tileSize), floorDiv(op.Y, b.tileSize)}][point{op.X, op.Y}]; !ok {
+			b.queue(op)
+		}
+	}
+}
+
+// Pending returns the number of queued ops
+func (b *UpdateBatcher) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.count
+}
+
+// Stats returns the messages sent so far and the ops saved by coalescing
+func (b *UpdateBatcher) Stats() (messages uint64, coalesced uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sent, b.coalesce
+}
+
+// Flush sends everything queued now. If Send fails the unsent ops stay
+// queued, and go out with the next Flush.
+func (b *UpdateBatcher) Flush() {
+	b.mu.Lock()
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if b.count == 0 {
+		b.mu.Unlock()
+		return
+	}
+
+	// Tiles in a fixed order, and pixels within them in scan order, so
+	// batches are deterministic
+	keys := make([]TileKey, 0, len(b.pending))
+	for k := range b.pending {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Y < keys[j].Y || keys[i].Y == keys[j].Y && keys[i].X < keys[j].X
+	})
+	per := b.maxBytes() / docOpSize
+	if per < 1 {
+		per = 1
+	}
+	var batches [][]PixelOp
+	batch := make([]PixelOp, 0, per)
+	for _, k := range keys {
+		tile := b.pending[k]
+		ops := make([]PixelOp, 0, len(tile))
+		for _, op := range tile {
+			ops = append(ops, op)
+		}
+		sort.Slice(ops, func(i, j int) bool {
+			return ops[i].Y < ops[j].Y || ops[i].Y == ops[j].Y && ops[i].X < ops[j].X
+		})
+		for _, op := range ops {
+			batch = append(batch, op)
+			if len(batch) == per {
+				batches = append(batches, batch)
+				batch = make([]PixelOp, 0, per)
+			}
+		}
+		delete(b.pending, k)
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	b.count = 0
+	send := b.Send
+	b.mu.Unlock()
+
+	sent := 0
+	if send != nil {
+		for ; sent < len(batches); sent++ {
+			if send(EncodeOps(batches[sent])) != nil {
+				break
+			}
+		}
+	}
+
+	b.mu.Lock()
+	b.sent += uint64(sent)
+	for _, ops := range batches[sent:] {
+		b.requeue(ops)
+	}
+	b.mu.Unlock()
+}
+
+// Stop cancels a pending timed flush, leaving queued ops in place
+func (b *UpdateBatcher) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+}
+
+func (b *UpdateBatcher) maxBytes() int {
+	if b.MaxBytes <= 0 {
+		return DefaultBatchBytes
+	}
+	return b.MaxBytes
+}
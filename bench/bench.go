@@ -0,0 +1,215 @@
+// Package bench provides reproducible synthetic workloads for comparing
+// pixelcanvas backends and copy-path changes.
+//
+// Each Workload updates a buffer in the shadow canvas layout (premultiplied
+// RGBA, rows bottom-up) once per frame from a fixed seed, so runs are
+// comparable between machines and commits. Run times the workloads
+// headless, as do the package's benchmarks (go test -bench .);
+// RunInBrowser runs them through a real Canvasp and draws the results on
+// screen.
+package bench
+
+import (
+	"runtime"
+	"time"
+)
+
+// runTime is how long Run times each workload for, at least
+const runTime = time.Second
+
+// Workload is one synthetic rendering load
+type Workload struct {
+	Name string
+
+	// Step draws frame number frame into pix, which holds width x height
+	// pixels and keeps its contents between frames
+	Step func(pix []uint8, width, height, frame int)
+}
+
+// Result is one workload's measurements
+type Result struct {
+	Name   string
+	Frames int
+
+	PerFrame time.Duration // Whole frame, or just Step when headless
+	Render   time.Duration // Step and handing the buffer to pixelgl (browser only)
+	Copy     time.Duration // Copying to the page (browser only)
+
+	AllocsPerFrame float64
+}
+
+// Workloads returns the standard set of workloads
+func Workloads() []Workload {
+	return []Workload{FullFrameNoise(), SparseUpdates(0.01), SpriteStorm(500, 16)}
+}
+
+// FullFrameNoise rewrites every pixel with opaque noise each frame: the
+// worst case for the copy path, since nothing can be skipped
+func FullFrameNoise() Workload {
+	var rng xorshift = 1
+	return Workload{
+		Name: "full-frame noise",
+		Step: func(pix []uint8, width, height, frame int) {
+			if frame == 0 {
+				rng = 1
+			}
+			for i := 0; i+3 < len(pix); i += 4 {
+				v := rng.next()
+				pix[i], pix[i+1], pix[i+2], pix[i+3] = uint8(v), uint8(v>>8), uint8(v>>16), 255
+			}
+		},
+	}
+}
+
+// SparseUpdates changes fraction (0-1) of the pixels each frame, at random
+// positions over a flat background, like a mostly static UI
+func SparseUpdates(fraction float64) Workload {
+	var rng xorshift = 2
+	return Workload{
+		Name: "sparse updates",
+		Step: func(pix []uint8, width, height, frame int) {
+			n := width * height
+			if frame == 0 {
+				rng = 2
+				for i := 0; i+3 < len(pix); i += 4 {
+					pix[i], pix[i+1], pix[i+2], pix[i+3] = 32, 32, 48, 255
+				}
+			}
+			if n == 0 {
+				return
+			}
+			for k := int(float64(n) * fraction); k > 0; k-- {
+				v := rng.next()
+				i := int(v%uint64(n)) * 4
+				pix[i], pix[i+1], pix[i+2] = uint8(v>>32), uint8(v>>40), uint8(v>>48)
+			}
+		},
+	}
+}
+
+// SpriteStorm blends count size x size translucent sprites moving over a
+// cleared background each frame, like a particle effect or bullet hell
+func SpriteStorm(count int, size int) Workload {
+	type sprite struct{ x, y, dx, dy float64 }
+	var sprites []sprite
+	stamp := make([]uint8, size*size*4)
+	r := float64(size) / 2
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := float64(x)+0.5-r, float64(y)+0.5-r
+			if dx*dx+dy*dy <= r*r {
+				i := (y*size + x) * 4
+				stamp[i], stamp[i+1], stamp[i+2], stamp[i+3] = 160, 96, 32, 192 // Premultiplied
+			}
+		}
+	}
+
+	return Workload{
+		Name: "sprite storm",
+		Step: func(pix []uint8, width, height, frame int) {
+			if frame == 0 {
+				var rng xorshift = 3
+				sprites = make([]sprite, count)
+				for i := range sprites {
+					sprites[i] = sprite{
+						x:  rng.float() * float64(width-size),
+						y:  rng.float() * float64(height-size),
+						dx: rng.float()*4 - 2,
+						dy: rng.float()*4 - 2,
+					}
+				}
+			}
+			for i := range pix {
+				pix[i] = 0
+			}
+			for i := range sprites {
+				s := &sprites[i]
+				s.x, s.y = s.x+s.dx, s.y+s.dy
+				if s.x < 0 || s.x > float64(width-size) {
+					s.dx = -s.dx
+				}
+				if s.y < 0 || s.y > float64(height-size) {
+					s.dy = -s.dy
+				}
+				blit(pix, width, height, stamp, size, int(s.x), int(s.y))
+			}
+		},
+	}
+}
+
+// blit blends a premultiplied size x size stamp over pix at x, y, clipped
+func blit(pix []uint8, width, height int, stamp []uint8, size, x0, y0 int) {
+	for y := 0; y < size; y++ {
+		py := y0 + y
+		if py < 0 || py >= height {
+			continue
+		}
+		for x := 0; x < size; x++ {
+			px := x0 + x
+			if px < 0 || px >= width {
+				continue
+			}
+			s := stamp[(y*size+x)*4:]
+			sa := uint32(s[3])
+			if sa == 0 {
+				continue
+			}
+			d := pix[(py*width+px)*4:]
+			inv := 255 - sa
+			d[0] = uint8(uint32(s[0]) + uint32(d[0])*inv/255)
+			d[1] = uint8(uint32(s[1]) + uint32(d[1])*inv/255)
+			d[2] = uint8(uint32(s[2]) + uint32(d[2])*inv/255)
+			d[3] = uint8(sa + uint32(d[3])*inv/255)
+		}
+	}
+}
+
+// Run times each workload headless, outside of go test, e.g. from a
+// command that prints or records the results. Frames are stepped in
+// doubling rounds until a round takes at least a second.
+func Run(workloads []Workload, width int, height int) []Result {
+	results := make([]Result, len(workloads))
+	pix := make([]uint8, width*height*4)
+	var mem runtime.MemStats
+	for i, w := range workloads {
+		w.Step(pix, width, height, 0)
+		for n := 1; ; n *= 2 {
+			runtime.ReadMemStats(&mem)
+			mallocs := mem.Mallocs
+			start := time.Now()
+			for f := 1; f <= n; f++ {
+				w.Step(pix, width, height, f)
+			}
+			elapsed := time.Since(start)
+			runtime.ReadMemStats(&mem)
+			if elapsed >= runTime || n >= 1<<30 {
+				results[i] = Result{
+					Name:           w.Name,
+					Frames:         n,
+					PerFrame:       elapsed / time.Duration(n),
+					AllocsPerFrame: float64(mem.Mallocs-mallocs) / float64(n),
+				}
+				break
+			}
+		}
+	}
+	return results
+}
+
+// xorshift is a tiny deterministic generator, so workloads don't depend on
+// math/rand's implementation
+type xorshift uint64
+
+func (x *xorshift) next() uint64 {
+	v := uint64(*x)
+	v ^= v << 13
+	v ^= v >> 7
+	v ^= v << 17
+	*x = xorshift(v)
+	return v
+}
+
+// float returns 0 to 1
+func (x *xorshift) float() float64 {
+	return float64(x.next()>>11) / (1 << 53)
+}
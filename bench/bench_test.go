@@ -0,0 +1,22 @@
+package bench
+
+import "testing"
+
+// benchmark runs w one frame per iteration at 1080p
+func benchmark(b *testing.B, w Workload) {
+	const width, height = 1920, 1080
+	pix := make([]uint8, width*height*4)
+	w.Step(pix, width, height, 0)
+	b.SetBytes(int64(len(pix)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 1; i <= b.N; i++ {
+		w.Step(pix, width, height, i)
+	}
+}
+
+func BenchmarkFullFrameNoise(b *testing.B) { benchmark(b, FullFrameNoise()) }
+
+func BenchmarkSparseUpdates(b *testing.B) { benchmark(b, SparseUpdates(0.01)) }
+
+func BenchmarkSpriteStorm(b *testing.B) { benchmark(b, SpriteStorm(500, 16)) }
@@ -0,0 +1,80 @@
+//go:build js && wasm
+// +build js,wasm
+
+package bench
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"github.com/faiface/pixel"
+	"github.com/lwayneh/pixelcanvas"
+)
+
+// RunInBrowser runs each workload for frames frames on c, uncapped, one
+// after another, measuring the whole frame including the copy to the page.
+// When all have run it draws a results table on the canvas, stops the loop
+// and calls done (which may be nil) with the results.
+func RunInBrowser(c *pixelcanvas.Canvasp, workloads []Workload, frames int, done func([]Result)) {
+	w, h := c.Width(), c.Height()
+	pix := make([]uint8, w*h*4)
+	results := make([]Result, 0, len(workloads))
+	current, frame := 0, 0
+	var started time.Time
+
+	c.TrackAllocs(true)
+	c.Start(1000, func(gc *pixelcanvas.Canvas) bool {
+		if current == len(workloads) {
+			return false
+		}
+		wl := workloads[current]
+		if frame == 0 {
+			c.ResetStats()
+			started = time.Now()
+		}
+		wl.Step(pix, w, h, frame)
+		gc.SetPixels(pix)
+		frame++
+
+		if frame > frames {
+			s := c.Stats()
+			results = append(results, Result{
+				Name:           wl.Name,
+				Frames:         frames,
+				PerFrame:       time.Since(started) / time.Duration(frames),
+				Render:         s.AvgRender,
+				Copy:           s.AvgCopy,
+				AllocsPerFrame: s.AllocsPerFrame,
+			})
+			current, frame = current+1, 0
+			if current == len(workloads) {
+				c.TrackAllocs(false)
+				drawResults(c, gc, results)
+				c.Stop()
+				if done != nil {
+					done(results)
+				}
+			}
+		}
+		return true
+	})
+}
+
+// drawResults clears the canvas and writes one line per result
+func drawResults(c *pixelcanvas.Canvasp, gc *pixelcanvas.Canvas, results []Result) {
+	gc.Clear(color.RGBA{R: 16, G: 16, B: 24, A: 255})
+	style := pixelcanvas.TextStyle{Font: "14px monospace", Color: color.White, Smooth: true}
+	line := c.MeasureText("M", style).LineHeight() * 1.4
+	y := float64(c.Height()) - line
+
+	c.DrawText(fmt.Sprintf("%-18s %10s %10s %10s %8s", "workload", "frame", "render", "copy", "allocs"), pixel.V(8, y), style)
+	for _, r := range results {
+		y -= line
+		c.DrawText(fmt.Sprintf("%-18s %10s %10s %10s %8.1f", r.Name, ms(r.PerFrame), ms(r.Render), ms(r.Copy), r.AllocsPerFrame), pixel.V(8, y), style)
+	}
+}
+
+func ms(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", d.Seconds()*1000)
+}
@@ -0,0 +1,237 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// BrushShape is the footprint of a brush dab
+type BrushShape int
+
+// Brush shapes
+const (
+	BrushRound  BrushShape = iota // Hard edged disc
+	BrushSquare                   // Size x Size square
+	BrushStamp                    // Stamp's alpha channel, scaled to Size
+)
+
+// Symmetry mirrors strokes about the brush's axis
+type Symmetry int
+
+// Symmetry modes. SymmetryX mirrors left/right, SymmetryY top/bottom
+const (
+	SymmetryNone Symmetry = 0
+	SymmetryX    Symmetry = 1
+	SymmetryY    Symmetry = 2
+	SymmetryXY   Symmetry = SymmetryX | SymmetryY
+)
+
+// DefaultBrushSpacing is the gap between dabs as a fraction of the size
+const DefaultBrushSpacing = 0.25
+
+// Brush paints strokes onto the shadow canvas. Within a stroke overlapping
+// dabs don't build up: each pixel takes the strongest coverage any dab gave
+// it, times Opacity, so a half opaque stroke is evenly half opaque.
+type Brush struct {
+	Shape   BrushShape
+	Size    int     // Diameter in pixels
+	Stamp   *Region // Footprint for BrushStamp
+	Color   color.Color
+	Opacity float64 // 0-1
+	Spacing float64 // Gap between dabs along a stroke, as a fraction of Size. 0 for DefaultBrushSpacing
+
+	Symmetry Symmetry
+	Axis     pixel.Vec // Mirror axes position. Zero for the canvas centre
+
+	History *History // If set, each stroke is recorded as one undoable step
+}
+
+// NewBrush creates a round, fully opaque brush
+func NewBrush(size int, col color.Color) *Brush {
+	return &Brush{Shape: BrushRound, Size: size, Color: col, Opacity: 1}
+}
+
+// Stroke is a brush stroke in progress, from BeginStroke to End
+type Stroke struct {
+	c *Canvasp
+	b *Brush
+
+	base []uint8 // Canvas before the stroke
+	pix  []uint8 // Canvas with the stroke so far
+	cov  []uint8 // Strongest coverage per pixel so far
+
+	dab   []uint8 // Footprint coverage, Size x Size, rows bottom-up
+	col   [4]uint8
+	axis  pixel.Vec
+	last  pixel.Vec
+	carry float64 // Distance travelled since the last dab
+
+	x0, y0, x1, y1 int // Dirty rectangle
+}
+
+// BeginStroke starts a stroke with b at 'at' (logical coordinates, like
+// FromClient's), painting the first dab
+func (c *Canvasp) BeginStroke(b *Brush, at pixel.Vec) *Stroke {
+	if b.History != nil {
+		b.History.Begin("brush")
+	}
+	s := &Stroke{
+		c:    c,
+		b:    b,
+		base: c.image.Pixels(),
+		cov:  make([]uint8, c.width*c.height),
+		dab:  brushDab(b),
+		col:  rgba8(b.Color),
+		axis: b.Axis,
+		last: c.ToCanvas(at),
+		x0:   c.width, y0: c.height,
+	}
+	s.pix = append([]uint8(nil), s.base...)
+	if s.axis == pixel.ZV {
+		s.axis = pixel.V(float64(c.width)/2, float64(c.height)/2)
+	} else {
+		s.axis = c.ToCanvas(s.axis)
+	}
+	s.stamp(s.last)
+	c.image.SetPixels(s.pix)
+	return s
+}
+
+// To continues the stroke to 'at', placing dabs along the line from the
+// previous point at the brush's spacing
+func (s *Stroke) To(at pixel.Vec) {
+	step := s.b.Spacing
+	if step <= 0 {
+		step = DefaultBrushSpacing
+	}
+	step *= float64(s.b.Size)
+	if step < 1 {
+		step = 1
+	}
+
+	at = s.c.ToCanvas(at)
+	d := at.Sub(s.last)
+	dist := d.Len()
+	if dist == 0 {
+		return
+	}
+	dir := d.Scaled(1 / dist)
+	t := step - s.carry
+	for ; t <= dist; t += step {
+		s.stamp(s.last.Add(dir.Scaled(t)))
+	}
+	s.carry = dist - (t - step)
+	s.last = at
+	s.c.image.SetPixels(s.pix)
+}
+
+// End finishes the stroke, recording it in the brush's History if set, and
+// returns the area it changed
+func (s *Stroke) End() pixel.Rect {
+	if s.x0 >= s.x1 || s.y0 >= s.y1 {
+		if s.b.History != nil {
+			s.b.History.Cancel()
+		}
+		return pixel.Rect{}
+	}
+	r := intRect(s.x0, s.y0, s.x1, s.y1)
+	if s.b.History != nil {
+		s.b.History.commitRect(r)
+	}
+	return s.c.FromCanvasRect(r)
+}
+
+// stamp places a dab centred on p, plus its mirror images
+func (s *Stroke) stamp(p pixel.Vec) {
+	s.dabAt(p)
+	sym := s.b.Symmetry
+	mx, my := 2*s.axis.X-p.X, 2*s.axis.Y-p.Y
+	if sym&SymmetryX != 0 {
+		s.dabAt(pixel.V(mx, p.Y))
+	}
+	if sym&SymmetryY != 0 {
+		s.dabAt(pixel.V(p.X, my))
+	}
+	if sym == SymmetryXY {
+		s.dabAt(pixel.V(mx, my))
+	}
+}
+
+func (s *Stroke) dabAt(p pixel.Vec) {
+	size := s.b.Size
+	w, h := s.c.width, s.c.height
+	bx := int(math.Floor(p.X - float64(size)/2 + 0.5))
+	by := int(math.Floor(p.Y - float64(size)/2 + 0.5))
+	op := s.b.Opacity
+	if op > 1 {
+		op = 1
+	}
+
+	for j := 0; j < size; j++ {
+		y := by + j
+		if y < 0 || y >= h {
+			continue
+		}
+		for i := 0; i < size; i++ {
+			x := bx + i
+			if x < 0 || x >= w {
+				continue
+			}
+			k := s.dab[j*size+i]
+			n := y*w + x
+			if k <= s.cov[n] {
+				continue
+			}
+			s.cov[n] = k
+
+			// Redo this pixel from the base with the stronger coverage
+			a := uint32(float64(k) * op)
+			src := [4]uint8{
+				uint8(uint32(s.col[0]) * a / 255),
+				uint8(uint32(s.col[1]) * a / 255),
+				uint8(uint32(s.col[2]) * a / 255),
+				uint8(uint32(s.col[3]) * a / 255),
+			}
+			px := s.pix[n*4 : n*4+4]
+			copy(px, s.base[n*4:n*4+4])
+			blendOver(px, src[:])
+		}
+	}
+
+	s.x0 = clampInt(minInt(s.x0, bx), 0, w)
+	s.y0 = clampInt(minInt(s.y0, by), 0, h)
+	s.x1 = clampInt(maxInt(s.x1, bx+size), 0, w)
+	s.y1 = clampInt(maxInt(s.y1, by+size), 0, h)
+}
+
+// brushDab builds the coverage footprint of a brush
+func brushDab(b *Brush) []uint8 {
+	if b.Size < 1 {
+		b.Size = 1
+	}
+	size := b.Size
+	dab := make([]uint8, size*size)
+	r := float64(size) / 2
+	for j := 0; j < size; j++ {
+		for i := 0; i < size; i++ {
+			switch b.Shape {
+			case BrushSquare:
+				dab[j*size+i] = 255
+			case BrushStamp:
+				if b.Stamp != nil && b.Stamp.Width > 0 && b.Stamp.Height > 0 {
+					sx := i * b.Stamp.Width / size
+					sy := j * b.Stamp.Height / size
+					dab[j*size+i] = b.Stamp.Pix[(sy*b.Stamp.Width+sx)*4+3]
+				}
+			default:
+				dx, dy := float64(i)+0.5-r, float64(j)+0.5-r
+				if dx*dx+dy*dy <= r*r || size == 1 {
+					dab[j*size+i] = 255
+				}
+			}
+		}
+	}
+	return dab
+}
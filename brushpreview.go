@@ -0,0 +1,221 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"math"
+	"syscall/js"
+
+	"github.com/faiface/pixel"
+)
+
+// BrushPreview draws the outline of a Brush's footprint under the pointer on
+// an Overlay, including its mirror images under Symmetry, so painting never
+// touches the shadow canvas until a stroke begins. Pointer moves only record
+// the position; the outline is redrawn at most once per frame, just before
+// the copy.
+type BrushPreview struct {
+	Brush *Brush
+
+	// The outline alternates between the two colours pixel by pixel, so it
+	// shows on light and dark artwork alike
+	Color    color.Color
+	AltColor color.Color
+
+	// Fill, if above zero, also shows the footprint in the brush colour at
+	// this opacity (0-1), previewing what a dab would paint
+	Fill float64
+
+	c       *Canvasp
+	overlay *Overlay
+	at      pixel.Vec
+	inside  bool
+	drawn   [][4]int // Bounds of the outlines last drawn, to erase them
+
+	dab     []uint8 // Cached footprint, rebuilt when the brush changes
+	dabKey  brushKey
+	outline []bool // Footprint edge pixels
+
+	listeners []*listener
+	cursor    string // Canvas CSS cursor before HideCursor
+}
+
+// brushKey is what the cached footprint was built from
+type brushKey struct {
+	shape BrushShape
+	size  int
+	stamp *Region
+}
+
+// NewBrushPreview adds a preview overlay for b that follows the pointer over
+// the canvas and hides when it leaves
+func (c *Canvasp) NewBrushPreview(b *Brush) *BrushPreview {
+	p := &BrushPreview{
+		Brush:    b,
+		Color:    color.RGBA{255, 255, 255, 220},
+		AltColor: color.RGBA{0, 0, 0, 220},
+		c:        c,
+	}
+	p.overlay = c.AddOverlay(p.draw)
+	p.listeners = []*listener{
+		c.listen(c.canvas, "pointermove", p.pointerMove),
+		c.listen(c.canvas, "pointerenter", p.pointerMove),
+		c.listen(c.canvas, "pointerleave", func(js.Value) { p.Hide() }),
+	}
+	return p
+}
+
+// MoveTo shows the preview at 'at' (logical coordinates), e.g. from an
+// app's own input handling or a replayed stroke
+func (p *BrushPreview) MoveTo(at pixel.Vec) {
+	at = p.c.ToCanvas(at)
+	if p.inside && at == p.at {
+		return
+	}
+	p.at, p.inside = at, true
+	p.overlay.Invalidate()
+}
+
+// Hide removes the preview until the pointer next moves over the canvas
+func (p *BrushPreview) Hide() {
+	if !p.inside {
+		return
+	}
+	p.inside = false
+	p.overlay.Invalidate()
+}
+
+// Update redraws the preview after the Brush or the colours have changed
+func (p *BrushPreview) Update() {
+	p.overlay.Invalidate()
+}
+
+// HideCursor hides the browser's pointer over the canvas, so the outline
+// is the only cursor, or restores it
+func (p *BrushPreview) HideCursor(on bool) {
+	style := p.c.canvas.Get("style")
+	if on {
+		p.cursor = style.Get("cursor").String()
+		style.Set("cursor", "none")
+		return
+	}
+	style.Set("cursor", p.cursor)
+}
+
+// Remove takes the preview off the canvas and stops following the pointer
+func (p *BrushPreview) Remove() {
+	for _, l := range p.listeners {
+		p.c.unlisten(l)
+	}
+	p.listeners = nil
+	p.c.RemoveOverlay(p.overlay)
+}
+
+func (p *BrushPreview) pointerMove(e js.Value) {
+	p.MoveTo(p.c.FromClient(e.Get("clientX").Float(), e.Get("clientY").Float()))
+}
+
+// draw erases the previous outlines and draws the current ones
+func (p *BrushPreview) draw(o *Overlay) {
+	for _, r := range p.drawn {
+		o.ClearRect(r[0], r[1], r[2], r[3])
+	}
+	p.drawn = p.drawn[:0]
+	b := p.Brush
+	if !p.inside || b == nil {
+		return
+	}
+	p.footprint()
+
+	// The same placements as Stroke.stamp
+	axis := b.Axis
+	if axis == pixel.ZV {
+		axis = pixel.V(float64(p.c.width)/2, float64(p.c.height)/2)
+	}
+	at := p.at
+	mx, my := 2*axis.X-at.X, 2*axis.Y-at.Y
+	p.drawAt(o, at)
+	if b.Symmetry&SymmetryX != 0 {
+		p.drawAt(o, pixel.V(mx, at.Y))
+	}
+	if b.Symmetry&SymmetryY != 0 {
+		p.drawAt(o, pixel.V(at.X, my))
+	}
+	if b.Symmetry == SymmetryXY {
+		p.drawAt(o, pixel.V(mx, my))
+	}
+}
+
+// drawAt draws one footprint centred on at, positioned as Stroke places
+// its dabs
+func (p *BrushPreview) drawAt(o *Overlay, at pixel.Vec) {
+	size := p.dabKey.size
+	bx := int(math.Floor(at.X - float64(size)/2 + 0.5))
+	by := int(math.Floor(at.Y - float64(size)/2 + 0.5))
+
+	var fill [4]uint8
+	if p.Fill > 0 && p.Brush.Color != nil {
+		fill = rgba8(p.Brush.Color)
+	}
+	op := math.Min(p.Fill, 1) * math.Min(p.Brush.Opacity, 1)
+
+	col, alt := rgba8(p.Color), rgba8(p.AltColor)
+	for j := 0; j < size; j++ {
+		y := by + j
+		if y < 0 || y >= o.Height {
+			continue
+		}
+		for i := 0; i < size; i++ {
+			x := bx + i
+			if x < 0 || x >= o.Width {
+				continue
+			}
+			k := j*size + i
+			px := o.Pix[(y*o.Width+x)*4:]
+			switch {
+			case p.outline[k]:
+				if (x+y)&1 == 0 {
+					copy(px[:4], col[:])
+				} else {
+					copy(px[:4], alt[:])
+				}
+			case op > 0 && p.dab[k] > 0:
+				a := uint32(float64(p.dab[k]) * op)
+				src := [4]uint8{
+					uint8(uint32(fill[0]) * a / 255),
+					uint8(uint32(fill[1]) * a / 255),
+					uint8(uint32(fill[2]) * a / 255),
+					uint8(uint32(fill[3]) * a / 255),
+				}
+				blendOver(px[:4], src[:])
+			}
+		}
+	}
+	p.drawn = append(p.drawn, [4]int{bx, by, bx + size, by + size})
+	o.Changed()
+}
+
+// footprint rebuilds the cached dab and its outline if the brush changed.
+// Edge pixels are covered pixels with an uncovered (or off the footprint)
+// neighbour.
+func (p *BrushPreview) footprint() {
+	b := p.Brush
+	key := brushKey{b.Shape, b.Size, b.Stamp}
+	if key == p.dabKey && p.dab != nil {
+		return
+	}
+	p.dab = brushDab(b)
+	key.size = b.Size // brushDab raises sizes below 1
+	p.dabKey = key
+
+	size := key.size
+	covered := func(i, j int) bool {
+		return i >= 0 && j >= 0 && i < size && j < size && p.dab[j*size+i] >= 128
+	}
+	p.outline = make([]bool, size*size)
+	for j := 0; j < size; j++ {
+		for i := 0; i < size; i++ {
+			p.outline[j*size+i] = covered(i, j) &&
+				(!covered(i-1, j) || !covered(i+1, j) || !covered(i, j-1) || !covered(i, j+1))
+		}
+	}
+}
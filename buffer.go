@@ -0,0 +1,98 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Helpers for working directly on the shadow canvas pixel buffer.
+//
+// The buffer returned by pixelgl is alpha-premultiplied RGBA, 4 bytes per
+// pixel, with row 0 at the bottom - the same orientation as pixel's
+// coordinates, so pixel (x, y) lives at offset (y*width + x) * 4.
+
+// rgba8 converts a colour to the premultiplied 8-bit layout of the buffer
+func rgba8(col color.Color) [4]uint8 {
+	r, g, b, a := col.RGBA()
+	return [4]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+}
+
+// pixelRect clamps r to the canvas and returns the integer pixel bounds it
+// covers, as [x0, x1) x [y0, y1).
+func (c *Canvasp) pixelRect(r pixel.Rect) (x0, y0, x1, y1 int) {
+	r = r.Norm()
+	x0 = clampInt(int(math.Floor(r.Min.X)), 0, c.width)
+	y0 = clampInt(int(math.Floor(r.Min.Y)), 0, c.height)
+	x1 = clampInt(int(math.Ceil(r.Max.X)), 0, c.width)
+	y1 = clampInt(int(math.Ceil(r.Max.Y)), 0, c.height)
+	return
+}
+
+// intRect builds a pixel.Rect from integer pixel bounds
+func intRect(x0, y0, x1, y1 int) pixel.Rect {
+	return pixel.R(float64(x0), float64(y0), float64(x1), float64(y1))
+}
+
+// within reports whether two pixels differ by at most tol on every channel
+func within(p []uint8, q [4]uint8, tol uint8) bool {
+	return absDiff(p[0], q[0]) <= tol && absDiff(p[1], q[1]) <= tol &&
+		absDiff(p[2], q[2]) <= tol && absDiff(p[3], q[3]) <= tol
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// blendOver composites premultiplied src pixels over dst (Porter-Duff source-over).
+// Both slices hold the same number of pixels.
+func blendOver(dst []uint8, src []uint8) {
+	for i := blendOverPairs(dst, src); i+3 < len(src); i += 4 {
+		blendPixel(dst[i:i+4], src[i:i+4])
+	}
+}
+
+// blendPixel is blendOver for a single pixel
+func blendPixel(dst []uint8, src []uint8) {
+	sa := uint32(src[3])
+	switch sa {
+	case 0:
+		return
+	case 255:
+		copy(dst, src[:4])
+		return
+	}
+	inv := 255 - sa
+	dst[0] = uint8(uint32(src[0]) + (uint32(dst[0])*inv+127)/255)
+	dst[1] = uint8(uint32(src[1]) + (uint32(dst[1])*inv+127)/255)
+	dst[2] = uint8(uint32(src[2]) + (uint32(dst[2])*inv+127)/255)
+	dst[3] = uint8(sa + (uint32(dst[3])*inv+127)/255)
+}
@@ -0,0 +1,89 @@
+package pixelcanvas
+
+import (
+	"github.com/faiface/pixel"
+)
+
+// Camera maps world coordinates onto the canvas. Pos is the world point shown
+// at the centre of the view, Zoom is canvas units per world unit. Offset
+// shifts the view by canvas units after zooming, e.g. for screen shake.
+// Canvas units are pixels for a camera from NewCamera, and the canvas's
+// logical coordinates (see SetCoordinates) for one from Canvasp.Camera.
+type Camera struct {
+	Pos    pixel.Vec
+	Zoom   float64
+	Offset pixel.Vec
+
+	size pixel.Vec // Viewport size in canvas units
+	c    *Canvasp  // Whose logical coordinates the camera works in; nil for pixels
+}
+
+// NewCamera creates a Camera covering a width x height viewport, centred on
+// the middle of the canvas at zoom 1, so world and canvas coordinates match.
+func NewCamera(width int, height int) *Camera {
+	size := pixel.V(float64(width), float64(height))
+	return &Camera{Pos: size.Scaled(0.5), Zoom: 1, size: size}
+}
+
+// Camera returns a new Camera sized to the canvas, working in its logical
+// coordinates: with a top-left origin world y increases downwards, and when
+// normalized zoom 1 shows a 1 x 1 world region
+func (c *Canvasp) Camera() *Camera {
+	size := c.LogicalSize()
+	return &Camera{Pos: size.Scaled(0.5), Zoom: 1, size: size, c: c}
+}
+
+// SetSize changes the viewport size in canvas pixels, e.g. after the canvas
+// has been resized
+func (cam *Camera) SetSize(width int, height int) {
+	cam.size = pixel.V(float64(width), float64(height))
+	if cam.c != nil && cam.c.coords.units == UnitsNormalized && cam.c.width > 0 && cam.c.height > 0 {
+		cam.size = pixel.V(cam.size.X/float64(cam.c.width), cam.size.Y/float64(cam.c.height))
+	}
+}
+
+// Size returns the viewport size in canvas units
+func (cam *Camera) Size() pixel.Vec {
+	return cam.size
+}
+
+// View returns the world rectangle currently visible through the camera,
+// Offset included
+func (cam *Camera) View() pixel.Rect {
+	half := cam.size.Scaled(0.5 / cam.Zoom)
+	centre := cam.Pos.Sub(cam.Offset.Scaled(1 / cam.Zoom))
+	return pixel.Rect{Min: centre.Sub(half), Max: centre.Add(half)}
+}
+
+// Matrix returns the world -> shadow canvas transform, suitable for
+// SetMatrix on the shadow canvas or for drawing sprites and imdraw shapes.
+// It includes the canvas's CoordMatrix for a camera from Canvasp.Camera.
+func (cam *Camera) Matrix() pixel.Matrix {
+	if cam.c != nil {
+		return cam.view().Chained(cam.c.CoordMatrix())
+	}
+	return cam.view()
+}
+
+// view is the world -> canvas units transform
+func (cam *Camera) view() pixel.Matrix {
+	return pixel.IM.Moved(cam.Pos.Scaled(-1)).Scaled(pixel.ZV, cam.Zoom).Moved(cam.size.Scaled(0.5).Add(cam.Offset))
+}
+
+// Project converts a world position to canvas units, e.g. for the drawing
+// helpers
+func (cam *Camera) Project(world pixel.Vec) pixel.Vec {
+	return cam.view().Project(world)
+}
+
+// Unproject converts a position in canvas units (e.g. from FromClient) to
+// world coordinates
+func (cam *Camera) Unproject(canvas pixel.Vec) pixel.Vec {
+	return cam.view().Unproject(canvas)
+}
+
+// Pin moves the camera so that world point 'world' appears at canvas point
+// 'at', keeping the zoom. Use it to zoom about the pointer.
+func (cam *Camera) Pin(world pixel.Vec, at pixel.Vec) {
+	cam.Pos = world.Sub(at.Sub(cam.size.Scaled(0.5).Add(cam.Offset)).Scaled(1 / cam.Zoom))
+}
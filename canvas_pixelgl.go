@@ -0,0 +1,36 @@
+//go:build pixelgl
+// +build pixelgl
+
+package pixelcanvas
+
+import (
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// Canvas is the drawing surface: the shadow canvas handed to a RenderFunc,
+// layers, render targets and viewports. Built with the pixelgl tag it is
+// pixelgl's OpenGL canvas, as in earlier versions of the package.
+type Canvas = pixelgl.Canvas
+
+// newCanvas creates a transparent Canvas covering bounds
+func newCanvas(bounds pixel.Rect) *Canvas {
+	return pixelgl.NewCanvas(bounds)
+}
+
+// canvasPixels returns gc's pixels for reading, read back from the GPU
+func canvasPixels(gc *Canvas) []uint8 {
+	return gc.Pixels()
+}
+
+// pixels returns the shadow canvas's pixels for reading, or for writing
+// followed by pixelsChanged. pixelgl keeps them on the GPU, so this reads
+// them back.
+func (c *Canvasp) pixels() []uint8 {
+	return c.image.Pixels()
+}
+
+// pixelsChanged stores writes made to the slice from pixels
+func (c *Canvasp) pixelsChanged(pix []uint8) {
+	c.image.SetPixels(pix)
+}
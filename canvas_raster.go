@@ -0,0 +1,37 @@
+//go:build !pixelgl
+// +build !pixelgl
+
+package pixelcanvas
+
+import (
+	"github.com/faiface/pixel"
+	"github.com/lwayneh/pixelcanvas/raster"
+)
+
+// Canvas is the drawing surface: the shadow canvas handed to a RenderFunc,
+// layers, render targets and viewports. By default it is the software
+// renderer in package raster, which keeps OpenGL and GLFW out of the
+// WebAssembly binary. Build with the pixelgl tag to use pixelgl.Canvas
+// instead, e.g. for code that relies on its fragment shaders.
+type Canvas = raster.Canvas
+
+// newCanvas creates a transparent Canvas covering bounds
+func newCanvas(bounds pixel.Rect) *Canvas {
+	return raster.NewCanvas(bounds)
+}
+
+// canvasPixels returns gc's pixels for reading, without a copy
+func canvasPixels(gc *Canvas) []uint8 {
+	return gc.Pix()
+}
+
+// pixels returns the shadow canvas's pixels for reading, or for writing
+// followed by pixelsChanged. Here they are the canvas's own, so nothing is
+// copied; don't keep them across a resize.
+func (c *Canvasp) pixels() []uint8 {
+	return c.image.Pix()
+}
+
+// pixelsChanged stores writes made to the slice from pixels. With the
+// software renderer they were made in place already.
+func (c *Canvasp) pixelsChanged(pix []uint8) {}
@@ -0,0 +1,177 @@
+package pixelcanvas
+
+import (
+	"strings"
+	"syscall/js"
+)
+
+// Capabilities reports which optional browser features are available, so
+// apps can adapt per browser rather than fail part way through. The package
+// uses it to pick its own backends, e.g. OffscreenCanvas for scratch
+// drawing.
+type Capabilities struct {
+	OffscreenCanvas     bool
+	SharedArrayBuffer   bool // Also needs the page to be cross-origin isolated
+	CrossOriginIsolated bool
+	WebGL               bool
+	WebGL2              bool
+	WebGPU              bool
+	WebCodecs           bool // VideoEncoder and VideoDecoder
+	WebAssemblySIMD     bool // See SIMDSupported
+	Workers             bool
+	IndexedDB           bool
+	Clipboard           bool // Async clipboard text
+	ClipboardImages     bool // ClipboardItem, for copying images
+	Gamepad             bool
+	EyeDropper          bool
+	FileSystemAccess    bool // showOpenFilePicker / showSaveFilePicker
+	Fullscreen          bool
+	PointerLock         bool
+	WakeLock            bool
+	Vibration           bool
+}
+
+var capabilities *Capabilities
+
+// DetectCapabilities probes the browser for its optional features. WebGL is
+// checked by creating (and then releasing) a context, as a GPU blocklist can
+// leave the API present but unusable. The result is cached.
+func DetectCapabilities() Capabilities {
+	if capabilities != nil {
+		return *capabilities
+	}
+	g := js.Global()
+	nav := g.Get("navigator")
+	doc := g.Get("document")
+	has := func(obj js.Value, name string) bool {
+		return !obj.IsUndefined() && !obj.IsNull() && !obj.Get(name).IsUndefined()
+	}
+
+	k := Capabilities{
+		OffscreenCanvas:     has(g, "OffscreenCanvas"),
+		SharedArrayBuffer:   has(g, "SharedArrayBuffer"),
+		CrossOriginIsolated: has(g, "crossOriginIsolated") && g.Get("crossOriginIsolated").Bool(),
+		WebGPU:              has(nav, "gpu"),
+		WebCodecs:           has(g, "VideoEncoder") && has(g, "VideoDecoder"),
+		WebAssemblySIMD:     SIMDSupported(),
+		Workers:             has(g, "Worker"),
+		IndexedDB:           has(g, "indexedDB"),
+		Clipboard:           has(nav, "clipboard"),
+		ClipboardImages:     has(nav, "clipboard") && has(g, "ClipboardItem"),
+		Gamepad:             has(nav, "getGamepads"),
+		EyeDropper:          has(g, "EyeDropper"),
+		FileSystemAccess:    has(g, "showOpenFilePicker"),
+		Fullscreen:          has(doc, "fullscreenEnabled") && doc.Get("fullscreenEnabled").Bool() || has(doc, "webkitFullscreenEnabled") && doc.Get("webkitFullscreenEnabled").Bool(),
+		WakeLock:            has(nav, "wakeLock"),
+		Vibration:           has(nav, "vibrate"),
+	}
+	if has(doc, "createElement") {
+		canvas := doc.Call("createElement", "canvas")
+		k.PointerLock = has(canvas, "requestPointerLock")
+		k.WebGL2 = probeContext(canvas, "webgl2")
+		if !k.WebGL2 {
+			canvas = doc.Call("createElement", "canvas") // A canvas only ever has one kind of context
+		}
+		k.WebGL = k.WebGL2 || probeContext(canvas, "webgl")
+	}
+	capabilities = &k
+	return k
+}
+
+// Capabilities returns the browser's optional features, see
+// DetectCapabilities
+func (c *Canvasp) Capabilities() Capabilities {
+	return DetectCapabilities()
+}
+
+// probeContext reports whether canvas can create a context of kind, and
+// releases the context straight away so probing doesn't use up one of the
+// browser's limited WebGL contexts
+func probeContext(canvas js.Value, kind string) bool {
+	ctx := canvas.Call("getContext", kind)
+	if ctx.IsNull() || ctx.IsUndefined() {
+		return false
+	}
+	if ext := ctx.Call("getExtension", "WEBGL_lose_context"); !ext.IsNull() {
+		ext.Call("loseContext")
+	}
+	return true
+}
+
+// Missing lists the features that aren't available, by field name, e.g.
+// for a degradation report in the log or a bug report
+func (k Capabilities) Missing() []string {
+	var missing []string
+	for _, f := range k.features() {
+		if !f.ok {
+			missing = append(missing, f.name)
+		}
+	}
+	return missing
+}
+
+// String lists every feature with a + or - for available or missing
+func (k Capabilities) String() string {
+	var b strings.Builder
+	for i, f := range k.features() {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		if f.ok {
+			b.WriteByte('+')
+		} else {
+			b.WriteByte('-')
+		}
+		b.WriteString(f.name)
+	}
+	return b.String()
+}
+
+type feature struct {
+	name string
+	ok   bool
+}
+
+func (k Capabilities) features() []feature {
+	return []feature{
+		{"OffscreenCanvas", k.OffscreenCanvas},
+		{"SharedArrayBuffer", k.SharedArrayBuffer},
+		{"CrossOriginIsolated", k.CrossOriginIsolated},
+		{"WebGL", k.WebGL},
+		{"WebGL2", k.WebGL2},
+		{"WebGPU", k.WebGPU},
+		{"WebCodecs", k.WebCodecs},
+		{"WebAssemblySIMD", k.WebAssemblySIMD},
+		{"Workers", k.Workers},
+		{"IndexedDB", k.IndexedDB},
+		{"Clipboard", k.Clipboard},
+		{"ClipboardImages", k.ClipboardImages},
+		{"Gamepad", k.Gamepad},
+		{"EyeDropper", k.EyeDropper},
+		{"FileSystemAccess", k.FileSystemAccess},
+		{"Fullscreen", k.Fullscreen},
+		{"PointerLock", k.PointerLock},
+		{"WakeLock", k.WakeLock},
+		{"Vibration", k.Vibration},
+	}
+}
+
+// LogCapabilities writes the capability report to the canvas's logger, with
+// the missing features listed separately so degraded sessions stand out
+func (c *Canvasp) LogCapabilities() {
+	k := DetectCapabilities()
+	c.log().Info("browser capabilities", "features", k.String(), "missing", strings.Join(k.Missing(), ","))
+}
+
+// scratchCanvas returns a width x height canvas for offscreen drawing: an
+// OffscreenCanvas where supported, which skips the DOM entirely, otherwise
+// a detached canvas element
+func (c *Canvasp) scratchCanvas(width, height int) js.Value {
+	if DetectCapabilities().OffscreenCanvas {
+		return js.Global().Get("OffscreenCanvas").New(width, height)
+	}
+	canvas := c.doc.Call("createElement", "canvas")
+	canvas.Set("width", width)
+	canvas.Set("height", height)
+	return canvas
+}
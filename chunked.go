@@ -0,0 +1,248 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/faiface/pixel"
+)
+
+// TileKey identifies a tile of a ChunkedCanvas. Tile (0, 0) covers world
+// pixels [0, TileSize) on both axes; negative keys extend left and down.
+type TileKey struct {
+	X, Y int
+}
+
+// Tile is one fixed-size block of a ChunkedCanvas. Pix uses the shadow
+// canvas layout: premultiplied RGBA, rows bottom-up.
+type Tile struct {
+	Pix   []uint8
+	Dirty bool // Modified since it was loaded. Cleared by the application, e.g. once saved
+
+	used uint64 // Draw generation the tile was last visible in
+}
+
+// ChunkedCanvas is a pixel surface of virtually unlimited size, stored as
+// fixed-size tiles that are allocated when first touched and evicted when
+// they have not been on screen for a while. It is presented through the
+// normal copy path by drawing the part seen through a Camera onto the
+// shadow canvas, see RenderFunc.
+type ChunkedCanvas struct {
+	TileSize int
+	MaxTiles int // Resident tiles kept before evicting off-screen ones. 0 for no limit
+
+	// Load, if set, fills a newly allocated tile, e.g. from a server or a
+	// generator. Tiles start transparent otherwise.
+	Load func(key TileKey, t *Tile)
+
+	// OnEvict, if set, is called before a tile is dropped, e.g. to save it
+	// if it is Dirty.
+	OnEvict func(key TileKey, t *Tile)
+
+	Background color.Color // Drawn where there is no tile. nil for transparent
+
+	tiles map[TileKey]*Tile
+	gen   uint64 // Draw generation
+	edits uint64 // Bumped by every change, so RenderFunc knows when to redraw
+
+	frame []uint8 // Scratch buffer Draw composes into
+}
+
+// NewChunkedCanvas creates an empty ChunkedCanvas
+func NewChunkedCanvas(tileSize int, maxTiles int) *ChunkedCanvas {
+	return &ChunkedCanvas{
+		TileSize: tileSize,
+		MaxTiles: maxTiles,
+		tiles:    make(map[TileKey]*Tile),
+	}
+}
+
+// TileAt returns the key of the tile holding world pixel (x, y), and the
+// pixel's position within it
+func (cc *ChunkedCanvas) TileAt(x, y int) (key TileKey, lx, ly int) {
+	key = TileKey{floorDiv(x, cc.TileSize), floorDiv(y, cc.TileSize)}
+	return key, x - key.X*cc.TileSize, y - key.Y*cc.TileSize
+}
+
+// Tile returns the tile for key, allocating and loading it if needed
+func (cc *ChunkedCanvas) Tile(key TileKey) *Tile {
+	if t, ok := cc.tiles[key]; ok {
+		return t
+	}
+	t := &Tile{Pix: make([]uint8, cc.TileSize*cc.TileSize*4), used: cc.gen}
+	if cc.Load != nil {
+		cc.Load(key, t)
+	}
+	cc.tiles[key] = t
+	cc.edits++
+	return t
+}
+
+// Peek returns the tile for key if it is resident, without allocating it
+func (cc *ChunkedCanvas) Peek(key TileKey) (*Tile, bool) {
+	t, ok := cc.tiles[key]
+	return t, ok
+}
+
+// Len returns the number of resident tiles
+func (cc *ChunkedCanvas) Len() int {
+	return len(cc.tiles)
+}
+
+// At returns the colour of world pixel (x, y). Pixels in tiles that are not
+// resident read as transparent.
+func (cc *ChunkedCanvas) At(x, y int) color.RGBA {
+	key, lx, ly := cc.TileAt(x, y)
+	t, ok := cc.tiles[key]
+	if !ok {
+		return color.RGBA{}
+	}
+	i := (ly*cc.TileSize + lx) * 4
+	return color.RGBA{R: t.Pix[i], G: t.Pix[i+1], B: t.Pix[i+2], A: t.Pix[i+3]}
+}
+
+// Set sets world pixel (x, y), allocating its tile if needed
+func (cc *ChunkedCanvas) Set(x, y int, col color.Color) {
+	key, lx, ly := cc.TileAt(x, y)
+	t := cc.Tile(key)
+	i := (ly*cc.TileSize + lx) * 4
+	p := rgba8(col)
+	copy(t.Pix[i:i+4], p[:])
+	t.Dirty = true
+	cc.edits++
+}
+
+// Invalidate marks a tile as changed after its Pix has been edited directly
+func (cc *ChunkedCanvas) Invalidate(key TileKey) {
+	if t, ok := cc.tiles[key]; ok {
+		t.Dirty = true
+	}
+	cc.edits++
+}
+
+// Visible returns the keys of the tiles that intersect a world rectangle
+func (cc *ChunkedCanvas) Visible(view pixel.Rect) []TileKey {
+	view = view.Norm()
+	ts := float64(cc.TileSize)
+	x0, y0 := int(math.Floor(view.Min.X/ts)), int(math.Floor(view.Min.Y/ts))
+	x1, y1 := int(math.Ceil(view.Max.X/ts)), int(math.Ceil(view.Max.Y/ts))
+
+	var keys []TileKey
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			keys = append(keys, TileKey{x, y})
+		}
+	}
+	return keys
+}
+
+// Draw renders the part of the canvas seen through cam onto gc, sampling
+// nearest pixels, and loads the visible tiles that are not yet resident.
+// Zoomed far out that can be a great many tiles, so limit the camera's
+// zoom to what MaxTiles can cover.
+func (cc *ChunkedCanvas) Draw(gc *Canvas, cam *Camera) {
+	cc.gen++
+	for _, key := range cc.Visible(cam.View()) {
+		cc.Tile(key).used = cc.gen
+	}
+
+	b := gc.Bounds()
+	w, h := int(b.W()), int(b.H())
+	if len(cc.frame) != w*h*4 {
+		cc.frame = make([]uint8, w*h*4)
+	}
+	var bg [4]uint8
+	if cc.Background != nil {
+		bg = rgba8(cc.Background)
+	}
+
+	// The camera is axis aligned, so world columns and rows can be worked
+	// out once each rather than per pixel
+	m := cam.Matrix()
+	cols := make([]int, w)
+	for px := range cols {
+		cols[px] = int(math.Floor(m.Unproject(pixel.V(float64(px)+0.5, 0)).X))
+	}
+
+	ts := cc.TileSize
+	for py := 0; py < h; py++ {
+		wy := int(math.Floor(m.Unproject(pixel.V(0, float64(py)+0.5)).Y))
+		ty := floorDiv(wy, ts)
+		ly := wy - ty*ts
+
+		row := cc.frame[py*w*4 : (py+1)*w*4]
+		var t *Tile
+		tx := math.MinInt32
+		for px, wx := range cols {
+			if k := floorDiv(wx, ts); k != tx {
+				tx = k
+				t = cc.tiles[TileKey{tx, ty}]
+			}
+			d := row[px*4 : px*4+4]
+			if t == nil {
+				copy(d, bg[:])
+				continue
+			}
+			i := (ly*ts + wx - tx*ts) * 4
+			copy(d, t.Pix[i:i+4])
+			if cc.Background != nil && d[3] != 255 {
+				p := [4]uint8{d[0], d[1], d[2], d[3]}
+				copy(d, bg[:])
+				blendOver(d, p[:])
+			}
+		}
+	}
+	gc.SetPixels(cc.frame)
+	cc.Evict()
+}
+
+// Evict drops the least recently visible tiles until at most MaxTiles are
+// resident. Tiles visible in the latest Draw are never evicted.
+func (cc *ChunkedCanvas) Evict() {
+	if cc.MaxTiles <= 0 || len(cc.tiles) <= cc.MaxTiles {
+		return
+	}
+
+	var old []TileKey
+	for key, t := range cc.tiles {
+		if t.used != cc.gen {
+			old = append(old, key)
+		}
+	}
+	sort.Slice(old, func(i, j int) bool { return cc.tiles[old[i]].used < cc.tiles[old[j]].used })
+
+	for _, key := range old {
+		if len(cc.tiles) <= cc.MaxTiles {
+			break
+		}
+		if cc.OnEvict != nil {
+			cc.OnEvict(key, cc.tiles[key])
+		}
+		delete(cc.tiles, key)
+	}
+}
+
+// RenderFunc returns a RenderFunc for Canvasp.Start that presents the canvas
+// through cam, only redrawing when the camera has moved or tiles changed.
+func (cc *ChunkedCanvas) RenderFunc(cam *Camera) RenderFunc {
+	var last Camera
+	lastEdits := ^uint64(0)
+	return func(gc *Canvas) bool {
+		if *cam == last && cc.edits == lastEdits {
+			return false
+		}
+		cc.Draw(gc, cam)
+		last, lastEdits = *cam, cc.edits
+		return true
+	}
+}
+
+// floorDiv divides rounding towards negative infinity
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,100 @@
+package pixelcanvas
+
+import (
+	"image/color"
+
+	"github.com/faiface/pixel"
+)
+
+// ClearPolicy decides when the shadow canvas is wiped to the clear colour
+// before the RenderFunc runs
+type ClearPolicy int
+
+// Clear policies
+const (
+	ClearNever    ClearPolicy = iota // Keep the previous frame, for incremental drawing. The default
+	ClearAlways                      // Wipe before every frame, for scenes redrawn from scratch
+	ClearOnDemand                    // Wipe before the next frame after each RequestClear
+)
+
+// String implements fmt.Stringer
+func (p ClearPolicy) String() string {
+	switch p {
+	case ClearNever:
+		return "never"
+	case ClearAlways:
+		return "always"
+	case ClearOnDemand:
+		return "on demand"
+	}
+	return "unknown"
+}
+
+// SetClearColor sets the colour the canvas is wiped to by the clear policy
+// and Clear. nil is transparent.
+func (c *Canvasp) SetClearColor(col color.Color) {
+	c.clearColor = col
+}
+
+// ClearColor returns the clear colour
+func (c *Canvasp) ClearColor() color.Color {
+	if c.clearColor == nil {
+		return color.Transparent
+	}
+	return c.clearColor
+}
+
+// SetClearPolicy sets when the canvas is wiped before the RenderFunc runs.
+// Scenes that redraw everything each frame want ClearAlways; without it,
+// anything that moves leaves a smear.
+func (c *Canvasp) SetClearPolicy(p ClearPolicy) {
+	c.clearPolicy = p
+}
+
+// ClearPolicy returns the clear policy
+func (c *Canvasp) ClearPolicy() ClearPolicy {
+	return c.clearPolicy
+}
+
+// RequestClear asks for the canvas to be wiped before the next frame, under
+// ClearOnDemand, e.g. when the scene changes
+func (c *Canvasp) RequestClear() {
+	c.clearPending = true
+}
+
+// Clear wipes area r of the shadow canvas to the clear colour (the zero
+// Rect for the whole canvas) and returns the area changed
+func (c *Canvasp) Clear(r pixel.Rect) pixel.Rect {
+	r = c.ToCanvasRect(r)
+	if r == (pixel.Rect{}) || r == c.image.Bounds() {
+		c.image.Clear(c.ClearColor())
+		return c.FromCanvasRect(c.image.Bounds())
+	}
+	x0, y0, x1, y1 := c.pixelRect(r)
+	if x0 >= x1 || y0 >= y1 {
+		return pixel.Rect{}
+	}
+	px := rgba8(c.ClearColor())
+	pix := c.pixels()
+	for y := y0; y < y1; y++ {
+		fillPixels(pix[(y*c.width+x0)*4:(y*c.width+x1)*4], px)
+	}
+	c.pixelsChanged(pix)
+	return c.FromCanvasRect(intRect(x0, y0, x1, y1))
+}
+
+// applyClearPolicy wipes the canvas before the RenderFunc if the policy
+// says so
+func (c *Canvasp) applyClearPolicy() {
+	switch c.clearPolicy {
+	case ClearAlways:
+	case ClearOnDemand:
+		if !c.clearPending {
+			return
+		}
+	default:
+		return
+	}
+	c.clearPending = false
+	c.image.Clear(c.ClearColor())
+}
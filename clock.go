@@ -0,0 +1,162 @@
+package pixelcanvas
+
+import (
+	"syscall/js"
+	"time"
+)
+
+// Clock drives any number of canvases from a single requestAnimationFrame
+// registration, so that e.g. a main view at 60 FPS, a minimap at 10 FPS and
+// a UI layer at 30 FPS all see the same frame timestamps and render in a
+// fixed order within one browser frame. Each canvas keeps its own FPS,
+// catch-up policy, lifecycle state and statistics.
+//
+// Canvases join with StartOn, and leave when stopped. The Clock requests
+// animation frames while it has canvases and stops when the last one leaves.
+type Clock struct {
+	rafCall js.Value
+	rafStop js.Value
+	raf     js.Func
+	reqID   js.Value
+	running bool // Animation frames are wanted
+	ticking bool // Inside tick, which requests the next frame itself
+
+	members []clockMember
+	current []clockMember // The members tick is running, taken out of members
+	now     float64       // Timestamp of the latest tick
+	start   float64       // Timestamp of the first tick
+	frames  uint64
+}
+
+type clockMember struct {
+	c    *Canvasp
+	done chan struct{}
+	rf   RenderFunc
+}
+
+// NewClock creates a shared Clock
+func NewClock() *Clock {
+	window := js.Global()
+	k := &Clock{
+		rafCall: bound(window, "requestAnimationFrame"),
+		rafStop: bound(window, "cancelAnimationFrame"),
+	}
+	k.raf = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		k.tick(args[0].Float())
+		return nil
+	})
+	return k
+}
+
+// StartOn is Start, but the canvas is driven by the shared clock k instead of
+// registering its own animation frame callback. Canvases render in the order
+// they were started. Stop, Pause and Resume work as usual.
+func (c *Canvasp) StartOn(k *Clock, maxFPS float64, rf RenderFunc) {
+	if c.state == StateRunning || c.state == StatePaused {
+		c.Stop()
+	}
+	c.SetFPS(maxFPS)
+	c.done = make(chan struct{})
+	c.clock.reset()
+	c.shared = k
+	c.reqID = js.Undefined()
+	c.state = StateRunning
+	k.add(clockMember{c: c, done: c.done, rf: rf})
+	c.log().Info("render loop started on shared clock", "maxFPS", maxFPS)
+}
+
+// Now returns the time since the clock's first tick, as of the latest tick.
+// Every canvas sees the same value within a frame.
+func (k *Clock) Now() time.Duration {
+	return msToDuration(k.now - k.start)
+}
+
+// Frames returns the number of animation frames the clock has run
+func (k *Clock) Frames() uint64 {
+	return k.frames
+}
+
+// Len returns the number of canvases the clock is driving
+func (k *Clock) Len() int {
+	return len(k.members)
+}
+
+// Stop stops every canvas on the clock. Called from a RenderFunc, it also
+// stops the canvases still to render in that frame.
+func (k *Clock) Stop() {
+	members := k.members
+	k.members = nil
+	for _, m := range members {
+		m.c.Stop()
+	}
+	for _, m := range k.current {
+		m.c.Stop() // No-op for those already stopped
+	}
+	k.halt()
+}
+
+// Release stops the clock and frees its callback. The Clock must not be used
+// afterwards.
+func (k *Clock) Release() {
+	k.Stop()
+	k.raf.Release()
+}
+
+func (k *Clock) add(m clockMember) {
+	k.members = append(k.members, m)
+	if !k.running {
+		k.running = true
+		if !k.ticking {
+			k.reqID = k.rafCall.Invoke(k.raf)
+		}
+	}
+}
+
+func (k *Clock) halt() {
+	if k.running {
+		k.rafStop.Invoke(k.reqID)
+		k.running = false
+	}
+}
+
+func (k *Clock) tick(timestamp float64) {
+	if !k.running {
+		return
+	}
+	if k.frames == 0 {
+		k.start = timestamp
+	}
+	k.now = timestamp
+	k.frames++
+
+	members := k.members
+	k.members = nil // Canvases started during the tick are added here
+	k.ticking, k.current = true, members
+	for _, m := range members {
+		m.c.animationFrame(m.done, timestamp, m.rf)
+	}
+	k.ticking, k.current = false, nil
+
+	// Drop members stopped since the last tick, or during this one. Paused
+	// members stay.
+	live := members[:0]
+	for _, m := range members {
+		select {
+		case <-m.done:
+		default:
+			live = append(live, m)
+		}
+	}
+	for i := len(live); i < len(members); i++ {
+		members[i] = clockMember{}
+	}
+	k.members = append(live, k.members...)
+
+	if len(k.members) == 0 {
+		k.halt()
+		return
+	}
+	if k.running { // Unless a RenderFunc stopped the clock and started nothing since
+		k.reqID = k.rafCall.Invoke(k.raf)
+	}
+}
@@ -0,0 +1,246 @@
+package pixelcanvas
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall/js"
+	"time"
+)
+
+// Clock synchronization
+//
+// ClockSync estimates the offset between this browser's clock and a
+// server's the way NTP does: each sample records when a request left, the
+// server's time when it answered, and when the reply arrived, assuming the
+// server answered half way through the round trip. Only the fastest recent
+// round trips are trusted, since queueing delays are what make samples
+// asymmetric, and the offset is smoothed so Now doesn't jump. Samples can
+// come over HTTP (SyncHTTP) or an app's existing WebSocket (SyncWebSocket),
+// or from any other transport through AddSample.
+
+// Clock sync defaults
+const (
+	DefaultSyncSamples  = 8               // Samples SyncHTTP takes, and the window of recent samples kept
+	DefaultSyncInterval = 5 * time.Second // Between SyncWebSocket samples when none is given
+	clockSyncSmoothing  = 0.25            // Weight of a new estimate in the smoothed offset
+	clockSyncRTTSlack   = 1.5             // Samples within this factor of the best round trip are trusted
+	clockSyncMessageKey = "pixelcanvas:clock"
+)
+
+// ErrBadClockReply is returned when a time server's reply has no time in it
+var ErrBadClockReply = errors.New("pixelcanvas: time server reply has no time")
+
+type clockSample struct {
+	offset time.Duration
+	rtt    time.Duration
+}
+
+// ClockSync keeps an estimate of a server's clock. It is safe to use from
+// several goroutines.
+type ClockSync struct {
+	mu      sync.Mutex
+	samples []clockSample // Most recent last, at most DefaultSyncSamples
+	offset  time.Duration // Smoothed
+	rtt     time.Duration // Best round trip among the samples
+	synced  bool
+
+	ws        js.Value
+	wsMessage js.Func
+	wsStop    chan struct{}
+}
+
+// NewClockSync creates a ClockSync that knows nothing yet: Now returns the
+// local time until the first sample
+func NewClockSync() *ClockSync {
+	return &ClockSync{}
+}
+
+// AddSample adds one exchange: the local time the request was sent, the
+// server's time in its reply and the local time the reply arrived
+func (s *ClockSync) AddSample(sent, server, received time.Time) {
+	rtt := received.Sub(sent)
+	if rtt < 0 {
+		return
+	}
+	offset := server.Sub(sent.Add(rtt / 2))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = append(s.samples, clockSample{offset, rtt})
+	if len(s.samples) > DefaultSyncSamples {
+		s.samples = s.samples[1:]
+	}
+
+	// Median offset of the samples near the best round trip
+	best := s.samples[0].rtt
+	for _, k := range s.samples {
+		if k.rtt < best {
+			best = k.rtt
+		}
+	}
+	var trusted []time.Duration
+	for _, k := range s.samples {
+		if float64(k.rtt) <= float64(best)*clockSyncRTTSlack {
+			trusted = append(trusted, k.offset)
+		}
+	}
+	sort.Slice(trusted, func(i, j int) bool { return trusted[i] < trusted[j] })
+	estimate := trusted[len(trusted)/2]
+
+	s.rtt = best
+	if !s.synced {
+		s.offset, s.synced = estimate, true
+		return
+	}
+	s.offset += time.Duration(float64(estimate-s.offset) * clockSyncSmoothing)
+}
+
+// Now returns the estimated server time
+func (s *ClockSync) Now() time.Time {
+	return time.Now().Add(s.Offset())
+}
+
+// Offset returns how far the server's clock is ahead of the local one
+func (s *ClockSync) Offset() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.offset
+}
+
+// RTT returns the best recent round trip time, which bounds the error of
+// the offset to about half of it
+func (s *ClockSync) RTT() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rtt
+}
+
+// Synced reports whether at least one sample has been taken
+func (s *ClockSync) Synced() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.synced
+}
+
+// SyncHTTP takes samples from a time endpoint at url, which must answer
+// with the server's time in milliseconds since the Unix epoch, either as a
+// bare number or as a JSON object's "time" field. Like all blocking
+// helpers, call it from a goroutine. Samples that fail are skipped; the
+// last error is returned if none succeeded.
+func (s *ClockSync) SyncHTTP(c *Canvasp, url string, samples int) error {
+	if samples <= 0 {
+		samples = DefaultSyncSamples
+	}
+	var lastErr error
+	ok := false
+	for i := 0; i < samples; i++ {
+		sep := "?"
+		if strings.Contains(url, "?") {
+			sep = "&"
+		}
+		sent := time.Now()
+		body, err := c.FetchBytes(url + sep + "t=" + strconv.FormatInt(sent.UnixNano(), 36)) // Defeat caches
+		received := time.Now()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		server, err := parseServerTime(body)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		s.AddSample(sent, server, received)
+		ok = true
+	}
+	if ok {
+		return nil
+	}
+	return lastErr
+}
+
+// SyncWebSocket samples the clock over an open WebSocket every interval
+// (DefaultSyncInterval if 0 or less) until StopWebSocket, for long sessions
+// where the clocks drift. It sends
+// {"type": "pixelcanvas:clock", "sent": <ms>} and expects the server to
+// echo the message back with its own time in milliseconds added as
+// "server". Other messages on the socket are left alone.
+func (s *ClockSync) SyncWebSocket(ws js.Value, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultSyncInterval
+	}
+	s.StopWebSocket()
+	s.ws = ws
+	s.wsMessage = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		received := time.Now()
+		if len(args) == 0 || args[0].Get("data").Type() != js.TypeString {
+			return nil
+		}
+		var msg struct {
+			Type   string  `json:"type"`
+			Sent   float64 `json:"sent"`
+			Server float64 `json:"server"`
+		}
+		if json.Unmarshal([]byte(args[0].Get("data").String()), &msg) != nil || msg.Type != clockSyncMessageKey || msg.Server == 0 {
+			return nil
+		}
+		s.AddSample(msTime(msg.Sent), msTime(msg.Server), received)
+		return nil
+	})
+	ws.Call("addEventListener", "message", s.wsMessage)
+
+	stop := make(chan struct{})
+	s.wsStop = stop
+	go func() {
+		for {
+			if ws.Get("readyState").Int() == 1 { // OPEN
+				now := float64(time.Now().UnixNano()) / 1e6
+				ws.Call("send", `{"type":"`+clockSyncMessageKey+`","sent":`+strconv.FormatFloat(now, 'f', 3, 64)+`}`)
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+// StopWebSocket stops sampling over the WebSocket, keeping the estimate
+func (s *ClockSync) StopWebSocket() {
+	if s.wsStop == nil {
+		return
+	}
+	close(s.wsStop)
+	s.wsStop = nil
+	s.ws.Call("removeEventListener", "message", s.wsMessage)
+	s.wsMessage.Release()
+	s.ws = js.Undefined()
+}
+
+// parseServerTime reads milliseconds since the epoch from a bare number or
+// a JSON object's "time" field
+func parseServerTime(body []byte) (time.Time, error) {
+	text := strings.TrimSpace(string(body))
+	if ms, err := strconv.ParseFloat(text, 64); err == nil {
+		return msTime(ms), nil
+	}
+	var obj struct {
+		Time float64 `json:"time"`
+	}
+	if err := json.Unmarshal(body, &obj); err != nil || obj.Time == 0 {
+		return time.Time{}, ErrBadClockReply
+	}
+	return msTime(obj.Time), nil
+}
+
+// msTime converts milliseconds since the Unix epoch to a time.Time
+func msTime(ms float64) time.Time {
+	sec, frac := math.Modf(ms / 1000)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
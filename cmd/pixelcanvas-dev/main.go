@@ -0,0 +1,263 @@
+// Command pixelcanvas-dev serves a pixelcanvas app for development,
+// rebuilding it when its sources change and reloading the browser.
+//
+// Run it in the app's package directory and open the address it prints:
+//
+//	pixelcanvas-dev -addr :8080
+//
+// It builds the package with GOOS=js GOARCH=wasm and serves it with the
+// loader from package loader, plus any static files from -static. Files
+// are sent with no-store caching and with the COOP/COEP headers that make
+// the page cross-origin isolated, so SharedArrayBuffer (and with it the
+// worker pool's shared buffers) is available as it would be in production
+// behind the same headers. The sources are polled for changes, as the
+// standard library has no file notifications; after a successful build the
+// page reloads itself, and a failed build shows its errors over the page.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lwayneh/pixelcanvas/loader"
+)
+
+const eventsPath = "/_pixelcanvas/events"
+
+var (
+	addr     = flag.String("addr", "localhost:8080", "address to serve on")
+	pkg      = flag.String("pkg", ".", "package to build")
+	static   = flag.String("static", ".", "directory of static files to serve; its index.html replaces the generated one")
+	watch    = flag.String("watch", ".", "directory tree to watch for changes")
+	interval = flag.Duration("interval", 500*time.Millisecond, "how often to check for changes")
+	tags     = flag.String("tags", "", "build tags")
+	title    = flag.String("title", "pixelcanvas", "title of the generated page")
+)
+
+// server holds the latest build and the browsers waiting for the next one
+type server struct {
+	mu       sync.Mutex
+	wasm     []byte
+	buildErr string
+	version  int
+	clients  map[chan struct{}]struct{}
+	script   []byte
+	dir      string // Build output
+}
+
+func main() {
+	log.SetFlags(log.Ltime)
+	log.SetPrefix("pixelcanvas-dev: ")
+	flag.Parse()
+
+	dir, err := ioutil.TempDir("", "pixelcanvas-dev")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := &server{clients: make(map[chan struct{}]struct{}), dir: dir}
+	bundle, err := loader.Bundle(loader.Options{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	s.script = append(bundle, reloadScript...)
+
+	s.build()
+	go s.watch()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/"+loader.DefaultWasm, s.serveWasm)
+	mux.HandleFunc("/"+loader.DefaultScript, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		w.Write(s.script)
+	})
+	mux.HandleFunc(eventsPath, s.serveEvents)
+	mux.HandleFunc("/", s.serveStatic)
+	log.Printf("serving %s on http://%s", *pkg, *addr)
+	log.Fatal(http.ListenAndServe(*addr, headers(mux)))
+}
+
+// headers sets what every response needs: no caching, and cross-origin
+// isolation for SharedArrayBuffer
+func headers(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Cross-Origin-Opener-Policy", "same-origin")
+		w.Header().Set("Cross-Origin-Embedder-Policy", "require-corp")
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) serveWasm(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	wasm := s.wasm
+	s.mu.Unlock()
+	if wasm == nil {
+		http.Error(w, "build failed", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/wasm")
+	http.ServeContent(w, r, loader.DefaultWasm, time.Time{}, bytes.NewReader(wasm))
+}
+
+func (s *server) serveStatic(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/" || r.URL.Path == "/index.html" {
+		if _, err := os.Stat(filepath.Join(*static, "index.html")); err != nil {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, loader.HTML(loader.Options{Title: *title}))
+			return
+		}
+	}
+	http.FileServer(http.Dir(*static)).ServeHTTP(w, r)
+}
+
+// serveEvents streams server-sent events to a page: "reload" after each
+// successful build, "error" with the output of a failed one
+func (s *server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	ch := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.clients[ch] = struct{}{}
+	version := s.version
+	if s.buildErr != "" {
+		ch <- struct{}{} // Show the current failure straight away
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, ch)
+		s.mu.Unlock()
+	}()
+
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+		}
+		s.mu.Lock()
+		v, buildErr := s.version, s.buildErr
+		s.mu.Unlock()
+		if buildErr != "" {
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", strings.Replace(buildErr, "\n", "\ndata: ", -1))
+		} else if v != version {
+			fmt.Fprint(w, "event: reload\ndata: \n\n")
+		}
+		version = v
+		flusher.Flush()
+	}
+}
+
+// build compiles the package, keeping the last good binary if it fails,
+// and tells the pages
+func (s *server) build() {
+	start := time.Now()
+	out := filepath.Join(s.dir, loader.DefaultWasm)
+	args := []string{"build", "-o", out}
+	if *tags != "" {
+		args = append(args, "-tags", *tags)
+	}
+	cmd := exec.Command("go", append(args, *pkg)...)
+	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	output, err := cmd.CombinedOutput()
+
+	s.mu.Lock()
+	if err != nil {
+		s.buildErr = strings.TrimSpace(string(output))
+		if s.buildErr == "" {
+			s.buildErr = err.Error()
+		}
+		log.Printf("build failed:\n%s", s.buildErr)
+	} else if wasm, err := ioutil.ReadFile(out); err != nil {
+		s.buildErr = err.Error()
+		log.Print(err)
+	} else {
+		s.wasm, s.buildErr = wasm, ""
+		s.version++
+		log.Printf("built %d bytes in %v", len(wasm), time.Since(start).Round(time.Millisecond))
+	}
+	for ch := range s.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	s.mu.Unlock()
+}
+
+// watch polls the sources and rebuilds when any change
+func (s *server) watch() {
+	last := snapshot(*watch)
+	for range time.Tick(*interval) {
+		if now := snapshot(*watch); now != last {
+			last = now
+			s.build()
+		}
+	}
+}
+
+// snapshot summarises the watched files' names, sizes and times, so any
+// change, addition or removal changes it
+func snapshot(root string) string {
+	var b strings.Builder
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if path != root && (strings.HasPrefix(name, ".") || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(name) {
+		case ".go", ".mod", ".sum", ".s":
+			fmt.Fprintf(&b, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		}
+		return nil
+	})
+	return b.String()
+}
+
+// reloadScript is appended to the loader: it reloads the page after a
+// rebuild and shows build errors over it
+const reloadScript = `
+(function () {
+"use strict";
+var overlay;
+var events = new EventSource("` + eventsPath + `");
+events.addEventListener("reload", function () {
+	location.reload();
+});
+events.addEventListener("error", function (e) {
+	if (!e.data) {
+		return; // The connection dropped; EventSource retries by itself
+	}
+	if (!overlay) {
+		overlay = document.createElement("pre");
+		overlay.style.cssText = "position:fixed;inset:0;margin:0;padding:1em;overflow:auto;" +
+			"background:rgba(30,30,40,0.95);color:#ff8080;font:13px monospace;z-index:2147483647";
+		document.body.appendChild(overlay);
+	}
+	overlay.textContent = e.data;
+});
+})();
+`
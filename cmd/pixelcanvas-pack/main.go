@@ -0,0 +1,251 @@
+// Command pixelcanvas-pack bundles a pixelcanvas app into a directory ready
+// to deploy to any static file server.
+//
+// Run it in the app's package directory:
+//
+//	pixelcanvas-pack -o dist -assets assets -sw
+//
+// It builds the package for js/wasm (or takes a prebuilt binary with
+// -wasm) and writes:
+//
+//	index.html          the page, from package loader or -html
+//	main.<hash>.wasm    the binary
+//	loader.<hash>.js    wasm_exec.js and the loader
+//	<asset>.<hash>.ext  each file under -assets, at the same relative path
+//	manifest.json       the loader.Manifest mapping asset names to the above
+//	sw.js               with -sw, a service worker precaching everything
+//
+// Every file but index.html, manifest.json and sw.js has its content hash
+// in its name, so it can be served with a far-future cache lifetime. With
+// -gzip (the default) and -brotli, compressible files also get .gz and .br
+// siblings for servers that serve precompressed files (nginx gzip_static,
+// Caddy precompressed and the like). Brotli needs the brotli command, as
+// the standard library has no encoder.
+package main
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lwayneh/pixelcanvas/loader"
+)
+
+const swFile = "sw.js"
+
+var (
+	out     = flag.String("o", "dist", "output directory")
+	pkg     = flag.String("pkg", ".", "package to build")
+	wasm    = flag.String("wasm", "", "prebuilt binary to pack instead of building -pkg")
+	assets  = flag.String("assets", "", "directory of assets to pack")
+	page    = flag.String("html", "", "page to use instead of the generated one; {{SCRIPT}} in it is replaced with the loader's URL")
+	title   = flag.String("title", "pixelcanvas", "title of the generated page")
+	tags    = flag.String("tags", "", "build tags")
+	ldflags = flag.String("ldflags", "-s -w", "linker flags for the build")
+	gz      = flag.Bool("gzip", true, "write gzip-compressed copies")
+	br      = flag.Bool("brotli", false, "write brotli-compressed copies (needs the brotli command)")
+	sw      = flag.Bool("sw", false, "generate a service worker for offline use")
+)
+
+// compressible are the extensions worth precompressing; images, audio and
+// fonts in modern formats are compressed already
+var compressible = map[string]bool{
+	".wasm": true, ".js": true, ".html": true, ".json": true, ".css": true,
+	".svg": true, ".txt": true, ".csv": true, ".xml": true, ".bmp": true,
+	".ttf": true, ".otf": true,
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("pixelcanvas-pack: ")
+	flag.Parse()
+
+	if *br {
+		if _, err := exec.LookPath("brotli"); err != nil {
+			log.Fatal("-brotli needs the brotli command in PATH")
+		}
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		log.Fatal(err)
+	}
+
+	bin, err := binary()
+	if err != nil {
+		log.Fatal(err)
+	}
+	m := &loader.Manifest{Assets: make(map[string]string)}
+	if m.Wasm, err = writeHashed("main.wasm", bin); err != nil {
+		log.Fatal(err)
+	}
+	if *assets != "" {
+		if err := packAssets(m); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	opts := loader.Options{Wasm: m.Wasm, Title: *title}
+	if *sw {
+		opts.ServiceWorker = swFile
+	}
+	script, err := loader.Bundle(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if m.Script, err = writeHashed(loader.DefaultScript, script); err != nil {
+		log.Fatal(err)
+	}
+	opts.Script = m.Script
+
+	html := []byte(loader.HTML(opts))
+	if *page != "" {
+		data, err := ioutil.ReadFile(*page)
+		if err != nil {
+			log.Fatal(err)
+		}
+		html = []byte(strings.Replace(string(data), "{{SCRIPT}}", m.Script, -1))
+	}
+	m.Version = version(m, html)
+	if err := write("index.html", html); err != nil {
+		log.Fatal(err)
+	}
+	manifest, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := write(loader.ManifestFile, manifest); err != nil {
+		log.Fatal(err)
+	}
+	if *sw {
+		if err := write(swFile, []byte(loader.ServiceWorker(m))); err != nil {
+			log.Fatal(err)
+		}
+	}
+	log.Printf("packed %s (%d assets, version %s) into %s", m.Wasm, len(m.Assets), m.Version, *out)
+}
+
+// binary builds the package, or reads -wasm
+func binary() ([]byte, error) {
+	if *wasm != "" {
+		return ioutil.ReadFile(*wasm)
+	}
+	tmp, err := ioutil.TempDir("", "pixelcanvas-pack")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	file := filepath.Join(tmp, "main.wasm")
+	args := []string{"build", "-trimpath", "-ldflags", *ldflags, "-o", file}
+	if *tags != "" {
+		args = append(args, "-tags", *tags)
+	}
+	cmd := exec.Command("go", append(args, *pkg)...)
+	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("build: %v", err)
+	}
+	return ioutil.ReadFile(file)
+}
+
+// packAssets copies every file under -assets with hashed names, recording
+// them in m
+func packAssets(m *loader.Manifest) error {
+	return filepath.Walk(*assets, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(*assets, file)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		m.Assets[name], err = writeHashed(name, data)
+		return err
+	})
+}
+
+// writeHashed writes data under name with its content hash before the
+// extension, returning the name used
+func writeHashed(name string, data []byte) (string, error) {
+	ext := path.Ext(name)
+	hashed := strings.TrimSuffix(name, ext) + "." + hash(data) + ext
+	return hashed, write(hashed, data)
+}
+
+// write writes a file to the output directory with its compressed copies
+func write(name string, data []byte) error {
+	file := filepath.Join(*out, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(file, data, 0644); err != nil {
+		return err
+	}
+	if !compressible[strings.ToLower(path.Ext(name))] || len(data) < 1024 {
+		return nil
+	}
+	if *gz {
+		if err := writeGzip(file, data); err != nil {
+			return err
+		}
+	}
+	if *br {
+		cmd := exec.Command("brotli", "--best", "--force", "--keep", "-o", file+".br", file)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("brotli %s: %v: %s", name, err, output)
+		}
+	}
+	return nil
+}
+
+func writeGzip(file string, data []byte) error {
+	f, err := os.Create(file + ".gz")
+	if err != nil {
+		return err
+	}
+	w, err := gzip.NewWriterLevel(f, gzip.BestCompression)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:5])
+}
+
+// version hashes the page and the manifest's names, which hold the content
+// hashes, so it changes with any file
+func version(m *loader.Manifest, html []byte) string {
+	names := []string{hash(html), m.Wasm, m.Script}
+	for name, hashed := range m.Assets {
+		names = append(names, name+"="+hashed)
+	}
+	sort.Strings(names[3:])
+	return hash([]byte(strings.Join(names, "\n")))
+}
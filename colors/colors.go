@@ -0,0 +1,145 @@
+// Package colors provides colour space conversions, palette loading,
+// nearest-colour quantization and dithering for pixel art built on pixelcanvas.
+package colors
+
+import (
+	"image/color"
+	"math"
+)
+
+// HSV is a colour in hue (degrees, 0-360), saturation, value and alpha (0-1).
+// It implements color.Color.
+type HSV struct {
+	H, S, V, A float64
+}
+
+// HSL is a colour in hue (degrees, 0-360), saturation, lightness and alpha (0-1).
+// It implements color.Color.
+type HSL struct {
+	H, S, L, A float64
+}
+
+// RGBA implements color.Color
+func (c HSV) RGBA() (r, g, b, a uint32) {
+	return premultiplied(hsvToRGB(c.H, c.S, c.V), c.A)
+}
+
+// RGBA implements color.Color
+func (c HSL) RGBA() (r, g, b, a uint32) {
+	return premultiplied(hslToRGB(c.H, c.S, c.L), c.A)
+}
+
+// ToHSV converts any colour to HSV
+func ToHSV(c color.Color) HSV {
+	r, g, b, a := unpremultiplied(c)
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+
+	h := hue(r, g, b, max, min)
+	s := 0.0
+	if max > 0 {
+		s = (max - min) / max
+	}
+	return HSV{H: h, S: s, V: max, A: a}
+}
+
+// ToHSL converts any colour to HSL
+func ToHSL(c color.Color) HSL {
+	r, g, b, a := unpremultiplied(c)
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+
+	h := hue(r, g, b, max, min)
+	l := (max + min) / 2
+	s := 0.0
+	if d := max - min; d > 0 {
+		s = d / (1 - math.Abs(2*l-1))
+	}
+	return HSL{H: h, S: s, L: l, A: a}
+}
+
+// ShiftHue rotates the hue of c by degrees, keeping saturation, value and alpha
+func ShiftHue(c color.Color, degrees float64) color.NRGBA {
+	hsv := ToHSV(c)
+	hsv.H = math.Mod(hsv.H+degrees+360, 360)
+	return color.NRGBAModel.Convert(hsv).(color.NRGBA)
+}
+
+func hue(r, g, b, max, min float64) float64 {
+	d := max - min
+	if d == 0 {
+		return 0
+	}
+	var h float64
+	switch max {
+	case r:
+		h = math.Mod((g-b)/d, 6)
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+func hsvToRGB(h, s, v float64) [3]float64 {
+	c := v * s
+	return chroma(h, c, v-c)
+}
+
+func hslToRGB(h, s, l float64) [3]float64 {
+	c := (1 - math.Abs(2*l-1)) * s
+	return chroma(h, c, l-c/2)
+}
+
+// chroma builds RGB from hue, chroma and the lightness offset m, shared by HSV and HSL
+func chroma(h, c, m float64) [3]float64 {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	hp := h / 60
+	x := c * (1 - math.Abs(math.Mod(hp, 2)-1))
+
+	var r, g, b float64
+	switch {
+	case hp < 1:
+		r, g, b = c, x, 0
+	case hp < 2:
+		r, g, b = x, c, 0
+	case hp < 3:
+		r, g, b = 0, c, x
+	case hp < 4:
+		r, g, b = 0, x, c
+	case hp < 5:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return [3]float64{r + m, g + m, b + m}
+}
+
+func unpremultiplied(c color.Color) (r, g, b, a float64) {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return float64(n.R) / 255, float64(n.G) / 255, float64(n.B) / 255, float64(n.A) / 255
+}
+
+func premultiplied(rgb [3]float64, a float64) (uint32, uint32, uint32, uint32) {
+	a = clamp01(a)
+	conv := func(v float64) uint32 { return uint32(clamp01(v)*a*0xffff + 0.5) }
+	return conv(rgb[0]), conv(rgb[1]), conv(rgb[2]), uint32(a*0xffff + 0.5)
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,124 @@
+package colors
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-3 }
+
+func TestToHSV(t *testing.T) {
+	tests := []struct {
+		in   color.Color
+		want HSV
+	}{
+		{color.NRGBA{0, 0, 0, 255}, HSV{0, 0, 0, 1}},
+		{color.NRGBA{255, 255, 255, 255}, HSV{0, 0, 1, 1}},
+		{color.NRGBA{255, 0, 0, 255}, HSV{0, 1, 1, 1}},
+		{color.NRGBA{0, 255, 0, 255}, HSV{120, 1, 1, 1}},
+		{color.NRGBA{0, 0, 255, 255}, HSV{240, 1, 1, 1}},
+		{color.NRGBA{255, 255, 0, 255}, HSV{60, 1, 1, 1}},
+		{color.NRGBA{255, 0, 255, 255}, HSV{300, 1, 1, 1}},
+		{color.NRGBA{255, 0, 0, 51}, HSV{0, 1, 1, 0.2}},
+		{color.RGBA{51, 0, 0, 51}, HSV{0, 1, 1, 0.2}}, // Premultiplied input
+	}
+	for _, tt := range tests {
+		got := ToHSV(tt.in)
+		if !near(got.H, tt.want.H) || !near(got.S, tt.want.S) || !near(got.V, tt.want.V) || !near(got.A, tt.want.A) {
+			t.Errorf("ToHSV(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestToHSL(t *testing.T) {
+	tests := []struct {
+		in   color.Color
+		want HSL
+	}{
+		{color.NRGBA{0, 0, 0, 255}, HSL{0, 0, 0, 1}},
+		{color.NRGBA{255, 255, 255, 255}, HSL{0, 0, 1, 1}},
+		{color.NRGBA{255, 0, 0, 255}, HSL{0, 1, 0.5, 1}},
+		{color.NRGBA{0, 255, 255, 255}, HSL{180, 1, 0.5, 1}},
+		{color.NRGBA{128, 128, 128, 255}, HSL{0, 0, 128.0 / 255, 1}},
+	}
+	for _, tt := range tests {
+		got := ToHSL(tt.in)
+		if !near(got.H, tt.want.H) || !near(got.S, tt.want.S) || !near(got.L, tt.want.L) || !near(got.A, tt.want.A) {
+			t.Errorf("ToHSL(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []color.NRGBA{
+		{0, 0, 0, 255},
+		{255, 255, 255, 255},
+		{12, 200, 99, 255},
+		{250, 128, 3, 255},
+		{90, 40, 170, 128},
+	}
+	for _, c := range tests {
+		if got := color.NRGBAModel.Convert(ToHSV(c)).(color.NRGBA); got != c {
+			t.Errorf("HSV round trip of %v = %v", c, got)
+		}
+		if got := color.NRGBAModel.Convert(ToHSL(c)).(color.NRGBA); got != c {
+			t.Errorf("HSL round trip of %v = %v", c, got)
+		}
+	}
+}
+
+func TestShiftHue(t *testing.T) {
+	tests := []struct {
+		in      color.NRGBA
+		degrees float64
+		want    color.NRGBA
+	}{
+		{color.NRGBA{255, 0, 0, 255}, 120, color.NRGBA{0, 255, 0, 255}},
+		{color.NRGBA{255, 0, 0, 255}, -120, color.NRGBA{0, 0, 255, 255}},
+		{color.NRGBA{255, 0, 0, 255}, 360, color.NRGBA{255, 0, 0, 255}},
+		{color.NRGBA{128, 128, 128, 255}, 90, color.NRGBA{128, 128, 128, 255}},
+	}
+	for _, tt := range tests {
+		if got := ShiftHue(tt.in, tt.degrees); got != tt.want {
+			t.Errorf("ShiftHue(%v, %v) = %v, want %v", tt.in, tt.degrees, got, tt.want)
+		}
+	}
+}
+
+func TestParseHex(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    color.NRGBA
+		wantErr bool
+	}{
+		{"#ff8000", color.NRGBA{255, 128, 0, 255}, false},
+		{"ff8000", color.NRGBA{255, 128, 0, 255}, false},
+		{"#f80", color.NRGBA{255, 136, 0, 255}, false},
+		{"#11223344", color.NRGBA{0x11, 0x22, 0x33, 0x44}, false},
+		{"#12345", color.NRGBA{}, true},
+		{"#gg0000", color.NRGBA{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseHex(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseHex(%q) = %v, %v, want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestHex(t *testing.T) {
+	tests := []struct {
+		in   color.Color
+		want string
+	}{
+		{color.NRGBA{255, 128, 0, 255}, "#ff8000"},
+		{color.NRGBA{0x11, 0x22, 0x33, 0x44}, "#11223344"},
+		{color.RGBA{0, 0, 0, 0}, "#00000000"},
+	}
+	for _, tt := range tests {
+		if got := Hex(tt.in); got != tt.want {
+			t.Errorf("Hex(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package colors
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Dither selects how true-colour images are reduced to a palette
+type Dither int
+
+// Dithering modes
+const (
+	DitherNone           Dither = iota // Plain nearest colour
+	DitherFloydSteinberg               // Error diffusion, best for photos
+	DitherOrdered4                     // 4x4 Bayer matrix, stable between animation frames
+	DitherOrdered8                     // 8x8 Bayer matrix, finer pattern
+)
+
+var bayer4 = [16]int{
+	0, 8, 2, 10,
+	12, 4, 14, 6,
+	3, 11, 1, 9,
+	15, 7, 13, 5,
+}
+
+var bayer8 = [64]int{
+	0, 32, 8, 40, 2, 34, 10, 42,
+	48, 16, 56, 24, 50, 18, 58, 26,
+	12, 44, 4, 36, 14, 46, 6, 38,
+	60, 28, 52, 20, 62, 30, 54, 22,
+	3, 35, 11, 43, 1, 33, 9, 41,
+	51, 19, 59, 27, 49, 17, 57, 25,
+	15, 47, 7, 39, 13, 45, 5, 37,
+	63, 31, 55, 23, 61, 29, 53, 21,
+}
+
+// Quantize reduces src to the palette using the given dithering mode.
+// image.Paletted holds at most 256 colours, so larger palettes are truncated.
+func Quantize(src image.Image, p *Palette, mode Dither) *image.Paletted {
+	b := src.Bounds()
+	cp := p.ColorPalette()
+	if len(cp) > 256 {
+		cp = cp[:256]
+		p = &Palette{Colors: p.Colors[:256]}
+	}
+	dst := image.NewPaletted(b, cp)
+
+	switch mode {
+	case DitherFloydSteinberg:
+		draw.FloydSteinberg.Draw(dst, b, src, b.Min)
+	case DitherOrdered4:
+		ordered(dst, src, p, bayer4[:], 4)
+	case DitherOrdered8:
+		ordered(dst, src, p, bayer8[:], 8)
+	default:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				dst.SetColorIndex(x, y, uint8(p.Nearest(src.At(x, y))))
+			}
+		}
+	}
+	return dst
+}
+
+// ordered applies a Bayer threshold matrix before the nearest-colour lookup.
+// The spread is scaled to the palette size so small palettes get stronger
+// patterns. The matrix is anchored to the image's top left corner.
+func ordered(dst *image.Paletted, src image.Image, p *Palette, matrix []int, n int) {
+	spread := 255.0 / float64(len(p.Colors)+1)
+	cells := float64(n * n)
+
+	b := src.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(src.At(x, y)).(color.NRGBA)
+			t := (float64(matrix[(y-b.Min.Y)%n*n+(x-b.Min.X)%n])+0.5)/cells - 0.5
+			off := int(t * spread)
+			c.R = clampByte(int(c.R) + off)
+			c.G = clampByte(int(c.G) + off)
+			c.B = clampByte(int(c.B) + off)
+			dst.SetColorIndex(x, y, uint8(p.Nearest(c)))
+		}
+	}
+}
+
+func clampByte(v int) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
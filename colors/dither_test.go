@@ -0,0 +1,47 @@
+package colors
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestQuantizeOffset(t *testing.T) {
+	p := &Palette{Colors: []color.NRGBA{{0, 0, 0, 255}, {255, 255, 255, 255}}}
+	gradient := func(r image.Rectangle) *image.NRGBA {
+		img := image.NewNRGBA(r)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				v := uint8((x - r.Min.X) * 255 / (r.Dx() - 1))
+				img.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+			}
+		}
+		return img
+	}
+	tests := []struct {
+		name string
+		mode Dither
+	}{
+		{"none", DitherNone},
+		{"Floyd-Steinberg", DitherFloydSteinberg},
+		{"ordered 4", DitherOrdered4},
+		{"ordered 8", DitherOrdered8},
+	}
+	for _, tt := range tests {
+		base := Quantize(gradient(image.Rect(0, 0, 19, 11)), p, tt.mode)
+		for _, off := range []image.Point{{-5, -3}, {-17, 2}, {6, -9}} {
+			got := Quantize(gradient(image.Rect(0, 0, 19, 11).Add(off)), p, tt.mode)
+			if got.Bounds() != base.Bounds().Add(off) {
+				t.Errorf("%s at %v: bounds %v", tt.name, off, got.Bounds())
+				continue
+			}
+			for y := 0; y < 11; y++ {
+				for x := 0; x < 19; x++ {
+					if got.ColorIndexAt(x+off.X, y+off.Y) != base.ColorIndexAt(x, y) {
+						t.Fatalf("%s at %v: pixel %d,%d differs from the image at the origin", tt.name, off, x, y)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package colors
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Palette is an ordered list of opaque or translucent colours
+type Palette struct {
+	Name   string
+	Colors []color.NRGBA
+}
+
+// ErrEmptyPalette is returned when a palette source contains no colours
+var ErrEmptyPalette = errors.New("colors: palette has no colours")
+
+// Len returns the number of colours
+func (p *Palette) Len() int {
+	return len(p.Colors)
+}
+
+// Nearest returns the index of the palette colour closest to c, using a
+// weighted RGB distance that roughly follows perceived brightness.
+func (p *Palette) Nearest(c color.Color) int {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	best, bestDist := 0, int64(-1)
+	for i, pc := range p.Colors {
+		d := distance(n, pc)
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+			if d == 0 {
+				break
+			}
+		}
+	}
+	return best
+}
+
+// Convert returns the palette colour closest to c, implementing color.Model
+func (p *Palette) Convert(c color.Color) color.Color {
+	if len(p.Colors) == 0 {
+		return c
+	}
+	return p.Colors[p.Nearest(c)]
+}
+
+// Add appends c unless the palette already holds exactly that colour, and
+// returns its index either way, e.g. for colours picked with an eyedropper
+func (p *Palette) Add(c color.Color) int {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	for i, pc := range p.Colors {
+		if pc == n {
+			return i
+		}
+	}
+	p.Colors = append(p.Colors, n)
+	return len(p.Colors) - 1
+}
+
+// ColorPalette returns the palette as a color.Palette, for use with
+// image.Paletted and the image/gif encoder.
+func (p *Palette) ColorPalette() color.Palette {
+	cp := make(color.Palette, len(p.Colors))
+	for i, c := range p.Colors {
+		cp[i] = c
+	}
+	return cp
+}
+
+// distance is a squared "redmean" distance between two colours, with alpha
+func distance(a, b color.NRGBA) int64 {
+	rm := (int64(a.R) + int64(b.R)) / 2
+	dr := int64(a.R) - int64(b.R)
+	dg := int64(a.G) - int64(b.G)
+	db := int64(a.B) - int64(b.B)
+	da := int64(a.A) - int64(b.A)
+	return ((512+rm)*dr*dr)>>8 + 4*dg*dg + ((767-rm)*db*db)>>8 + 3*da*da
+}
+
+// ParseGPL reads a GIMP palette (.gpl) file
+func ParseGPL(r io.Reader) (*Palette, error) {
+	p := &Palette{}
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		switch {
+		case line == 1:
+			if text != "GIMP Palette" {
+				return nil, errors.New("colors: missing GIMP Palette header")
+			}
+			continue
+		case text == "" || strings.HasPrefix(text, "#"):
+			continue
+		case strings.HasPrefix(text, "Name:"):
+			p.Name = strings.TrimSpace(strings.TrimPrefix(text, "Name:"))
+			continue
+		case strings.HasPrefix(text, "Columns:"):
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("colors: bad GPL entry on line %d", line)
+		}
+		var rgb [3]uint8
+		for i := 0; i < 3; i++ {
+			v, err := strconv.ParseUint(fields[i], 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("colors: bad GPL entry on line %d: %v", line, err)
+			}
+			rgb[i] = uint8(v)
+		}
+		p.Colors = append(p.Colors, color.NRGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 255})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(p.Colors) == 0 {
+		return nil, ErrEmptyPalette
+	}
+	return p, nil
+}
+
+// ParseHexList reads one colour per line as RRGGBB or RRGGBBAA, with or
+// without a leading '#', as exported by Lospec and most palette sites.
+// Blank lines and lines starting with ';' or '//' are skipped.
+func ParseHexList(r io.Reader) (*Palette, error) {
+	p := &Palette{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, ";") || strings.HasPrefix(text, "//") {
+			continue
+		}
+		c, err := ParseHex(text)
+		if err != nil {
+			return nil, err
+		}
+		p.Colors = append(p.Colors, c)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(p.Colors) == 0 {
+		return nil, ErrEmptyPalette
+	}
+	return p, nil
+}
+
+// ParseHex parses a single #RRGGBB, #RRGGBBAA or #RGB colour
+func ParseHex(s string) (color.NRGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) == 6 {
+		s += "ff"
+	}
+	if len(s) != 8 {
+		return color.NRGBA{}, fmt.Errorf("colors: bad hex colour %q", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("colors: bad hex colour %q", s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// Hex formats a colour as #RRGGBB, or #RRGGBBAA when it is not opaque
+func Hex(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if n.A == 255 {
+		return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", n.R, n.G, n.B, n.A)
+}
+
+// FromImage builds a palette from the distinct colours of an image in reading
+// order, which is how palette "strips" (1px per colour PNGs) are laid out.
+// Fully transparent pixels are skipped.
+func FromImage(img image.Image) (*Palette, error) {
+	p := &Palette{}
+	seen := make(map[color.NRGBA]bool)
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A == 0 || seen[c] {
+				continue
+			}
+			seen[c] = true
+			p.Colors = append(p.Colors, c)
+		}
+	}
+	if len(p.Colors) == 0 {
+		return nil, ErrEmptyPalette
+	}
+	return p, nil
+}
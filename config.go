@@ -0,0 +1,265 @@
+package pixelcanvas
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lwayneh/pixelcanvas/colors"
+)
+
+// Configuration
+//
+// A Config describes an app's canvas setup in JSON, embedded in the binary
+// or fetched at startup, so it can be tuned without recompiling:
+//
+//	{
+//	  "element": "#game", "width": 320, "height": 180,
+//	  "scale": 3, "pixelated": true,
+//	  "fps": 60, "catchUp": "clamp", "resize": "scale",
+//	  "clear": "always", "clearColor": "#1d2b53",
+//	  "assets": {"tiles": "img/tiles.png"},
+//	  "bindings": {"jump": ["key:Space", "pad:0"]},
+//	  "layers": [{"name": "background"}, {"name": "ink", "blend": "multiply"}],
+//	  "app": {"anything": "the app wants"}
+//	}
+//
+// Enumerated settings are the lower case names of their constants.
+
+// Config is an app configuration
+type Config struct {
+	Element string `json:"element,omitempty"` // CSS selector of an existing canvas. Empty creates one
+	Width   int    `json:"width,omitempty"`   // Resolution. 0 fills the window
+	Height  int    `json:"height,omitempty"`
+
+	Scale     float64 `json:"scale,omitempty"` // Display size as a multiple of the resolution. 0 leaves the CSS size alone
+	Pixelated bool    `json:"pixelated,omitempty"`
+
+	FPS        float64 `json:"fps,omitempty"` // 0 for 60
+	VSync      bool    `json:"vsync,omitempty"`
+	CatchUp    string  `json:"catchUp,omitempty"`    // none, skip, clamp, halfrate
+	MaxDeltaMS float64 `json:"maxDeltaMs,omitempty"` // 0 for DefaultMaxDelta
+	Resize     string  `json:"resize,omitempty"`     // keep, scale, clear
+	Clear      string  `json:"clear,omitempty"`      // never, always, ondemand
+	ClearColor string  `json:"clearColor,omitempty"` // #RRGGBB or #RRGGBBAA. Empty is transparent
+
+	Assets   map[string]string `json:"assets,omitempty"`   // Name to URL, see LoadAssets
+	Bindings json.RawMessage   `json:"bindings,omitempty"` // As saved by ActionMap, see Actions
+	Layers   []LayerConfig     `json:"layers,omitempty"`   // See LayerDocument
+
+	// App holds the app's own settings, for it to decode as it likes
+	App json.RawMessage `json:"app,omitempty"`
+}
+
+// LayerConfig describes one layer of a LayerDocument
+type LayerConfig struct {
+	Name    string   `json:"name"`
+	Opacity *float64 `json:"opacity,omitempty"` // Omitted for 1
+	Blend   string   `json:"blend,omitempty"`   // normal, multiply, screen, overlay, darken, lighten, add
+	Hidden  bool     `json:"hidden,omitempty"`
+}
+
+// Named values of the enumerated settings
+var (
+	configCatchUp = map[string]CatchUp{
+		"none": CatchUpNone, "skip": CatchUpSkip, "clamp": CatchUpClamp, "halfrate": CatchUpHalfRate,
+	}
+	configResize = map[string]ResizeMode{
+		"keep": ResizeKeep, "scale": ResizeScale, "clear": ResizeClear,
+	}
+	configClear = map[string]ClearPolicy{
+		"never": ClearNever, "always": ClearAlways, "ondemand": ClearOnDemand,
+	}
+	configBlend = map[string]BlendMode{
+		"normal": BlendNormal, "multiply": BlendMultiply, "screen": BlendScreen, "overlay": BlendOverlay,
+		"darken": BlendDarken, "lighten": BlendLighten, "add": BlendAdd,
+	}
+)
+
+// ParseConfig decodes and checks a JSON configuration
+func ParseConfig(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("pixelcanvas: config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// FetchConfig fetches and parses a configuration. It blocks, so call it
+// from a goroutine; c need not have a canvas yet.
+func (c *Canvasp) FetchConfig(url string) (*Config, error) {
+	data, err := c.FetchBytes(url)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data)
+}
+
+// Validate checks the enumerated settings and colours
+func (cfg *Config) Validate() error {
+	bad := func(setting, value string) error {
+		return fmt.Errorf("pixelcanvas: config: unknown %s %q", setting, value)
+	}
+	if _, ok := configCatchUp[strings.ToLower(cfg.CatchUp)]; cfg.CatchUp != "" && !ok {
+		return bad("catchUp", cfg.CatchUp)
+	}
+	if _, ok := configResize[strings.ToLower(cfg.Resize)]; cfg.Resize != "" && !ok {
+		return bad("resize", cfg.Resize)
+	}
+	if _, ok := configClear[strings.ToLower(cfg.Clear)]; cfg.Clear != "" && !ok {
+		return bad("clear", cfg.Clear)
+	}
+	if cfg.ClearColor != "" {
+		if _, err := colors.ParseHex(cfg.ClearColor); err != nil {
+			return fmt.Errorf("pixelcanvas: config: clearColor: %v", err)
+		}
+	}
+	for _, l := range cfg.Layers {
+		if _, ok := configBlend[strings.ToLower(l.Blend)]; l.Blend != "" && !ok {
+			return bad("blend", l.Blend)
+		}
+	}
+	if cfg.Width < 0 || cfg.Height < 0 || cfg.FPS < 0 {
+		return fmt.Errorf("pixelcanvas: config: negative size or fps")
+	}
+	return nil
+}
+
+// NewCanvaspFromConfig creates a canvas as cfg describes: on the element
+// it names or a new one, at its resolution, with its settings applied
+func NewCanvaspFromConfig(cfg *Config) (*Canvasp, error) {
+	c, err := NewCanvasp(false)
+	if err != nil {
+		return nil, err
+	}
+	w, h := cfg.Width, cfg.Height
+	if w == 0 || h == 0 {
+		w, h = c.window.Get("innerWidth").Int(), c.window.Get("innerHeight").Int()
+	}
+	if cfg.Element != "" {
+		el := c.doc.Call("querySelector", cfg.Element)
+		if el.IsNull() {
+			return nil, fmt.Errorf("pixelcanvas: config: no element matches %q", cfg.Element)
+		}
+		el.Set("width", w)
+		el.Set("height", h)
+		c.Set(el, w, h)
+	} else {
+		c.Create(w, h)
+	}
+	if err := cfg.Apply(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Apply applies the runtime settings (everything but the element, assets,
+// bindings and layers) to c. The FPS takes effect from the next Start, or
+// at once if already running.
+func (cfg *Config) Apply(c *Canvasp) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if cfg.Scale > 0 {
+		c.SetDisplaySize(float64(c.width)*cfg.Scale, float64(c.height)*cfg.Scale)
+	}
+	c.SetPixelated(cfg.Pixelated)
+
+	c.SetFPS(cfg.fps())
+	c.SetVSync(cfg.VSync)
+	c.SetCatchUp(configCatchUp[strings.ToLower(cfg.CatchUp)], time.Duration(cfg.MaxDeltaMS*float64(time.Millisecond)))
+	c.SetResizeMode(configResize[strings.ToLower(cfg.Resize)])
+	c.SetClearPolicy(configClear[strings.ToLower(cfg.Clear)])
+	if cfg.ClearColor != "" {
+		col, _ := colors.ParseHex(cfg.ClearColor)
+		c.SetClearColor(col)
+	} else {
+		c.SetClearColor(nil)
+	}
+	return nil
+}
+
+func (cfg *Config) fps() float64 {
+	if cfg.FPS <= 0 {
+		return 60
+	}
+	return cfg.FPS
+}
+
+// Start starts c at the configured FPS
+func (cfg *Config) Start(c *Canvasp, rf RenderFunc) {
+	c.Start(cfg.fps(), rf)
+}
+
+// Actions creates an ActionMap with the configured bindings
+func (cfg *Config) Actions(c *Canvasp) (*ActionMap, error) {
+	m := c.NewActionMap()
+	if len(cfg.Bindings) > 0 {
+		if err := m.UnmarshalJSON(cfg.Bindings); err != nil {
+			m.Remove()
+			return nil, fmt.Errorf("pixelcanvas: config: bindings: %v", err)
+		}
+	}
+	return m, nil
+}
+
+// LayerDocument creates a LayerDocument with the configured layers, bottom
+// first. The first layer holds the current canvas contents. Without any
+// layers configured the document has its usual single layer.
+func (cfg *Config) LayerDocument(c *Canvasp) *LayerDocument {
+	d := c.NewLayerDocument()
+	for i, lc := range cfg.Layers {
+		l := d.Layers[0]
+		if i > 0 {
+			l = d.AddLayer(lc.Name)
+		}
+		l.Name = lc.Name
+		l.Blend = configBlend[strings.ToLower(lc.Blend)]
+		l.Hidden = lc.Hidden
+		if lc.Opacity != nil {
+			l.Opacity = *lc.Opacity
+		}
+	}
+	d.SetActive(0)
+	d.Update()
+	return d
+}
+
+// LoadAssets fetches every asset in parallel, returning their bytes by
+// name. It blocks, so call it from a goroutine. The first error is
+// returned, with whatever assets did load.
+func (cfg *Config) LoadAssets(c *Canvasp) (map[string][]byte, error) {
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		first error
+		done  int
+	)
+	out := make(map[string][]byte, len(cfg.Assets))
+	Progress(0, len(cfg.Assets), "assets")
+	for name, url := range cfg.Assets {
+		wg.Add(1)
+		go func(name, url string) {
+			defer wg.Done()
+			data, err := c.FetchBytes(url)
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			Progress(done, len(cfg.Assets), "assets")
+			if err != nil {
+				if first == nil {
+					first = fmt.Errorf("pixelcanvas: config: asset %s: %v", name, err)
+				}
+				return
+			}
+			out[name] = data
+		}(name, url)
+	}
+	wg.Wait()
+	return out, first
+}
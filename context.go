@@ -0,0 +1,27 @@
+package pixelcanvas
+
+import (
+	"context"
+)
+
+// StartContext is Start tied to a context. When ctx is canceled the render
+// loop stops and the package's event listeners, observers and in-flight
+// fetches are shut down; the canvas element and its contents are left in
+// place. Stopping the canvas directly also ends the context watch.
+func (c *Canvasp) StartContext(ctx context.Context, maxFPS float64, rf RenderFunc) {
+	c.Start(maxFPS, rf)
+
+	done := c.done
+	go func() {
+		select {
+		case <-ctx.Done():
+			select {
+			case <-done: // Already stopped or restarted by hand
+			default:
+				c.log().Info("context canceled, shutting down", "err", ctx.Err())
+				c.shutdown()
+			}
+		case <-done:
+		}
+	}()
+}
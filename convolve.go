@@ -0,0 +1,178 @@
+package pixelcanvas
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// EdgeMode selects what a convolution reads beyond the edges of the area
+// being filtered
+type EdgeMode int
+
+// Edge modes
+const (
+	EdgeClamp  EdgeMode = iota // Repeat the edge pixels
+	EdgeWrap                   // Read from the opposite edge, for tiling textures
+	EdgeMirror                 // Reflect about the edge pixels
+)
+
+// Convolve filters area r of the shadow canvas (the zero Rect for the whole
+// canvas) with kernel, and returns the area changed. kernel is written as
+// it looks, its first row the top, and its centre is the pixel being
+// computed, so it should have odd dimensions. Pixels outside r are never
+// read: edge decides what lies beyond it. Separable kernels (e.g. a
+// gaussian) are detected and run as two one dimensional passes.
+func (c *Canvasp) Convolve(r pixel.Rect, kernel [][]float64, edge EdgeMode) pixel.Rect {
+	return c.filter(r, func(s *filterScratch, pix []uint8, w, h int) {
+		s.convolve(pix, w, h, kernel, edge)
+	})
+}
+
+// Convolve filters the Region in place, as Canvasp.Convolve
+func (reg *Region) Convolve(kernel [][]float64, edge EdgeMode) {
+	s := getScratch()
+	defer putScratch(s)
+	s.convolve(reg.Pix, reg.Width, reg.Height, kernel, edge)
+}
+
+func (s *filterScratch) convolve(pix []uint8, w, h int, kernel [][]float64, edge EdgeMode) {
+	kh := len(kernel)
+	if kh == 0 || len(kernel[0]) == 0 || w == 0 || h == 0 {
+		return
+	}
+	kw := len(kernel[0])
+
+	orig := scratch(&s.orig, len(pix))
+	copy(orig, pix)
+	if col, row, ok := separate(kernel); ok {
+		if cap(s.acc) < len(pix) {
+			s.acc = make([]float32, len(pix))
+		}
+		acc := s.acc[:len(pix)]
+		// Rows into acc, then columns back into pix. Kernel rows go top to
+		// bottom, buffer rows bottom to top, hence the negated offset.
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				var sum [4]float64
+				for i, k := range row {
+					p := (y*w + edgeIndex(x+i-kw/2, w, edge)) * 4
+					sum[0] += k * float64(orig[p])
+					sum[1] += k * float64(orig[p+1])
+					sum[2] += k * float64(orig[p+2])
+					sum[3] += k * float64(orig[p+3])
+				}
+				d := (y*w + x) * 4
+				acc[d], acc[d+1], acc[d+2], acc[d+3] = float32(sum[0]), float32(sum[1]), float32(sum[2]), float32(sum[3])
+			}
+		}
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				var sum [4]float64
+				for j, k := range col {
+					p := (edgeIndex(y-j+kh/2, h, edge)*w + x) * 4
+					sum[0] += k * float64(acc[p])
+					sum[1] += k * float64(acc[p+1])
+					sum[2] += k * float64(acc[p+2])
+					sum[3] += k * float64(acc[p+3])
+				}
+				storeConvolved(pix[(y*w+x)*4:], sum)
+			}
+		}
+		return
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum [4]float64
+			for j, krow := range kernel {
+				yy := edgeIndex(y-j+kh/2, h, edge)
+				for i, k := range krow {
+					if k == 0 {
+						continue
+					}
+					p := (yy*w + edgeIndex(x+i-kw/2, w, edge)) * 4
+					sum[0] += k * float64(orig[p])
+					sum[1] += k * float64(orig[p+1])
+					sum[2] += k * float64(orig[p+2])
+					sum[3] += k * float64(orig[p+3])
+				}
+			}
+			storeConvolved(pix[(y*w+x)*4:], sum)
+		}
+	}
+}
+
+// storeConvolved writes a premultiplied result, clamped so no colour
+// channel exceeds alpha
+func storeConvolved(px []uint8, sum [4]float64) {
+	a := math.Max(0, math.Min(255, sum[3]))
+	px[3] = uint8(a + 0.5)
+	for ch := 0; ch < 3; ch++ {
+		px[ch] = uint8(math.Max(0, math.Min(a, sum[ch])) + 0.5)
+	}
+}
+
+// separate splits a rank one kernel into a column and a row whose outer
+// product it is, reporting false if the kernel isn't separable
+func separate(kernel [][]float64) (col []float64, row []float64, ok bool) {
+	// Pivot on the largest element
+	pi, pj, big := 0, 0, 0.0
+	for i, r := range kernel {
+		if len(r) != len(kernel[0]) {
+			return nil, nil, false
+		}
+		for j, k := range r {
+			if math.Abs(k) > big {
+				pi, pj, big = i, j, math.Abs(k)
+			}
+		}
+	}
+	if big == 0 || len(kernel) == 1 && len(kernel[0]) == 1 {
+		return nil, nil, false
+	}
+
+	p := kernel[pi][pj]
+	col = make([]float64, len(kernel))
+	row = append([]float64(nil), kernel[pi]...)
+	for i := range kernel {
+		col[i] = kernel[i][pj] / p
+	}
+	for i, r := range kernel {
+		for j, k := range r {
+			if math.Abs(col[i]*row[j]-k) > 1e-9*big {
+				return nil, nil, false
+			}
+		}
+	}
+	return col, row, true
+}
+
+// edgeIndex maps coordinate i, which may be outside 0..n-1, back inside
+func edgeIndex(i int, n int, edge EdgeMode) int {
+	if i >= 0 && i < n {
+		return i
+	}
+	switch edge {
+	case EdgeWrap:
+		i %= n
+		if i < 0 {
+			i += n
+		}
+		return i
+	case EdgeMirror:
+		if n == 1 {
+			return 0
+		}
+		period := 2 * (n - 1)
+		i %= period
+		if i < 0 {
+			i += period
+		}
+		if i >= n {
+			i = period - i
+		}
+		return i
+	}
+	return clampInt(i, 0, n-1)
+}
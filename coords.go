@@ -0,0 +1,217 @@
+package pixelcanvas
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Coordinate conventions
+//
+// The shadow canvas uses pixel's convention: the origin is the bottom left
+// and y increases upwards, and its rows are stored bottom-up. The DOM canvas,
+// ImageData and pointer events use the opposite: origin at the top left with
+// y increasing downwards. imgCopy flips the rows so the picture appears the
+// right way up.
+//
+// The package's own API works in logical coordinates, chosen with
+// SetCoordinates: bottom-left or top-left origin, in pixels or normalized
+// so the canvas is 1 x 1. Pointer positions from FromClient, the Camera and
+// the drawing helpers (DrawLine, Copy, Paste, Clear, the filters and so on)
+// all take and return logical coordinates, so an app picks the convention
+// it thinks in once. The default, bottom-left in pixels, is the shadow
+// canvas's own, and drawing through pixelgl directly stays in it: use
+// CoordMatrix (or a Camera's Matrix, which includes it) with SetMatrix to
+// draw there in logical coordinates too. ToCanvas and FromCanvas convert
+// between the two.
+
+// Origin is the corner logical coordinates are measured from
+type Origin int
+
+// Origins
+const (
+	OriginBottomLeft Origin = iota // y increases upwards, as in pixelgl
+	OriginTopLeft                  // y increases downwards, as in the DOM
+)
+
+// Units are what logical coordinates are measured in
+type Units int
+
+// Units
+const (
+	UnitsPixels     Units = iota // Canvas pixels
+	UnitsNormalized              // Fractions of the canvas size, 0 to 1 on each axis
+)
+
+type coordSystem struct {
+	origin Origin
+	units  Units
+}
+
+// SetCoordinates chooses the origin and units of logical coordinates. Call
+// it before Start, along with anything else that sets up positions: it
+// doesn't move what has already been placed, such as action zones or a
+// Camera.
+func (c *Canvasp) SetCoordinates(origin Origin, units Units) {
+	c.coords = coordSystem{origin: origin, units: units}
+}
+
+// Coordinates returns the origin and units set by SetCoordinates
+func (c *Canvasp) Coordinates() (Origin, Units) {
+	return c.coords.origin, c.coords.units
+}
+
+// LogicalSize returns the canvas size in logical units: its size in pixels,
+// or 1 x 1 when normalized
+func (c *Canvasp) LogicalSize() pixel.Vec {
+	if c.coords.units == UnitsNormalized {
+		return pixel.V(1, 1)
+	}
+	return pixel.V(float64(c.width), float64(c.height))
+}
+
+// identityCoords reports whether logical and canvas coordinates are the same
+func (c *Canvasp) identityCoords() bool {
+	return c.coords == coordSystem{}
+}
+
+// CoordMatrix returns the logical -> shadow canvas transform, for SetMatrix
+// on the shadow canvas when drawing through pixelgl in logical coordinates.
+// Sprites drawn through it with a top-left origin come out upside down
+// unless their own matrix flips them back.
+func (c *Canvasp) CoordMatrix() pixel.Matrix {
+	scale := pixel.V(1, 1)
+	if c.coords.units == UnitsNormalized {
+		scale = pixel.V(float64(c.width), float64(c.height))
+	}
+	m := pixel.IM.ScaledXY(pixel.ZV, scale)
+	if c.coords.origin == OriginTopLeft {
+		m = m.ScaledXY(pixel.ZV, pixel.V(1, -1)).Moved(pixel.V(0, float64(c.height)))
+	}
+	return m
+}
+
+// ToCanvas converts a logical position to shadow canvas pixels (origin
+// bottom left)
+func (c *Canvasp) ToCanvas(v pixel.Vec) pixel.Vec {
+	if c.coords.units == UnitsNormalized {
+		v = pixel.V(v.X*float64(c.width), v.Y*float64(c.height))
+	}
+	if c.coords.origin == OriginTopLeft {
+		v.Y = float64(c.height) - v.Y
+	}
+	return v
+}
+
+// FromCanvas converts a position in shadow canvas pixels to logical
+// coordinates
+func (c *Canvasp) FromCanvas(v pixel.Vec) pixel.Vec {
+	if c.coords.origin == OriginTopLeft {
+		v.Y = float64(c.height) - v.Y
+	}
+	if c.coords.units == UnitsNormalized && c.width > 0 && c.height > 0 {
+		v = pixel.V(v.X/float64(c.width), v.Y/float64(c.height))
+	}
+	return v
+}
+
+// ToCanvasRect converts a logical rectangle to shadow canvas pixels. The
+// zero Rect, which helpers take to mean the whole canvas, stays zero.
+func (c *Canvasp) ToCanvasRect(r pixel.Rect) pixel.Rect {
+	if r == (pixel.Rect{}) || c.identityCoords() {
+		return r
+	}
+	return pixel.Rect{Min: c.ToCanvas(r.Min), Max: c.ToCanvas(r.Max)}.Norm()
+}
+
+// FromCanvasRect converts a rectangle in shadow canvas pixels, such as one
+// returned by drawing straight to the shadow canvas, to logical
+// coordinates. The zero Rect stays zero.
+func (c *Canvasp) FromCanvasRect(r pixel.Rect) pixel.Rect {
+	if r == (pixel.Rect{}) || c.identityCoords() {
+		return r
+	}
+	return pixel.Rect{Min: c.FromCanvas(r.Min), Max: c.FromCanvas(r.Max)}.Norm()
+}
+
+// canvasPixel returns the shadow canvas pixel holding the logical position
+// v. With a top-left origin in pixels, whole numbers address pixels by
+// their top left corner, as in the DOM.
+func (c *Canvasp) canvasPixel(v pixel.Vec) point {
+	p := c.ToCanvas(v)
+	if c.coords.origin == OriginTopLeft {
+		return point{int(math.Floor(p.X)), int(math.Ceil(p.Y)) - 1}
+	}
+	return pixelPoint(p)
+}
+
+// canvasCorner returns where, in shadow canvas pixels, to put the bottom
+// left corner of something h pixels high whose corner nearest the logical
+// origin goes at the logical position at
+func (c *Canvasp) canvasCorner(at pixel.Vec, h float64) pixel.Vec {
+	v := c.ToCanvas(at)
+	if c.coords.origin == OriginTopLeft {
+		v.Y -= h
+	}
+	return v
+}
+
+// SetVerticalFlip controls whether rows are reversed when copying to the
+// browser. It is on by default, which is correct for anything drawn through
+// pixelgl. Turn it off if an external renderer fills the buffer top-down.
+func (c *Canvasp) SetVerticalFlip(on bool) {
+	c.flipY = on
+}
+
+// VerticalFlip reports whether rows are reversed on copy
+func (c *Canvasp) VerticalFlip() bool {
+	return c.flipY
+}
+
+// ToDOM converts a logical position to DOM canvas pixels (origin top left).
+func (c *Canvasp) ToDOM(v pixel.Vec) pixel.Vec {
+	return c.canvasToDOM(c.ToCanvas(v))
+}
+
+// FromDOM converts a position in DOM canvas pixels (origin top left) to
+// logical coordinates.
+func (c *Canvasp) FromDOM(v pixel.Vec) pixel.Vec {
+	return c.FromCanvas(c.domToCanvas(v))
+}
+
+// FromClient converts a pointer event's clientX/clientY to logical
+// coordinates, allowing for the canvas's position on the page and any CSS
+// scaling between its displayed size and its resolution.
+func (c *Canvasp) FromClient(clientX float64, clientY float64) pixel.Vec {
+	return c.FromCanvas(c.clientToCanvas(clientX, clientY))
+}
+
+// canvasToDOM converts shadow canvas pixels to DOM canvas pixels
+func (c *Canvasp) canvasToDOM(v pixel.Vec) pixel.Vec {
+	if !c.flipY {
+		return v
+	}
+	return pixel.V(v.X, float64(c.height)-v.Y)
+}
+
+// domToCanvas converts DOM canvas pixels to shadow canvas pixels
+func (c *Canvasp) domToCanvas(v pixel.Vec) pixel.Vec {
+	if !c.flipY {
+		return v
+	}
+	return pixel.V(v.X, float64(c.height)-v.Y)
+}
+
+// clientToCanvas is FromClient in shadow canvas pixels
+func (c *Canvasp) clientToCanvas(clientX float64, clientY float64) pixel.Vec {
+	r := c.canvas.Call("getBoundingClientRect")
+	left, top := r.Get("left").Float(), r.Get("top").Float()
+	w, h := r.Get("width").Float(), r.Get("height").Float()
+
+	x, y := clientX-left, clientY-top
+	if w > 0 && h > 0 {
+		x *= float64(c.width) / w
+		y *= float64(c.height) / h
+	}
+	return c.domToCanvas(pixel.V(x, y))
+}
@@ -0,0 +1,291 @@
+package pixelcanvas
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"syscall/js"
+	"time"
+)
+
+// Crash reporting
+//
+// A panic in a WebAssembly Go program normally ends it, leaving the last
+// frame frozen on the canvas and nothing but a console message to go on.
+// With ReportCrashes on, panics in the render loop and in the package's
+// event handlers are recovered instead: the loop is stopped, a CrashReport
+// with the Go stack, recent breadcrumbs and the browser's capabilities is
+// delivered to OnCrash and/or posted to Endpoint, and a short message can
+// replace the frozen frame. Uncaught JavaScript errors and unhandled
+// promise rejections are reported too, without stopping anything.
+//
+// Goroutines the app starts are its own: put defer c.Recover() at their
+// top to have their panics reported the same way.
+
+// Crash reporter defaults
+const (
+	DefaultMaxBreadcrumbs = 50
+	DefaultCrashText      = "Something went wrong. Please reload the page."
+	crashFrames           = 30 // Recent frame timings kept for a report
+)
+
+// Breadcrumb is a recent event leading up to a crash
+type Breadcrumb struct {
+	Time    time.Time              `json:"time"`
+	Kind    string                 `json:"kind"` // "input", "lifecycle", or the app's own
+	Message string                 `json:"message"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// FrameCrumb is the timing of one of the last frames before a crash
+type FrameCrumb struct {
+	Frame  uint64        `json:"frame"`
+	Render time.Duration `json:"render"`
+	Copy   time.Duration `json:"copy"`
+}
+
+// CrashReport describes a crash
+type CrashReport struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`          // "panic", "error" or "unhandledrejection"
+	Where   string    `json:"where,omitempty"` // What was running, e.g. "frame" or "event keydown"
+	Message string    `json:"message"`
+	Stack   string    `json:"stack,omitempty"`   // Go stack, for a panic
+	JSStack string    `json:"jsStack,omitempty"` // JavaScript stack, for an error
+
+	Breadcrumbs  []Breadcrumb `json:"breadcrumbs"`
+	Frames       []FrameCrumb `json:"frames"` // Oldest first
+	State        string       `json:"state"`
+	Capabilities Capabilities `json:"capabilities"`
+	URL          string       `json:"url"`
+	UserAgent    string       `json:"userAgent"`
+	Session      string       `json:"session,omitempty"` // Analytics session, if running
+}
+
+// Fatal reports whether the crash stopped the app (a Go panic), rather
+// than being a JavaScript error it may survive
+func (r CrashReport) Fatal() bool {
+	return r.Source == "panic"
+}
+
+// CrashReporter captures crashes on a canvas
+type CrashReporter struct {
+	// OnCrash is called with each report
+	OnCrash func(CrashReport)
+
+	// Endpoint, if set, is sent each report as JSON with
+	// navigator.sendBeacon (or a keepalive fetch)
+	Endpoint string
+
+	// Screen replaces the canvas with ScreenText after a panic
+	Screen     bool
+	ScreenText string
+
+	MaxBreadcrumbs int
+
+	c         *Canvasp
+	listeners []*listener
+	crumbs    []Breadcrumb // Ring, next at crumb % len once full
+	crumb     int
+	frames    [crashFrames]FrameCrumb
+	frame     int // Frames recorded
+	reports   int
+}
+
+// ReportCrashes starts capturing crashes, recording input breadcrumbs from
+// the canvas and keyboard, and returns the reporter to configure. Calling
+// it again returns the same reporter.
+func (c *Canvasp) ReportCrashes() *CrashReporter {
+	if c.crash != nil {
+		return c.crash
+	}
+	r := &CrashReporter{
+		Screen:         true,
+		ScreenText:     DefaultCrashText,
+		MaxBreadcrumbs: DefaultMaxBreadcrumbs,
+		c:              c,
+	}
+	c.crash = r
+	r.listeners = []*listener{
+		c.listen(c.window, "error", r.jsError),
+		c.listen(c.window, "unhandledrejection", r.jsRejection),
+		c.listen(c.canvas, "pointerdown", func(e js.Value) {
+			c.Breadcrumb("input", "pointerdown", "button", e.Get("button").Int(),
+				"x", e.Get("offsetX").Float(), "y", e.Get("offsetY").Float())
+		}),
+		c.listen(c.window, "keydown", func(e js.Value) {
+			c.Breadcrumb("input", "keydown", "code", e.Get("code").String())
+		}),
+	}
+	return r
+}
+
+// StopReportingCrashes stops capturing crashes; panics end the program
+// again
+func (c *Canvasp) StopReportingCrashes() {
+	r := c.crash
+	if r == nil {
+		return
+	}
+	for _, l := range r.listeners {
+		c.unlisten(l)
+	}
+	c.crash = nil
+}
+
+// Breadcrumb records an event to include in a crash report, with data
+// given as alternating keys and values. It does nothing unless
+// ReportCrashes is on.
+func (c *Canvasp) Breadcrumb(kind, message string, keyvals ...interface{}) {
+	r := c.crash
+	if r == nil || r.MaxBreadcrumbs <= 0 {
+		return
+	}
+	b := Breadcrumb{Time: time.Now(), Kind: kind, Message: message}
+	if len(keyvals) > 1 {
+		b.Data = make(map[string]interface{}, len(keyvals)/2)
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			if k, ok := keyvals[i].(string); ok {
+				b.Data[k] = keyvals[i+1]
+			}
+		}
+	}
+	if len(r.crumbs) < r.MaxBreadcrumbs {
+		r.crumbs = append(r.crumbs, b)
+		return
+	}
+	r.crumbs[r.crumb%len(r.crumbs)] = b
+	r.crumb++
+}
+
+// Recover reports a panic in the goroutine it is deferred in, as the
+// render loop's are, and ends the goroutine quietly. Use it directly:
+// defer c.Recover(). Without ReportCrashes it lets the panic through.
+func (c *Canvasp) Recover() {
+	if c.crash == nil {
+		return
+	}
+	if v := recover(); v != nil {
+		c.crash.panicked("goroutine", v)
+	}
+}
+
+// catchPanic is Recover for the package's own callbacks, naming what was
+// running
+func (c *Canvasp) catchPanic(where string) {
+	if c.crash == nil {
+		return
+	}
+	if v := recover(); v != nil {
+		c.crash.panicked(where, v)
+	}
+}
+
+// panicked stops the loop and reports a recovered panic
+func (r *CrashReporter) panicked(where string, v interface{}) {
+	report := r.report("panic", where, fmt.Sprint(v))
+	report.Stack = string(debug.Stack())
+	r.c.Stop()
+	if r.Screen {
+		r.showScreen()
+	}
+	r.deliver(report)
+}
+
+func (r *CrashReporter) jsError(e js.Value) {
+	report := r.report("error", "", e.Get("message").String())
+	if err := e.Get("error"); err.Type() == js.TypeObject {
+		report.JSStack = err.Get("stack").String()
+	}
+	r.deliver(report)
+}
+
+func (r *CrashReporter) jsRejection(e js.Value) {
+	reason := e.Get("reason")
+	report := r.report("unhandledrejection", "", reason.Call("toString").String())
+	if reason.Type() == js.TypeObject && reason.Get("stack").Type() == js.TypeString {
+		report.JSStack = reason.Get("stack").String()
+	}
+	r.deliver(report)
+}
+
+// report fills in what every report carries
+func (r *CrashReporter) report(source, where, message string) CrashReport {
+	rep := CrashReport{
+		Time:         time.Now(),
+		Source:       source,
+		Where:        where,
+		Message:      message,
+		State:        r.c.state.String(),
+		Capabilities: r.c.Capabilities(),
+		URL:          r.c.window.Get("location").Get("href").String(),
+		UserAgent:    js.Global().Get("navigator").Get("userAgent").String(),
+	}
+	n := len(r.crumbs)
+	rep.Breadcrumbs = make([]Breadcrumb, 0, n)
+	for i := 0; i < n; i++ {
+		rep.Breadcrumbs = append(rep.Breadcrumbs, r.crumbs[(r.crumb+i)%n])
+	}
+	count := minInt(r.frame, crashFrames)
+	rep.Frames = make([]FrameCrumb, 0, count)
+	for i := r.frame - count; i < r.frame; i++ {
+		rep.Frames = append(rep.Frames, r.frames[i%crashFrames])
+	}
+	if r.c.analytics != nil {
+		rep.Session = r.c.analytics.Session()
+	}
+	return rep
+}
+
+// deliver hands a report to the callback, the endpoint, the analytics
+// session and the log
+func (r *CrashReporter) deliver(rep CrashReport) {
+	r.reports++
+	r.c.log().Error("crash", "source", rep.Source, "where", rep.Where, "message", rep.Message)
+	if r.c.analytics != nil {
+		r.c.analytics.Track(EventError, "message", rep.Message, "source", rep.Source, "fatal", rep.Fatal())
+		if rep.Fatal() {
+			r.c.analytics.Flush() // The app may not get another chance
+		}
+	}
+	if r.Endpoint != "" {
+		if data, err := json.Marshal(rep); err == nil {
+			beacon(r.Endpoint, data)
+		}
+	}
+	if r.OnCrash != nil {
+		r.OnCrash(rep)
+	}
+}
+
+// Reports returns the number of crashes reported so far
+func (r *CrashReporter) Reports() int {
+	return r.reports
+}
+
+// recordFrame keeps a frame's timing for the next report
+func (r *CrashReporter) recordFrame(frame uint64, render, present time.Duration) {
+	r.frames[r.frame%crashFrames] = FrameCrumb{Frame: frame, Render: render, Copy: present}
+	r.frame++
+}
+
+// showScreen paints ScreenText over the canvas with the 2D context
+// directly, as the shadow canvas may be what broke
+func (r *CrashReporter) showScreen() {
+	ctx := r.c.ctx
+	if ctx.IsUndefined() {
+		return
+	}
+	w, h := float64(r.c.width), float64(r.c.height)
+	ctx.Call("save")
+	ctx.Call("setTransform", 1, 0, 0, 1, 0, 0)
+	ctx.Set("fillStyle", "#1e1e28")
+	ctx.Call("fillRect", 0, 0, w, h)
+	ctx.Set("fillStyle", "#ebebf0")
+	ctx.Set("font", fmt.Sprintf("%dpx sans-serif", clampInt(r.c.width/30, 10, 24)))
+	ctx.Set("textAlign", "center")
+	ctx.Set("textBaseline", "middle")
+	ctx.Call("fillText", r.ScreenText, w/2, h/2, w*0.9)
+	ctx.Call("restore")
+	r.c.invalidateCopy()
+}
@@ -0,0 +1,449 @@
+package pixelcanvas
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"image/color"
+	"math/rand"
+	"syscall/js"
+	"time"
+)
+
+// Collaborative documents
+//
+// A PixelDoc is a conflict-free replicated pixel document: every pixel is a
+// last-writer-wins register stamped with a Lamport clock and the writing
+// replica's ID, so replicas that have applied the same edits hold the same
+// pixels whatever order the edits arrived in, and the server only has to
+// relay messages. Edits travel as small ops; a replica joining late asks
+// for whole tiles, which merge with what it already has the same way. Tiles
+// are compared by a digest of their stamps, and a replica that receives a
+// tile holding pixels newer than the sender's answers with its own, so both
+// sides end up with every pixel's latest write.
+//
+// The pixels live in a ChunkedCanvas, so the document can be presented and
+// scrolled like any other. Stamps are kept by the PixelDoc itself and are
+// never evicted; if the ChunkedCanvas has a MaxTiles limit, its Load and
+// OnEvict must keep evicted pixels somewhere.
+
+// Sync message kinds, the first byte of every message
+const (
+	docMsgOps  = 1 // Ops, see EncodeOps
+	docMsgTile = 2 // One tile's state, see EncodeTile
+	docMsgWant = 3 // A request for tiles, see RequestTiles
+)
+
+// docOpSize is the encoded size of a PixelOp
+const docOpSize = 4 + 4 + 4 + 8 + 4
+
+// Document sync errors
+var (
+	ErrBadDocMessage = errors.New("pixelcanvas: invalid document sync message")
+	ErrNotConnected  = errors.New("pixelcanvas: no open socket to send on")
+)
+
+// docStamp orders writes to one pixel: higher clock wins, ties go to the
+// higher replica ID
+type docStamp struct {
+	clock   uint64
+	replica uint32
+}
+
+func (a docStamp) after(b docStamp) bool {
+	return a.clock > b.clock || a.clock == b.clock && a.replica > b.replica
+}
+
+// PixelOp is one pixel write
+type PixelOp struct {
+	X, Y    int
+	Color   color.RGBA // Premultiplied
+	Clock   uint64
+	Replica uint32
+}
+
+// PixelDoc is a replicated pixel document, see NewPixelDoc
+type PixelDoc struct {
+	Canvas  *ChunkedCanvas
+	Replica uint32 // This replica's ID, unique among the collaborators
+
+	// OnRemote, if set, is called after remote ops or tiles changed pixels
+	OnRemote func()
+
+	clock   uint64
+	stamps  map[TileKey][]docStamp
+	pending []PixelOp // Local ops not yet taken by Flush
+	batcher *UpdateBatcher
+
+	ws        js.Value
+	wsMessage js.Func
+	wsOpen    js.Func
+	joins     []TileKey // Join requests waiting for the socket to open
+}
+
+// NewPixelDoc creates an empty document over cc with a random replica ID
+func NewPixelDoc(cc *ChunkedCanvas) *PixelDoc {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return &PixelDoc{
+		Canvas:  cc,
+		Replica: rng.Uint32(),
+		stamps:  make(map[TileKey][]docStamp),
+	}
+}
+
+// Set writes a pixel locally and queues the op for Flush
+func (d *PixelDoc) Set(x, y int, col color.Color) {
+	d.clock++
+	p := rgba8(col)
+	op := PixelOp{X: x, Y: y, Color: color.RGBA{p[0], p[1], p[2], p[3]}, Clock: d.clock, Replica: d.Replica}
+	d.write(op)
+	if d.batcher != nil {
+		d.batcher.Add(op)
+		return
+	}
+	d.pending = append(d.pending, op)
+}
+
+// Apply merges ops from another replica, reporting whether any pixel
+// changed. Ops that lose to what the document already has are ignored, so
+// applying an op twice, or out of order, is harmless.
+func (d *PixelDoc) Apply(ops []PixelOp) bool {
+	changed := false
+	for _, op := range ops {
+		if op.Clock > d.clock {
+			d.clock = op.Clock
+		}
+		if d.write(op) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Pending returns how many local ops are waiting for Flush
+func (d *PixelDoc) Pending() int {
+	return len(d.pending)
+}
+
+// Flush takes the queued local ops
+func (d *PixelDoc) Flush() []PixelOp {
+	ops := d.pending
+	d.pending = nil
+	return ops
+}
+
+// write applies op if it is newer than the pixel's stamp
+func (d *PixelDoc) write(op PixelOp) bool {
+	key, lx, ly := d.Canvas.TileAt(op.X, op.Y)
+	st := d.tileStamps(key)
+	i := ly*d.Canvas.TileSize + lx
+	s := docStamp{op.Clock, op.Replica}
+	if !s.after(st[i]) {
+		return false
+	}
+	st[i] = s
+	d.Canvas.Set(op.X, op.Y, op.Color)
+	return true
+}
+
+func (d *PixelDoc) tileStamps(key TileKey) []docStamp {
+	st, ok := d.stamps[key]
+	if !ok {
+		st = make([]docStamp, d.Canvas.TileSize*d.Canvas.TileSize)
+		d.stamps[key] = st
+	}
+	return st
+}
+
+// TileDigest returns a hash of the stamps of every pixel in a tile, 0 if it
+// has never been written. Replicas holding the same writes to a tile have
+// the same digest, so peers compare digests to decide which tiles to send.
+func (d *PixelDoc) TileDigest(key TileKey) uint64 {
+	st, ok := d.stamps[key]
+	if !ok {
+		return 0
+	}
+	h := fnv.New64a()
+	var buf [12]byte
+	for _, s := range st {
+		binary.LittleEndian.PutUint64(buf[0:], s.clock)
+		binary.LittleEndian.PutUint32(buf[8:], s.replica)
+		h.Write(buf[:])
+	}
+	if v := h.Sum64(); v != 0 {
+		return v
+	}
+	return 1 // Keep 0 for never written
+}
+
+// Tiles returns the keys of every tile that has been written
+func (d *PixelDoc) Tiles() []TileKey {
+	keys := make([]TileKey, 0, len(d.stamps))
+	for k := range d.stamps {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// EncodeOps encodes ops as a sync message
+func EncodeOps(ops []PixelOp) []byte {
+	b := make([]byte, 1, 1+len(ops)*docOpSize)
+	b[0] = docMsgOps
+	var buf [docOpSize]byte
+	for _, op := range ops {
+		binary.LittleEndian.PutUint32(buf[0:], uint32(int32(op.X)))
+		binary.LittleEndian.PutUint32(buf[4:], uint32(int32(op.Y)))
+		buf[8], buf[9], buf[10], buf[11] = op.Color.R, op.Color.G, op.Color.B, op.Color.A
+		binary.LittleEndian.PutUint64(buf[12:], op.Clock)
+		binary.LittleEndian.PutUint32(buf[20:], op.Replica)
+		b = append(b, buf[:]...)
+	}
+	return b
+}
+
+func decodeOps(b []byte) ([]PixelOp, error) {
+	if len(b)%docOpSize != 0 {
+		return nil, ErrBadDocMessage
+	}
+	ops := make([]PixelOp, len(b)/docOpSize)
+	for i := range ops {
+		r := b[i*docOpSize:]
+		ops[i] = PixelOp{
+			X:       int(int32(binary.LittleEndian.Uint32(r[0:]))),
+			Y:       int(int32(binary.LittleEndian.Uint32(r[4:]))),
+			Color:   color.RGBA{r[8], r[9], r[10], r[11]},
+			Clock:   binary.LittleEndian.Uint64(r[12:]),
+			Replica: binary.LittleEndian.Uint32(r[20:]),
+		}
+	}
+	return ops, nil
+}
+
+// EncodeTile encodes a tile's pixels and stamps as a sync message, for a
+// replica that is missing it or holds different writes to it
+func (d *PixelDoc) EncodeTile(key TileKey) []byte {
+	ts := d.Canvas.TileSize
+	n := ts * ts
+	b := make([]byte, 1+12+n*16)
+	b[0] = docMsgTile
+	binary.LittleEndian.PutUint32(b[1:], uint32(int32(key.X)))
+	binary.LittleEndian.PutUint32(b[5:], uint32(int32(key.Y)))
+	binary.LittleEndian.PutUint32(b[9:], uint32(ts))
+	pix := d.Canvas.Tile(key).Pix
+	st := d.tileStamps(key)
+	for i := 0; i < n; i++ {
+		r := b[13+i*16:]
+		copy(r[:4], pix[i*4:i*4+4])
+		binary.LittleEndian.PutUint64(r[4:], st[i].clock)
+		binary.LittleEndian.PutUint32(r[12:], st[i].replica)
+	}
+	return b
+}
+
+// mergeTile merges an encoded tile pixel by pixel, reporting whether any
+// pixel changed and whether this replica holds writes the sender lacks
+func (d *PixelDoc) mergeTile(b []byte) (key TileKey, changed, newer bool, err error) {
+	if len(b) < 12 {
+		return key, false, false, ErrBadDocMessage
+	}
+	key = TileKey{int(int32(binary.LittleEndian.Uint32(b[0:]))), int(int32(binary.LittleEndian.Uint32(b[4:])))}
+	ts := int(binary.LittleEndian.Uint32(b[8:]))
+	if ts != d.Canvas.TileSize || len(b) != 12+ts*ts*16 {
+		return key, false, false, ErrBadDocMessage
+	}
+	st := d.stamps[key] // nil if this replica has never written the tile
+	var ops []PixelOp
+	for i := 0; i < ts*ts; i++ {
+		r := b[12+i*16:]
+		s := docStamp{binary.LittleEndian.Uint64(r[4:]), binary.LittleEndian.Uint32(r[12:])}
+		if st != nil && st[i].after(s) {
+			newer = true
+		}
+		if s.clock == 0 {
+			continue // Never written
+		}
+		ops = append(ops, PixelOp{
+			X:       key.X*ts + i%ts,
+			Y:       key.Y*ts + i/ts,
+			Color:   color.RGBA{r[0], r[1], r[2], r[3]},
+			Clock:   s.clock,
+			Replica: s.replica,
+		})
+	}
+	return key, d.Apply(ops), newer, nil
+}
+
+// RequestTiles encodes a request for the given tiles along with this
+// replica's digest of each; a replica receiving it with HandleMessage
+// answers with the tiles whose digest differs from its own. Pixels the
+// requester has newer are then sent back in answer to those tiles.
+//
+// Requests made before this replica writes a tile carry digest 0, matching
+// any replica that hasn't either.
+func (d *PixelDoc) RequestTiles(keys []TileKey) []byte {
+	b := make([]byte, 1, 1+len(keys)*16)
+	b[0] = docMsgWant
+	var buf [16]byte
+	for _, k := range keys {
+		binary.LittleEndian.PutUint32(buf[0:], uint32(int32(k.X)))
+		binary.LittleEndian.PutUint32(buf[4:], uint32(int32(k.Y)))
+		binary.LittleEndian.PutUint64(buf[8:], d.TileDigest(k))
+		b = append(b, buf[:]...)
+	}
+	return b
+}
+
+// HandleMessage applies a sync message from another replica, returning any
+// replies to send back: tiles in answer to a request (and a request for the
+// requester's tiles this replica has none of), or this replica's copy of a
+// tile it received when it holds pixels the sender lacks
+func (d *PixelDoc) HandleMessage(msg []byte) (replies [][]byte, err error) {
+	if len(msg) == 0 {
+		return nil, ErrBadDocMessage
+	}
+	changed := false
+	switch msg[0] {
+	case docMsgOps:
+		ops, err := decodeOps(msg[1:])
+		if err != nil {
+			return nil, err
+		}
+		changed = d.Apply(ops)
+	case docMsgTile:
+		key, merged, newer, err := d.mergeTile(msg[1:])
+		if err != nil {
+			return nil, err
+		}
+		changed = merged
+		if newer {
+			replies = append(replies, d.EncodeTile(key))
+		}
+	case docMsgWant:
+		body := msg[1:]
+		if len(body)%16 != 0 {
+			return nil, ErrBadDocMessage
+		}
+		var missing []TileKey // Tiles the requester has written and this replica hasn't
+		for i := 0; i < len(body); i += 16 {
+			k := TileKey{int(int32(binary.LittleEndian.Uint32(body[i:]))), int(int32(binary.LittleEndian.Uint32(body[i+4:])))}
+			theirs := binary.LittleEndian.Uint64(body[i+8:])
+			if _, ok := d.stamps[k]; !ok {
+				if theirs != 0 {
+					missing = append(missing, k)
+				}
+			} else if d.TileDigest(k) != theirs {
+				replies = append(replies, d.EncodeTile(k))
+			}
+		}
+		if len(missing) > 0 {
+			replies = append(replies, d.RequestTiles(missing))
+		}
+	default:
+		return nil, ErrBadDocMessage
+	}
+	if changed && d.OnRemote != nil {
+		d.OnRemote()
+	}
+	return replies, nil
+}
+
+// AttachSocket syncs the document over a WebSocket whose server relays
+// every binary message to the other clients: incoming messages are handled
+// and answered, and SendPending sends local edits. Edits and Join requests
+// made while the socket is still connecting are kept and sent when it
+// opens. The socket's
+// binaryType is set to "arraybuffer".
+func (d *PixelDoc) AttachSocket(ws js.Value) {
+	d.DetachSocket()
+	d.ws = ws
+	ws.Set("binaryType", "arraybuffer")
+	d.wsMessage = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		data := args[0].Get("data")
+		if !data.InstanceOf(js.Global().Get("ArrayBuffer")) {
+			return nil
+		}
+		arr := js.Global().Get("Uint8Array").New(data)
+		msg := make([]byte, arr.Length())
+		js.CopyBytesToGo(msg, arr)
+		replies, err := d.HandleMessage(msg)
+		if err != nil {
+			return nil
+		}
+		for _, r := range replies {
+			d.send(r)
+		}
+		return nil
+	})
+	d.wsOpen = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if keys := d.joins; keys != nil {
+			d.joins = nil
+			d.Join(keys)
+		}
+		d.SendPending()
+		return nil
+	})
+	ws.Call("addEventListener", "message", d.wsMessage)
+	ws.Call("addEventListener", "open", d.wsOpen)
+}
+
+// DetachSocket stops syncing over the WebSocket
+func (d *PixelDoc) DetachSocket() {
+	if d.ws.IsUndefined() || d.ws.IsNull() {
+		return
+	}
+	d.ws.Call("removeEventListener", "message", d.wsMessage)
+	d.ws.Call("removeEventListener", "open", d.wsOpen)
+	d.wsMessage.Release()
+	d.wsOpen.Release()
+	d.ws = js.Undefined()
+	d.joins = nil
+}
+
+// Batch sends local edits to the attached socket through an UpdateBatcher
+// as they are made, instead of waiting for SendPending. The batcher is
+// returned so its Interval and MaxBytes can be tuned.
+func (d *PixelDoc) Batch() *UpdateBatcher {
+	if d.batcher == nil {
+		d.batcher = NewUpdateBatcher(d.Canvas.TileSize, d.send)
+		d.batcher.Add(d.Flush()...)
+	}
+	return d.batcher
+}
+
+// SendPending flushes the queued local ops to the attached socket, e.g.
+// once per frame. Ops stay queued while the socket isn't open.
+func (d *PixelDoc) SendPending() {
+	if d.batcher != nil {
+		d.batcher.Flush()
+	}
+	if len(d.pending) == 0 {
+		return
+	}
+	if d.send(EncodeOps(d.pending)) == nil {
+		d.pending = nil
+	}
+}
+
+// Join asks the other replicas, through the attached socket, for every tile
+// in keys that they hold different writes to, e.g. the visible tiles when a
+// client connects or scrolls. While the socket is connecting the request
+// waits for it to open.
+func (d *PixelDoc) Join(keys []TileKey) {
+	if d.ws.IsUndefined() || len(keys) == 0 {
+		return
+	}
+	if d.send(d.RequestTiles(keys)) != nil {
+		d.joins = append(d.joins, keys...)
+	}
+}
+
+// send sends msg on the attached socket, or returns ErrNotConnected
+func (d *PixelDoc) send(msg []byte) error {
+	if d.ws.IsUndefined() || d.ws.Get("readyState").Int() != 1 { // OPEN
+		return ErrNotConnected
+	}
+	arr := js.Global().Get("Uint8Array").New(len(msg))
+	js.CopyBytesToJS(arr, msg)
+	d.ws.Call("send", arr)
+	return nil
+}
@@ -0,0 +1,227 @@
+package pixelcanvas
+
+import (
+	"bytes"
+	"image"
+	"syscall/js"
+
+	"github.com/faiface/pixel"
+)
+
+// DefaultDiffCell is the cell size, in pixels, used when Diff is given 0
+const DefaultDiffCell = 16
+
+// Snapshot is a copy of the shadow canvas pixels at one moment, for finding
+// what changed between frames: premultiplied RGBA, rows bottom-up
+type Snapshot struct {
+	Width, Height int
+	Pix           []uint8
+}
+
+// Snapshot copies the shadow canvas as it is now
+func (c *Canvasp) Snapshot() *Snapshot {
+	return &Snapshot{Width: c.width, Height: c.height, Pix: c.image.Pixels()}
+}
+
+// DecodeSnapshot decodes a snapshot made by Serialize, e.g. a reference
+// image for a visual test
+func DecodeSnapshot(data []byte) (*Snapshot, error) {
+	pix, w, h, err := decodeSnapshot(data, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{Width: w, Height: h, Pix: pix}, nil
+}
+
+// Bounds returns the snapshot's rectangle in canvas coordinates
+func (s *Snapshot) Bounds() pixel.Rect {
+	return pixel.R(0, 0, float64(s.Width), float64(s.Height))
+}
+
+// Diff compares two snapshots in cells of cell x cell pixels and returns
+// rectangles, in canvas coordinates, covering every cell that changed.
+// Neighbouring changed cells are merged, so a moving sprite gives a
+// rectangle or two rather than dozens of cells. Smaller cells fit the
+// changes more tightly at the cost of more rectangles; 0 uses
+// DefaultDiffCell. Snapshots of different sizes give cur's whole bounds.
+func Diff(prev, cur *Snapshot, cell int) []pixel.Rect {
+	if prev.Width != cur.Width || prev.Height != cur.Height {
+		return []pixel.Rect{cur.Bounds()}
+	}
+	rects := diffRects(prev.Pix, cur.Pix, cur.Width, cur.Height, cell)
+	out := make([]pixel.Rect, len(rects))
+	for i, r := range rects {
+		out[i] = pixel.R(float64(r.x0), float64(r.y0), float64(r.x1), float64(r.y1))
+	}
+	return out
+}
+
+// DiffCells is Diff without the merging: the column and row of each changed
+// cell, cells counted from the bottom left, in rows. This suits sending
+// deltas over the network in fixed-size pieces. Snapshots of different
+// sizes give nil.
+func DiffCells(prev, cur *Snapshot, cell int) []image.Point {
+	if prev.Width != cur.Width || prev.Height != cur.Height {
+		return nil
+	}
+	cell = diffCellSize(cell)
+	cols, grid := diffGrid(prev.Pix, cur.Pix, cur.Width, cur.Height, cell)
+	var out []image.Point
+	for i, changed := range grid {
+		if changed {
+			out = append(out, image.Pt(i%cols, i/cols))
+		}
+	}
+	return out
+}
+
+// DiffBounds returns the smallest rectangle holding every changed pixel,
+// empty when nothing changed
+func DiffBounds(prev, cur *Snapshot) pixel.Rect {
+	if prev.Width != cur.Width || prev.Height != cur.Height {
+		return cur.Bounds()
+	}
+	x0, y0, x1, y1 := diffBounds(prev.Pix, cur.Pix, cur.Width, cur.Height)
+	if x0 >= x1 || y0 >= y1 {
+		return pixel.Rect{}
+	}
+	return pixel.R(float64(x0), float64(y0), float64(x1), float64(y1))
+}
+
+// Equal reports whether two snapshots are identical
+func (s *Snapshot) Equal(o *Snapshot) bool {
+	return s.Width == o.Width && s.Height == o.Height && bytes.Equal(s.Pix, o.Pix)
+}
+
+// diffRect is a changed area in buffer pixels, [x0, x1) x [y0, y1)
+type diffRect struct {
+	x0, y0, x1, y1 int
+}
+
+func diffCellSize(cell int) int {
+	if cell <= 0 {
+		return DefaultDiffCell
+	}
+	return cell
+}
+
+// diffGrid marks the cell x cell cells of two w x h buffers that differ,
+// row by row, returning the number of columns
+func diffGrid(a, b []uint8, w, h, cell int) (int, []bool) {
+	cols, rows := (w+cell-1)/cell, (h+cell-1)/cell
+	grid := make([]bool, cols*rows)
+	stride := w * 4
+	for y := 0; y < h; y++ {
+		ra, rb := a[y*stride:(y+1)*stride], b[y*stride:(y+1)*stride]
+		if bytes.Equal(ra, rb) {
+			continue
+		}
+		row := grid[(y/cell)*cols : (y/cell+1)*cols]
+		for cx := range row {
+			if row[cx] {
+				continue
+			}
+			x0, x1 := cx*cell*4, minInt((cx+1)*cell, w)*4
+			if !bytes.Equal(ra[x0:x1], rb[x0:x1]) {
+				row[cx] = true
+			}
+		}
+	}
+	return cols, grid
+}
+
+// diffRects finds the changed cells of two w x h buffers and merges them:
+// runs of changed cells in a row, then runs spanning the same columns in
+// the rows above
+func diffRects(a, b []uint8, w, h, cell int) []diffRect {
+	cell = diffCellSize(cell)
+	cols, grid := diffGrid(a, b, w, h, cell)
+	if cols == 0 {
+		return nil
+	}
+	rows := len(grid) / cols
+	var out []diffRect
+	open := map[[2]int]int{} // Column span to its rectangle in out, if it reaches the previous row
+	for cy := 0; cy < rows; cy++ {
+		next := map[[2]int]int{}
+		y0, y1 := cy*cell, minInt((cy+1)*cell, h)
+		for cx := 0; cx < cols; {
+			if !grid[cy*cols+cx] {
+				cx++
+				continue
+			}
+			start := cx
+			for cx < cols && grid[cy*cols+cx] {
+				cx++
+			}
+			span := [2]int{start, cx}
+			if i, ok := open[span]; ok {
+				out[i].y1 = y1
+				next[span] = i
+				continue
+			}
+			out = append(out, diffRect{x0: start * cell, y0: y0, x1: minInt(cx*cell, w), y1: y1})
+			next[span] = len(out) - 1
+		}
+		open = next
+	}
+	return out
+}
+
+// Partial copy
+
+type partialCopy struct {
+	cell int     // 0 when off
+	prev []uint8 // Last frame presented, in ImageData's layout; nil to copy all of the next
+}
+
+// SetPartialCopy makes each frame copy only the cells, of cell x cell
+// pixels, that differ from the last frame presented, instead of the whole
+// canvas; 0 turns it off (the default). Comparing costs a pass over the
+// frame and a copy of it is kept, so this pays off on large canvases where
+// little changes between frames, such as an editor or a board game, and
+// costs a little where most of the frame changes.
+func (c *Canvasp) SetPartialCopy(cell int) {
+	if cell < 0 {
+		cell = 0
+	}
+	c.partial = partialCopy{cell: cell}
+}
+
+// invalidateCopy makes the next partial copy a full one, after something
+// else has drawn to the canvas element
+func (c *Canvasp) invalidateCopy() {
+	c.partial.prev = nil
+}
+
+// partialImgCopy is imgCopy for SetPartialCopy: it copies and presents the
+// rows of each changed rectangle, with the rectangle as putImageData's
+// dirty area
+func (c *Canvasp) partialImgCopy() {
+	c.mark("copy-start")
+	out := c.convert(c.pixels())
+	p := &c.partial
+	var rects []diffRect
+	if len(p.prev) != len(out) {
+		p.prev = make([]uint8, len(out))
+		rects = []diffRect{{0, 0, c.width, c.height}}
+	} else {
+		rects = diffRects(p.prev, out, c.width, c.height, p.cell)
+	}
+	stride := c.width * 4
+	for _, r := range rects {
+		band := c.copybuff.Call("subarray", r.y0*stride, r.y1*stride)
+		js.CopyBytesToJS(band, out[r.y0*stride:r.y1*stride])
+		c.dataSet.Invoke(band, r.y0*stride)
+		copy(p.prev[r.y0*stride:r.y1*stride], out[r.y0*stride:r.y1*stride])
+	}
+	c.mark("copy-end")
+	c.measure("copy", "copy-start", "copy-end")
+
+	c.mark("present-start")
+	for _, r := range rects {
+		c.putImage.Invoke(c.imgData, 0, 0, r.x0, r.y0, r.x1-r.x0, r.y1-r.y0)
+	}
+	c.mark("present-end")
+	c.measure("present", "present-start", "present-end")
+}
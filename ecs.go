@@ -0,0 +1,265 @@
+package pixelcanvas
+
+import "sort"
+
+// Entity identifies a game object in a World. Entities have no data of their
+// own, just components stored against them.
+type Entity uint32
+
+// Store holds one kind of component for many entities as a sparse set, so
+// iteration is over a dense slice in insertion order.
+type Store struct {
+	name   string
+	index  map[Entity]int
+	dense  []Entity
+	values []interface{}
+}
+
+func newStore(name string) *Store {
+	return &Store{name: name, index: make(map[Entity]int)}
+}
+
+// Name returns the name the Store was registered with
+func (s *Store) Name() string {
+	return s.name
+}
+
+// Len returns the number of entities with this component
+func (s *Store) Len() int {
+	return len(s.dense)
+}
+
+// Set attaches (or replaces) the component for e
+func (s *Store) Set(e Entity, v interface{}) {
+	if i, ok := s.index[e]; ok {
+		s.values[i] = v
+		return
+	}
+	s.index[e] = len(s.dense)
+	s.dense = append(s.dense, e)
+	s.values = append(s.values, v)
+}
+
+// Get returns the component for e, or nil. Callers type-assert the result.
+func (s *Store) Get(e Entity) interface{} {
+	if i, ok := s.index[e]; ok {
+		return s.values[i]
+	}
+	return nil
+}
+
+// Has reports whether e has this component
+func (s *Store) Has(e Entity) bool {
+	_, ok := s.index[e]
+	return ok
+}
+
+// Remove detaches the component from e
+func (s *Store) Remove(e Entity) {
+	i, ok := s.index[e]
+	if !ok {
+		return
+	}
+	last := len(s.dense) - 1
+	if i != last {
+		s.dense[i] = s.dense[last]
+		s.values[i] = s.values[last]
+		s.index[s.dense[i]] = i
+	}
+	s.values[last] = nil
+	s.dense = s.dense[:last]
+	s.values = s.values[:last]
+	delete(s.index, e)
+}
+
+// Each calls fn for every entity with this component
+func (s *Store) Each(fn func(e Entity, v interface{})) {
+	for i := 0; i < len(s.dense); i++ {
+		fn(s.dense[i], s.values[i])
+	}
+}
+
+// System is a unit of per-tick game logic
+type System interface {
+	Update(w *World, dt float64)
+}
+
+// SystemFunc adapts a plain function to a System
+type SystemFunc func(w *World, dt float64)
+
+// Update implements System
+func (f SystemFunc) Update(w *World, dt float64) {
+	f(w, dt)
+}
+
+// DrawFunc draws the World onto the shadow canvas once per rendered frame
+type DrawFunc func(w *World, gc *Canvas)
+
+type systemEntry struct {
+	order  int
+	system System
+}
+
+// World owns entities, their component stores and the systems that run over them.
+type World struct {
+	next     Entity
+	alive    map[Entity]struct{}
+	stores   map[string]*Store
+	systems  []systemEntry
+	draws    []DrawFunc
+	updating bool
+	doomed   []Entity // Destroyed during Update, removed once the tick has finished
+
+	// Fixed timestep state used by RenderFunc
+	step  float64
+	acc   float64
+	ticks uint64
+}
+
+// NewWorld creates an empty World
+func NewWorld() *World {
+	return &World{
+		alive:  make(map[Entity]struct{}),
+		stores: make(map[string]*Store),
+	}
+}
+
+// Spawn creates a new entity
+func (w *World) Spawn() Entity {
+	w.next++
+	w.alive[w.next] = struct{}{}
+	return w.next
+}
+
+// Alive reports whether e exists and has not been destroyed
+func (w *World) Alive(e Entity) bool {
+	_, ok := w.alive[e]
+	return ok
+}
+
+// Destroy removes e and all its components. Inside an Update the removal is
+// deferred to the end of the tick so systems can safely iterate stores.
+func (w *World) Destroy(e Entity) {
+	if w.updating {
+		w.doomed = append(w.doomed, e)
+		return
+	}
+	delete(w.alive, e)
+	for _, s := range w.stores {
+		s.Remove(e)
+	}
+}
+
+// Store returns the component store with the given name, creating it if needed
+func (w *World) Store(name string) *Store {
+	s, ok := w.stores[name]
+	if !ok {
+		s = newStore(name)
+		w.stores[name] = s
+	}
+	return s
+}
+
+// Query returns the entities having every one of the given stores' components
+func (w *World) Query(stores ...*Store) []Entity {
+	if len(stores) == 0 {
+		return nil
+	}
+
+	// Iterate the smallest store and probe the rest
+	smallest := stores[0]
+	for _, s := range stores[1:] {
+		if s.Len() < smallest.Len() {
+			smallest = s
+		}
+	}
+
+	var out []Entity
+	for _, e := range smallest.dense {
+		match := true
+		for _, s := range stores {
+			if s != smallest && !s.Has(e) {
+				match = false
+				break
+			}
+		}
+		if match {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// AddSystem registers a system. Systems run in ascending order, with ties run
+// in the order they were added.
+func (w *World) AddSystem(order int, s System) {
+	w.systems = append(w.systems, systemEntry{order: order, system: s})
+	sort.SliceStable(w.systems, func(i, j int) bool { return w.systems[i].order < w.systems[j].order })
+}
+
+// AddDraw registers a draw function, run in the order added after the systems
+func (w *World) AddDraw(fn DrawFunc) {
+	w.draws = append(w.draws, fn)
+}
+
+// Update runs every system once with the given timestep (in seconds)
+func (w *World) Update(dt float64) {
+	w.updating = true
+	for _, se := range w.systems {
+		se.system.Update(w, dt)
+	}
+	w.updating = false
+
+	doomed := w.doomed
+	w.doomed = nil
+	for _, e := range doomed {
+		w.Destroy(e)
+	}
+	w.ticks++
+}
+
+// Draw runs every registered draw function
+func (w *World) Draw(gc *Canvas) {
+	for _, fn := range w.draws {
+		fn(w, gc)
+	}
+}
+
+// Ticks returns the number of Updates run so far
+func (w *World) Ticks() uint64 {
+	return w.ticks
+}
+
+// Alpha returns how far RenderFunc's clock is between the last tick and the
+// next, from 0 to 1, for interpolating drawing between ticks (see
+// Body.Transform)
+func (w *World) Alpha() float64 {
+	if w.step <= 0 {
+		return 1
+	}
+	return w.acc / w.step
+}
+
+// RenderFunc returns a RenderFunc for c.Start which advances the World in
+// fixed steps of 1/tickRate seconds, independent of the frame rate, then
+// draws it. Time comes from c's simulation clock (see Canvasp.Delta), so
+// Pause, the catch-up policy and hit stop apply to the World too. Long
+// stalls are still capped to avoid a spiral of catch-up ticks.
+func (w *World) RenderFunc(c *Canvasp, tickRate float64) RenderFunc {
+	w.step = 1 / tickRate
+
+	return func(gc *Canvas) bool {
+		w.acc += c.Delta().Seconds()
+
+		if max := w.step * 8; w.acc > max {
+			w.acc = max
+		}
+		for w.acc >= w.step {
+			w.Update(w.step)
+			w.acc -= w.step
+		}
+
+		w.Draw(gc)
+		return true
+	}
+}
@@ -0,0 +1,154 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/faiface/pixel"
+)
+
+// Screen effects
+//
+// Effects adds the small touches that make hits feel like hits: camera
+// shake, a full-screen colour flash, and hit-stop, a brief freeze of
+// simulation time. Shake moves a Camera's Offset, so the world shakes while
+// anything drawn without the camera (a HUD) stays put. Flashes are applied
+// to the copied frame above the overlays. Hit-stop holds SimTime and Delta
+// still, so everything driven by them pauses together, while Effects itself
+// keeps to real frame time so shakes and flashes carry on through it.
+
+// Effects runs shakes and flashes for a canvas
+type Effects struct {
+	// Camera is shaken by Shake. nil leaves shaking to the app, through
+	// ShakeOffset.
+	Camera *Camera
+
+	c *Canvasp
+
+	shakeAmp  float64
+	shakeLen  time.Duration
+	shakeLeft time.Duration
+	offset    pixel.Vec
+	rng       *rand.Rand
+
+	flash flashPass
+}
+
+// flashPass tints the frame with a colour fading out
+type flashPass struct {
+	col    color.NRGBA // Straight, as the pass blends over straight rows
+	length time.Duration
+	left   time.Duration
+	shown  bool // Drawn in the last copy, so its end needs a copy too
+}
+
+// NewEffects creates an effects helper shaking cam, which may be nil.
+// Call Update once per frame from the RenderFunc.
+func (c *Canvasp) NewEffects(cam *Camera) *Effects {
+	e := &Effects{Camera: cam, c: c, rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	c.addPass(&e.flash)
+	return e
+}
+
+// Shake shakes the camera by up to amplitude canvas pixels, dying away
+// over duration. A shake already running is replaced if the new one is
+// stronger.
+func (e *Effects) Shake(amplitude float64, duration time.Duration) {
+	if e.shakeLeft > 0 && e.shakeAmp*e.shakeLeft.Seconds()/e.shakeLen.Seconds() > amplitude {
+		return
+	}
+	e.shakeAmp, e.shakeLen, e.shakeLeft = amplitude, duration, duration
+}
+
+// Flash covers the screen with col, at col's alpha, fading out over
+// duration
+func (e *Effects) Flash(col color.RGBA, duration time.Duration) {
+	e.flash.col = color.NRGBAModel.Convert(col).(color.NRGBA)
+	e.flash.length, e.flash.left = duration, duration
+}
+
+// HitStop freezes simulation time for d, e.g. a few frames on a heavy hit
+func (e *Effects) HitStop(d time.Duration) {
+	e.c.HitStop(d)
+}
+
+// HitStop freezes SimTime and Delta for d of real time; longer freezes
+// already pending are kept
+func (c *Canvasp) HitStop(d time.Duration) {
+	c.clock.freeze = math.Max(c.clock.freeze, float64(d)/float64(time.Millisecond))
+}
+
+// Frozen reports whether a hit-stop is holding simulation time
+func (c *Canvasp) Frozen() bool {
+	return c.clock.freeze > 0 || c.clock.held > 0
+}
+
+// ShakeOffset returns the current shake, in canvas pixels
+func (e *Effects) ShakeOffset() pixel.Vec {
+	return e.offset
+}
+
+// Update advances the effects by the frame's real time, including any held
+// by hit-stop, and moves the camera
+func (e *Effects) Update() {
+	dt := msToDuration(e.c.clock.delta + e.c.clock.held)
+
+	if e.Camera != nil {
+		e.Camera.Offset = e.Camera.Offset.Sub(e.offset)
+	}
+	e.offset = pixel.ZV
+	if e.shakeLeft > 0 {
+		e.shakeLeft -= dt
+		if e.shakeLeft > 0 {
+			// Decay quadratically, which reads as a jolt settling
+			f := e.shakeLeft.Seconds() / e.shakeLen.Seconds()
+			amp := e.shakeAmp * f * f
+			e.offset = pixel.V((e.rng.Float64()*2-1)*amp, (e.rng.Float64()*2-1)*amp)
+		}
+	}
+	if e.Camera != nil {
+		e.Camera.Offset = e.Camera.Offset.Add(e.offset)
+	}
+
+	if e.flash.left > 0 {
+		e.flash.left -= dt
+	}
+}
+
+// Stop ends any shake and flash at once
+func (e *Effects) Stop() {
+	e.shakeLeft, e.flash.left = 0, 0
+	e.Update()
+}
+
+// Remove stops the effects and detaches them from the canvas
+func (e *Effects) Remove() {
+	e.Stop()
+	e.c.removePass(&e.flash)
+}
+
+func (f *flashPass) prepare(c *Canvasp) bool {
+	if f.left > 0 {
+		f.shown = true
+		return true
+	}
+	if f.shown {
+		f.shown = false
+		return true
+	}
+	return false
+}
+
+func (f *flashPass) active() bool { return f.left > 0 }
+
+func (f *flashPass) overOverlays() bool { return true }
+
+func (f *flashPass) row(dst []uint8, y int) {
+	a := uint32(float64(f.col.A) * f.left.Seconds() / f.length.Seconds())
+	col := color.RGBA{f.col.R, f.col.G, f.col.B, 255}
+	for i := 0; i+3 < len(dst); i += 4 {
+		mixStraight(dst[i:i+4], col, a)
+	}
+}
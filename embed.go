@@ -0,0 +1,198 @@
+package pixelcanvas
+
+import (
+	"syscall/js"
+)
+
+// Embedding
+//
+// A canvas running inside an iframe as a widget sizes itself to the frame
+// and talks to the host page with postMessage. Messages in both directions
+// are objects with a "type" field prefixed "pixelcanvas:"; the host sizes
+// the iframe from the widget's size reports, may ask it for a size, and can
+// send a "pixelcanvas:ping" to have the current size reported again:
+//
+//	window.addEventListener("message", e => {
+//		if (e.source === frame.contentWindow && e.data.type === "pixelcanvas:size") {
+//			frame.style.width = e.data.width + "px"
+//			frame.style.height = e.data.height + "px"
+//		}
+//	})
+//	frame.contentWindow.postMessage({type: "pixelcanvas:resize", width: 640, height: 480}, origin)
+//
+// Embedded pages are often restricted by the iframe's sandbox and allow
+// attributes; EmbedInfo reports what the frame permits so apps can hide
+// fullscreen or pointer lock controls that would only fail.
+
+// embedPrefix starts the type of every message the embed protocol sends or
+// understands
+const embedPrefix = "pixelcanvas:"
+
+// EmbedInfo describes the frame the page is running in
+type EmbedInfo struct {
+	Embedded    bool // Running inside an iframe
+	CrossOrigin bool // The top level page is on another origin
+	Fullscreen  bool // Fullscreen is available and permitted (allow="fullscreen")
+	PointerLock bool // The Pointer Lock API is present. A sandbox without allow-pointer-lock only shows when a request fails
+}
+
+// EmbedInfo reports whether the page is embedded and what the frame allows
+func (c *Canvasp) EmbedInfo() EmbedInfo {
+	var e EmbedInfo
+	e.Embedded = !c.window.Get("top").Equal(c.window.Get("self"))
+	if e.Embedded {
+		e.CrossOrigin = !sameOriginTop(c.window)
+	}
+
+	if v := c.doc.Get("fullscreenEnabled"); !v.IsUndefined() {
+		e.Fullscreen = v.Bool()
+	} else if v := c.doc.Get("webkitFullscreenEnabled"); !v.IsUndefined() {
+		e.Fullscreen = v.Bool()
+	}
+	e.PointerLock = !c.canvas.IsUndefined() && !c.canvas.Get("requestPointerLock").IsUndefined()
+	return e
+}
+
+// sameOriginTop reports whether the top level page can be read from this
+// frame. The browser throws on cross-origin access, which syscall/js turns
+// into a panic.
+func sameOriginTop(window js.Value) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	window.Get("top").Get("location").Get("href")
+	return true
+}
+
+// Embed runs the widget side of the embed protocol, see NewEmbed
+type Embed struct {
+	// OnResize, if set, is called after the canvas has been resized to fit
+	// the frame or at the host's request
+	OnResize func(width, height int)
+
+	// OnMessage, if set, receives the data of messages from the host that
+	// aren't part of the embed protocol
+	OnMessage func(data js.Value)
+
+	c       *Canvasp
+	origin  string
+	parent  js.Value
+	message *listener
+	resize  *listener
+}
+
+// NewEmbed starts the embed protocol with the host page at parentOrigin
+// (e.g. "https://example.com"). Only messages from that origin are
+// accepted and replies are only delivered to it; "*" accepts any host, for
+// widgets meant to be embedded anywhere. When fit is set the canvas fills
+// the frame and follows its size. The host is sent a "ready" message with
+// the canvas size.
+//
+// Outside an iframe NewEmbed still works, there's just no one to talk to.
+func (c *Canvasp) NewEmbed(parentOrigin string, fit bool) *Embed {
+	m := &Embed{
+		c:      c,
+		origin: parentOrigin,
+		parent: c.window.Get("parent"),
+	}
+
+	m.message = c.listen(c.window, "message", m.receive)
+	if fit {
+		style := c.canvas.Get("style")
+		style.Set("display", "block") // No inline baseline gap to cause scrollbars
+		c.body.Get("style").Set("margin", "0")
+		m.resize = c.listen(c.window, "resize", func(js.Value) { m.Fit() })
+		m.Fit()
+	}
+	m.Post("ready", map[string]interface{}{"width": c.width, "height": c.height})
+	return m
+}
+
+// Fit resizes the canvas to the frame's viewport
+func (m *Embed) Fit() {
+	w := m.c.window.Get("innerWidth").Int()
+	h := m.c.window.Get("innerHeight").Int()
+	m.setSize(w, h, false)
+}
+
+// RequestSize asks the host to resize the frame to width x height CSS
+// pixels, e.g. when the content needs more room. The host decides; with fit
+// set the canvas follows once the frame actually changes.
+func (m *Embed) RequestSize(width, height int) {
+	m.Post("size", map[string]interface{}{"width": width, "height": height})
+}
+
+// Post sends a message of type "pixelcanvas:"+kind to the host page, with
+// the fields of data alongside
+func (m *Embed) Post(kind string, data map[string]interface{}) {
+	if m.parent.Equal(m.c.window) {
+		return
+	}
+	msg := map[string]interface{}{"type": embedPrefix + kind}
+	for k, v := range data {
+		msg[k] = v
+	}
+	m.parent.Call("postMessage", msg, m.origin)
+}
+
+// Stop ends the protocol and removes its listeners. The canvas keeps its
+// current size.
+func (m *Embed) Stop() {
+	if m.message != nil {
+		m.c.unlisten(m.message)
+		m.message = nil
+	}
+	if m.resize != nil {
+		m.c.unlisten(m.resize)
+		m.resize = nil
+	}
+}
+
+// receive handles a message event from the host
+func (m *Embed) receive(e js.Value) {
+	if !e.Get("source").Equal(m.parent) {
+		return
+	}
+	if m.origin != "*" && e.Get("origin").String() != m.origin {
+		return
+	}
+	data := e.Get("data")
+	kind := ""
+	if data.Type() == js.TypeObject && !data.IsNull() {
+		if t := data.Get("type"); t.Type() == js.TypeString {
+			kind = t.String()
+		}
+	}
+
+	switch kind {
+	case embedPrefix + "resize":
+		w, h := data.Get("width"), data.Get("height")
+		if w.Type() == js.TypeNumber && h.Type() == js.TypeNumber {
+			m.setSize(w.Int(), h.Int(), true)
+		}
+	case embedPrefix + "ping":
+		m.Post("size", map[string]interface{}{"width": m.c.width, "height": m.c.height})
+	default:
+		if m.OnMessage != nil {
+			m.OnMessage(data)
+		}
+	}
+}
+
+// setSize resizes the canvas, and the frame's view of it. Sizes the host
+// asked for are reported back so it knows they took effect.
+func (m *Embed) setSize(width, height int, report bool) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+	changed := width != m.c.width || height != m.c.height
+	m.c.Resize(width, height)
+	if report {
+		m.Post("size", map[string]interface{}{"width": width, "height": height})
+	}
+	if changed && m.OnResize != nil {
+		m.OnResize(width, height)
+	}
+}
@@ -0,0 +1,47 @@
+package pixelcanvas
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+)
+
+// Image returns a copy of the shadow canvas as an image.NRGBA.
+//
+// The pixels are converted from the declared PixelFormat to straight alpha,
+// and the rows put in the browser's order (see SetVerticalFlip), exactly as
+// for the copy to the browser, so the image matches what is shown without
+// the overlays.
+func (c *Canvasp) Image() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, c.width, c.height))
+	c.convertTo(img.Pix, c.pixels(), false)
+	return img
+}
+
+// EncodePNG encodes the shadow canvas as a PNG, keeping transparency
+func (c *Canvasp) EncodePNG() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.Image()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeJPEG encodes the shadow canvas as a JPEG with quality 1-100.
+// JPEG has no alpha channel, so transparent areas come out black.
+func (c *Canvasp) EncodeJPEG(quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, c.Image(), &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// flipRows copies h rows of stride bytes from src to dst in reverse order.
+// dst and src must not overlap.
+func flipRows(dst []uint8, src []uint8, stride int, h int) {
+	for y := 0; y < h; y++ {
+		copy(dst[y*stride:(y+1)*stride], src[(h-1-y)*stride:(h-y)*stride])
+	}
+}
@@ -0,0 +1,260 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Mask is a per-pixel selection over the canvas, in shadow canvas pixels
+// whatever SetCoordinates chose.
+type Mask struct {
+	Width, Height int
+	Bits          []bool // Row-major, row 0 at the bottom like the pixel buffer
+
+	x0, y0, x1, y1 int // Bounding box of the set bits, empty when x0 >= x1
+}
+
+// NewMask creates an empty mask of the given size
+func NewMask(width int, height int) *Mask {
+	return &Mask{Width: width, Height: height, Bits: make([]bool, width*height)}
+}
+
+// Contains reports whether pixel (x, y) is selected
+func (m *Mask) Contains(x int, y int) bool {
+	if x < 0 || y < 0 || x >= m.Width || y >= m.Height {
+		return false
+	}
+	return m.Bits[y*m.Width+x]
+}
+
+// Set selects or deselects pixel (x, y)
+func (m *Mask) Set(x int, y int, on bool) {
+	if x < 0 || y < 0 || x >= m.Width || y >= m.Height {
+		return
+	}
+	m.Bits[y*m.Width+x] = on
+	if on {
+		m.grow(x, y, x+1, y+1)
+	}
+}
+
+// SetRect selects every pixel in r
+func (m *Mask) SetRect(r pixel.Rect) {
+	r = r.Norm()
+	x0, y0 := clampInt(int(math.Floor(r.Min.X)), 0, m.Width), clampInt(int(math.Floor(r.Min.Y)), 0, m.Height)
+	x1, y1 := clampInt(int(math.Ceil(r.Max.X)), 0, m.Width), clampInt(int(math.Ceil(r.Max.Y)), 0, m.Height)
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			m.Bits[y*m.Width+x] = true
+		}
+	}
+	if x0 < x1 && y0 < y1 {
+		m.grow(x0, y0, x1, y1)
+	}
+}
+
+// Bounds returns the smallest rectangle containing every selected pixel
+func (m *Mask) Bounds() pixel.Rect {
+	if m.x0 >= m.x1 {
+		return pixel.Rect{}
+	}
+	return intRect(m.x0, m.y0, m.x1, m.y1)
+}
+
+// Empty reports whether nothing is selected
+func (m *Mask) Empty() bool {
+	return m.x0 >= m.x1
+}
+
+// Count returns the number of selected pixels
+func (m *Mask) Count() int {
+	n := 0
+	for _, b := range m.Bits {
+		if b {
+			n++
+		}
+	}
+	return n
+}
+
+// Invert flips the selection
+func (m *Mask) Invert() {
+	for i := range m.Bits {
+		m.Bits[i] = !m.Bits[i]
+	}
+	m.recalc()
+}
+
+// Union adds o's selection to m. Both masks must be the same size.
+func (m *Mask) Union(o *Mask) {
+	for i, b := range o.Bits {
+		m.Bits[i] = m.Bits[i] || b
+	}
+	m.recalc()
+}
+
+// Intersect keeps only pixels selected in both masks
+func (m *Mask) Intersect(o *Mask) {
+	for i, b := range o.Bits {
+		m.Bits[i] = m.Bits[i] && b
+	}
+	m.recalc()
+}
+
+func (m *Mask) grow(x0, y0, x1, y1 int) {
+	if m.x0 >= m.x1 {
+		m.x0, m.y0, m.x1, m.y1 = x0, y0, x1, y1
+		return
+	}
+	if x0 < m.x0 {
+		m.x0 = x0
+	}
+	if y0 < m.y0 {
+		m.y0 = y0
+	}
+	if x1 > m.x1 {
+		m.x1 = x1
+	}
+	if y1 > m.y1 {
+		m.y1 = y1
+	}
+}
+
+func (m *Mask) recalc() {
+	m.x0, m.y0, m.x1, m.y1 = 0, 0, 0, 0
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			if m.Bits[y*m.Width+x] {
+				m.grow(x, y, x+1, y+1)
+			}
+		}
+	}
+}
+
+// FloodFill fills the contiguous area around 'at' whose colour is within
+// tolerance (per channel, 0 = exact match) of the colour at 'at'. It returns
+// the bounds of the changed area, which is empty if nothing changed.
+func (c *Canvasp) FloodFill(at pixel.Vec, col color.Color, tolerance uint8) pixel.Rect {
+	pix := c.pixels()
+	m := c.selectRegion(pix, c.canvasPixel(at), tolerance, true)
+	if m.Empty() {
+		return pixel.Rect{}
+	}
+	fillMask(pix, m, rgba8(col))
+	c.pixelsChanged(pix)
+	return c.FromCanvasRect(m.Bounds())
+}
+
+// SelectRegion is the magic wand: it returns a mask of pixels within tolerance
+// of the colour at 'at'. When contiguous is false every matching pixel on the
+// canvas is selected, not just the connected area.
+func (c *Canvasp) SelectRegion(at pixel.Vec, tolerance uint8, contiguous bool) *Mask {
+	return c.selectRegion(c.pixels(), c.canvasPixel(at), tolerance, contiguous)
+}
+
+// FillMask fills every selected pixel with col
+func (c *Canvasp) FillMask(m *Mask, col color.Color) {
+	if m.Empty() {
+		return
+	}
+	pix := c.pixels()
+	fillMask(pix, m, rgba8(col))
+	c.pixelsChanged(pix)
+}
+
+// DrawMasked runs draw against the shadow canvas, then discards any changes
+// it made outside the mask, so arbitrary drawing calls can be confined to a
+// selection.
+func (c *Canvasp) DrawMasked(m *Mask, draw func(gc *Canvas)) {
+	before := c.image.Pixels()
+	draw(c.image)
+	after := c.pixels()
+
+	for i, sel := range m.Bits {
+		if !sel {
+			copy(after[i*4:i*4+4], before[i*4:i*4+4])
+		}
+	}
+	c.pixelsChanged(after)
+}
+
+func (c *Canvasp) selectRegion(pix []uint8, at point, tol uint8, contiguous bool) *Mask {
+	w, h := c.width, c.height
+	m := NewMask(w, h)
+	sx, sy := at.x, at.y
+	if sx < 0 || sy < 0 || sx >= w || sy >= h {
+		return m
+	}
+
+	var target [4]uint8
+	copy(target[:], pix[(sy*w+sx)*4:])
+	match := func(x, y int) bool {
+		i := y*w + x
+		return !m.Bits[i] && within(pix[i*4:i*4+4], target, tol)
+	}
+
+	if !contiguous {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				if match(x, y) {
+					m.Set(x, y, true)
+				}
+			}
+		}
+		return m
+	}
+
+	// Scanline fill: take a seed, extend it left and right to a full span,
+	// mark it, then seed the rows above and below.
+	stack := []int{sx, sy}
+	for len(stack) > 0 {
+		x, y := stack[len(stack)-2], stack[len(stack)-1]
+		stack = stack[:len(stack)-2]
+		if !match(x, y) {
+			continue
+		}
+
+		x0, x1 := x, x
+		for x0 > 0 && match(x0-1, y) {
+			x0--
+		}
+		for x1 < w-1 && match(x1+1, y) {
+			x1++
+		}
+		for i := x0; i <= x1; i++ {
+			m.Bits[y*w+i] = true
+		}
+		m.grow(x0, y, x1+1, y+1)
+
+		for _, ny := range [2]int{y - 1, y + 1} {
+			if ny < 0 || ny >= h {
+				continue
+			}
+			inSpan := false
+			for i := x0; i <= x1; i++ {
+				if match(i, ny) {
+					if !inSpan {
+						stack = append(stack, i, ny)
+						inSpan = true
+					}
+				} else {
+					inSpan = false
+				}
+			}
+		}
+	}
+	return m
+}
+
+func fillMask(pix []uint8, m *Mask, col [4]uint8) {
+	for y := m.y0; y < m.y1; y++ {
+		for x := m.x0; x < m.x1; x++ {
+			i := y*m.Width + x
+			if m.Bits[i] {
+				copy(pix[i*4:i*4+4], col[:])
+			}
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package pixelcanvas
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Image filters
+//
+// Each filter works in place on area r of the shadow canvas, in logical
+// coordinates (the zero Rect for the whole canvas), and returns the area
+// changed, ready for
+// History.CommitRect. Blurs work on the premultiplied pixels directly, which
+// is what stops transparent pixels bleeding dark fringes into their
+// neighbours; colour adjustments un-premultiply first. Working buffers come
+// from a pool and are reused, so repeated filtering (e.g. every frame)
+// doesn't allocate beyond the pixel read back the Canvas itself does.
+
+// filterScratch holds the buffers reused between filter calls
+type filterScratch struct {
+	area []uint8   // The area being filtered, compact
+	tmp  []uint8   // Between separable passes
+	orig []uint8   // Unfiltered copy, for filters that mix it back in
+	lum  []int32   // Per pixel luminance
+	acc  []float32 // Between separable convolution passes
+}
+
+// scratch returns *buf resized to n, reallocating only if it must grow
+func scratch(buf *[]uint8, n int) []uint8 {
+	if cap(*buf) < n {
+		*buf = make([]uint8, n)
+	}
+	*buf = (*buf)[:n]
+	return *buf
+}
+
+// filter runs fn over a compact copy of area r, with pooled scratch buffers,
+// and writes the result back
+func (c *Canvasp) filter(r pixel.Rect, fn func(s *filterScratch, pix []uint8, w, h int)) pixel.Rect {
+	r = c.ToCanvasRect(r)
+	if r == (pixel.Rect{}) {
+		r = c.image.Bounds()
+	}
+	x0, y0, x1, y1 := c.pixelRect(r)
+	if x0 >= x1 || y0 >= y1 {
+		return pixel.Rect{}
+	}
+	w, h := x1-x0, y1-y0
+	pix := c.pixels()
+	s := getScratch()
+	defer putScratch(s)
+	area := scratch(&s.area, w*h*4)
+	for y := y0; y < y1; y++ {
+		copy(area[(y-y0)*w*4:], pix[(y*c.width+x0)*4:(y*c.width+x1)*4])
+	}
+	fn(s, area, w, h)
+	pasteRect(pix, c.width, x0, y0, x1, y1, area)
+	c.pixelsChanged(pix)
+	return c.FromCanvasRect(intRect(x0, y0, x1, y1))
+}
+
+// BoxBlur averages each pixel with its neighbours up to radius pixels away
+func (c *Canvasp) BoxBlur(r pixel.Rect, radius int) pixel.Rect {
+	return c.filter(r, func(s *filterScratch, pix []uint8, w, h int) {
+		s.boxBlur(pix, w, h, radius)
+	})
+}
+
+// GaussianBlur blurs with standard deviation sigma pixels, approximated by
+// three box blurs, so the cost doesn't grow with sigma
+func (c *Canvasp) GaussianBlur(r pixel.Rect, sigma float64) pixel.Rect {
+	return c.filter(r, func(s *filterScratch, pix []uint8, w, h int) {
+		s.gaussianBlur(pix, w, h, sigma)
+	})
+}
+
+// Sharpen applies an unsharp mask: amount times the difference from a
+// slightly blurred copy is added back. 0.5-1.5 are typical.
+func (c *Canvasp) Sharpen(r pixel.Rect, amount float64) pixel.Rect {
+	return c.filter(r, func(s *filterScratch, pix []uint8, w, h int) {
+		orig := scratch(&s.orig, len(pix))
+		copy(orig, pix)
+		s.gaussianBlur(pix, w, h, 1)
+		for i := 0; i < len(pix); i += 4 {
+			a := float64(orig[i+3])
+			for ch := 0; ch < 3; ch++ {
+				o := float64(orig[i+ch])
+				v := o + amount*(o-float64(pix[i+ch]))
+				pix[i+ch] = uint8(math.Max(0, math.Min(a, v)) + 0.5) // Premultiplied, so no more than alpha
+			}
+			pix[i+3] = orig[i+3]
+		}
+	})
+}
+
+// BrightnessContrast adjusts brightness and contrast, each -1 to 1 with 0
+// unchanged. Brightness shifts every channel by up to full scale; contrast
+// stretches or squashes values about mid grey.
+func (c *Canvasp) BrightnessContrast(r pixel.Rect, brightness float64, contrast float64) pixel.Rect {
+	contrast = math.Max(-1, math.Min(0.999, contrast))
+	k := (1 + contrast) / (1 - contrast)
+	var lut [256]uint8
+	for v := range lut {
+		f := (float64(v)-127.5)*k + 127.5 + brightness*255
+		lut[v] = uint8(math.Max(0, math.Min(255, f)) + 0.5)
+	}
+	return c.filter(r, func(s *filterScratch, pix []uint8, w, h int) {
+		mapStraight(pix, func(px []uint8) {
+			px[0], px[1], px[2] = lut[px[0]], lut[px[1]], lut[px[2]]
+		})
+	})
+}
+
+// HueShift rotates hues by degrees, keeping luminance, using the same
+// matrix as the CSS hue-rotate filter
+func (c *Canvasp) HueShift(r pixel.Rect, degrees float64) pixel.Rect {
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	m := [9]float64{
+		0.213 + cos*0.787 - sin*0.213, 0.715 - cos*0.715 - sin*0.715, 0.072 - cos*0.072 + sin*0.928,
+		0.213 - cos*0.213 + sin*0.143, 0.715 + cos*0.285 + sin*0.140, 0.072 - cos*0.072 - sin*0.283,
+		0.213 - cos*0.213 - sin*0.787, 0.715 - cos*0.715 + sin*0.715, 0.072 + cos*0.928 + sin*0.072,
+	}
+	return c.filter(r, func(s *filterScratch, pix []uint8, w, h int) {
+		// The matrix is linear, so it works on premultiplied values as they are
+		for i := 0; i < len(pix); i += 4 {
+			if pix[i+3] == 0 {
+				continue
+			}
+			a := float64(pix[i+3])
+			rr, gg, bb := float64(pix[i]), float64(pix[i+1]), float64(pix[i+2])
+			for ch := 0; ch < 3; ch++ {
+				v := m[ch*3]*rr + m[ch*3+1]*gg + m[ch*3+2]*bb
+				pix[i+ch] = uint8(math.Max(0, math.Min(a, v)) + 0.5)
+			}
+		}
+	})
+}
+
+// Threshold turns pixels white where their luminance is at least level and
+// black below it, keeping alpha
+func (c *Canvasp) Threshold(r pixel.Rect, level uint8) pixel.Rect {
+	return c.filter(r, func(s *filterScratch, pix []uint8, w, h int) {
+		mapStraight(pix, func(px []uint8) {
+			v := uint8(0)
+			if luma(px[0], px[1], px[2]) >= int32(level) {
+				v = 255
+			}
+			px[0], px[1], px[2] = v, v, v
+		})
+	})
+}
+
+// Sobel replaces the area with its edge strength: the Sobel gradient
+// magnitude of the luminance, as opaque grey. Flat areas go black, edges
+// white.
+func (c *Canvasp) Sobel(r pixel.Rect) pixel.Rect {
+	return c.filter(r, func(s *filterScratch, pix []uint8, w, h int) {
+		s.sobel(pix, w, h)
+	})
+}
+
+func (s *filterScratch) boxBlur(pix []uint8, w, h, radius int) {
+	if radius < 1 {
+		return
+	}
+	tmp := scratch(&s.tmp, len(pix))
+	boxPass(tmp, pix, w, h, 4, w*4, radius) // Rows
+	boxPass(pix, tmp, h, w, w*4, 4, radius) // Columns
+}
+
+func (s *filterScratch) gaussianBlur(pix []uint8, w, h int, sigma float64) {
+	for _, r := range gaussBoxes(sigma) {
+		s.boxBlur(pix, w, h, r)
+	}
+}
+
+// boxPass blurs n lines of length pixels each, to radius, with a running
+// sum. step is the byte distance between pixels along a line and stride
+// between lines, so the same code does rows and columns. Edges clamp.
+func boxPass(dst, src []uint8, length, n, step, stride, radius int) {
+	div := uint32(2*radius + 1)
+	last := length - 1
+	for l := 0; l < n; l++ {
+		base := l * stride
+		var sum [4]uint32
+		for k := -radius; k <= radius; k++ {
+			p := base + clampInt(k, 0, last)*step
+			sum[0] += uint32(src[p])
+			sum[1] += uint32(src[p+1])
+			sum[2] += uint32(src[p+2])
+			sum[3] += uint32(src[p+3])
+		}
+		for i := 0; i < length; i++ {
+			d := base + i*step
+			dst[d] = uint8((sum[0] + div/2) / div)
+			dst[d+1] = uint8((sum[1] + div/2) / div)
+			dst[d+2] = uint8((sum[2] + div/2) / div)
+			dst[d+3] = uint8((sum[3] + div/2) / div)
+
+			in := base + minInt(i+radius+1, last)*step
+			out := base + maxInt(i-radius, 0)*step
+			sum[0] += uint32(src[in]) - uint32(src[out])
+			sum[1] += uint32(src[in+1]) - uint32(src[out+1])
+			sum[2] += uint32(src[in+2]) - uint32(src[out+2])
+			sum[3] += uint32(src[in+3]) - uint32(src[out+3])
+		}
+	}
+}
+
+// gaussBoxes picks three box blur radii whose combination approximates a
+// gaussian of sigma
+func gaussBoxes(sigma float64) [3]int {
+	var radii [3]int
+	if sigma <= 0 {
+		return radii
+	}
+	const n = 3
+	wl := int(math.Floor(math.Sqrt(12*sigma*sigma/n + 1)))
+	if wl%2 == 0 {
+		wl--
+	}
+	wu := wl + 2
+	m := int(math.Round((12*sigma*sigma - n*float64(wl*wl) - 4*n*float64(wl) - 3*n) / float64(-4*wl-4)))
+	for i := range radii {
+		size := wu
+		if i < m {
+			size = wl
+		}
+		radii[i] = (size - 1) / 2
+	}
+	return radii
+}
+
+func (s *filterScratch) sobel(pix []uint8, w, h int) {
+	if cap(s.lum) < w*h {
+		s.lum = make([]int32, w*h)
+	}
+	lum := s.lum[:w*h]
+	for i := range lum {
+		lum[i] = luma(pix[i*4], pix[i*4+1], pix[i*4+2])
+	}
+	at := func(x, y int) int32 {
+		return lum[clampInt(y, 0, h-1)*w+clampInt(x, 0, w-1)]
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gx := at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x-1, y) - at(x-1, y+1)
+			gy := at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x, y-1) - at(x+1, y-1)
+			v := uint8(math.Min(255, math.Sqrt(float64(gx*gx+gy*gy))))
+			i := (y*w + x) * 4
+			pix[i], pix[i+1], pix[i+2], pix[i+3] = v, v, v, 255
+		}
+	}
+}
+
+// mapStraight runs fn on each visible pixel un-premultiplied, and
+// premultiplies the result again
+func mapStraight(pix []uint8, fn func(px []uint8)) {
+	for i := 0; i < len(pix); i += 4 {
+		a := pix[i+3]
+		if a == 0 {
+			continue
+		}
+		px := pix[i : i+4]
+		if a < 255 {
+			u := &unpremul[a]
+			px[0], px[1], px[2] = u[px[0]], u[px[1]], u[px[2]]
+		}
+		fn(px)
+		if a < 255 {
+			px[0] = uint8((uint32(px[0])*uint32(a) + 127) / 255)
+			px[1] = uint8((uint32(px[1])*uint32(a) + 127) / 255)
+			px[2] = uint8((uint32(px[2])*uint32(a) + 127) / 255)
+		}
+	}
+}
+
+// luma is the Rec. 601 luminance of a colour, 0-255
+func luma(r, g, b uint8) int32 {
+	return (299*int32(r) + 587*int32(g) + 114*int32(b) + 500) / 1000
+}
@@ -0,0 +1,125 @@
+package pixelcanvas
+
+import (
+	"fmt"
+	"strings"
+	"syscall/js"
+)
+
+// Web fonts, through the CSS Font Loading API (FontFace and document.fonts).
+// Fonts loaded here can be named in TextStyle.Font. Text drawn before its
+// font has loaded silently falls back to another font, so load and wait
+// first. The loading calls block: call them from a goroutine, not from a
+// RenderFunc or event handler.
+
+// FontOptions are the optional descriptors of a loaded font, as in CSS
+// @font-face. Empty fields take the browser defaults.
+type FontOptions struct {
+	Weight       string // e.g. "bold" or "100 900" for a variable font
+	Style        string // "normal", "italic" or "oblique"
+	Stretch      string
+	UnicodeRange string // e.g. "U+0000-00FF"
+}
+
+// LoadFont downloads the font at url (WOFF2, WOFF, TTF or OTF), adds it to
+// the page as family and waits until it is ready to draw with
+func (c *Canvasp) LoadFont(family string, url string, opts FontOptions) error {
+	return c.addFont(family, fmt.Sprintf("url(%q)", url), opts)
+}
+
+// LoadFontData adds a font from its file contents, e.g. fetched with
+// FetchBytes or generated into the app
+func (c *Canvasp) LoadFontData(family string, data []byte, opts FontOptions) error {
+	arr := c.window.Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(arr, data)
+	return c.addFont(family, arr.Get("buffer"), opts)
+}
+
+func (c *Canvasp) addFont(family string, source interface{}, opts FontOptions) error {
+	ctor := c.window.Get("FontFace")
+	if ctor.IsUndefined() {
+		return fmt.Errorf("pixelcanvas: font loading is not supported")
+	}
+	desc := js.Global().Get("Object").New()
+	for k, v := range map[string]string{
+		"weight":       opts.Weight,
+		"style":        opts.Style,
+		"stretch":      opts.Stretch,
+		"unicodeRange": opts.UnicodeRange,
+	} {
+		if v != "" {
+			desc.Set(k, v)
+		}
+	}
+
+	face := ctor.New(family, source, desc)
+	if _, err := await(face.Call("load")); err != nil {
+		return fmt.Errorf("pixelcanvas: loading font %s: %v", family, err)
+	}
+	c.doc.Get("fonts").Call("add", face)
+	c.log().Debug("font loaded", "family", family)
+	return nil
+}
+
+// WaitForText waits until every font needed to draw text in style is
+// loaded, starting any downloads for fonts the page declares (e.g. in CSS
+// @font-face rules) but hasn't fetched yet. It reports whether a matching
+// font was found.
+func (c *Canvasp) WaitForText(style TextStyle, text string) (bool, error) {
+	font := style.Font
+	if font == "" {
+		font = DefaultFont
+	}
+	faces, err := await(c.doc.Get("fonts").Call("load", font, text))
+	if err != nil {
+		return false, err
+	}
+	return faces.Length() > 0, nil
+}
+
+// FontsReady waits until all pending font loads on the page have finished
+func (c *Canvasp) FontsReady() error {
+	_, err := await(c.doc.Get("fonts").Get("ready"))
+	return err
+}
+
+// FontAvailable reports whether text in style can be drawn without waiting
+// for a font to load. It doesn't block.
+func (c *Canvasp) FontAvailable(style TextStyle) bool {
+	font := style.Font
+	if font == "" {
+		font = DefaultFont
+	}
+	return c.doc.Get("fonts").Call("check", font).Bool()
+}
+
+// FontFamilies lists the families added to the page through LoadFont or CSS
+// @font-face, loaded or not. Built in system fonts aren't listed.
+func (c *Canvasp) FontFamilies() []string {
+	var families []string
+	seen := make(map[string]bool)
+	each := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		f := strings.Trim(args[0].Get("family").String(), `"'`)
+		if !seen[f] {
+			seen[f] = true
+			families = append(families, f)
+		}
+		return nil
+	})
+	c.doc.Get("fonts").Call("forEach", each)
+	each.Release()
+	return families
+}
+
+// OnFontsLoaded calls fn with the families loaded whenever a batch of font
+// loads finishes, e.g. to re-measure and redraw text that used a fallback
+func (c *Canvasp) OnFontsLoaded(fn func(families []string)) {
+	c.listen(c.doc.Get("fonts"), "loadingdone", func(e js.Value) {
+		faces := e.Get("fontfaces")
+		families := make([]string, faces.Length())
+		for i := range families {
+			families[i] = strings.Trim(faces.Index(i).Get("family").String(), `"'`)
+		}
+		fn(families)
+	})
+}
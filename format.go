@@ -0,0 +1,114 @@
+package pixelcanvas
+
+import "encoding/binary"
+
+// PixelFormat describes the byte layout of the shadow canvas pixels, as
+// produced by the Canvas or by an external renderer writing into it with
+// SetPixels. ImageData always wants straight (non-premultiplied) RGBA, so
+// imgCopy converts from this format during the copy.
+type PixelFormat int
+
+// Pixel formats
+const (
+	FormatRGBAPremultiplied PixelFormat = iota // The Canvas's native layout. The default
+	FormatRGBA                                 // Straight alpha RGBA, copied as is
+	FormatBGRAPremultiplied
+	FormatBGRA
+)
+
+// String implements fmt.Stringer
+func (f PixelFormat) String() string {
+	switch f {
+	case FormatRGBAPremultiplied:
+		return "RGBA (premultiplied)"
+	case FormatRGBA:
+		return "RGBA"
+	case FormatBGRAPremultiplied:
+		return "BGRA (premultiplied)"
+	case FormatBGRA:
+		return "BGRA"
+	}
+	return "unknown"
+}
+
+func (f PixelFormat) premultiplied() bool {
+	return f == FormatRGBAPremultiplied || f == FormatBGRAPremultiplied
+}
+
+func (f PixelFormat) bgra() bool {
+	return f == FormatBGRA || f == FormatBGRAPremultiplied
+}
+
+// SetPixelFormat declares the layout of the shadow canvas pixels. Only
+// needed when something other than the Canvas fills the buffer.
+func (c *Canvasp) SetPixelFormat(f PixelFormat) {
+	c.format = f
+}
+
+// PixelFormat returns the declared layout of the shadow canvas pixels
+func (c *Canvasp) PixelFormat() PixelFormat {
+	return c.format
+}
+
+// unpremul[a][v] is v un-premultiplied by alpha a, i.e. v*255/a rounded
+var unpremul [256][256]uint8
+
+func init() {
+	for a := 1; a < 256; a++ {
+		for v := 0; v < 256; v++ {
+			u := (v*255 + a/2) / a
+			if u > 255 {
+				u = 255
+			}
+			unpremul[a][v] = uint8(u)
+		}
+	}
+}
+
+// convertRow converts one row of pixels from format f to straight RGBA.
+// Pixels are taken two at a time as 64-bit words (see simd.go), so pairs
+// that are both opaque, which need no un-premultiplying, or both
+// transparent are converted with a couple of word operations.
+func convertRow(dst []uint8, src []uint8, f PixelFormat) {
+	if f == FormatRGBA {
+		copy(dst, src)
+		return
+	}
+
+	ri, bi := 0, 2 // Source offsets of red and blue
+	if f.bgra() {
+		ri, bi = 2, 0
+	}
+	pre := f.premultiplied()
+
+	n := len(src) &^ 7
+	i := 0
+	for ; i < n; i += 8 {
+		v := binary.LittleEndian.Uint64(src[i:])
+		switch a := v & alphaMask2; {
+		case a == alphaMask2 || !pre:
+			if ri != 0 {
+				v = swapRB2(v)
+			}
+			binary.LittleEndian.PutUint64(dst[i:], v)
+		case a == 0:
+			binary.LittleEndian.PutUint64(dst[i:], 0)
+		default:
+			convertPixel(dst[i:i+4], src[i:i+4], ri, bi, pre)
+			convertPixel(dst[i+4:i+8], src[i+4:i+8], ri, bi, pre)
+		}
+	}
+	for ; i+3 < len(src); i += 4 {
+		convertPixel(dst[i:i+4], src[i:i+4], ri, bi, pre)
+	}
+}
+
+func convertPixel(d []uint8, s []uint8, ri, bi int, pre bool) {
+	a := s[3]
+	r, g, b := s[ri], s[1], s[bi]
+	if pre && a != 255 {
+		t := &unpremul[a]
+		r, g, b = t[r], t[g], t[b]
+	}
+	d[0], d[1], d[2], d[3] = r, g, b, a
+}
@@ -0,0 +1,381 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+	"syscall/js"
+
+	"github.com/faiface/pixel"
+)
+
+// Guide is a ruler guide line. Pos is a world x for a vertical guide and a
+// world y for a horizontal one.
+type Guide struct {
+	Vertical bool
+	Pos      float64
+}
+
+// Guides draws a pixel grid, rulers along the top and left edges, and guide
+// lines on an Overlay, so none of it reaches exported artwork. With
+// Interactive, guides are dragged out of the rulers, moved, and removed by
+// dragging them back onto a ruler.
+type Guides struct {
+	Camera *Camera // Maps world units onto the canvas. nil for canvas pixels
+
+	Grid          float64 // Grid spacing in world units. 0 for no grid
+	Major         int     // Every Major'th grid line uses MajorColor. 0 for none
+	MinGridPixels float64 // The grid is hidden when its lines are closer than this on screen
+
+	Rulers    bool
+	RulerSize int // Ruler thickness in canvas pixels
+
+	Lines []Guide
+
+	GridColor  color.Color
+	MajorColor color.Color
+	RulerColor color.Color
+	TickColor  color.Color
+	GuideColor color.Color
+
+	// OnChange, if set, is called when the user adds, moves or removes a guide
+	OnChange func(lines []Guide)
+
+	c       *Canvasp
+	overlay *Overlay
+	last    Camera // Camera as last drawn, to notice it moving
+
+	drag      int // Index in Lines of the guide being dragged, -1 for none
+	listeners []*listener
+}
+
+// Guide defaults
+const (
+	DefaultRulerSize     = 16
+	DefaultMinGridPixels = 4
+	guideGrab            = 4  // Pointer distance in canvas pixels to pick up a guide
+	rulerLabelSpacing    = 50 // Minimum canvas pixels between labelled ruler ticks
+)
+
+// AddGuides adds a grid, ruler and guide overlay. It starts with nothing
+// enabled; set Grid, Rulers or Lines and call Update.
+func (c *Canvasp) AddGuides(cam *Camera) *Guides {
+	g := &Guides{
+		Camera:        cam,
+		MinGridPixels: DefaultMinGridPixels,
+		RulerSize:     DefaultRulerSize,
+		GridColor:     color.RGBA{0, 0, 0, 40},
+		MajorColor:    color.RGBA{0, 0, 0, 90},
+		RulerColor:    color.RGBA{230, 230, 230, 255},
+		TickColor:     color.RGBA{60, 60, 60, 255},
+		GuideColor:    color.RGBA{0, 170, 255, 200},
+		c:             c,
+		drag:          -1,
+	}
+	g.overlay = c.AddOverlay(g.draw)
+	g.overlay.Stale = func() bool {
+		return g.Camera != nil && *g.Camera != g.last
+	}
+	return g
+}
+
+// Update redraws the overlay after fields have been changed
+func (g *Guides) Update() {
+	g.overlay.Invalidate()
+}
+
+// Show shows or hides everything Guides draws
+func (g *Guides) Show(on bool) {
+	g.overlay.Show(on)
+}
+
+// Remove takes the overlay off the canvas and stops any pointer handling
+func (g *Guides) Remove() {
+	g.Interactive(false)
+	g.c.RemoveOverlay(g.overlay)
+}
+
+// project converts world coordinates to shadow canvas coordinates
+func (g *Guides) project(w pixel.Vec) pixel.Vec {
+	if g.Camera != nil {
+		w = g.Camera.Project(w)
+	}
+	return g.c.ToCanvas(w)
+}
+
+// unproject converts shadow canvas coordinates to world coordinates
+func (g *Guides) unproject(v pixel.Vec) pixel.Vec {
+	v = g.c.FromCanvas(v)
+	if g.Camera == nil {
+		return v
+	}
+	return g.Camera.Unproject(v)
+}
+
+// zoom returns canvas pixels per world unit
+func (g *Guides) zoom() float64 {
+	z := g.c.CoordMatrix()[0] // Canvas pixels per logical unit across
+	if g.Camera != nil {
+		z *= g.Camera.Zoom
+	}
+	return z
+}
+
+// worldView returns the world rectangle covering the canvas
+func (g *Guides) worldView() pixel.Rect {
+	a := g.unproject(pixel.ZV)
+	b := g.unproject(pixel.V(float64(g.c.width), float64(g.c.height)))
+	return pixel.Rect{Min: a, Max: b}.Norm()
+}
+
+func (g *Guides) draw(o *Overlay) {
+	if g.Camera != nil {
+		g.last = *g.Camera
+	}
+	view := g.worldView()
+
+	if g.Grid > 0 {
+		g.drawGrid(o, view)
+	}
+	for _, l := range g.Lines {
+		p := g.project(pixel.V(l.Pos, l.Pos))
+		if l.Vertical {
+			o.VLine(int(math.Floor(p.X)), 0, o.Height, g.GuideColor)
+		} else {
+			o.HLine(0, o.Width, int(math.Floor(p.Y)), g.GuideColor)
+		}
+	}
+	if g.Rulers {
+		g.drawRulers(o, view)
+	}
+}
+
+func (g *Guides) drawGrid(o *Overlay, view pixel.Rect) {
+	spacing := g.Grid * g.zoom()
+	minor := spacing >= g.MinGridPixels
+	if !minor && (g.Major <= 0 || spacing*float64(g.Major) < g.MinGridPixels) {
+		return
+	}
+
+	lineColor := func(k int) color.Color {
+		if g.Major > 0 && k%g.Major == 0 {
+			return g.MajorColor
+		}
+		if minor {
+			return g.GridColor
+		}
+		return nil
+	}
+
+	for k := int(math.Floor(view.Min.X / g.Grid)); float64(k)*g.Grid <= view.Max.X; k++ {
+		if col := lineColor(k); col != nil {
+			x := g.project(pixel.V(float64(k)*g.Grid, 0)).X
+			o.VLine(int(math.Floor(x)), 0, o.Height, col)
+		}
+	}
+	for k := int(math.Floor(view.Min.Y / g.Grid)); float64(k)*g.Grid <= view.Max.Y; k++ {
+		if col := lineColor(k); col != nil {
+			y := g.project(pixel.V(0, float64(k)*g.Grid)).Y
+			o.HLine(0, o.Width, int(math.Floor(y)), col)
+		}
+	}
+}
+
+func (g *Guides) drawRulers(o *Overlay, view pixel.Rect) {
+	rs := g.RulerSize
+	// Rulers sit along the top and left of the picture as displayed
+	top0, top1 := g.displayRows(0, rs)
+	o.FillRect(0, top0, o.Width, top1, g.RulerColor)
+	o.FillRect(0, 0, rs, o.Height, g.RulerColor)
+
+	step := niceStep(rulerLabelSpacing / g.zoom())
+	minorStep := step / 5
+
+	// Top ruler: world x
+	for k := math.Floor(view.Min.X / minorStep); k*minorStep <= view.Max.X; k++ {
+		x := int(math.Floor(g.project(pixel.V(k*minorStep, 0)).X))
+		length := rs / 4
+		labelled := math.Mod(k, 5) == 0
+		if labelled {
+			length = rs / 2
+		}
+		y0, y1 := g.displayRows(rs-length, rs)
+		o.FillRect(x, y0, x+1, y1, g.TickColor)
+		if labelled {
+			y, _ := g.displayRows(1, 2)
+			drawDigits(o, x+2, y, g.c.flipY, formatTick(k*minorStep, step), g.TickColor)
+		}
+	}
+
+	// Left ruler: world y
+	for k := math.Floor(view.Min.Y / minorStep); k*minorStep <= view.Max.Y; k++ {
+		y := int(math.Floor(g.project(pixel.V(0, k*minorStep)).Y))
+		length := rs / 4
+		labelled := math.Mod(k, 5) == 0
+		if labelled {
+			length = rs / 2
+		}
+		o.HLine(rs-length, rs, y, g.TickColor)
+		if labelled {
+			ly := y + 2 // Label just below the tick as displayed
+			if g.c.flipY {
+				ly = y - 2
+			}
+			drawDigits(o, 1, ly, g.c.flipY, formatTick(k*minorStep, step), g.TickColor)
+		}
+	}
+
+	// Corner square
+	o.FillRect(0, top0, rs, top1, g.RulerColor)
+}
+
+// displayRows converts rows [from, to), counted down from the top of the
+// picture as displayed, to overlay rows [y0, y1)
+func (g *Guides) displayRows(from, to int) (y0, y1 int) {
+	if g.c.flipY {
+		return g.overlay.Height - to, g.overlay.Height - from
+	}
+	return from, to
+}
+
+// niceStep rounds a world distance up to 1, 2 or 5 times a power of ten
+func niceStep(min float64) float64 {
+	if min <= 0 {
+		return 1
+	}
+	p := math.Pow(10, math.Floor(math.Log10(min)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if m*p >= min {
+			return m * p
+		}
+	}
+	return 10 * p
+}
+
+// formatTick formats a ruler label with as many decimals as step needs
+func formatTick(v float64, step float64) string {
+	decimals := int(-math.Floor(math.Log10(step)))
+	if decimals < 0 {
+		decimals = 0
+	}
+	return strconv.FormatFloat(v, 'f', decimals, 64)
+}
+
+// Interactive turns pointer handling for guides on or off
+func (g *Guides) Interactive(on bool) {
+	for _, l := range g.listeners {
+		g.c.unlisten(l)
+	}
+	g.listeners = nil
+	g.drag = -1
+	if !on {
+		return
+	}
+
+	c := g.c
+	g.listeners = append(g.listeners,
+		c.listen(c.canvas, "pointerdown", g.pointerDown),
+		c.listen(c.canvas, "pointermove", g.pointerMove),
+		c.listen(c.canvas, "pointerup", g.pointerUp),
+		c.listen(c.canvas, "pointercancel", g.pointerUp),
+	)
+}
+
+func (g *Guides) pointerDown(e js.Value) {
+	p := g.c.clientToCanvas(e.Get("clientX").Float(), e.Get("clientY").Float())
+	d := g.c.canvasToDOM(p)
+	w := g.unproject(p)
+
+	g.drag = -1
+	switch {
+	case g.Rulers && d.Y < float64(g.RulerSize) && d.X >= float64(g.RulerSize):
+		g.Lines = append(g.Lines, Guide{Vertical: false, Pos: w.Y})
+		g.drag = len(g.Lines) - 1
+	case g.Rulers && d.X < float64(g.RulerSize) && d.Y >= float64(g.RulerSize):
+		g.Lines = append(g.Lines, Guide{Vertical: true, Pos: w.X})
+		g.drag = len(g.Lines) - 1
+	default:
+		for i, l := range g.Lines {
+			q := g.project(pixel.V(l.Pos, l.Pos))
+			if (l.Vertical && math.Abs(q.X-p.X) <= guideGrab) || (!l.Vertical && math.Abs(q.Y-p.Y) <= guideGrab) {
+				g.drag = i
+				break
+			}
+		}
+	}
+	if g.drag < 0 {
+		return
+	}
+	e.Call("preventDefault")
+	g.c.canvas.Call("setPointerCapture", e.Get("pointerId"))
+	g.Update()
+}
+
+func (g *Guides) pointerMove(e js.Value) {
+	if g.drag < 0 {
+		return
+	}
+	w := g.unproject(g.c.clientToCanvas(e.Get("clientX").Float(), e.Get("clientY").Float()))
+	if g.Lines[g.drag].Vertical {
+		g.Lines[g.drag].Pos = w.X
+	} else {
+		g.Lines[g.drag].Pos = w.Y
+	}
+	g.Update()
+}
+
+func (g *Guides) pointerUp(e js.Value) {
+	if g.drag < 0 {
+		return
+	}
+	d := g.c.canvasToDOM(g.c.clientToCanvas(e.Get("clientX").Float(), e.Get("clientY").Float()))
+	l := g.Lines[g.drag]
+	if g.Rulers && ((l.Vertical && d.X < float64(g.RulerSize)) || (!l.Vertical && d.Y < float64(g.RulerSize))) {
+		g.Lines = append(g.Lines[:g.drag], g.Lines[g.drag+1:]...) // Dropped back on its ruler
+	}
+	g.drag = -1
+	g.Update()
+	if g.OnChange != nil {
+		g.OnChange(g.Lines)
+	}
+}
+
+// digitFont is a 3x5 pixel font for ruler labels. Each glyph is five rows,
+// top first, of three bits, most significant on the left.
+var digitFont = map[rune][5]uint8{
+	'0': {7, 5, 5, 5, 7},
+	'1': {2, 6, 2, 2, 7},
+	'2': {7, 1, 7, 4, 7},
+	'3': {7, 1, 3, 1, 7},
+	'4': {5, 5, 7, 1, 1},
+	'5': {7, 4, 7, 1, 7},
+	'6': {7, 4, 7, 5, 7},
+	'7': {7, 1, 1, 2, 2},
+	'8': {7, 5, 7, 5, 7},
+	'9': {7, 5, 7, 1, 7},
+	'-': {0, 0, 7, 0, 0},
+	'.': {0, 0, 0, 0, 2},
+}
+
+// drawDigits writes s in digitFont with its top left at (x, y). When flipY
+// is set the overlay is displayed bottom-up, so rows go downwards from y.
+func drawDigits(o *Overlay, x, y int, flipY bool, s string, col color.Color) {
+	dy := 1
+	if flipY {
+		dy = -1
+	}
+	for _, r := range s {
+		glyph, ok := digitFont[r]
+		if !ok {
+			continue
+		}
+		for row, bits := range glyph {
+			for bit := 0; bit < 3; bit++ {
+				if bits&(4>>uint(bit)) != 0 {
+					o.Set(x+bit, y+row*dy, col)
+				}
+			}
+		}
+		x += 4
+	}
+}
@@ -0,0 +1,33 @@
+package pixelcanvas
+
+import (
+	"syscall/js"
+)
+
+// CanVibrate reports whether the browser supports navigator.vibrate.
+// Desktop browsers and iOS Safari do not.
+func CanVibrate() bool {
+	return js.Global().Get("navigator").Get("vibrate").Type() == js.TypeFunction
+}
+
+// Vibrate plays a haptic pattern of alternating vibration and pause lengths
+// in milliseconds, e.g. []int{50} for a short tap or []int{100, 50, 100} for
+// a double buzz. It returns false if vibration is unsupported or was refused
+// (browsers ignore it until the user has interacted with the page).
+func Vibrate(pattern []int) bool {
+	if !CanVibrate() || len(pattern) == 0 {
+		return false
+	}
+	arr := make([]interface{}, len(pattern))
+	for i, p := range pattern {
+		arr[i] = p
+	}
+	return js.Global().Get("navigator").Call("vibrate", arr).Bool()
+}
+
+// StopVibration cancels any vibration in progress
+func StopVibration() {
+	if CanVibrate() {
+		js.Global().Get("navigator").Call("vibrate", 0)
+	}
+}
@@ -0,0 +1,204 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"sort"
+
+	"github.com/faiface/pixel"
+)
+
+// Histogram counts pixels by value in each channel. Colour and luminance
+// are of the un-premultiplied colour, and only pixels with some alpha are
+// counted in them; A counts every pixel.
+type Histogram struct {
+	R, G, B, Luma [256]int
+	A             [256]int
+	Pixels        int // Pixels counted in the colour channels
+}
+
+// Histogram counts area r of the shadow canvas (the zero Rect for all of it)
+func (c *Canvasp) Histogram(r pixel.Rect) *Histogram {
+	h := &Histogram{}
+	c.eachPixel(r, h.add)
+	return h
+}
+
+// Histogram counts the Region's pixels
+func (reg *Region) Histogram() *Histogram {
+	h := &Histogram{}
+	regionPixels(reg, h.add)
+	return h
+}
+
+func (h *Histogram) add(px []uint8) {
+	a := px[3]
+	h.A[a]++
+	if a == 0 {
+		return
+	}
+	r, g, b := px[0], px[1], px[2]
+	if a < 255 {
+		u := &unpremul[a]
+		r, g, b = u[r], u[g], u[b]
+	}
+	h.R[r]++
+	h.G[g]++
+	h.B[b]++
+	h.Luma[luma(r, g, b)]++
+	h.Pixels++
+}
+
+// MeanLuma is the average luminance, 0-255
+func (h *Histogram) MeanLuma() float64 {
+	if h.Pixels == 0 {
+		return 0
+	}
+	sum := 0
+	for v, n := range h.Luma {
+		sum += v * n
+	}
+	return float64(sum) / float64(h.Pixels)
+}
+
+// LumaPercentile is the luminance that fraction p (0-1) of the pixels are
+// at or below, e.g. 0.5 for the median or 0.95 for the highlights an auto
+// exposure effect might key on
+func (h *Histogram) LumaPercentile(p float64) uint8 {
+	target := int(p * float64(h.Pixels))
+	seen := 0
+	for v, n := range h.Luma {
+		seen += n
+		if seen > target {
+			return uint8(v)
+		}
+	}
+	return 255
+}
+
+// AverageLuminance is the alpha weighted average luminance of area r of
+// the shadow canvas (the zero Rect for all of it), 0-1. Transparent pixels
+// don't count; an empty or fully transparent area gives 0.
+func (c *Canvasp) AverageLuminance(r pixel.Rect) float64 {
+	var sum, weight int64
+	c.eachPixel(r, func(px []uint8) {
+		// Premultiplied, so the luminance is already weighted by alpha
+		sum += int64(luma(px[0], px[1], px[2]))
+		weight += int64(px[3])
+	})
+	if weight == 0 {
+		return 0
+	}
+	return float64(sum) / float64(weight)
+}
+
+// AverageColor is the alpha weighted average colour of area r, opaque
+func (c *Canvasp) AverageColor(r pixel.Rect) color.RGBA {
+	var sum [4]int64
+	c.eachPixel(r, func(px []uint8) {
+		sum[0] += int64(px[0])
+		sum[1] += int64(px[1])
+		sum[2] += int64(px[2])
+		sum[3] += int64(px[3])
+	})
+	if sum[3] == 0 {
+		return color.RGBA{}
+	}
+	return color.RGBA{
+		R: uint8(sum[0] * 255 / sum[3]),
+		G: uint8(sum[1] * 255 / sum[3]),
+		B: uint8(sum[2] * 255 / sum[3]),
+		A: 255,
+	}
+}
+
+// DominantColor is one of the most common colours in an area
+type DominantColor struct {
+	Color color.RGBA // Opaque average of the colours in this group
+	Share float64    // Fraction of the visible pixels, 0-1
+}
+
+// DominantColors returns up to n of the most common colours in area r of
+// the shadow canvas (the zero Rect for all of it), most common first.
+// Colours are grouped 16 levels to a channel, so near identical shades
+// count together. Mostly transparent pixels are ignored.
+func (c *Canvasp) DominantColors(r pixel.Rect, n int) []DominantColor {
+	var d dominance
+	c.eachPixel(r, d.add)
+	return d.top(n)
+}
+
+// DominantColors is Canvasp.DominantColors for a Region
+func (reg *Region) DominantColors(n int) []DominantColor {
+	var d dominance
+	regionPixels(reg, d.add)
+	return d.top(n)
+}
+
+// dominance buckets colours by their top 4 bits per channel
+type dominance struct {
+	count [4096]int
+	sum   [4096][3]int
+	total int
+}
+
+func (d *dominance) add(px []uint8) {
+	a := px[3]
+	if a < 128 {
+		return
+	}
+	r, g, b := px[0], px[1], px[2]
+	if a < 255 {
+		u := &unpremul[a]
+		r, g, b = u[r], u[g], u[b]
+	}
+	k := int(r>>4)<<8 | int(g>>4)<<4 | int(b>>4)
+	d.count[k]++
+	d.sum[k][0] += int(r)
+	d.sum[k][1] += int(g)
+	d.sum[k][2] += int(b)
+	d.total++
+}
+
+func (d *dominance) top(n int) []DominantColor {
+	var keys []int
+	for k, cnt := range d.count {
+		if cnt > 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return d.count[keys[i]] > d.count[keys[j]] })
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	out := make([]DominantColor, len(keys))
+	for i, k := range keys {
+		cnt := d.count[k]
+		out[i] = DominantColor{
+			Color: color.RGBA{R: uint8(d.sum[k][0] / cnt), G: uint8(d.sum[k][1] / cnt), B: uint8(d.sum[k][2] / cnt), A: 255},
+			Share: float64(cnt) / float64(d.total),
+		}
+	}
+	return out
+}
+
+// eachPixel calls fn with each premultiplied pixel in area r of the shadow
+// canvas, the zero Rect meaning all of it
+func (c *Canvasp) eachPixel(r pixel.Rect, fn func(px []uint8)) {
+	r = c.ToCanvasRect(r)
+	if r == (pixel.Rect{}) {
+		r = c.image.Bounds()
+	}
+	x0, y0, x1, y1 := c.pixelRect(r)
+	pix := c.pixels()
+	for y := y0; y < y1; y++ {
+		for i := (y*c.width + x0) * 4; i < (y*c.width+x1)*4; i += 4 {
+			fn(pix[i : i+4])
+		}
+	}
+}
+
+func regionPixels(reg *Region, fn func(px []uint8)) {
+	for i := 0; i+3 < len(reg.Pix); i += 4 {
+		fn(reg.Pix[i : i+4])
+	}
+}
@@ -0,0 +1,255 @@
+package pixelcanvas
+
+import (
+	"bytes"
+	"image/color"
+
+	"github.com/faiface/pixel"
+)
+
+// DefaultHistoryLimit is the memory budget used when NewHistory is given 0
+const DefaultHistoryLimit = 64 << 20
+
+// History records pixel buffer changes as dirty rectangles and lets them be
+// undone and redone. Only the changed area of each operation is kept, and
+// the oldest steps are dropped once the memory limit is exceeded.
+type History struct {
+	c     *Canvasp
+	undo  []*historyStep
+	redo  []*historyStep
+	limit int
+	used  int
+
+	pending []uint8 // Full buffer captured by Begin
+	name    string
+}
+
+type historyStep struct {
+	name           string
+	x0, y0, x1, y1 int
+	before, after  []uint8 // Rect contents, tightly packed rows
+}
+
+func (s *historyStep) size() int {
+	return len(s.before) + len(s.after)
+}
+
+// NewHistory creates a History for the canvas, capped at roughly limit bytes.
+// Resizing the canvas clears it, and abandons an operation in progress.
+func (c *Canvasp) NewHistory(limit int) *History {
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	h := &History{c: c, limit: limit}
+	c.OnResize(func(int, int) {
+		h.Clear()
+		h.pending = nil
+	})
+	return h
+}
+
+// Begin starts recording an operation. Make the changes, then call Commit.
+func (h *History) Begin(name string) {
+	h.pending = h.c.image.Pixels()
+	h.name = name
+}
+
+// Commit finishes the operation started by Begin. The changed area is found
+// by comparing against the buffer captured at Begin. Nothing is recorded if
+// the operation made no changes.
+func (h *History) Commit() {
+	h.commit(h.c.image.Pixels(), 0, 0, -1, -1)
+}
+
+// CommitRect is Commit for callers that already know the changed area,
+// skipping the full-buffer comparison.
+func (h *History) CommitRect(r pixel.Rect) {
+	h.commitRect(h.c.ToCanvasRect(r))
+}
+
+// commitRect is CommitRect in shadow canvas pixels
+func (h *History) commitRect(r pixel.Rect) {
+	x0, y0, x1, y1 := h.c.pixelRect(r)
+	h.commit(h.c.image.Pixels(), x0, y0, x1, y1)
+}
+
+// Cancel abandons an operation started by Begin, restoring the canvas
+func (h *History) Cancel() {
+	if h.pending == nil {
+		return
+	}
+	h.c.image.SetPixels(h.pending)
+	h.pending = nil
+}
+
+// Do records arbitrary drawing made by fn as one undoable step
+func (h *History) Do(name string, fn func(gc *Canvas)) {
+	h.Begin(name)
+	fn(h.c.image)
+	h.Commit()
+}
+
+// FloodFill is Canvasp.FloodFill recorded as an undoable step
+func (h *History) FloodFill(at pixel.Vec, col color.Color, tolerance uint8) pixel.Rect {
+	h.Begin("fill")
+	r := h.c.FloodFill(at, col, tolerance)
+	h.CommitRect(r)
+	return r
+}
+
+// FillMask is Canvasp.FillMask recorded as an undoable step
+func (h *History) FillMask(m *Mask, col color.Color) {
+	h.Begin("fill")
+	h.c.FillMask(m, col)
+	h.commitRect(m.Bounds())
+}
+
+// CanUndo reports whether there is a step to undo
+func (h *History) CanUndo() bool {
+	return len(h.undo) > 0
+}
+
+// CanRedo reports whether there is a step to redo
+func (h *History) CanRedo() bool {
+	return len(h.redo) > 0
+}
+
+// UndoName returns the name of the step Undo would revert, for menu labels
+func (h *History) UndoName() string {
+	if len(h.undo) == 0 {
+		return ""
+	}
+	return h.undo[len(h.undo)-1].name
+}
+
+// RedoName returns the name of the step Redo would re-apply
+func (h *History) RedoName() string {
+	if len(h.redo) == 0 {
+		return ""
+	}
+	return h.redo[len(h.redo)-1].name
+}
+
+// Undo reverts the most recent step, returning false if there was none
+func (h *History) Undo() bool {
+	if len(h.undo) == 0 {
+		return false
+	}
+	s := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	h.apply(s, s.before)
+	h.redo = append(h.redo, s)
+	return true
+}
+
+// Redo re-applies the most recently undone step
+func (h *History) Redo() bool {
+	if len(h.redo) == 0 {
+		return false
+	}
+	s := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.apply(s, s.after)
+	h.undo = append(h.undo, s)
+	return true
+}
+
+// Clear forgets every recorded step
+func (h *History) Clear() {
+	h.undo, h.redo = nil, nil
+	h.used = 0
+}
+
+// MemoryUsed returns the bytes held by recorded steps
+func (h *History) MemoryUsed() int {
+	return h.used
+}
+
+// commit stores the step. A negative rect means 'work it out by diffing'.
+func (h *History) commit(after []uint8, x0, y0, x1, y1 int) {
+	before := h.pending
+	h.pending = nil
+	if before == nil || len(before) != len(after) {
+		return
+	}
+
+	w := h.c.width
+	if x1 < 0 {
+		x0, y0, x1, y1 = diffBounds(before, after, w, h.c.height)
+	}
+	if x0 >= x1 || y0 >= y1 {
+		return
+	}
+
+	s := &historyStep{
+		name: h.name,
+		x0:   x0, y0: y0, x1: x1, y1: y1,
+		before: cropRect(before, w, x0, y0, x1, y1),
+		after:  cropRect(after, w, x0, y0, x1, y1),
+	}
+
+	// A new step invalidates anything that was undone
+	for _, r := range h.redo {
+		h.used -= r.size()
+	}
+	h.redo = nil
+
+	h.undo = append(h.undo, s)
+	h.used += s.size()
+	for h.used > h.limit && len(h.undo) > 1 {
+		h.used -= h.undo[0].size()
+		h.undo[0] = nil
+		h.undo = h.undo[1:]
+	}
+}
+
+func (h *History) apply(s *historyStep, data []uint8) {
+	pix := h.c.pixels()
+	pasteRect(pix, h.c.width, s.x0, s.y0, s.x1, s.y1, data)
+	h.c.pixelsChanged(pix)
+}
+
+// diffBounds returns the bounding box of all pixels that differ
+func diffBounds(a, b []uint8, w, h int) (x0, y0, x1, y1 int) {
+	x0, y0 = w, h
+	stride := w * 4
+	for y := 0; y < h; y++ {
+		ra, rb := a[y*stride:(y+1)*stride], b[y*stride:(y+1)*stride]
+		if bytes.Equal(ra, rb) {
+			continue
+		}
+		if y < y0 {
+			y0 = y
+		}
+		y1 = y + 1
+		for x := 0; x < w; x++ {
+			if !bytes.Equal(ra[x*4:x*4+4], rb[x*4:x*4+4]) {
+				if x < x0 {
+					x0 = x
+				}
+				if x+1 > x1 {
+					x1 = x + 1
+				}
+			}
+		}
+	}
+	return
+}
+
+// cropRect copies a rectangle out of a buffer w pixels wide
+func cropRect(pix []uint8, w, x0, y0, x1, y1 int) []uint8 {
+	rw := (x1 - x0) * 4
+	out := make([]uint8, rw*(y1-y0))
+	for y := y0; y < y1; y++ {
+		copy(out[(y-y0)*rw:], pix[(y*w+x0)*4:(y*w+x1)*4])
+	}
+	return out
+}
+
+// pasteRect writes data from cropRect back into a buffer w pixels wide
+func pasteRect(pix []uint8, w, x0, y0, x1, y1 int, data []uint8) {
+	rw := (x1 - x0) * 4
+	for y := y0; y < y1; y++ {
+		copy(pix[(y*w+x0)*4:(y*w+x1)*4], data[(y-y0)*rw:])
+	}
+}
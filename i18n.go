@@ -0,0 +1,136 @@
+package pixelcanvas
+
+import (
+	"fmt"
+	"syscall/js"
+
+	"github.com/faiface/pixel"
+	"github.com/lwayneh/pixelcanvas/i18n"
+)
+
+// Localization
+//
+// The canvas holds a translation bundle (see package i18n) and the current
+// language. Apps translate with T and draw translated text with DrawT,
+// which also sets the text direction for right to left languages.
+// Switching language with SetLanguage tells everything following
+// OnLanguageChange, so UI can re-render; tooltips whose TextFunc calls T
+// pick up the new language by themselves.
+
+// localeState is a canvas's translations and who follows the language
+type localeState struct {
+	bundle   *i18n.Bundle
+	loc      *i18n.Localizer
+	watchers map[*func(string)]struct{}
+}
+
+// Translations returns the canvas's bundle, created with fallback "en" on
+// first use
+func (c *Canvasp) Translations() *i18n.Bundle {
+	if c.locale.bundle == nil {
+		c.locale.bundle = i18n.NewBundle("en")
+	}
+	return c.locale.bundle
+}
+
+// SetTranslations replaces the canvas's bundle and re-selects the current
+// language from it
+func (c *Canvasp) SetTranslations(b *i18n.Bundle) {
+	c.locale.bundle = b
+	if c.locale.loc != nil {
+		c.SetLanguage(c.locale.loc.Locale())
+	}
+}
+
+// LoadCatalog fetches a JSON catalog for locale from url and adds it to
+// the bundle. It blocks, so call it from a goroutine.
+func (c *Canvasp) LoadCatalog(locale, url string) error {
+	data, err := c.FetchBytes(url)
+	if err != nil {
+		return fmt.Errorf("pixelcanvas: catalog %s: %v", locale, err)
+	}
+	if err := c.Translations().Load(locale, data); err != nil {
+		return err
+	}
+	if c.locale.loc != nil {
+		c.locale.loc = c.Translations().Localizer(c.locale.loc.Locale())
+	}
+	return nil
+}
+
+// PreferredLanguages returns the browser's languages, most preferred
+// first, from navigator.languages or navigator.language
+func (c *Canvasp) PreferredLanguages() []string {
+	nav := js.Global().Get("navigator")
+	if nav.IsUndefined() {
+		return nil
+	}
+	var out []string
+	if langs := nav.Get("languages"); !langs.IsUndefined() && !langs.IsNull() {
+		for i := 0; i < langs.Length(); i++ {
+			out = append(out, langs.Index(i).String())
+		}
+	}
+	if lang := nav.Get("language"); len(out) == 0 && lang.Type() == js.TypeString {
+		out = append(out, lang.String())
+	}
+	return out
+}
+
+// DetectLanguage switches to the bundle's best match for the browser's
+// languages and returns it
+func (c *Canvasp) DetectLanguage() string {
+	lang := c.Translations().Match(c.PreferredLanguages()...)
+	c.SetLanguage(lang)
+	return lang
+}
+
+// Language returns the current locale, the bundle's fallback until
+// SetLanguage or DetectLanguage
+func (c *Canvasp) Language() string {
+	return c.localizer().Locale()
+}
+
+// SetLanguage switches the current locale and tells the OnLanguageChange
+// functions
+func (c *Canvasp) SetLanguage(locale string) {
+	c.locale.loc = c.Translations().Localizer(locale)
+	lang := c.locale.loc.Locale()
+	for fn := range c.locale.watchers {
+		(*fn)(lang)
+	}
+	c.log().Debug("language changed", "locale", lang)
+}
+
+// OnLanguageChange calls fn with the new locale whenever SetLanguage is
+// called. The returned func stops it.
+func (c *Canvasp) OnLanguageChange(fn func(locale string)) func() {
+	if c.locale.watchers == nil {
+		c.locale.watchers = make(map[*func(string)]struct{})
+	}
+	key := &fn
+	c.locale.watchers[key] = struct{}{}
+	return func() { delete(c.locale.watchers, key) }
+}
+
+// T translates key into the current language, filling placeholders from
+// args, which alternate names and values; see i18n.Localizer.T
+func (c *Canvasp) T(key string, args ...interface{}) string {
+	return c.localizer().T(key, args...)
+}
+
+// DrawT draws the translation of key like DrawText. If style leaves the
+// direction to the browser, it is set from the current language.
+func (c *Canvasp) DrawT(at pixel.Vec, style TextStyle, key string, args ...interface{}) pixel.Rect {
+	if style.Direction == "" {
+		style.Direction = i18n.Direction(c.Language())
+	}
+	return c.DrawText(c.T(key, args...), at, style)
+}
+
+func (c *Canvasp) localizer() *i18n.Localizer {
+	if c.locale.loc == nil {
+		c.locale.loc = c.Translations().Localizer(c.Translations().Fallback())
+	}
+	return c.locale.loc
+}
@@ -0,0 +1,379 @@
+// Package i18n holds translation catalogs for pixelcanvas apps and formats
+// their messages, with named parameters and plural forms.
+//
+// A catalog is a flat JSON object from message keys to text. Text may use
+// {name} placeholders, filled from key/value arguments, and a message with
+// plural forms is an object of CLDR categories (zero, one, two, few, many,
+// other), optionally with exact cases like "=0", chosen by the "count"
+// argument:
+//
+//	{
+//	  "menu.start": "Commencer",
+//	  "hello": "Bonjour, {name} !",
+//	  "coins": {"=0": "Aucune pièce", "one": "{count} pièce", "other": "{count} pièces"}
+//	}
+//
+// A Bundle holds the catalogs of every language, and a Localizer
+// translates into one of them, falling back from a regional locale to its
+// language and then to the bundle's fallback locale, and finally to the
+// key itself, so a missing translation shows up rather than breaking.
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Message is a catalog entry: plain text, or text by plural category
+type Message struct {
+	Text   string
+	Plural map[string]string // Categories or exact cases ("=2") to text
+}
+
+// UnmarshalJSON accepts a string or an object of plural forms
+func (m *Message) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '{' {
+		m.Text = ""
+		return json.Unmarshal(data, &m.Plural)
+	}
+	m.Plural = nil
+	return json.Unmarshal(data, &m.Text)
+}
+
+// MarshalJSON writes the form UnmarshalJSON reads
+func (m Message) MarshalJSON() ([]byte, error) {
+	if m.Plural != nil {
+		return json.Marshal(m.Plural)
+	}
+	return json.Marshal(m.Text)
+}
+
+// Catalog is the messages of one language by key
+type Catalog map[string]Message
+
+// ParseCatalog decodes a catalog from JSON
+func ParseCatalog(data []byte) (Catalog, error) {
+	var cat Catalog
+	if err := json.Unmarshal(data, &cat); err != nil {
+		return nil, fmt.Errorf("i18n: catalog: %v", err)
+	}
+	return cat, nil
+}
+
+// Bundle is the catalogs of an app
+type Bundle struct {
+	catalogs map[string]Catalog
+	fallback string
+}
+
+// NewBundle creates an empty bundle. Messages missing from a language are
+// taken from fallback's catalog, e.g. "en".
+func NewBundle(fallback string) *Bundle {
+	return &Bundle{catalogs: make(map[string]Catalog), fallback: Normalize(fallback)}
+}
+
+// Fallback returns the fallback locale
+func (b *Bundle) Fallback() string {
+	return b.fallback
+}
+
+// Add merges cat into locale's catalog, replacing messages with the same key
+func (b *Bundle) Add(locale string, cat Catalog) {
+	locale = Normalize(locale)
+	dst := b.catalogs[locale]
+	if dst == nil {
+		dst = make(Catalog, len(cat))
+		b.catalogs[locale] = dst
+	}
+	for k, m := range cat {
+		dst[k] = m
+	}
+}
+
+// Load parses a JSON catalog and adds it to locale
+func (b *Bundle) Load(locale string, data []byte) error {
+	cat, err := ParseCatalog(data)
+	if err != nil {
+		return err
+	}
+	b.Add(locale, cat)
+	return nil
+}
+
+// Locales returns the locales with a catalog, sorted
+func (b *Bundle) Locales() []string {
+	out := make([]string, 0, len(b.catalogs))
+	for l := range b.catalogs {
+		out = append(out, l)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Match returns the best locale the bundle has for the preferences given,
+// most preferred first (e.g. navigator.languages): an exact match, else
+// the same language in another region, else the fallback
+func (b *Bundle) Match(preferred ...string) string {
+	for _, p := range preferred {
+		p = Normalize(p)
+		if _, ok := b.catalogs[p]; ok {
+			return p
+		}
+		lang := Language(p)
+		if _, ok := b.catalogs[lang]; ok {
+			return lang
+		}
+		for _, l := range b.Locales() {
+			if Language(l) == lang {
+				return l
+			}
+		}
+	}
+	return b.fallback
+}
+
+// Localizer translates into one locale
+type Localizer struct {
+	bundle *Bundle
+	locale string
+	chain  []Catalog // Catalogs to look in, in order
+	tags   []string  // The locale of each catalog in chain
+}
+
+// Localizer returns a localizer for locale
+func (b *Bundle) Localizer(locale string) *Localizer {
+	locale = Normalize(locale)
+	l := &Localizer{bundle: b, locale: locale}
+	seen := make(map[string]bool)
+	for _, tag := range []string{locale, Language(locale), b.fallback} {
+		if cat, ok := b.catalogs[tag]; ok && !seen[tag] {
+			seen[tag] = true
+			l.chain = append(l.chain, cat)
+			l.tags = append(l.tags, tag)
+		}
+	}
+	return l
+}
+
+// Locale returns the localizer's locale
+func (l *Localizer) Locale() string {
+	return l.locale
+}
+
+// Has reports whether key is translated, in the locale or a fallback
+func (l *Localizer) Has(key string) bool {
+	_, _, ok := l.lookup(key)
+	return ok
+}
+
+// T translates key, filling its placeholders from args, which alternate
+// names and values: T("coins", "count", 3). A plural message picks its
+// form by the "count" argument. Unknown keys return the key.
+func (l *Localizer) T(key string, args ...interface{}) string {
+	m, locale, ok := l.lookup(key)
+	if !ok {
+		return Format(key, args...)
+	}
+	text := m.Text
+	if m.Plural != nil {
+		text = plural(locale, m.Plural, arg(args, "count"))
+	}
+	return Format(text, args...)
+}
+
+// lookup finds key along the chain, returning the locale of the catalog it
+// was found in
+func (l *Localizer) lookup(key string) (Message, string, bool) {
+	for i, cat := range l.chain {
+		if m, ok := cat[key]; ok {
+			return m, l.tags[i], true
+		}
+	}
+	return Message{}, "", false
+}
+
+// plural picks a form for n: an exact case, then the category in locale,
+// the language the forms are written in, then "other"
+func plural(locale string, forms map[string]string, n interface{}) string {
+	f, ok := number(n)
+	if ok {
+		if s, ok := forms["="+strconv.FormatFloat(f, 'f', -1, 64)]; ok {
+			return s
+		}
+		if f == float64(int64(f)) {
+			if s, ok := forms[PluralCategory(locale, int64(f))]; ok {
+				return s
+			}
+		}
+	}
+	return forms["other"]
+}
+
+// Format fills {name} placeholders in text from args, which alternate
+// names and values. "{{" and "}}" stand for literal braces. Placeholders
+// without an argument are left as they are.
+func Format(text string, args ...interface{}) string {
+	if !strings.ContainsAny(text, "{}") {
+		return text
+	}
+	var sb strings.Builder
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case ch == '{' && i+1 < len(text) && text[i+1] == '{',
+			ch == '}' && i+1 < len(text) && text[i+1] == '}':
+			sb.WriteByte(ch)
+			i++
+		case ch == '{':
+			end := strings.IndexByte(text[i:], '}')
+			if end < 0 {
+				sb.WriteString(text[i:])
+				return sb.String()
+			}
+			name := text[i+1 : i+end]
+			if v := arg(args, name); v != nil {
+				fmt.Fprint(&sb, v)
+			} else {
+				sb.WriteString(text[i : i+end+1])
+			}
+			i += end
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	return sb.String()
+}
+
+// arg returns the value after name in args, or nil
+func arg(args []interface{}, name string) interface{} {
+	for i := 0; i+1 < len(args); i += 2 {
+		if k, ok := args[i].(string); ok && k == name {
+			return args[i+1]
+		}
+	}
+	return nil
+}
+
+// number converts a numeric argument to float64
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// PluralCategory returns the CLDR plural category of the whole number n
+// in locale's language. It covers the common rule families; languages it
+// doesn't know use the English rule.
+func PluralCategory(locale string, n int64) string {
+	if n < 0 {
+		n = -n
+	}
+	n10, n100 := n%10, n%100
+	switch Language(Normalize(locale)) {
+	case "ja", "zh", "ko", "vi", "th", "id", "ms", "lo", "my":
+		return "other"
+	case "fr", "hi", "fa", "bn", "pt":
+		if n <= 1 {
+			return "one"
+		}
+	case "ru", "uk", "be":
+		switch {
+		case n10 == 1 && n100 != 11:
+			return "one"
+		case n10 >= 2 && n10 <= 4 && (n100 < 12 || n100 > 14):
+			return "few"
+		}
+		return "many"
+	case "sr", "hr", "bs":
+		switch {
+		case n10 == 1 && n100 != 11:
+			return "one"
+		case n10 >= 2 && n10 <= 4 && (n100 < 12 || n100 > 14):
+			return "few"
+		}
+	case "pl":
+		switch {
+		case n == 1:
+			return "one"
+		case n10 >= 2 && n10 <= 4 && (n100 < 12 || n100 > 14):
+			return "few"
+		}
+		return "many"
+	case "cs", "sk":
+		switch {
+		case n == 1:
+			return "one"
+		case n >= 2 && n <= 4:
+			return "few"
+		}
+	case "ar":
+		switch {
+		case n == 0:
+			return "zero"
+		case n == 1:
+			return "one"
+		case n == 2:
+			return "two"
+		case n100 >= 3 && n100 <= 10:
+			return "few"
+		case n100 >= 11:
+			return "many"
+		}
+	default:
+		if n == 1 {
+			return "one"
+		}
+	}
+	return "other"
+}
+
+// Normalize puts a locale tag in the form used for matching: lower case,
+// with "-" separators ("pt_BR" becomes "pt-br")
+func Normalize(locale string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(locale), "_", "-", -1))
+}
+
+// Language returns the language part of a locale tag ("pt-br" gives "pt")
+func Language(locale string) string {
+	if i := strings.IndexByte(locale, '-'); i >= 0 {
+		return locale[:i]
+	}
+	return locale
+}
+
+// Direction returns "rtl" for languages written right to left, otherwise
+// "ltr"
+func Direction(locale string) string {
+	switch Language(Normalize(locale)) {
+	case "ar", "he", "fa", "ur", "ps", "sd", "yi", "dv", "ug", "ckb":
+		return "rtl"
+	}
+	return "ltr"
+}
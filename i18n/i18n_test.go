@@ -0,0 +1,133 @@
+package i18n
+
+import "testing"
+
+func TestPluralCategory(t *testing.T) {
+	tests := []struct {
+		locale string
+		n      int64
+		want   string
+	}{
+		{"en", 0, "other"},
+		{"en", 1, "one"},
+		{"en", 2, "other"},
+		{"en-GB", 1, "one"},
+		{"fr", 0, "one"},
+		{"fr", 1, "one"},
+		{"fr", 2, "other"},
+		{"ja", 1, "other"},
+		{"ru", 1, "one"},
+		{"ru", 21, "one"},
+		{"ru", 11, "many"},
+		{"ru", 3, "few"},
+		{"ru", 13, "many"},
+		{"ru", 5, "many"},
+		{"uk_UA", 22, "few"},
+		{"hr", 1, "one"},
+		{"hr", 21, "one"},
+		{"hr", 11, "other"},
+		{"sr", 4, "few"},
+		{"sr", 14, "other"},
+		{"bs", 5, "other"},
+		{"pl", 1, "one"},
+		{"pl", 21, "many"},
+		{"pl", 22, "few"},
+		{"pl", 12, "many"},
+		{"cs", 3, "few"},
+		{"cs", 5, "other"},
+		{"ar", 0, "zero"},
+		{"ar", 2, "two"},
+		{"ar", 103, "few"},
+		{"ar", 111, "many"},
+		{"ar", 100, "other"},
+		{"en", -1, "one"},
+	}
+	for _, tt := range tests {
+		if got := PluralCategory(tt.locale, tt.n); got != tt.want {
+			t.Errorf("PluralCategory(%q, %d) = %q, want %q", tt.locale, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		text string
+		args []interface{}
+		want string
+	}{
+		{"plain", nil, "plain"},
+		{"Hello, {name}!", []interface{}{"name", "Ada"}, "Hello, Ada!"},
+		{"{a}+{b}={c}", []interface{}{"a", 1, "b", 2.5, "c", "x"}, "1+2.5=x"},
+		{"{missing} stays", []interface{}{"name", "Ada"}, "{missing} stays"},
+		{"{{literal}} {name}", []interface{}{"name", "x"}, "{literal} x"},
+		{"open {name", []interface{}{"name", "x"}, "open {name"},
+		{"odd {name}", []interface{}{"name"}, "odd {name}"},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.text, tt.args...); got != tt.want {
+			t.Errorf("Format(%q, %v) = %q, want %q", tt.text, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	b := NewBundle("en")
+	b.Add("en", Catalog{
+		"hello": {Text: "Hello"},
+		"bye":   {Text: "Bye"},
+		"only":  {Text: "English only"},
+		"coins": {Plural: map[string]string{"one": "{count} coin", "other": "{count} coins"}},
+	})
+	b.Add("pt", Catalog{
+		"hello": {Text: "Olá"},
+		"bye":   {Text: "Tchau"},
+	})
+	b.Add("pt-BR", Catalog{
+		"hello": {Text: "Oi"},
+	})
+	b.Add("ru", Catalog{
+		"apples": {Plural: map[string]string{"=0": "нет яблок", "one": "{count} яблоко", "few": "{count} яблока", "many": "{count} яблок"}},
+	})
+
+	tests := []struct {
+		locale string
+		key    string
+		args   []interface{}
+		want   string
+	}{
+		{"pt-BR", "hello", nil, "Oi"},
+		{"pt_br", "bye", nil, "Tchau"}, // Region, then language
+		{"pt-BR", "only", nil, "English only"},
+		{"pt-BR", "nothing.{x}", []interface{}{"x", 1}, "nothing.1"}, // The key itself
+		{"de", "hello", nil, "Hello"},
+		{"ru", "apples", []interface{}{"count", 0}, "нет яблок"},
+		{"ru", "apples", []interface{}{"count", 21}, "21 яблоко"},
+		{"ru", "apples", []interface{}{"count", 3}, "3 яблока"},
+		{"ru", "apples", []interface{}{"count", 5}, "5 яблок"},
+		// An English fallback message takes English plurals
+		{"ru", "coins", []interface{}{"count", 21}, "21 coins"},
+		{"ru", "coins", []interface{}{"count", 1}, "1 coin"},
+		{"en", "coins", []interface{}{"count", 1.5}, "1.5 coins"},
+		{"en", "coins", nil, "{count} coins"},
+	}
+	for _, tt := range tests {
+		if got := b.Localizer(tt.locale).T(tt.key, tt.args...); got != tt.want {
+			t.Errorf("%s: T(%q, %v) = %q, want %q", tt.locale, tt.key, tt.args, got, tt.want)
+		}
+	}
+
+	matches := []struct {
+		preferred []string
+		want      string
+	}{
+		{[]string{"pt-BR"}, "pt-br"},
+		{[]string{"pt-PT"}, "pt"},
+		{[]string{"de-DE", "ru-RU"}, "ru"},
+		{[]string{"de"}, "en"},
+	}
+	for _, tt := range matches {
+		if got := b.Match(tt.preferred...); got != tt.want {
+			t.Errorf("Match(%v) = %q, want %q", tt.preferred, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package pixelcanvas
+
+import (
+	"syscall/js"
+	"time"
+)
+
+// idleFallback is the time budget given to idle work where the browser has
+// no requestIdleCallback (Safari) and a timeout is used instead
+const idleFallback = 5 * time.Millisecond
+
+// idleTask is one pending idle callback
+type idleTask struct {
+	id      js.Value
+	fn      js.Func
+	timeout bool // Scheduled with setTimeout rather than requestIdleCallback
+}
+
+// ScheduleIdle runs fn once the browser is idle, between frames, passing the
+// time it may use before it risks delaying the next frame. Use it for work
+// such as asset decoding or pathfinding that shouldn't jank the render
+// loop. Where requestIdleCallback is unavailable fn runs from a short
+// timeout with a small fixed budget. The returned func cancels fn if it has
+// not run yet. Pending callbacks are canceled by Destroy.
+func (c *Canvasp) ScheduleIdle(fn func(deadline time.Duration)) (cancel func()) {
+	t := &idleTask{}
+	t.fn = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		c.finishIdle(t)
+		deadline := idleFallback
+		if len(args) > 0 && args[0].Type() == js.TypeObject {
+			deadline = msToDuration(args[0].Call("timeRemaining").Float())
+		}
+		fn(deadline)
+		return nil
+	})
+
+	if c.window.Get("requestIdleCallback").Type() == js.TypeFunction {
+		t.id = c.window.Call("requestIdleCallback", t.fn)
+	} else {
+		t.timeout = true
+		t.id = c.window.Call("setTimeout", t.fn, 1)
+	}
+	if c.idle == nil {
+		c.idle = make(map[*idleTask]struct{})
+	}
+	c.idle[t] = struct{}{}
+
+	return func() { c.cancelIdle(t) }
+}
+
+// RunIdle splits long running work across idle periods. step is called
+// repeatedly while idle time remains, and should do a small unit of work
+// and return true while there is more to do. The returned func stops the
+// work before the next step.
+func (c *Canvasp) RunIdle(step func() bool) (cancel func()) {
+	stopped := false
+	var pending func()
+
+	var run func(deadline time.Duration)
+	run = func(deadline time.Duration) {
+		start := time.Now()
+		for !stopped && time.Since(start) < deadline {
+			if !step() {
+				return
+			}
+		}
+		if !stopped {
+			pending = c.ScheduleIdle(run)
+		}
+	}
+	pending = c.ScheduleIdle(run)
+
+	return func() {
+		stopped = true
+		pending()
+	}
+}
+
+// finishIdle forgets a task that is running
+func (c *Canvasp) finishIdle(t *idleTask) {
+	delete(c.idle, t)
+	t.fn.Release()
+}
+
+// cancelIdle cancels a task that has not run yet
+func (c *Canvasp) cancelIdle(t *idleTask) {
+	if _, ok := c.idle[t]; !ok {
+		return
+	}
+	if t.timeout {
+		c.window.Call("clearTimeout", t.id)
+	} else {
+		c.window.Call("cancelIdleCallback", t.id)
+	}
+	c.finishIdle(t)
+}
+
+// cancelAllIdle cancels every pending idle task
+func (c *Canvasp) cancelAllIdle() {
+	for t := range c.idle {
+		c.cancelIdle(t)
+	}
+}
@@ -0,0 +1,42 @@
+package pixelcanvas
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif" // Register decoders for image.Decode
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/faiface/pixel"
+)
+
+// LoadIntoCanvas decodes PNG, JPEG or GIF bytes (e.g. from drag and drop,
+// paste or fetch) and draws the image onto the shadow canvas with its bottom
+// left corner (top left with a top-left origin) at 'at'.
+func (c *Canvasp) LoadIntoCanvas(data []byte, at pixel.Vec) error {
+	return c.LoadIntoCanvasScaled(data, at, 1)
+}
+
+// LoadIntoCanvasScaled is LoadIntoCanvas with the image scaled by 'scale'
+func (c *Canvasp) LoadIntoCanvasScaled(data []byte, at pixel.Vec, scale float64) error {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	c.DrawImage(img, at, scale)
+	return nil
+}
+
+// DrawImage draws img onto the shadow canvas with its bottom left corner
+// (top left with a top-left origin) at 'at', scaled by 'scale' in pixels,
+// blending over the existing contents. It returns the canvas area covered.
+func (c *Canvasp) DrawImage(img image.Image, at pixel.Vec, scale float64) pixel.Rect {
+	pic := pixel.PictureDataFromImage(img)
+	size := pic.Bounds().Size().Scaled(scale)
+	sprite := pixel.NewSprite(pic, pic.Bounds())
+
+	// Sprites draw centred on the matrix origin
+	at = c.canvasCorner(at, size.Y)
+	sprite.Draw(c.image, pixel.IM.Scaled(pixel.ZV, scale).Moved(at.Add(size.Scaled(0.5))))
+	return c.FromCanvasRect(pixel.Rect{Min: at, Max: at.Add(size)})
+}
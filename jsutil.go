@@ -0,0 +1,156 @@
+package pixelcanvas
+
+import (
+	"fmt"
+	"syscall/js"
+)
+
+// listener is a DOM event handler registered through listen, kept so that
+// Destroy can remove and release it.
+type listener struct {
+	target js.Value
+	event  string
+	fn     js.Func
+}
+
+// listen adds an event listener to target and tracks it for teardown. The
+// returned handle can be passed to unlisten to remove it early.
+func (c *Canvasp) listen(target js.Value, event string, handler func(e js.Value)) *listener {
+	l := &listener{target: target, event: event}
+	l.fn = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		var e js.Value
+		if len(args) > 0 {
+			e = args[0]
+		}
+		defer c.catchPanic("event " + event)
+		handler(e)
+		return nil
+	})
+	target.Call("addEventListener", event, l.fn)
+	c.listeners = append(c.listeners, l)
+	return l
+}
+
+// unlisten removes a single listener added by listen
+func (c *Canvasp) unlisten(l *listener) {
+	for i, o := range c.listeners {
+		if o == l {
+			c.listeners = append(c.listeners[:i], c.listeners[i+1:]...)
+			break
+		}
+	}
+	l.target.Call("removeEventListener", l.event, l.fn)
+	l.fn.Release()
+}
+
+// releaseListeners removes every tracked listener
+func (c *Canvasp) releaseListeners() {
+	for _, l := range c.listeners {
+		l.target.Call("removeEventListener", l.event, l.fn)
+		l.fn.Release()
+	}
+	c.listeners = nil
+}
+
+// await blocks until a JS promise settles. It must be called from a
+// goroutine, never from inside a JS callback, or it will deadlock.
+func await(p js.Value) (js.Value, error) {
+	done := make(chan struct{})
+	var result js.Value
+	var err error
+
+	then := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) > 0 {
+			result = args[0]
+		}
+		close(done)
+		return nil
+	})
+	catch := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		err = jsError(args)
+		close(done)
+		return nil
+	})
+	p.Call("then", then, catch)
+	<-done
+	then.Release()
+	catch.Release()
+	return result, err
+}
+
+// jsError converts a promise rejection reason into a Go error
+func jsError(args []js.Value) error {
+	if len(args) == 0 || args[0].IsUndefined() || args[0].IsNull() {
+		return fmt.Errorf("pixelcanvas: promise rejected")
+	}
+	return js.Error{Value: args[0]}
+}
+
+// abortSignal returns the AbortSignal used for this canvas's fetches, so that
+// Destroy can cancel anything in flight.
+func (c *Canvasp) abortSignal() js.Value {
+	if c.abort.IsUndefined() {
+		c.abort = js.Global().Get("AbortController").New()
+	}
+	return c.abort.Get("signal")
+}
+
+// abortFetches cancels every in-flight fetch started through fetch
+func (c *Canvasp) abortFetches() {
+	if c.abort.IsUndefined() {
+		return
+	}
+	c.abort.Call("abort")
+	c.abort = js.Undefined()
+}
+
+// fetch starts a fetch tied to the canvas's abort signal and waits for the
+// response headers. Non-2xx responses are returned as errors.
+func (c *Canvasp) fetch(url string) (js.Value, error) {
+	opts := js.Global().Get("Object").New()
+	opts.Set("signal", c.abortSignal())
+	resp, err := await(js.Global().Call("fetch", url, opts))
+	if err != nil {
+		return js.Undefined(), err
+	}
+	if !resp.Get("ok").Bool() {
+		return js.Undefined(), fmt.Errorf("pixelcanvas: fetch %s: %d %s", url, resp.Get("status").Int(), resp.Get("statusText").String())
+	}
+	return resp, nil
+}
+
+// FetchBytes downloads url and returns its body. The request is cancelled if
+// the canvas is destroyed. Like all blocking helpers, call it from a
+// goroutine rather than from a RenderFunc or event handler.
+func (c *Canvasp) FetchBytes(url string) ([]byte, error) {
+	resp, err := c.fetch(url)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := await(resp.Call("arrayBuffer"))
+	if err != nil {
+		return nil, err
+	}
+	arr := js.Global().Get("Uint8Array").New(buf)
+	data := make([]byte, arr.Length())
+	js.CopyBytesToGo(data, arr)
+	return data, nil
+}
+
+// jsFloat reads a number that may be null or undefined (common in sensor and
+// device APIs), returning def in that case.
+func jsFloat(v js.Value, def float64) float64 {
+	if v.Type() != js.TypeNumber {
+		return def
+	}
+	return v.Float()
+}
+
+// contextPixels reads the w x h top left corner of a 2D context into Go, as
+// straight alpha, top-down RGBA
+func contextPixels(ctx js.Value, w int, h int) []uint8 {
+	data := ctx.Call("getImageData", 0, 0, w, h).Get("data")
+	raw := make([]uint8, w*h*4)
+	js.CopyBytesToGo(raw, js.Global().Get("Uint8Array").New(data.Get("buffer")))
+	return raw
+}
@@ -0,0 +1,359 @@
+package pixelcanvas
+
+import (
+	"github.com/faiface/pixel"
+)
+
+// BlendMode selects how a layer combines with the layers below it
+type BlendMode int
+
+// Blend modes, as in the W3C compositing spec (Add is linear dodge)
+const (
+	BlendNormal BlendMode = iota
+	BlendMultiply
+	BlendScreen
+	BlendOverlay
+	BlendDarken
+	BlendLighten
+	BlendAdd
+)
+
+// String implements fmt.Stringer
+func (m BlendMode) String() string {
+	switch m {
+	case BlendNormal:
+		return "Normal"
+	case BlendMultiply:
+		return "Multiply"
+	case BlendScreen:
+		return "Screen"
+	case BlendOverlay:
+		return "Overlay"
+	case BlendDarken:
+		return "Darken"
+	case BlendLighten:
+		return "Lighten"
+	case BlendAdd:
+		return "Add"
+	}
+	return "Unknown"
+}
+
+// Layer is one document layer: its own pixels plus how they are composited.
+// After changing the exported fields call LayerDocument.Update.
+type Layer struct {
+	Name    string
+	Opacity float64 // 0-1
+	Blend   BlendMode
+	Hidden  bool
+	Locked  bool // Edit does nothing on a locked layer
+
+	canvas *Canvas // Drawn on by Edit
+	pix    []uint8 // Cached contents of canvas
+	stale  bool    // pix needs reading back from canvas
+	doc    *LayerDocument
+}
+
+// Edit draws on the layer with the usual pixel drawing calls, and marks it
+// changed. Coordinates are the shadow canvas's.
+func (l *Layer) Edit(fn func(gc *Canvas)) {
+	if l.Locked {
+		return
+	}
+	fn(l.canvas)
+	l.stale = true
+	l.doc.changed = true
+}
+
+// Pixels returns the layer's contents in the shadow canvas layout
+// (premultiplied RGBA, rows bottom-up). It must not be modified; use
+// SetPixels.
+func (l *Layer) Pixels() []uint8 {
+	if l.stale {
+		l.pix = l.canvas.Pixels()
+		l.stale = false
+	}
+	return l.pix
+}
+
+// SetPixels replaces the layer's contents
+func (l *Layer) SetPixels(pix []uint8) {
+	if l.Locked {
+		return
+	}
+	l.canvas.SetPixels(pix)
+	l.pix = append(l.pix[:0], pix...)
+	l.stale = false
+	l.doc.changed = true
+}
+
+// LayerDocument holds a stack of layers, bottom first, and composites the
+// visible ones into the shadow canvas whenever something has changed.
+// Drawing goes to the active layer with Active().Edit.
+type LayerDocument struct {
+	Layers []*Layer
+
+	c       *Canvasp
+	active  int
+	changed bool
+	out     []uint8 // Composite buffer
+}
+
+// NewLayerDocument creates a document with a single layer holding the
+// current canvas contents. When the canvas is resized every layer follows,
+// its contents carried over according to the ResizeMode.
+func (c *Canvasp) NewLayerDocument() *LayerDocument {
+	d := &LayerDocument{c: c, changed: true}
+	l := d.newLayer("Layer 1")
+	l.SetPixels(c.pixels())
+	d.Layers = []*Layer{l}
+	c.OnResize(d.resize)
+	return d
+}
+
+// resize brings every layer to the canvas's new size
+func (d *LayerDocument) resize(width, height int) {
+	for _, l := range d.Layers {
+		b := l.canvas.Bounds()
+		pix := resizePixels(l.Pixels(), int(b.W()), int(b.H()), width, height, d.c.resizeMode)
+		l.canvas = newCanvas(pixel.R(0, 0, float64(width), float64(height)))
+		l.canvas.SetPixels(pix)
+		l.pix, l.stale = pix, false
+	}
+	d.changed = true
+}
+
+func (d *LayerDocument) newLayer(name string) *Layer {
+	return &Layer{
+		Name:    name,
+		Opacity: 1,
+		canvas:  newCanvas(pixel.R(0, 0, float64(d.c.width), float64(d.c.height))),
+		pix:     make([]uint8, d.c.width*d.c.height*4),
+		doc:     d,
+	}
+}
+
+// Active returns the layer being edited
+func (d *LayerDocument) Active() *Layer {
+	return d.Layers[d.active]
+}
+
+// ActiveIndex returns the index of the layer being edited
+func (d *LayerDocument) ActiveIndex() int {
+	return d.active
+}
+
+// SetActive selects the layer to edit
+func (d *LayerDocument) SetActive(i int) {
+	if i >= 0 && i < len(d.Layers) {
+		d.active = i
+	}
+}
+
+// AddLayer adds an empty layer above the active one and makes it active
+func (d *LayerDocument) AddLayer(name string) *Layer {
+	l := d.newLayer(name)
+	at := d.active + 1
+	d.Layers = append(d.Layers, nil)
+	copy(d.Layers[at+1:], d.Layers[at:])
+	d.Layers[at] = l
+	d.active = at
+	d.changed = true
+	return l
+}
+
+// RemoveLayer deletes layer i. The last layer can't be removed.
+func (d *LayerDocument) RemoveLayer(i int) {
+	if len(d.Layers) <= 1 || i < 0 || i >= len(d.Layers) {
+		return
+	}
+	d.Layers = append(d.Layers[:i], d.Layers[i+1:]...)
+	if d.active >= len(d.Layers) || d.active > i {
+		d.active--
+	}
+	d.changed = true
+}
+
+// MoveLayer moves layer from to index to, keeping the same layer active
+func (d *LayerDocument) MoveLayer(from, to int) {
+	if from < 0 || from >= len(d.Layers) || to < 0 || to >= len(d.Layers) || from == to {
+		return
+	}
+	active := d.Layers[d.active]
+	l := d.Layers[from]
+	d.Layers = append(d.Layers[:from], d.Layers[from+1:]...)
+	d.Layers = append(d.Layers, nil)
+	copy(d.Layers[to+1:], d.Layers[to:])
+	d.Layers[to] = l
+	for i, o := range d.Layers {
+		if o == active {
+			d.active = i
+		}
+	}
+	d.changed = true
+}
+
+// MergeDown composites layer i onto the layer below it, using i's opacity
+// and blend mode, and removes i
+func (d *LayerDocument) MergeDown(i int) {
+	if i <= 0 || i >= len(d.Layers) {
+		return
+	}
+	upper, lower := d.Layers[i], d.Layers[i-1]
+	if !upper.Hidden {
+		pix := append([]uint8(nil), lower.Pixels()...)
+		compositeLayer(pix, upper.Pixels(), upper.Opacity, upper.Blend)
+		locked := lower.Locked
+		lower.Locked = false
+		lower.SetPixels(pix)
+		lower.Locked = locked
+	}
+	d.RemoveLayer(i)
+}
+
+// Flatten merges all visible layers into one, discarding hidden layers
+func (d *LayerDocument) Flatten() {
+	flat := d.newLayer(d.Layers[0].Name)
+	flat.SetPixels(d.composite())
+	d.Layers = []*Layer{flat}
+	d.active = 0
+	d.changed = true
+}
+
+// Update marks the document for recompositing, after changing layer fields
+func (d *LayerDocument) Update() {
+	d.changed = true
+}
+
+// Composite draws the visible layers into the shadow canvas if anything has
+// changed since the last call, and reports whether it did
+func (d *LayerDocument) Composite() bool {
+	if !d.changed {
+		return false
+	}
+	d.c.image.SetPixels(d.composite())
+	d.changed = false
+	return true
+}
+
+// RenderFunc returns a RenderFunc for Canvasp.Start that calls rf (which may
+// be nil) to edit the layers, then composites them
+func (d *LayerDocument) RenderFunc(rf RenderFunc) RenderFunc {
+	return func(gc *Canvas) bool {
+		changed := false
+		if rf != nil {
+			changed = rf(gc)
+		}
+		return d.Composite() || changed
+	}
+}
+
+// composite blends the visible layers into d.out
+func (d *LayerDocument) composite() []uint8 {
+	n := d.c.width * d.c.height * 4
+	if len(d.out) != n {
+		d.out = make([]uint8, n)
+	}
+	for i := range d.out {
+		d.out[i] = 0
+	}
+	for _, l := range d.Layers {
+		if !l.Hidden && l.Opacity > 0 {
+			compositeLayer(d.out, l.Pixels(), l.Opacity, l.Blend)
+		}
+	}
+	return d.out
+}
+
+// compositeLayer blends premultiplied src onto premultiplied dst
+func compositeLayer(dst, src []uint8, opacity float64, mode BlendMode) {
+	if mode == BlendNormal && opacity >= 1 {
+		blendOver(dst, src)
+		return
+	}
+
+	op := float32(opacity)
+	if op > 1 {
+		op = 1
+	}
+	if mode == BlendNormal {
+		k := uint32(op * 255)
+		var px [4]uint8
+		for i := 0; i+3 < len(src); i += 4 {
+			if src[i+3] == 0 {
+				continue
+			}
+			px[0] = uint8(uint32(src[i]) * k / 255)
+			px[1] = uint8(uint32(src[i+1]) * k / 255)
+			px[2] = uint8(uint32(src[i+2]) * k / 255)
+			px[3] = uint8(uint32(src[i+3]) * k / 255)
+			blendOver(dst[i:i+4], px[:])
+		}
+		return
+	}
+
+	// co = cs(1-ab) + cb(1-as) + as*ab*B(Cb, Cs), on premultiplied cs and cb
+	for i := 0; i+3 < len(src); i += 4 {
+		if src[i+3] == 0 {
+			continue
+		}
+		as := float32(src[i+3]) / 255 * op
+		ab := float32(dst[i+3]) / 255
+		ao := as + ab*(1-as)
+		for ch := 0; ch < 3; ch++ {
+			cs := float32(src[i+ch]) / 255 * op
+			cb := float32(dst[i+ch]) / 255
+			var Cs, Cb float32
+			if as > 0 {
+				Cs = cs / as
+			}
+			if ab > 0 {
+				Cb = cb / ab
+			}
+			co := cs*(1-ab) + cb*(1-as) + as*ab*blendChannel(mode, Cb, Cs)
+			dst[i+ch] = uint8(clamp32(co)*255 + 0.5)
+		}
+		dst[i+3] = uint8(clamp32(ao)*255 + 0.5)
+	}
+}
+
+// blendChannel is the separable blend function B(Cb, Cs) on straight values
+func blendChannel(mode BlendMode, b, s float32) float32 {
+	switch mode {
+	case BlendMultiply:
+		return b * s
+	case BlendScreen:
+		return b + s - b*s
+	case BlendOverlay:
+		if b <= 0.5 {
+			return 2 * b * s
+		}
+		return 1 - 2*(1-b)*(1-s)
+	case BlendDarken:
+		if b < s {
+			return b
+		}
+		return s
+	case BlendLighten:
+		if b > s {
+			return b
+		}
+		return s
+	case BlendAdd:
+		if b+s > 1 {
+			return 1
+		}
+		return b + s
+	}
+	return s
+}
+
+func clamp32(v float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
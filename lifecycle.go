@@ -0,0 +1,61 @@
+package pixelcanvas
+
+// State is the lifecycle state of a Canvasp's render loop
+type State int
+
+// Lifecycle states. A canvas starts Idle; Start moves it to Running, Pause
+// and Resume switch between Running and Paused, and Stop moves either to
+// Stopped, from which Start can run it again.
+const (
+	StateIdle State = iota
+	StateRunning
+	StatePaused
+	StateStopped
+)
+
+// String implements fmt.Stringer
+func (s State) String() string {
+	switch s {
+	case StateIdle:
+		return "Idle"
+	case StateRunning:
+		return "Running"
+	case StatePaused:
+		return "Paused"
+	case StateStopped:
+		return "Stopped"
+	}
+	return "Unknown"
+}
+
+// State returns the current lifecycle state
+func (c *Canvasp) State() State {
+	return c.state
+}
+
+// Pause suspends the render loop without tearing it down. No frames are
+// requested until Resume. Pausing a canvas that is not running is a no-op.
+func (c *Canvasp) Pause() {
+	if c.state != StateRunning {
+		return
+	}
+	if c.shared == nil {
+		c.rafStop.Invoke(c.reqID)
+	}
+	c.state = StatePaused
+	c.log().Debug("render loop paused")
+}
+
+// Resume restarts a paused render loop with the same RenderFunc and FPS.
+// Time spent paused is not counted in Delta or SimTime.
+func (c *Canvasp) Resume() {
+	if c.state != StatePaused {
+		return
+	}
+	c.state = StateRunning
+	c.clock.resync()
+	if c.shared == nil { // A shared Clock is still ticking and picks the canvas up again
+		c.reqID = c.rafCall.Invoke(c.raf)
+	}
+	c.log().Debug("render loop resumed")
+}
@@ -0,0 +1,289 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Lighting
+//
+// A Lighting pass multiplies the frame by a light map as it is copied to
+// the browser: ambient light everywhere, plus point and cone lights that
+// fade with distance and are blocked by rectangular occluders. The shadow
+// canvas keeps the unlit scene, so drawing code doesn't change, and
+// overlays (UI, cursors) are composited on top unlit.
+//
+// Light positions are in shadow canvas pixels. The light map is only
+// rebuilt after Invalidate (or adding and removing lights), so a static
+// lighting setup costs one multiply per pixel per copy.
+
+// Light is a point light, or a cone when Angle is set
+type Light struct {
+	Pos       pixel.Vec
+	Radius    float64 // Distance at which the light has faded out
+	Color     color.RGBA
+	Intensity float64 // Brightness at the centre. 1 exactly cancels a black ambient
+
+	// Falloff shapes the fade: 1 is linear, 2 (the default for 0) is
+	// quadratic, with a brighter core and softer edge
+	Falloff float64
+
+	Dir   float64 // Cone direction in degrees, anticlockwise from the positive x axis
+	Angle float64 // Cone half-width in degrees. 0 for a point light
+}
+
+// Lighting is a canvas's light map pass
+type Lighting struct {
+	// Ambient is the light everywhere before lights are added; black for
+	// darkness, white for none
+	Ambient color.RGBA
+
+	// Occluders cast shadows: a pixel is unlit by a light when the line to
+	// it crosses one. Pixels inside an occluder are lit as normal, so
+	// walls catch the light on their faces.
+	Occluders []pixel.Rect
+
+	c      *Canvasp
+	lights []*Light
+	lmap   []uint16 // Light per pixel and channel, 256 being 1
+	w, h   int
+	stale  bool
+	hidden bool
+}
+
+// maxLight caps accumulated light at twice full brightness, allowing
+// overexposed highlights where lights overlap
+const maxLight = 512
+
+// Lighting returns the canvas's lighting pass, creating it, dark blue and
+// with no lights, on first use
+func (c *Canvasp) Lighting() *Lighting {
+	for _, p := range c.passes {
+		if l, ok := p.(*Lighting); ok {
+			return l
+		}
+	}
+	l := &Lighting{Ambient: color.RGBA{40, 40, 64, 255}, c: c, stale: true}
+	c.addPass(l)
+	return l
+}
+
+// RemoveLighting removes the lighting pass, leaving the scene unlit
+func (c *Canvasp) RemoveLighting() {
+	for _, p := range c.passes {
+		if l, ok := p.(*Lighting); ok {
+			c.removePass(l)
+			return
+		}
+	}
+}
+
+// Add adds a light, returning it so it can be moved later
+func (l *Lighting) Add(light *Light) *Light {
+	l.lights = append(l.lights, light)
+	l.Invalidate()
+	return light
+}
+
+// Remove removes a light
+func (l *Lighting) Remove(light *Light) {
+	for i, o := range l.lights {
+		if o == light {
+			l.lights = append(l.lights[:i], l.lights[i+1:]...)
+			l.Invalidate()
+			return
+		}
+	}
+}
+
+// Lights returns the lights, in the order added
+func (l *Lighting) Lights() []*Light {
+	return l.lights
+}
+
+// Invalidate rebuilds the light map before the next copy. Call it after
+// changing a light, the ambient or the occluders.
+func (l *Lighting) Invalidate() {
+	l.stale = true
+}
+
+// Show turns the lighting on or off without losing the lights
+func (l *Lighting) Show(on bool) {
+	if l.hidden == !on {
+		return
+	}
+	l.hidden = !on
+	l.c.overlayRemoved = true // Copy the frame again
+}
+
+// At returns the light falling on pixel x, y as a colour, e.g. to make
+// game logic react to shadows. Values above 255 are clamped.
+func (l *Lighting) At(x, y int) color.RGBA {
+	l.rebuild()
+	if x < 0 || y < 0 || x >= l.w || y >= l.h {
+		return l.Ambient
+	}
+	i := (y*l.w + x) * 3
+	return color.RGBA{
+		uint8(minInt(int(l.lmap[i]), 255)), uint8(minInt(int(l.lmap[i+1]), 255)),
+		uint8(minInt(int(l.lmap[i+2]), 255)), 255,
+	}
+}
+
+func (l *Lighting) prepare(c *Canvasp) bool {
+	if l.hidden {
+		return false
+	}
+	if l.w != c.width || l.h != c.height {
+		l.stale = true
+	}
+	if !l.stale {
+		return false
+	}
+	l.rebuild()
+	return true
+}
+
+func (l *Lighting) active() bool { return !l.hidden }
+
+func (l *Lighting) overOverlays() bool { return false }
+
+// row multiplies a straight RGBA row by the light map
+func (l *Lighting) row(dst []uint8, y int) {
+	if y >= l.h {
+		return
+	}
+	m := l.lmap[y*l.w*3 : (y+1)*l.w*3]
+	for x, i := 0, 0; i+3 < len(dst) && x < l.w; x, i = x+1, i+4 {
+		k := x * 3
+		dst[i] = uint8(minInt(int(dst[i])*int(m[k])>>8, 255))
+		dst[i+1] = uint8(minInt(int(dst[i+1])*int(m[k+1])>>8, 255))
+		dst[i+2] = uint8(minInt(int(dst[i+2])*int(m[k+2])>>8, 255))
+	}
+}
+
+// rebuild recomputes the light map if it is stale
+func (l *Lighting) rebuild() {
+	if !l.stale && l.w == l.c.width && l.h == l.c.height {
+		return
+	}
+	l.stale = false
+	l.w, l.h = l.c.width, l.c.height
+	if len(l.lmap) != l.w*l.h*3 {
+		l.lmap = make([]uint16, l.w*l.h*3)
+	}
+	a := [3]uint16{uint16(l.Ambient.R), uint16(l.Ambient.G), uint16(l.Ambient.B)}
+	for i := 0; i < len(l.lmap); i += 3 {
+		l.lmap[i], l.lmap[i+1], l.lmap[i+2] = a[0], a[1], a[2]
+	}
+	for _, light := range l.lights {
+		l.addLight(light)
+	}
+}
+
+// addLight accumulates one light into the map
+func (l *Lighting) addLight(light *Light) {
+	if light.Radius <= 0 || light.Intensity <= 0 {
+		return
+	}
+	falloff := light.Falloff
+	if falloff <= 0 {
+		falloff = 2
+	}
+	r := light.Radius
+	x0, y0 := maxInt(int(light.Pos.X-r), 0), maxInt(int(light.Pos.Y-r), 0)
+	x1, y1 := minInt(int(light.Pos.X+r)+1, l.w), minInt(int(light.Pos.Y+r)+1, l.h)
+	if x0 >= x1 || y0 >= y1 {
+		return
+	}
+
+	// Only occluders within reach can cast shadows
+	reach := pixel.R(float64(x0), float64(y0), float64(x1), float64(y1))
+	var occ []pixel.Rect
+	for _, o := range l.Occluders {
+		if overlaps(o.Norm(), reach) {
+			occ = append(occ, o.Norm())
+		}
+	}
+
+	cone := light.Angle > 0 && light.Angle < 180
+	dirS, dirC := math.Sincos(light.Dir * math.Pi / 180)
+	cosHalf := math.Cos(light.Angle * math.Pi / 180)
+	col := [3]float64{
+		float64(light.Color.R) * light.Intensity,
+		float64(light.Color.G) * light.Intensity,
+		float64(light.Color.B) * light.Intensity,
+	}
+
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			p := pixel.V(float64(x)+0.5, float64(y)+0.5)
+			d := p.Sub(light.Pos)
+			dist := d.Len()
+			if dist >= r {
+				continue
+			}
+			f := math.Pow(1-dist/r, falloff)
+			if cone && dist > 0 {
+				c := (d.X*dirC + d.Y*dirS) / dist
+				if c < cosHalf {
+					continue
+				}
+				// Soften the cone's edge over the outer fifth of its angle
+				if edge := (c - cosHalf) / ((1 - cosHalf) * 0.2); edge < 1 {
+					f *= edge
+				}
+			}
+			if shadowed(light.Pos, p, occ) {
+				continue
+			}
+			i := (y*l.w + x) * 3
+			for k := 0; k < 3; k++ {
+				v := int(l.lmap[i+k]) + int(col[k]*f)
+				l.lmap[i+k] = uint16(minInt(v, maxLight))
+			}
+		}
+	}
+}
+
+// shadowed reports whether the segment from a to b crosses an occluder
+// that doesn't contain b
+func shadowed(a, b pixel.Vec, occ []pixel.Rect) bool {
+	for _, o := range occ {
+		if o.Contains(b) || o.Contains(a) {
+			continue
+		}
+		if segmentHitsRect(a, b, o) {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentHitsRect is the slab test for the segment from a to b
+func segmentHitsRect(a, b pixel.Vec, r pixel.Rect) bool {
+	t0, t1 := 0.0, 1.0
+	d := b.Sub(a)
+	for _, axis := range [2]struct{ p, d, lo, hi float64 }{
+		{a.X, d.X, r.Min.X, r.Max.X},
+		{a.Y, d.Y, r.Min.Y, r.Max.Y},
+	} {
+		if axis.d == 0 {
+			if axis.p < axis.lo || axis.p > axis.hi {
+				return false
+			}
+			continue
+		}
+		ta, tb := (axis.lo-axis.p)/axis.d, (axis.hi-axis.p)/axis.d
+		if ta > tb {
+			ta, tb = tb, ta
+		}
+		t0, t1 = math.Max(t0, ta), math.Min(t1, tb)
+		if t0 > t1 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,277 @@
+// Package loader generates the HTML and JavaScript that bootstrap a
+// pixelcanvas app, so a deployment doesn't need a hand-written page.
+//
+// The loader script fetches the WebAssembly binary, reporting download
+// progress to a <progress> element and as "pixelcanvas:progress" events,
+// compiles it with WebAssembly.instantiateStreaming (falling back to
+// instantiate on an ArrayBuffer where streaming is missing or the server
+// sends the wrong MIME type) and runs it with Go's wasm_exec.js, which
+// Bundle prepends from the local Go installation. The progress bar stays up
+// until the app calls pixelcanvas.Ready, which also resolves the
+// window.pixelcanvas.ready promise for the rest of the page.
+//
+// For deployment, a Manifest records the content-hashed names of a packed
+// app's files, and ServiceWorker generates a worker precaching them for
+// offline use.
+package loader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Defaults for Options
+const (
+	DefaultWasm     = "main.wasm"
+	DefaultScript   = "loader.js"
+	DefaultProgress = "pixelcanvas-progress"
+)
+
+// ErrNoWasmExec is returned when wasm_exec.js can't be found in GOROOT
+var ErrNoWasmExec = errors.New("pixelcanvas: loader: wasm_exec.js not found in GOROOT")
+
+// Options configures the generated loader
+type Options struct {
+	Wasm     string            // URL of the binary, DefaultWasm if empty
+	Progress string            // id of the <progress> element, DefaultProgress if empty
+	Args     []string          // os.Args for the program after its name
+	Env      map[string]string // Environment for the program
+	Title    string            // Page title, for HTML
+	Script   string            // URL of the loader script, for HTML; DefaultScript if empty
+
+	// ServiceWorker, if set, is the URL of a service worker (see
+	// ServiceWorker) for the loader to register
+	ServiceWorker string
+}
+
+func (o Options) withDefaults() Options {
+	if o.Wasm == "" {
+		o.Wasm = DefaultWasm
+	}
+	if o.Progress == "" {
+		o.Progress = DefaultProgress
+	}
+	if o.Script == "" {
+		o.Script = DefaultScript
+	}
+	if o.Title == "" {
+		o.Title = "pixelcanvas"
+	}
+	return o
+}
+
+// Script returns the loader script for opts. It expects the Go class from
+// wasm_exec.js to be defined already; use Bundle for a single file with both.
+func Script(opts Options) string {
+	opts = opts.withDefaults()
+	args := opts.Args
+	if args == nil {
+		args = []string{}
+	}
+	env := opts.Env
+	if env == nil {
+		env = map[string]string{}
+	}
+	cfg, _ := json.Marshal(map[string]interface{}{
+		"wasm":     opts.Wasm,
+		"progress": opts.Progress,
+		"argv":     append([]string{"js"}, args...),
+		"env":      env,
+		"sw":       opts.ServiceWorker,
+	})
+	return strings.Replace(script, "/*CONFIG*/", string(cfg), 1)
+}
+
+// Bundle returns wasm_exec.js from the local Go installation followed by the
+// loader script, as one file to serve
+func Bundle(opts Options) ([]byte, error) {
+	path, err := WasmExecPath()
+	if err != nil {
+		return nil, err
+	}
+	exec, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("pixelcanvas: loader: %v", err)
+	}
+	return append(append(exec, '\n'), Script(opts)...), nil
+}
+
+// WasmExecPath returns the path of wasm_exec.js for the go command on the
+// PATH, which is the toolchain that builds the binary. The binary must be
+// served with the same version it was built with.
+func WasmExecPath() (string, error) {
+	out, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		return "", fmt.Errorf("pixelcanvas: loader: go env GOROOT: %v", err)
+	}
+	root := strings.TrimSpace(string(out))
+	for _, dir := range []string{"lib", "misc"} { // lib since Go 1.24
+		path := filepath.Join(root, dir, "wasm", "wasm_exec.js")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", ErrNoWasmExec
+}
+
+// HTML returns a minimal page for the app: a progress bar and the loader
+// script (see Bundle) from opts.Script. The package's canvas is appended to
+// the body.
+func HTML(opts Options) string {
+	opts = opts.withDefaults()
+	return fmt.Sprintf(page, html.EscapeString(opts.Title), html.EscapeString(opts.Progress),
+		html.EscapeString(opts.Script))
+}
+
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style>
+html, body { margin: 0; height: 100%%; background: #1e1e28; }
+progress { position: fixed; left: 25%%; top: 50%%; width: 50%%; }
+</style>
+</head>
+<body>
+<progress id="%s"></progress>
+<script src="%s"></script>
+</body>
+</html>
+`
+
+// script is the loader. Download progress is counted on the response body
+// as it streams, against Content-Length; with a compressed response the
+// body is larger than that, so the count is clamped and the bar may sit
+// full until compiling ends.
+const script = `(function () {
+"use strict";
+var cfg = /*CONFIG*/;
+var api = window.pixelcanvas = window.pixelcanvas || {};
+var bar = document.getElementById(cfg.progress);
+var markReady;
+api.ready = new Promise(function (resolve) { markReady = resolve; });
+
+function emit(type, detail) {
+	window.dispatchEvent(new CustomEvent("pixelcanvas:" + type, {detail: detail}));
+}
+
+// progress updates the bar; total 0 makes it indeterminate
+api.progress = function (loaded, total, stage) {
+	if (bar) {
+		if (total > 0) {
+			bar.max = total;
+			bar.value = Math.min(loaded, total);
+		} else {
+			bar.removeAttribute("value");
+		}
+	}
+	emit("progress", {loaded: loaded, total: total, stage: stage});
+};
+
+// markReady is called by pixelcanvas.Ready once the app is up
+api.markReady = function () {
+	if (bar) {
+		bar.hidden = true;
+	}
+	emit("ready", {});
+	markReady();
+};
+
+function fail(err) {
+	if (bar) {
+		bar.hidden = true;
+	}
+	console.error("pixelcanvas: loading failed:", err);
+	emit("error", {message: String(err && err.message || err)});
+}
+
+// counted wraps a response to report its body's progress as it is read
+function counted(resp) {
+	if (!resp.body || typeof ReadableStream === "undefined") {
+		return resp;
+	}
+	var total = +resp.headers.get("Content-Length") || 0;
+	var loaded = 0;
+	var reader = resp.body.getReader();
+	var body = new ReadableStream({
+		pull: function (ctl) {
+			return reader.read().then(function (r) {
+				if (r.done) {
+					api.progress(1, 1, "compile");
+					ctl.close();
+					return;
+				}
+				loaded += r.value.byteLength;
+				api.progress(loaded, total, "download");
+				ctl.enqueue(r.value);
+			});
+		}
+	});
+	return new Response(body, {status: resp.status, headers: {"Content-Type": "application/wasm"}});
+}
+
+function fetchWasm() {
+	return fetch(cfg.wasm).then(function (resp) {
+		if (!resp.ok) {
+			throw new Error(cfg.wasm + ": " + resp.status + " " + resp.statusText);
+		}
+		return counted(resp);
+	});
+}
+
+function instantiate(go) {
+	var buffered = function () {
+		return fetchWasm().then(function (resp) {
+			return resp.arrayBuffer();
+		}).then(function (buf) {
+			return WebAssembly.instantiate(buf, go.importObject);
+		});
+	};
+	if (!WebAssembly.instantiateStreaming) {
+		return buffered();
+	}
+	return WebAssembly.instantiateStreaming(fetchWasm(), go.importObject).catch(function (err) {
+		console.warn("pixelcanvas: streaming compile failed, retrying buffered:", err);
+		return buffered();
+	});
+}
+
+if (typeof Go === "undefined") {
+	fail(new Error("wasm_exec.js is not loaded"));
+	return;
+}
+var go = new Go();
+go.argv = cfg.argv;
+for (var k in cfg.env) {
+	go.env[k] = cfg.env[k];
+}
+// Keep the install prompt for the app to offer (Canvasp.Install); it
+// usually fires before the binary has started
+window.addEventListener("beforeinstallprompt", function (e) {
+	e.preventDefault();
+	api.installPrompt = e;
+	emit("installable", {});
+});
+if (cfg.sw && "serviceWorker" in navigator) {
+	navigator.serviceWorker.register(cfg.sw).catch(function (err) {
+		console.warn("pixelcanvas: service worker registration failed:", err);
+	});
+}
+api.progress(0, 0, "download");
+instantiate(go).then(function (result) {
+	emit("loaded", {});
+	return go.run(result.instance);
+}).then(function () {
+	emit("exit", {code: go.exitCode});
+}).catch(fail);
+})();
+`
@@ -0,0 +1,115 @@
+package loader
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// ManifestFile is the name a Manifest is written under
+const ManifestFile = "manifest.json"
+
+// Manifest lists a packed app's files, mapping the names they were built
+// from to the content-hashed names they are served under, so every file
+// but the page itself can be cached forever
+type Manifest struct {
+	Version string            `json:"version"` // Changes whenever any file does
+	Wasm    string            `json:"wasm"`
+	Script  string            `json:"script"`
+	Assets  map[string]string `json:"assets,omitempty"` // Original name to served name
+}
+
+// ParseManifest decodes a manifest
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Files returns every URL in the manifest, including the page and the
+// manifest itself, sorted
+func (m *Manifest) Files() []string {
+	files := []string{"./", ManifestFile, m.Wasm, m.Script}
+	for _, url := range m.Assets {
+		files = append(files, url)
+	}
+	sort.Strings(files[4:])
+	return files
+}
+
+// ServiceWorker returns a service worker script that precaches every file
+// in m on install, so the app then starts offline. Each manifest version
+// gets its own cache, and older ones are deleted once the new version
+// activates. Page navigations go to the network first, so a new version is
+// found when online, and everything else is served from the cache first.
+// A new version waits until the page activates it (Canvasp's
+// ServiceWorker.Activate); files the page precaches itself go to a runtime
+// cache that is kept across versions.
+func ServiceWorker(m *Manifest) string {
+	files, _ := json.Marshal(m.Files())
+	r := strings.NewReplacer("/*VERSION*/", jsString(m.Version), "/*FILES*/", string(files))
+	return r.Replace(serviceWorker)
+}
+
+func jsString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+const serviceWorker = `"use strict";
+var PREFIX = "pixelcanvas-";
+var CACHE = PREFIX + /*VERSION*/;
+var RUNTIME = PREFIX + "runtime"; // Filled by the page, see Canvasp.Precache
+var FILES = /*FILES*/;
+
+self.addEventListener("install", function (e) {
+	e.waitUntil(caches.open(CACHE).then(function (cache) {
+		return cache.addAll(FILES);
+	}));
+});
+
+self.addEventListener("activate", function (e) {
+	e.waitUntil(caches.keys().then(function (keys) {
+		return Promise.all(keys.filter(function (k) {
+			return k.indexOf(PREFIX) === 0 && k !== CACHE && k !== RUNTIME;
+		}).map(function (k) {
+			return caches.delete(k);
+		}));
+	}).then(function () {
+		return self.clients.claim();
+	}));
+});
+
+// The page activates a waiting update with Activate
+self.addEventListener("message", function (e) {
+	if (e.data && e.data.type === "pixelcanvas:skipWaiting") {
+		self.skipWaiting();
+	}
+});
+
+self.addEventListener("fetch", function (e) {
+	var req = e.request;
+	if (req.method !== "GET" || new URL(req.url).origin !== location.origin) {
+		return;
+	}
+	if (req.mode === "navigate") {
+		e.respondWith(fetch(req).then(function (resp) {
+			if (resp.ok) {
+				var copy = resp.clone();
+				caches.open(CACHE).then(function (cache) {
+					cache.put("./", copy);
+				});
+			}
+			return resp;
+		}).catch(function () {
+			return caches.match("./", {cacheName: CACHE});
+		}));
+		return;
+	}
+	e.respondWith(caches.match(req).then(function (hit) {
+		return hit || fetch(req);
+	}));
+});
+`
@@ -0,0 +1,161 @@
+package pixelcanvas
+
+import (
+	"fmt"
+	"syscall/js"
+)
+
+// Logger receives the package's diagnostic output. keyvals are alternating
+// key/value pairs, e.g. log.Warn("frame overrun", "ms", 21.5).
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// LogLevel filters ConsoleLogger output
+type LogLevel int
+
+// Log levels, in increasing severity
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// nopLogger is the default: the package stays silent unless asked
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+// ConsoleLogger writes to the browser console using the matching console
+// method, so levels can be filtered in devtools. Key/value pairs are passed
+// as an object so they can be expanded and inspected.
+type ConsoleLogger struct {
+	Level  LogLevel
+	Prefix string // Prepended to each message. Defaults to "pixelcanvas: "
+
+	console js.Value
+}
+
+// NewConsoleLogger creates a ConsoleLogger showing messages at level and above
+func NewConsoleLogger(level LogLevel) *ConsoleLogger {
+	return &ConsoleLogger{Level: level, Prefix: "pixelcanvas: ", console: js.Global().Get("console")}
+}
+
+// Debug implements Logger
+func (l *ConsoleLogger) Debug(msg string, keyvals ...interface{}) {
+	l.write(LevelDebug, "debug", msg, keyvals)
+}
+
+// Info implements Logger
+func (l *ConsoleLogger) Info(msg string, keyvals ...interface{}) {
+	l.write(LevelInfo, "info", msg, keyvals)
+}
+
+// Warn implements Logger
+func (l *ConsoleLogger) Warn(msg string, keyvals ...interface{}) {
+	l.write(LevelWarn, "warn", msg, keyvals)
+}
+
+// Error implements Logger
+func (l *ConsoleLogger) Error(msg string, keyvals ...interface{}) {
+	l.write(LevelError, "error", msg, keyvals)
+}
+
+func (l *ConsoleLogger) write(level LogLevel, method string, msg string, keyvals []interface{}) {
+	if level < l.Level {
+		return
+	}
+	if len(keyvals) == 0 {
+		l.console.Call(method, l.Prefix+msg)
+		return
+	}
+
+	fields := js.Global().Get("Object").New()
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		if i+1 >= len(keyvals) {
+			fields.Set(key, js.Null())
+			break
+		}
+		fields.Set(key, jsLogValue(keyvals[i+1]))
+	}
+	l.console.Call(method, l.Prefix+msg, fields)
+}
+
+// jsLogValue passes through values syscall/js understands, and formats the rest
+func jsLogValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, bool, string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, js.Value:
+		return v
+	case error:
+		return v.Error()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// SetLogger sets where the package logs to. nil silences it again.
+func (c *Canvasp) SetLogger(l Logger) {
+	c.logger = l
+}
+
+// log returns the current Logger, never nil
+func (c *Canvasp) log() Logger {
+	if c.logger == nil {
+		return nopLogger{}
+	}
+	return c.logger
+}
+
+// SetTracing turns on performance.mark/measure emission around each frame's
+// render and copy stages, so frames show up with labels in the browser's
+// Performance profiler. It costs a few JS calls per frame, so leave it off
+// in production.
+func (c *Canvasp) SetTracing(on bool) {
+	c.tracing = on
+	if on && c.perf.IsUndefined() {
+		c.perf = js.Global().Get("performance")
+	}
+}
+
+// Trace marks are named with this prefix
+const tracePrefix = "pixelcanvas:"
+
+// traceNames caches the prefixed names, so tracing doesn't build the same
+// strings every frame
+var traceNames = map[string]string{}
+
+func traceName(name string) string {
+	n, ok := traceNames[name]
+	if !ok {
+		n = tracePrefix + name
+		traceNames[name] = n
+	}
+	return n
+}
+
+// mark emits a performance mark when tracing is on
+func (c *Canvasp) mark(name string) {
+	if !c.tracing || c.perf.IsUndefined() {
+		return
+	}
+	c.perf.Call("mark", traceName(name))
+}
+
+// measure emits a performance measure between two marks when tracing is on,
+// then clears the marks so they do not accumulate.
+func (c *Canvasp) measure(name string, start string, end string) {
+	if !c.tracing || c.perf.IsUndefined() {
+		return
+	}
+	c.perf.Call("measure", traceName(name), traceName(start), traceName(end))
+	c.perf.Call("clearMarks", traceName(start))
+	c.perf.Call("clearMarks", traceName(end))
+}
@@ -0,0 +1,235 @@
+package pixelcanvas
+
+import (
+	"encoding/json"
+	"syscall/js"
+	"time"
+)
+
+// Macros
+//
+// A Macro is a timed list of input actions and named operations, recorded
+// from a live session and replayed later against the same app: for demos,
+// tutorials, and driving automated UI tests. Actions come from an
+// ActionMap; operations are whatever the app records with Op (e.g. "fill"
+// with a colour index) plus, optionally, raw pointer gestures on the
+// canvas. Times are simulation time (see SimTime), so a replay runs at the
+// speed the frames do, and a paused or throttled tab doesn't skip steps.
+
+// Pointer operations recorded by MacroRecorder.RecordPointer. Their args
+// are the shadow canvas x, y and the pressed buttons bitmask.
+const (
+	MacroPointerDown = "pointerdown"
+	MacroPointerMove = "pointermove"
+	MacroPointerUp   = "pointerup"
+)
+
+// MacroStep is one recorded event
+type MacroStep struct {
+	At     time.Duration `json:"at"`               // Since recording started
+	Action string        `json:"action,omitempty"` // An ActionMap action going down or up
+	Down   bool          `json:"down,omitempty"`
+	Op     string        `json:"op,omitempty"` // Or a named operation
+	Args   []float64     `json:"args,omitempty"`
+}
+
+// Macro is a recorded sequence of steps, in time order. It encodes to
+// JSON for saving alongside an app or a test.
+type Macro struct {
+	Steps []MacroStep `json:"steps"`
+}
+
+// Duration returns the time of the last step
+func (m *Macro) Duration() time.Duration {
+	if len(m.Steps) == 0 {
+		return 0
+	}
+	return m.Steps[len(m.Steps)-1].At
+}
+
+// ParseMacro decodes a macro saved as JSON
+func ParseMacro(data []byte) (*Macro, error) {
+	m := &Macro{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MacroRecorder records a Macro from a running canvas
+type MacroRecorder struct {
+	c       *Canvasp
+	start   time.Duration
+	macro   *Macro
+	actions *ActionMap
+
+	pointer []*listener
+}
+
+// NewMacroRecorder starts recording. With actions set, every action
+// going down or up (as seen by ActionMap.Update) is recorded.
+func (c *Canvasp) NewMacroRecorder(actions *ActionMap) *MacroRecorder {
+	r := &MacroRecorder{c: c, start: c.SimTime(), macro: &Macro{}, actions: actions}
+	if actions != nil {
+		actions.recorder = r
+	}
+	return r
+}
+
+// Op records a named operation with its arguments, to be handed to the
+// player's handler for name on replay
+func (r *MacroRecorder) Op(name string, args ...float64) {
+	r.add(MacroStep{Op: name, Args: append([]float64(nil), args...)})
+}
+
+// RecordPointer turns recording of pointer gestures over the canvas on or
+// off, as MacroPointerDown, MacroPointerMove and MacroPointerUp operations
+func (r *MacroRecorder) RecordPointer(on bool) {
+	for _, l := range r.pointer {
+		r.c.unlisten(l)
+	}
+	r.pointer = nil
+	if !on {
+		return
+	}
+	op := func(name string) func(js.Value) {
+		return func(e js.Value) {
+			p := r.c.FromClient(e.Get("clientX").Float(), e.Get("clientY").Float())
+			r.Op(name, p.X, p.Y, float64(e.Get("buttons").Int()))
+		}
+	}
+	c := r.c
+	r.pointer = []*listener{
+		c.listen(c.canvas, "pointerdown", op(MacroPointerDown)),
+		c.listen(c.canvas, "pointermove", op(MacroPointerMove)),
+		c.listen(c.canvas, "pointerup", op(MacroPointerUp)),
+	}
+}
+
+// Stop ends the recording and returns the macro
+func (r *MacroRecorder) Stop() *Macro {
+	r.RecordPointer(false)
+	if r.actions != nil && r.actions.recorder == r {
+		r.actions.recorder = nil
+	}
+	return r.macro
+}
+
+func (r *MacroRecorder) add(s MacroStep) {
+	s.At = r.c.SimTime() - r.start
+	r.macro.Steps = append(r.macro.Steps, s)
+}
+
+// MacroPlayer replays a Macro. Call Update once per frame, before
+// ActionMap.Update, so replayed actions are seen the same frame.
+type MacroPlayer struct {
+	Speed float64 // Playback rate. 1 for real time
+	Loop  bool    // Start over after the last step
+
+	// OnDone, if set, is called when playback reaches the end (without Loop)
+	OnDone func()
+
+	c        *Canvasp
+	macro    *Macro
+	actions  *ActionMap
+	handlers map[string]func(args []float64)
+
+	playing bool
+	last    time.Duration // SimTime at the previous Update
+	at      time.Duration // Position in the macro
+	next    int           // Index of the next step to run
+}
+
+// NewMacroPlayer prepares m for playback. Recorded actions are pressed
+// and released on actions, which may be nil if the macro has none.
+func (c *Canvasp) NewMacroPlayer(m *Macro, actions *ActionMap) *MacroPlayer {
+	return &MacroPlayer{
+		Speed:    1,
+		c:        c,
+		macro:    m,
+		actions:  actions,
+		handlers: make(map[string]func(args []float64)),
+	}
+}
+
+// Handle sets the function that replays operation name. Operations without
+// a handler are skipped.
+func (p *MacroPlayer) Handle(name string, fn func(args []float64)) {
+	p.handlers[name] = fn
+}
+
+// Play starts (or resumes) playback
+func (p *MacroPlayer) Play() {
+	p.playing = true
+	p.last = p.c.SimTime()
+}
+
+// Pause stops playback where it is, releasing any actions it holds
+func (p *MacroPlayer) Pause() {
+	p.playing = false
+	p.releaseActions()
+}
+
+// Rewind goes back to the start
+func (p *MacroPlayer) Rewind() {
+	p.at, p.next = 0, 0
+	p.releaseActions()
+}
+
+// Playing reports whether playback is running
+func (p *MacroPlayer) Playing() bool {
+	return p.playing
+}
+
+// Position returns how far into the macro playback is
+func (p *MacroPlayer) Position() time.Duration {
+	return p.at
+}
+
+// Update runs every step that has come due since the last frame
+func (p *MacroPlayer) Update() {
+	if !p.playing {
+		return
+	}
+	now := p.c.SimTime()
+	p.at += time.Duration(float64(now-p.last) * p.Speed)
+	p.last = now
+
+	steps := p.macro.Steps
+	for p.next < len(steps) && steps[p.next].At <= p.at {
+		p.run(steps[p.next])
+		p.next++
+	}
+	if p.next < len(steps) {
+		return
+	}
+	if p.Loop && len(steps) > 0 {
+		p.Rewind()
+		return
+	}
+	p.playing = false
+	p.releaseActions()
+	if p.OnDone != nil {
+		p.OnDone()
+	}
+}
+
+func (p *MacroPlayer) run(s MacroStep) {
+	if s.Action != "" {
+		if p.actions != nil {
+			p.actions.inject(s.Action, s.Down)
+		}
+		return
+	}
+	if fn := p.handlers[s.Op]; fn != nil {
+		fn(s.Args)
+	}
+}
+
+func (p *MacroPlayer) releaseActions() {
+	if p.actions != nil {
+		for a := range p.actions.injected {
+			p.actions.inject(a, false)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package pixelcanvas
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// NineSlice scales a panel image to any size without distorting its
+// border: the corners are kept as they are, the edges stretch (or tile)
+// along their length, and the centre fills the rest. Insets are in the
+// image's pixels.
+type NineSlice struct {
+	Image                    *Region
+	Left, Bottom, Right, Top int
+
+	TileEdges  bool // Repeat the edges rather than stretching them
+	TileCentre bool // Repeat the centre rather than stretching it
+}
+
+// Render draws the panel at width x height into a new Region, e.g. to
+// cache a window background or paste onto an overlay
+func (n *NineSlice) Render(width, height int) *Region {
+	out := NewRegion(maxInt(width, 0), maxInt(height, 0))
+	img := n.Image
+	if img == nil || width <= 0 || height <= 0 {
+		return out
+	}
+
+	// Shrink the insets proportionally if the panel is smaller than them
+	l, r, b, t := n.Left, n.Right, n.Bottom, n.Top
+	if l+r > width {
+		l = l * width / (l + r)
+		r = width - l
+	}
+	if b+t > height {
+		b = b * height / (b + t)
+		t = height - b
+	}
+
+	// Source and destination column and row spans: start, size
+	scols := [3][2]int{{0, n.Left}, {n.Left, img.Width - n.Left - n.Right}, {img.Width - n.Right, n.Right}}
+	srows := [3][2]int{{0, n.Bottom}, {n.Bottom, img.Height - n.Bottom - n.Top}, {img.Height - n.Top, n.Top}}
+	dcols := [3][2]int{{0, l}, {l, width - l - r}, {width - r, r}}
+	drows := [3][2]int{{0, b}, {b, height - b - t}, {height - t, t}}
+
+	for j := 0; j < 3; j++ {
+		for i := 0; i < 3; i++ {
+			tile := false
+			switch {
+			case i == 1 && j == 1:
+				tile = n.TileCentre
+			case i == 1 || j == 1:
+				tile = n.TileEdges
+			}
+			fillArea(out, dcols[i][0], drows[j][0], dcols[i][1], drows[j][1],
+				img, scols[i][0], srows[j][0], scols[i][1], srows[j][1], tile)
+		}
+	}
+	return out
+}
+
+// DrawNineSlice draws n filling r on the shadow canvas, blending over the
+// existing contents, and returns the area changed
+func (c *Canvasp) DrawNineSlice(n *NineSlice, r pixel.Rect) pixel.Rect {
+	r = c.ToCanvasRect(r).Norm()
+	w, h := int(math.Round(r.W())), int(math.Round(r.H()))
+	return c.FromCanvasRect(c.paste(n.Render(w, h), r.Min, true))
+}
+
+// TileFill repeats tex across r on the shadow canvas, blending over the
+// existing contents. The tiles are aligned to origin, so neighbouring
+// fills with the same origin line up seamlessly. It returns the area
+// changed.
+func (c *Canvasp) TileFill(tex *Region, r pixel.Rect, origin pixel.Vec) pixel.Rect {
+	x0, y0, x1, y1 := c.pixelRect(c.ToCanvasRect(r))
+	if x0 >= x1 || y0 >= y1 || tex.Width == 0 || tex.Height == 0 {
+		return pixel.Rect{}
+	}
+	origin = c.canvasCorner(origin, float64(tex.Height))
+	ox, oy := int(math.Floor(origin.X)), int(math.Floor(origin.Y))
+	pix := c.pixels()
+	for y := y0; y < y1; y++ {
+		sy := mod(y-oy, tex.Height)
+		src := tex.Pix[sy*tex.Width*4 : (sy+1)*tex.Width*4]
+		dst := pix[(y*c.width)*4 : (y+1)*c.width*4]
+
+		// Whole runs of the tile row at a time
+		for x := x0; x < x1; {
+			sx := mod(x-ox, tex.Width)
+			run := minInt(tex.Width-sx, x1-x)
+			blendOver(dst[x*4:(x+run)*4], src[sx*4:(sx+run)*4])
+			x += run
+		}
+	}
+	c.pixelsChanged(pix)
+	return c.FromCanvasRect(intRect(x0, y0, x1, y1))
+}
+
+// fillArea fills dst's [dx, dx+dw) x [dy, dy+dh) from src's [sx, sx+sw) x
+// [sy, sy+sh), stretching nearest neighbour or tiling, replacing what is
+// there
+func fillArea(dst *Region, dx, dy, dw, dh int, src *Region, sx, sy, sw, sh int, tile bool) {
+	if dw <= 0 || dh <= 0 || sw <= 0 || sh <= 0 {
+		return
+	}
+	for y := 0; y < dh; y++ {
+		var ry int
+		if tile {
+			ry = sy + y%sh
+		} else {
+			ry = sy + y*sh/dh
+		}
+		srow := src.Pix[ry*src.Width*4 : (ry+1)*src.Width*4]
+		drow := dst.Pix[((dy+y)*dst.Width+dx)*4 : ((dy+y)*dst.Width+dx+dw)*4]
+		for x := 0; x < dw; x++ {
+			var rx int
+			if tile {
+				rx = sx + x%sw
+			} else {
+				rx = sx + x*sw/dw
+			}
+			copy(drow[x*4:x*4+4], srow[rx*4:rx*4+4])
+		}
+	}
+}
+
+// mod is a % b, made non-negative
+func mod(a, b int) int {
+	a %= b
+	if a < 0 {
+		a += b
+	}
+	return a
+}
@@ -0,0 +1,173 @@
+// Package noise provides seeded 2D value, Perlin, simplex and Worley noise
+// plus fractal (fBm) layering, for procedural textures and backgrounds with
+// pixelcanvas.NoisePattern.
+package noise
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Func is a 2D noise function, such as a method value like n.Perlin
+type Func func(x, y float64) float64
+
+// Noise generates the noise functions from a seeded permutation table. The
+// same seed always gives the same noise.
+type Noise struct {
+	perm [512]uint8
+}
+
+// New creates a Noise from seed
+func New(seed int64) *Noise {
+	n := &Noise{}
+	p := rand.New(rand.NewSource(seed)).Perm(256)
+	for i := range n.perm {
+		n.perm[i] = uint8(p[i&255])
+	}
+	return n
+}
+
+// hash mixes lattice point i, j into 0-255
+func (n *Noise) hash(i int, j int) uint8 {
+	return n.perm[int(n.perm[i&255])+j&255]
+}
+
+// Value is value noise: random values at integer points, smoothly
+// interpolated. Range -1 to 1. Blocky compared to Perlin, but cheap.
+func (n *Noise) Value(x float64, y float64) float64 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	i, j := int(x0), int(y0)
+	u, v := fade(x-x0), fade(y-y0)
+	a := lerp(n.lattice(i, j), n.lattice(i+1, j), u)
+	b := lerp(n.lattice(i, j+1), n.lattice(i+1, j+1), u)
+	return lerp(a, b, v)
+}
+
+func (n *Noise) lattice(i int, j int) float64 {
+	return float64(n.hash(i, j))/127.5 - 1
+}
+
+// Perlin is Ken Perlin's improved gradient noise. Range about -1 to 1.
+func (n *Noise) Perlin(x float64, y float64) float64 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	i, j := int(x0), int(y0)
+	fx, fy := x-x0, y-y0
+	u, v := fade(fx), fade(fy)
+	a := lerp(grad(n.hash(i, j), fx, fy), grad(n.hash(i+1, j), fx-1, fy), u)
+	b := lerp(grad(n.hash(i, j+1), fx, fy-1), grad(n.hash(i+1, j+1), fx-1, fy-1), u)
+	return a + (b-a)*v
+}
+
+// Simplex is 2D simplex noise: like Perlin but on a triangular grid, with
+// fewer directional artifacts. Range about -1 to 1.
+func (n *Noise) Simplex(x float64, y float64) float64 {
+	const (
+		f2 = 0.36602540378443864676 // (sqrt(3) - 1) / 2
+		g2 = 0.21132486540518711775 // (3 - sqrt(3)) / 6
+	)
+
+	// Skew to find the simplex cell, then unskew back
+	s := (x + y) * f2
+	i, j := math.Floor(x+s), math.Floor(y+s)
+	t := (i + j) * g2
+	x0, y0 := x-(i-t), y-(j-t)
+
+	// Which of the cell's two triangles we are in
+	i1, j1 := 0, 1
+	if x0 > y0 {
+		i1, j1 = 1, 0
+	}
+	x1, y1 := x0-float64(i1)+g2, y0-float64(j1)+g2
+	x2, y2 := x0-1+2*g2, y0-1+2*g2
+
+	ii, jj := int(i), int(j)
+	corner := func(h uint8, x float64, y float64) float64 {
+		t := 0.5 - x*x - y*y
+		if t < 0 {
+			return 0
+		}
+		t *= t
+		return t * t * grad(h, x, y)
+	}
+	sum := corner(n.hash(ii, jj), x0, y0) +
+		corner(n.hash(ii+i1, jj+j1), x1, y1) +
+		corner(n.hash(ii+1, jj+1), x2, y2)
+	return 70 * sum
+}
+
+// Worley is cellular noise: the distance to the nearest of a set of random
+// feature points, one per unit cell. Range 0 to about 1; the cells look like
+// stone, scales or cracked mud.
+func (n *Noise) Worley(x float64, y float64) float64 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	i, j := int(x0), int(y0)
+	best := math.Inf(1)
+	for dj := -1; dj <= 1; dj++ {
+		for di := -1; di <= 1; di++ {
+			h := n.hash(i+di, j+dj)
+			px := float64(i+di) + float64(h)/255
+			py := float64(j+dj) + float64(n.hash(int(h), i+di+j+dj))/255
+			dx, dy := px-x, py-y
+			if d := dx*dx + dy*dy; d < best {
+				best = d
+			}
+		}
+	}
+	return math.Sqrt(best)
+}
+
+// FBM layers octaves of fn (fractal Brownian motion): each octave has
+// lacunarity times the frequency and gain times the amplitude of the one
+// before. The result is normalized back to fn's range. Typical values are
+// lacunarity 2 and gain 0.5.
+func FBM(fn Func, x float64, y float64, octaves int, lacunarity float64, gain float64) float64 {
+	sum, amp, norm := 0.0, 1.0, 0.0
+	for o := 0; o < octaves; o++ {
+		sum += amp * fn(x, y)
+		norm += amp
+		amp *= gain
+		x *= lacunarity
+		y *= lacunarity
+	}
+	if norm == 0 {
+		return 0
+	}
+	return sum / norm
+}
+
+// Fractal returns fn layered with FBM, as a Func
+func Fractal(fn Func, octaves int, lacunarity float64, gain float64) Func {
+	return func(x float64, y float64) float64 {
+		return FBM(fn, x, y, octaves, lacunarity, gain)
+	}
+}
+
+// grad is the dot product of x, y with one of 8 gradient directions
+func grad(h uint8, x float64, y float64) float64 {
+	switch h & 7 {
+	case 0:
+		return x + y
+	case 1:
+		return -x + y
+	case 2:
+		return x - y
+	case 3:
+		return -x - y
+	case 4:
+		return x
+	case 5:
+		return -x
+	case 6:
+		return y
+	}
+	return -y
+}
+
+// fade is Perlin's 6t^5 - 15t^4 + 10t^3 smoothing curve
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(a float64, b float64, t float64) float64 {
+	return a + (b-a)*t
+}
@@ -0,0 +1,105 @@
+package noise
+
+import (
+	"math"
+	"testing"
+)
+
+// funcs picks each noise function off n, with its expected range
+func funcs(n *Noise) []struct {
+	name     string
+	fn       Func
+	min, max float64
+} {
+	return []struct {
+		name     string
+		fn       Func
+		min, max float64
+	}{
+		{"Value", n.Value, -1, 1},
+		{"Perlin", n.Perlin, -1, 1},
+		{"Simplex", n.Simplex, -1, 1},
+		{"Worley", n.Worley, 0, 1.5},
+		{"FBM", Fractal(n.Perlin, 4, 2, 0.5), -1, 1},
+	}
+}
+
+// samples walks a grid crossing the origin, with off-lattice steps
+func samples(f func(x, y float64)) {
+	for y := -20.0; y < 20; y += 0.37 {
+		for x := -20.0; x < 20; x += 0.41 {
+			f(x, y)
+		}
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	a, b := funcs(New(42)), funcs(New(42))
+	for i := range a {
+		samples(func(x, y float64) {
+			if va, vb := a[i].fn(x, y), b[i].fn(x, y); va != vb {
+				t.Fatalf("%s(%v, %v) = %v and %v with the same seed", a[i].name, x, y, va, vb)
+			}
+		})
+	}
+}
+
+func TestSeedsDiffer(t *testing.T) {
+	a, b := funcs(New(1)), funcs(New(2))
+	for i := range a {
+		same, total := 0, 0
+		samples(func(x, y float64) {
+			total++
+			if a[i].fn(x, y) == b[i].fn(x, y) {
+				same++
+			}
+		})
+		if same*20 > total { // With 8 gradients some agreement is chance
+			t.Errorf("%s: %d of %d samples equal across seeds 1 and 2", a[i].name, same, total)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	for _, seed := range []int64{0, 7, -3, 1 << 40} {
+		for _, f := range funcs(New(seed)) {
+			samples(func(x, y float64) {
+				if v := f.fn(x, y); math.IsNaN(v) || v < f.min || v > f.max {
+					t.Fatalf("seed %d: %s(%v, %v) = %v, want %v to %v", seed, f.name, x, y, v, f.min, f.max)
+				}
+			})
+		}
+	}
+}
+
+func TestLattice(t *testing.T) {
+	n := New(5)
+	tests := []struct{ x, y float64 }{{0, 0}, {3, -2}, {-7, 11}, {255, 256}}
+	for _, tt := range tests {
+		if v := n.Perlin(tt.x, tt.y); v != 0 {
+			t.Errorf("Perlin(%v, %v) = %v, want 0 on the lattice", tt.x, tt.y, v)
+		}
+		if v, want := n.Value(tt.x, tt.y), n.lattice(int(tt.x), int(tt.y)); v != want {
+			t.Errorf("Value(%v, %v) = %v, want lattice value %v", tt.x, tt.y, v, want)
+		}
+	}
+}
+
+func TestFBM(t *testing.T) {
+	n := New(9)
+	tests := []struct {
+		octaves int
+		want    func(x, y float64) float64
+	}{
+		{0, func(x, y float64) float64 { return 0 }},
+		{1, n.Perlin},
+		{2, func(x, y float64) float64 { return (n.Perlin(x, y) + 0.5*n.Perlin(2*x, 2*y)) / 1.5 }},
+	}
+	for _, tt := range tests {
+		samples(func(x, y float64) {
+			if got, want := FBM(n.Perlin, x, y, tt.octaves, 2, 0.5), tt.want(x, y); math.Abs(got-want) > 1e-12 {
+				t.Fatalf("FBM(%v, %v, %d octaves) = %v, want %v", x, y, tt.octaves, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package pixelcanvas
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image/png"
+	"syscall/js"
+
+	"github.com/faiface/pixel"
+)
+
+// Notification errors
+var (
+	ErrNoNotifications     = errors.New("pixelcanvas: Notifications not supported")
+	ErrNotificationsDenied = errors.New("pixelcanvas: notification permission not granted")
+)
+
+// NotificationPermission returns "granted", "denied" or "default" (not yet
+// asked), or "" if notifications are unsupported.
+func NotificationPermission() string {
+	n := js.Global().Get("Notification")
+	if n.IsUndefined() {
+		return ""
+	}
+	return n.Get("permission").String()
+}
+
+// RequestNotificationPermission asks the user for permission to show
+// notifications. Browsers only prompt in response to a user gesture, so call
+// it from a click handler; done receives nil once permission is granted.
+func (c *Canvasp) RequestNotificationPermission(done func(err error)) {
+	n := js.Global().Get("Notification")
+	if n.IsUndefined() {
+		done(ErrNoNotifications)
+		return
+	}
+	if n.Get("permission").String() == "granted" {
+		done(nil)
+		return
+	}
+
+	p := n.Call("requestPermission")
+	go func() {
+		state, err := await(p)
+		if err == nil && state.String() != "granted" {
+			err = ErrNotificationsDenied
+		}
+		done(err)
+	}()
+}
+
+// Notify shows a system notification. icon may be empty or any image URL,
+// including one from FaviconDataURL.
+func Notify(title string, body string, icon string) error {
+	n := js.Global().Get("Notification")
+	if n.IsUndefined() {
+		return ErrNoNotifications
+	}
+	if n.Get("permission").String() != "granted" {
+		return ErrNotificationsDenied
+	}
+
+	opts := js.Global().Get("Object").New()
+	opts.Set("body", body)
+	if icon != "" {
+		opts.Set("icon", icon)
+	}
+	n.New(title, opts)
+	return nil
+}
+
+// SetTitle sets the page title, e.g. to show progress or unread counts
+func (c *Canvasp) SetTitle(title string) {
+	c.doc.Set("title", title)
+}
+
+// SetFavicon points the page icon at url, creating the link element if needed
+func (c *Canvasp) SetFavicon(url string) {
+	link := c.doc.Call("querySelector", "link[rel~='icon']")
+	if link.IsNull() {
+		link = c.doc.Call("createElement", "link")
+		link.Set("rel", "icon")
+		c.doc.Get("head").Call("appendChild", link)
+	}
+	link.Set("href", url)
+}
+
+// FaviconDataURL renders area r of the shadow canvas to a size x size PNG
+// data URL (nearest neighbour scaled), suitable for SetFavicon or Notify.
+func (c *Canvasp) FaviconDataURL(r pixel.Rect, size int) (string, error) {
+	img := c.Copy(r).Scale(size, size).Image()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// SetFaviconFromCanvas uses area r of the shadow canvas as the page icon,
+// e.g. a small status indicator drawn by the app. 32px is a good size.
+func (c *Canvasp) SetFaviconFromCanvas(r pixel.Rect, size int) error {
+	url, err := c.FaviconDataURL(r, size)
+	if err != nil {
+		return err
+	}
+	c.SetFavicon(url)
+	return nil
+}
@@ -0,0 +1,250 @@
+package pixelcanvas
+
+import (
+	"errors"
+	"syscall/js"
+
+	"github.com/lwayneh/pixelcanvas/loader"
+)
+
+// Offline and installed apps
+//
+// A service worker lets the app start without a network and is what makes
+// it installable. The worker itself has to be a script file served by the
+// app's origin: pixelcanvas-pack -sw generates one (see
+// loader.ServiceWorker) that precaches the packed files. From Go,
+// RegisterServiceWorker registers it and reports when the app first works
+// offline and when a new version is waiting; Precache adds files to the
+// cache at runtime, e.g. levels as they are unlocked. Install shows the
+// browser's install prompt when it has one to offer.
+
+// Offline errors
+var (
+	ErrNoServiceWorker = errors.New("pixelcanvas: service workers not supported")
+	ErrNotInstallable  = errors.New("pixelcanvas: the browser is not offering to install the app")
+)
+
+// runtimeCache is the cache Precache fills, which loader.ServiceWorker
+// serves from and keeps across versions
+const runtimeCache = "pixelcanvas-runtime"
+
+type offlineState struct {
+	installPrompt js.Value // beforeinstallprompt event kept for Install
+	installable   map[*func()]struct{}
+	watching      bool
+}
+
+// ServiceWorker is a registered service worker
+type ServiceWorker struct {
+	// OnOfflineReady is called when the worker is first installed, so
+	// the app now starts offline
+	OnOfflineReady func()
+
+	// OnUpdate is called when a new version has been installed and is
+	// waiting; Activate switches to it
+	OnUpdate func()
+
+	c          *Canvasp
+	reg        js.Value
+	activating bool
+}
+
+// RegisterServiceWorker registers the service worker script at url,
+// returning once the browser has accepted it. Set the callbacks on the
+// result straight away: installing continues in the background. An update
+// that was already waiting doesn't call OnUpdate; check UpdateWaiting. It
+// blocks, so call it from a goroutine.
+func (c *Canvasp) RegisterServiceWorker(url string) (*ServiceWorker, error) {
+	sw := js.Global().Get("navigator").Get("serviceWorker")
+	if sw.IsUndefined() {
+		return nil, ErrNoServiceWorker
+	}
+	reg, err := await(sw.Call("register", url))
+	if err != nil {
+		return nil, err
+	}
+	w := &ServiceWorker{c: c, reg: reg}
+	c.listen(reg, "updatefound", func(js.Value) {
+		worker := reg.Get("installing")
+		if !worker.Truthy() {
+			return
+		}
+		var l *listener
+		l = c.listen(worker, "statechange", func(js.Value) {
+			if worker.Get("state").String() != "installed" {
+				return
+			}
+			c.unlisten(l)
+			if sw.Get("controller").Truthy() {
+				w.updated()
+			} else {
+				w.offlineReady()
+			}
+		})
+	})
+	c.listen(sw, "controllerchange", func(js.Value) {
+		if w.activating {
+			c.window.Get("location").Call("reload")
+		}
+	})
+	c.log().Info("service worker registered", "url", url, "scope", reg.Get("scope").String())
+	return w, nil
+}
+
+func (w *ServiceWorker) updated() {
+	w.c.log().Info("app update waiting")
+	dispatchLoaderEvent("sw-update", map[string]interface{}{})
+	if w.OnUpdate != nil {
+		w.OnUpdate()
+	}
+}
+
+func (w *ServiceWorker) offlineReady() {
+	w.c.log().Info("app ready offline")
+	dispatchLoaderEvent("sw-offline-ready", map[string]interface{}{})
+	if w.OnOfflineReady != nil {
+		w.OnOfflineReady()
+	}
+}
+
+// UpdateWaiting reports whether a new version is installed and waiting
+func (w *ServiceWorker) UpdateWaiting() bool {
+	return w.reg.Get("waiting").Truthy()
+}
+
+// CheckForUpdate asks the server for a new version of the worker now,
+// rather than at the browser's next check. A new version arrives through
+// OnUpdate once installed. It blocks, so call it from a goroutine.
+func (w *ServiceWorker) CheckForUpdate() error {
+	_, err := await(w.reg.Call("update"))
+	return err
+}
+
+// Activate switches to the waiting version and reloads the page into it.
+// Save anything unsaved first. It does nothing when no update is waiting.
+func (w *ServiceWorker) Activate() {
+	waiting := w.reg.Get("waiting")
+	if !waiting.Truthy() {
+		return
+	}
+	w.activating = true
+	waiting.Call("postMessage", map[string]interface{}{"type": embedPrefix + "skipWaiting"})
+}
+
+// Unregister removes the worker; cached files stay until the browser
+// evicts them. It blocks, so call it from a goroutine.
+func (w *ServiceWorker) Unregister() error {
+	_, err := await(w.reg.Call("unregister"))
+	return err
+}
+
+// Precache fetches urls into the cache the service worker serves from, so
+// they are available offline from then on. It blocks, so call it from a
+// goroutine.
+func (c *Canvasp) Precache(urls ...string) error {
+	caches := js.Global().Get("caches")
+	if caches.IsUndefined() {
+		return ErrNoServiceWorker
+	}
+	cache, err := await(caches.Call("open", runtimeCache))
+	if err != nil {
+		return err
+	}
+	list := make([]interface{}, len(urls))
+	for i, u := range urls {
+		list[i] = u
+	}
+	_, err = await(cache.Call("addAll", list))
+	return err
+}
+
+// PrecacheManifest loads a manifest written by pixelcanvas-pack and
+// precaches every file in it, for apps whose own worker doesn't. The
+// manifest is returned either way, to look up the served names of assets.
+// It blocks, so call it from a goroutine.
+func (c *Canvasp) PrecacheManifest(url string) (*loader.Manifest, error) {
+	data, err := c.FetchBytes(url)
+	if err != nil {
+		return nil, err
+	}
+	m, err := loader.ParseManifest(data)
+	if err != nil {
+		return nil, err
+	}
+	return m, c.Precache(m.Files()...)
+}
+
+// Online reports whether the browser believes it has a network connection
+func (c *Canvasp) Online() bool {
+	return js.Global().Get("navigator").Get("onLine").Bool()
+}
+
+// Installed reports whether the app is running installed, in its own
+// window rather than a browser tab
+func (c *Canvasp) Installed() bool {
+	mq := c.window.Call("matchMedia", "(display-mode: standalone)")
+	return mq.Get("matches").Bool() || js.Global().Get("navigator").Get("standalone").Truthy()
+}
+
+// CanInstall reports whether the browser is offering to install the app,
+// so an install button can be shown
+func (c *Canvasp) CanInstall() bool {
+	c.watchInstall()
+	return c.offline.installPrompt.Truthy()
+}
+
+// OnInstallable calls fn when the browser starts offering to install the
+// app. The returned func stops it.
+func (c *Canvasp) OnInstallable(fn func()) func() {
+	c.watchInstall()
+	if c.offline.installable == nil {
+		c.offline.installable = make(map[*func()]struct{})
+	}
+	key := &fn
+	c.offline.installable[key] = struct{}{}
+	return func() { delete(c.offline.installable, key) }
+}
+
+// Install shows the browser's install prompt, returning whether the user
+// accepted, or ErrNotInstallable when CanInstall is false. The browser only
+// shows it in response to a user gesture, and the call blocks until the
+// user answers, so call it from a goroutine started in a click or key
+// handler. A prompt can only be shown once.
+func (c *Canvasp) Install() (bool, error) {
+	if !c.CanInstall() {
+		return false, ErrNotInstallable
+	}
+	prompt := c.offline.installPrompt
+	c.offline.installPrompt = js.Undefined()
+	if api := js.Global().Get("pixelcanvas"); api.Type() == js.TypeObject {
+		api.Set("installPrompt", js.Undefined())
+	}
+	prompt.Call("prompt")
+	choice, err := await(prompt.Get("userChoice"))
+	if err != nil {
+		return false, err
+	}
+	return choice.Get("outcome").String() == "accepted", nil
+}
+
+// watchInstall keeps the browser's install prompt, taking the one the
+// loader caught before the program started if there is one
+func (c *Canvasp) watchInstall() {
+	if c.offline.watching {
+		return
+	}
+	c.offline.watching = true
+	if api := js.Global().Get("pixelcanvas"); api.Type() == js.TypeObject {
+		c.offline.installPrompt = api.Get("installPrompt")
+	}
+	c.listen(c.window, "beforeinstallprompt", func(e js.Value) {
+		e.Call("preventDefault")
+		c.offline.installPrompt = e
+		for fn := range c.offline.installable {
+			(*fn)()
+		}
+	})
+	c.listen(c.window, "appinstalled", func(js.Value) {
+		c.offline.installPrompt = js.Undefined()
+	})
+}
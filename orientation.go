@@ -0,0 +1,88 @@
+package pixelcanvas
+
+import (
+	"math"
+	"syscall/js"
+)
+
+// Orientation describes the device orientation and the visible viewport
+type Orientation struct {
+	Portrait bool
+	Angle    int // Screen rotation in degrees: 0, 90, 180 or 270
+
+	Width, Height int // Visible viewport in CSS pixels, excluding browser chrome
+	KeyboardInset int // CSS pixels at the bottom currently covered by an on-screen keyboard
+}
+
+// Landscape is the opposite of Portrait
+func (o Orientation) Landscape() bool {
+	return !o.Portrait
+}
+
+// keyboardRatio is how much the visual viewport must shrink, relative to the
+// layout viewport, before the difference is treated as an on-screen keyboard
+// rather than browser chrome.
+const keyboardRatio = 0.75
+
+// Orientation returns the current orientation and visible viewport
+func (c *Canvasp) Orientation() Orientation {
+	var o Orientation
+
+	if so := js.Global().Get("screen").Get("orientation"); !so.IsUndefined() {
+		o.Angle = so.Get("angle").Int()
+	} else if wo := c.window.Get("orientation"); !wo.IsUndefined() {
+		o.Angle = (wo.Int() + 360) % 360 // Older iOS Safari, -90..180
+	}
+
+	layoutH := c.window.Get("innerHeight").Float()
+	o.Width = c.window.Get("innerWidth").Int()
+	o.Height = int(layoutH)
+
+	if vv := c.window.Get("visualViewport"); !vv.IsUndefined() {
+		vw, vh := vv.Get("width").Float(), vv.Get("height").Float()
+		o.Width, o.Height = int(math.Round(vw)), int(math.Round(vh))
+		if vh < layoutH*keyboardRatio {
+			o.KeyboardInset = int(math.Round(layoutH - vh - vv.Get("offsetTop").Float()))
+			o.Height = int(layoutH) // Keep the canvas size while the keyboard is up
+		}
+	}
+	o.Portrait = o.Height >= o.Width
+	return o
+}
+
+// WatchViewport listens for orientation changes and visual viewport resizes
+// (rotation, browser chrome showing or hiding, on-screen keyboards). When
+// fit is set the canvas is resized to the visible viewport, using the
+// current ResizeMode; while a keyboard is open the size is held steady and
+// the inset is reported instead. fn, if not nil, receives every change.
+func (c *Canvasp) WatchViewport(fit bool, fn func(Orientation)) {
+	last := c.Orientation()
+	update := func(js.Value) {
+		o := c.Orientation()
+		if o == last {
+			return
+		}
+		last = o
+		if fit && o.KeyboardInset == 0 {
+			c.Resize(o.Width, o.Height)
+		}
+		if fn != nil {
+			fn(o)
+		}
+	}
+
+	c.listen(c.window, "orientationchange", update)
+	if so := js.Global().Get("screen").Get("orientation"); !so.IsUndefined() {
+		c.listen(so, "change", update)
+	}
+	if vv := c.window.Get("visualViewport"); !vv.IsUndefined() {
+		c.listen(vv, "resize", update)
+		c.listen(vv, "scroll", update) // iOS moves the visual viewport when the keyboard opens
+	} else {
+		c.listen(c.window, "resize", update)
+	}
+
+	if fit {
+		c.Resize(last.Width, last.Height)
+	}
+}
@@ -0,0 +1,250 @@
+package pixelcanvas
+
+import (
+	"encoding/binary"
+	"image/color"
+)
+
+// Overlay is a transparent layer the size of the canvas, composited over the
+// shadow canvas as it is copied to the browser. Nothing drawn on an overlay
+// reaches the shadow canvas itself, so grids, selections, previews and
+// cursors never end up in Image, exports or snapshots.
+//
+// Pix uses the shadow canvas layout: premultiplied RGBA, rows bottom-up.
+// Changing an overlay causes the frame to be copied again even if the
+// RenderFunc reports no change.
+type Overlay struct {
+	Pix           []uint8
+	Width, Height int
+
+	// Redraw, if set, paints the overlay from scratch. It is called lazily
+	// before the next copy after Invalidate, and after the canvas is resized
+	// (which clears the overlay).
+	Redraw func(o *Overlay)
+
+	// Stale, if set, is checked before every copy and invalidates the
+	// overlay when it returns true, e.g. when a camera it follows has moved
+	Stale func() bool
+
+	hidden bool
+	stale  bool // Redraw before the next copy
+	dirty  bool // Changed since the last copy
+}
+
+// AddOverlay creates an overlay above any existing ones
+func (c *Canvasp) AddOverlay(redraw func(o *Overlay)) *Overlay {
+	o := &Overlay{Redraw: redraw}
+	o.resize(c.width, c.height)
+	c.overlays = append(c.overlays, o)
+	return o
+}
+
+// RemoveOverlay removes an overlay from the canvas
+func (c *Canvasp) RemoveOverlay(o *Overlay) {
+	for i, v := range c.overlays {
+		if v == o {
+			c.overlays = append(c.overlays[:i], c.overlays[i+1:]...)
+			c.overlayRemoved = true
+			return
+		}
+	}
+}
+
+// Show shows or hides the overlay
+func (o *Overlay) Show(on bool) {
+	if o.hidden == !on {
+		return
+	}
+	o.hidden = !on
+	o.dirty = true
+}
+
+// Visible reports whether the overlay is shown
+func (o *Overlay) Visible() bool {
+	return !o.hidden
+}
+
+// Invalidate asks for the overlay to be redrawn before the next copy
+func (o *Overlay) Invalidate() {
+	o.stale = true
+	o.dirty = true
+}
+
+// Changed marks the overlay as modified after writing to Pix directly
+func (o *Overlay) Changed() {
+	o.dirty = true
+}
+
+// Clear makes the whole overlay transparent
+func (o *Overlay) Clear() {
+	for i := range o.Pix {
+		o.Pix[i] = 0
+	}
+	o.dirty = true
+}
+
+// ClearRect makes [x0, x1) x [y0, y1), clipped to the overlay, transparent
+func (o *Overlay) ClearRect(x0, y0, x1, y1 int) {
+	x0, x1 = clampInt(x0, 0, o.Width), clampInt(x1, 0, o.Width)
+	y0, y1 = clampInt(y0, 0, o.Height), clampInt(y1, 0, o.Height)
+	if x0 >= x1 {
+		return
+	}
+	for y := y0; y < y1; y++ {
+		row := o.Pix[(y*o.Width+x0)*4 : (y*o.Width+x1)*4]
+		for i := range row {
+			row[i] = 0
+		}
+	}
+	o.dirty = true
+}
+
+// Set sets a pixel, ignoring positions outside the overlay
+func (o *Overlay) Set(x, y int, col color.Color) {
+	if x < 0 || y < 0 || x >= o.Width || y >= o.Height {
+		return
+	}
+	p := rgba8(col)
+	i := (y*o.Width + x) * 4
+	copy(o.Pix[i:i+4], p[:])
+	o.dirty = true
+}
+
+// Blend draws a pixel over the existing overlay contents
+func (o *Overlay) Blend(x, y int, col color.Color) {
+	if x < 0 || y < 0 || x >= o.Width || y >= o.Height {
+		return
+	}
+	p := rgba8(col)
+	i := (y*o.Width + x) * 4
+	blendOver(o.Pix[i:i+4], p[:])
+	o.dirty = true
+}
+
+// FillRect fills [x0, x1) x [y0, y1), clipped to the overlay, blending col
+// over the existing contents
+func (o *Overlay) FillRect(x0, y0, x1, y1 int, col color.Color) {
+	x0, x1 = clampInt(x0, 0, o.Width), clampInt(x1, 0, o.Width)
+	y0, y1 = clampInt(y0, 0, o.Height), clampInt(y1, 0, o.Height)
+	p := rgba8(col)
+	if p[3] == 255 && x0 < x1 {
+		for y := y0; y < y1; y++ {
+			fillPixels(o.Pix[(y*o.Width+x0)*4:(y*o.Width+x1)*4], p)
+		}
+		o.dirty = true
+		return
+	}
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			i := (y*o.Width + x) * 4
+			blendOver(o.Pix[i:i+4], p[:])
+		}
+	}
+	o.dirty = true
+}
+
+// DrawRegion blends reg over the overlay with its bottom left corner at
+// x, y, clipped to the overlay
+func (o *Overlay) DrawRegion(reg *Region, x, y int) {
+	x0, x1 := clampInt(x, 0, o.Width), clampInt(x+reg.Width, 0, o.Width)
+	y0, y1 := clampInt(y, 0, o.Height), clampInt(y+reg.Height, 0, o.Height)
+	if x0 >= x1 {
+		return
+	}
+	for py := y0; py < y1; py++ {
+		src := reg.Pix[((py-y)*reg.Width+(x0-x))*4 : ((py-y)*reg.Width+(x1-x))*4]
+		blendOver(o.Pix[(py*o.Width+x0)*4:(py*o.Width+x1)*4], src)
+	}
+	o.dirty = true
+}
+
+// HLine draws a horizontal line from x0 to x1 (exclusive) at y
+func (o *Overlay) HLine(x0, x1, y int, col color.Color) {
+	o.FillRect(x0, y, x1, y+1, col)
+}
+
+// VLine draws a vertical line from y0 to y1 (exclusive) at x
+func (o *Overlay) VLine(x, y0, y1 int, col color.Color) {
+	o.FillRect(x, y0, x+1, y1, col)
+}
+
+// resize reallocates the overlay, leaving it to be redrawn
+func (o *Overlay) resize(width, height int) {
+	o.Width, o.Height = width, height
+	o.Pix = make([]uint8, width*height*4)
+	o.Invalidate()
+}
+
+// refreshOverlays redraws stale overlays, and reports whether any overlay
+// has changed since the last copy
+func (c *Canvasp) refreshOverlays() bool {
+	changed := c.overlayRemoved
+	c.overlayRemoved = false
+	for _, o := range c.overlays {
+		if o.Stale != nil && !o.hidden && o.Stale() {
+			o.Invalidate()
+		}
+		if o.stale {
+			o.stale = false
+			if o.Redraw != nil {
+				for i := range o.Pix {
+					o.Pix[i] = 0
+				}
+				o.Redraw(o)
+			}
+		}
+		if o.dirty {
+			changed = true
+			o.dirty = false
+		}
+	}
+	return changed
+}
+
+// hasOverlays reports whether any overlay is shown
+func (c *Canvasp) hasOverlays() bool {
+	for _, o := range c.overlays {
+		if !o.hidden {
+			return true
+		}
+	}
+	return false
+}
+
+// blendOverlays composites the visible overlays' row y (shadow canvas rows)
+// over dst, a row already converted to ImageData's straight RGBA
+func (c *Canvasp) blendOverlays(dst []uint8, y int) {
+	for _, o := range c.overlays {
+		if o.hidden || len(o.Pix) != len(dst)*o.Height {
+			continue
+		}
+		blendPremulRow(dst, o.Pix[y*len(dst):(y+1)*len(dst)])
+	}
+}
+
+// blendPremulRow composites row, premultiplied, over dst, a row of the same
+// length already converted to ImageData's straight RGBA
+func blendPremulRow(dst []uint8, row []uint8) {
+	for i := 0; i+3 < len(row); i += 4 {
+		if i&7 == 0 && i+8 <= len(row) && binary.LittleEndian.Uint64(row[i:]) == 0 {
+			i += 4 // Overlays are mostly empty: skip transparent pairs
+			continue
+		}
+		a := row[i+3]
+		switch a {
+		case 0:
+			continue
+		case 255:
+			copy(dst[i:i+4], row[i:i+4])
+			continue
+		}
+		// Premultiplied source over straight destination, giving straight
+		ao := float32(a) / 255
+		ad := float32(dst[i+3]) / 255 * (1 - ao)
+		out := ao + ad
+		dst[i] = uint8((float32(row[i]) + float32(dst[i])*ad) / out)
+		dst[i+1] = uint8((float32(row[i+1]) + float32(dst[i+1])*ad) / out)
+		dst[i+2] = uint8((float32(row[i+2]) + float32(dst[i+2])*ad) / out)
+		dst[i+3] = uint8(out*255 + 0.5)
+	}
+}
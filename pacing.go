@@ -0,0 +1,282 @@
+package pixelcanvas
+
+import (
+	"math"
+	"time"
+)
+
+// CatchUp selects what the render loop does when frames can't keep up with
+// the target FPS
+type CatchUp int
+
+// Catch-up policies
+const (
+	// CatchUpNone renders whenever at least one timestep has passed since the
+	// last render, and measures the next timestep from there. Late frames
+	// push every later frame back, so the cadence drifts. The default.
+	CatchUpNone CatchUp = iota
+
+	// CatchUpSkip keeps frames on a fixed grid of timesteps. A late frame
+	// skips the renders it missed, but Delta covers all of them so
+	// simulation time stays consistent with real time.
+	CatchUpSkip
+
+	// CatchUpClamp renders as CatchUpNone, but caps Delta at the max delta,
+	// so a long stall (a GC pause, a background tab) is seen as one ordinary
+	// slow frame rather than a jump.
+	CatchUpClamp
+
+	// CatchUpHalfRate drops to rendering every second timestep when frames
+	// keep missing their slot, and returns to the full rate once frames fit
+	// comfortably in a timestep again.
+	CatchUpHalfRate
+)
+
+// String implements fmt.Stringer
+func (p CatchUp) String() string {
+	switch p {
+	case CatchUpNone:
+		return "None"
+	case CatchUpSkip:
+		return "Skip"
+	case CatchUpClamp:
+		return "Clamp"
+	case CatchUpHalfRate:
+		return "HalfRate"
+	}
+	return "Unknown"
+}
+
+// DefaultMaxDelta is the largest Delta a frame reports when no max delta is set
+const DefaultMaxDelta = 250 * time.Millisecond
+
+// Half rate switching
+const (
+	halfRateTrigger = 3    // Consecutive late frames before dropping to half rate
+	halfRateRecover = 60   // Consecutive quick frames before returning to full rate
+	halfRateQuick   = 0.75 // A frame is quick if its work fits in this share of a timestep
+)
+
+// frameClock decides which animation frames render and tracks simulation time.
+// All times are in milliseconds, as given by requestAnimationFrame.
+type frameClock struct {
+	policy   CatchUp
+	maxDelta float64 // 0 means DefaultMaxDelta
+
+	last    float64 // Timestamp of the last render (or grid slot). 0 until the first frame
+	delta   float64 // Simulation time covered by the current frame
+	sim     float64 // Total simulation time this run
+	skipped uint64  // Renders skipped by CatchUpSkip
+
+	half    bool // CatchUpHalfRate is currently at half rate
+	strikes int  // Consecutive late frames at full rate
+	quick   int  // Consecutive quick frames at half rate
+
+	vsync   bool    // Render every animation frame, ignoring the timestep
+	freeze  float64 // Simulation time still to withhold, see HitStop
+	held    float64 // Time withheld from the current frame's delta
+	prev    float64 // Timestamp of the previous animation frame, rendered or not
+	refresh float64 // Smoothed interval between animation frames. 0 until measured
+}
+
+// refreshOutlier is how many times the current estimate an interval between
+// animation frames can be before it is treated as missed frames rather
+// than a change in refresh rate
+const refreshOutlier = 2.5
+
+// SetCatchUp sets the catch-up policy, and the largest Delta a single frame
+// may report (0 for DefaultMaxDelta). With CatchUpSkip and CatchUpHalfRate
+// any lag beyond maxDelta is dropped rather than caught up. Takes effect
+// next frame.
+func (c *Canvasp) SetCatchUp(policy CatchUp, maxDelta time.Duration) {
+	c.clock.policy = policy
+	c.clock.maxDelta = float64(maxDelta) / float64(time.Millisecond)
+	if policy != CatchUpHalfRate {
+		c.clock.half = false
+	}
+}
+
+// SetVSync switches to rendering on every animation frame, so the loop runs
+// at the display's refresh rate (60Hz, 120Hz or whatever it is) and the
+// maxFPS given to Start is ignored. This avoids the uneven cadence the FPS
+// limit produces at targets close to the refresh rate. Delta is still
+// measured, and clamped to the max delta set with SetCatchUp.
+func (c *Canvasp) SetVSync(on bool) {
+	c.clock.vsync = on
+}
+
+// VSync reports whether the loop renders on every animation frame
+func (c *Canvasp) VSync() bool {
+	return c.clock.vsync
+}
+
+// RefreshInterval returns the measured interval between animation frames,
+// i.e. the display refresh interval while the browser keeps up. It is
+// measured whether or not VSync is on, and is 0 until two frames have run.
+func (c *Canvasp) RefreshInterval() time.Duration {
+	return msToDuration(c.clock.refresh)
+}
+
+// RefreshRate returns the measured display refresh rate in Hz, or 0 if
+// not yet known
+func (c *Canvasp) RefreshRate() float64 {
+	if c.clock.refresh <= 0 {
+		return 0
+	}
+	return 1000 / c.clock.refresh
+}
+
+// CatchUp returns the current catch-up policy
+func (c *Canvasp) CatchUp() CatchUp {
+	return c.clock.policy
+}
+
+// Delta returns the simulation time the current frame covers. Call it from
+// the RenderFunc to advance animations; it is zero on the first frame.
+func (c *Canvasp) Delta() time.Duration {
+	return msToDuration(c.clock.delta)
+}
+
+// SimTime returns the simulation time accumulated since Start
+func (c *Canvasp) SimTime() time.Duration {
+	return msToDuration(c.clock.sim)
+}
+
+// SkippedFrames returns the number of renders CatchUpSkip has dropped since Start
+func (c *Canvasp) SkippedFrames() uint64 {
+	return c.clock.skipped
+}
+
+// HalfRate reports whether CatchUpHalfRate has dropped to half rate
+func (c *Canvasp) HalfRate() bool {
+	return c.clock.half
+}
+
+// reset starts a new run, keeping the settings and refresh estimate
+func (k *frameClock) reset() {
+	*k = frameClock{policy: k.policy, maxDelta: k.maxDelta, vsync: k.vsync, refresh: k.refresh}
+}
+
+// resync forgets the last timestamp, so time spent paused isn't caught up
+func (k *frameClock) resync() {
+	k.last, k.prev = 0, 0
+}
+
+// measure updates the refresh interval estimate from one animation frame
+func (k *frameClock) measure(now float64) {
+	if k.prev > 0 {
+		interval := now - k.prev
+		switch {
+		case k.refresh == 0:
+			k.refresh = interval
+		case interval > 0 && interval < k.refresh*refreshOutlier:
+			k.refresh += statsSmoothing * (interval - k.refresh)
+		}
+	}
+	k.prev = now
+}
+
+func (k *frameClock) limit() float64 {
+	if k.maxDelta > 0 {
+		return k.maxDelta
+	}
+	return float64(DefaultMaxDelta) / float64(time.Millisecond)
+}
+
+// tick reports whether the frame at timestamp now should render, given the
+// timestep and the work (render plus copy) the last rendered frame took.
+func (k *frameClock) tick(now float64, step float64, work time.Duration) bool {
+	k.measure(now)
+	if k.last == 0 {
+		k.last, k.delta = now, 0
+		return true
+	}
+	elapsed := now - k.last
+
+	if k.vsync {
+		k.last, k.delta = now, math.Min(elapsed, k.limit())
+	} else if !k.paced(now, elapsed, step, work) {
+		return false
+	}
+
+	k.held = 0
+	if k.freeze > 0 {
+		k.held = math.Min(k.freeze, k.delta)
+		k.freeze -= k.held
+		k.delta -= k.held
+	}
+	k.sim += k.delta
+	return true
+}
+
+// paced applies the catch-up policy, reporting whether the frame renders
+func (k *frameClock) paced(now float64, elapsed float64, step float64, work time.Duration) bool {
+	switch k.policy {
+	case CatchUpSkip:
+		if elapsed < step {
+			return false
+		}
+		if elapsed > k.limit() { // Too far behind, drop the backlog
+			k.last, k.delta = now, step
+			break
+		}
+		n := math.Floor(elapsed / step)
+		k.last += n * step
+		k.delta = n * step
+		k.skipped += uint64(n) - 1
+
+	case CatchUpClamp:
+		if elapsed < step {
+			return false
+		}
+		k.last, k.delta = now, math.Min(elapsed, k.limit())
+
+	case CatchUpHalfRate:
+		interval := step
+		if k.half {
+			interval *= 2
+		}
+		if elapsed < interval {
+			return false
+		}
+		k.pace(elapsed, step, work)
+		if elapsed > k.limit() {
+			k.last, k.delta = now, interval
+			break
+		}
+		n := math.Floor(elapsed/interval) * interval
+		k.last += n
+		k.delta = n // The remainder is counted by the next frame
+
+	default:
+		if elapsed < step {
+			return false
+		}
+		k.last, k.delta = now, elapsed
+	}
+	return true
+}
+
+// pace switches CatchUpHalfRate between full and half rate
+func (k *frameClock) pace(elapsed float64, step float64, work time.Duration) {
+	if !k.half {
+		if elapsed >= 2*step {
+			k.strikes++
+		} else {
+			k.strikes = 0
+		}
+		if k.strikes >= halfRateTrigger {
+			k.half, k.strikes, k.quick = true, 0, 0
+		}
+		return
+	}
+
+	if float64(work)/float64(time.Millisecond) < step*halfRateQuick {
+		k.quick++
+	} else {
+		k.quick = 0
+	}
+	if k.quick >= halfRateRecover {
+		k.half, k.strikes, k.quick = false, 0, 0
+	}
+}
@@ -0,0 +1,43 @@
+package pixelcanvas
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestFrameClockSimTime(t *testing.T) {
+	const step = 1000.0 / 60
+	tests := []struct {
+		name     string
+		policy   CatchUp
+		interval float64 // Mean time between animation frames
+		jitter   float64 // Up to this much either way
+	}{
+		{"None", CatchUpNone, step, 3},
+		{"Skip", CatchUpSkip, step, 3},
+		{"Clamp", CatchUpClamp, step, 3},
+		{"HalfRate", CatchUpHalfRate, step, 3},
+		{"HalfRate at half rate", CatchUpHalfRate, 2 * step, 3},
+		{"Skip behind", CatchUpSkip, 2.5 * step, 5},
+	}
+	for _, tt := range tests {
+		k := frameClock{policy: tt.policy}
+		rng := rand.New(rand.NewSource(1))
+		start := 1000.0
+		now, rendered := start, start
+		for i := 0; i < 6000; i++ {
+			if k.tick(now, step, 4*time.Millisecond) {
+				rendered = now
+			}
+			now += tt.interval + (rng.Float64()*2-1)*tt.jitter
+		}
+		// Simulation time trails the last render by less than a frame
+		if real := rendered - start; k.sim > real || real-k.sim > 2*step {
+			t.Errorf("%s: sim %.1fms after %.1fms of real time", tt.name, k.sim, real)
+		}
+		if tt.policy == CatchUpHalfRate && k.half != (tt.interval > step*1.5) {
+			t.Errorf("%s: half rate %v", tt.name, k.half)
+		}
+	}
+}
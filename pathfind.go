@@ -0,0 +1,248 @@
+package pixelcanvas
+
+import (
+	"container/heap"
+	"image"
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Grid is anything A* can search: a tilemap collision layer, a bitmap, or a
+// user-defined board. Cells are addressed with (0,0) at the bottom left, to
+// match pixel's coordinate system.
+type Grid interface {
+	Size() (width int, height int)
+	Walkable(x int, y int) bool
+}
+
+// CostGrid is an optional extension of Grid for terrain with varying movement
+// cost. Cost is multiplied by the step length, so 1 means normal ground.
+type CostGrid interface {
+	Grid
+	Cost(x int, y int) float64
+}
+
+// BoolGrid is a simple Grid backed by a slice of blocked flags
+type BoolGrid struct {
+	W, H    int
+	Blocked []bool // Row-major, W*H entries
+}
+
+// NewBoolGrid creates a fully walkable BoolGrid
+func NewBoolGrid(width int, height int) *BoolGrid {
+	return &BoolGrid{W: width, H: height, Blocked: make([]bool, width*height)}
+}
+
+// Size implements Grid
+func (g *BoolGrid) Size() (int, int) {
+	return g.W, g.H
+}
+
+// Walkable implements Grid
+func (g *BoolGrid) Walkable(x int, y int) bool {
+	return !g.Blocked[y*g.W+x]
+}
+
+// SetBlocked marks a cell as blocked or walkable
+func (g *BoolGrid) SetBlocked(x int, y int, blocked bool) {
+	g.Blocked[y*g.W+x] = blocked
+}
+
+// Heuristic estimates the remaining cost from a cell offset of (dx, dy) cells
+// to the goal. dx and dy are always non-negative.
+type Heuristic func(dx float64, dy float64) float64
+
+// Common heuristics. Use Manhattan for 4-way movement and Octile for 8-way.
+var (
+	Manhattan Heuristic = func(dx, dy float64) float64 { return dx + dy }
+	Euclidean Heuristic = func(dx, dy float64) float64 { return math.Hypot(dx, dy) }
+	Chebyshev Heuristic = func(dx, dy float64) float64 { return math.Max(dx, dy) }
+	Octile    Heuristic = func(dx, dy float64) float64 {
+		return math.Max(dx, dy) + (math.Sqrt2-1)*math.Min(dx, dy)
+	}
+)
+
+// Pathfinder runs A* searches over a Grid and converts the result to world
+// coordinates.
+type Pathfinder struct {
+	Grid      Grid
+	Heuristic Heuristic // Defaults to Manhattan, or Octile when Diagonal is set
+	Diagonal  bool      // Allow 8-way movement
+	CutCorner bool      // Allow diagonal steps past a blocked orthogonal neighbour
+
+	CellSize pixel.Vec // World size of one cell. Defaults to 1x1
+	Origin   pixel.Vec // World position of the bottom left corner of cell (0,0)
+}
+
+// NewPathfinder creates a Pathfinder over grid with cells of cellSize world units
+func NewPathfinder(grid Grid, cellSize float64) *Pathfinder {
+	return &Pathfinder{Grid: grid, CellSize: pixel.V(cellSize, cellSize)}
+}
+
+// CellAt returns the cell containing a world position
+func (p *Pathfinder) CellAt(world pixel.Vec) image.Point {
+	cs := p.cellSize()
+	return image.Pt(
+		int(math.Floor((world.X-p.Origin.X)/cs.X)),
+		int(math.Floor((world.Y-p.Origin.Y)/cs.Y)),
+	)
+}
+
+// CellCenter returns the world position of the centre of a cell
+func (p *Pathfinder) CellCenter(cell image.Point) pixel.Vec {
+	cs := p.cellSize()
+	return pixel.V(
+		p.Origin.X+(float64(cell.X)+0.5)*cs.X,
+		p.Origin.Y+(float64(cell.Y)+0.5)*cs.Y,
+	)
+}
+
+// FindPath searches between two world positions, returning the cell centres
+// to walk through (including the start and goal cells), or nil if the goal is
+// unreachable.
+func (p *Pathfinder) FindPath(from pixel.Vec, to pixel.Vec) []pixel.Vec {
+	cells := p.FindCells(p.CellAt(from), p.CellAt(to))
+	if cells == nil {
+		return nil
+	}
+
+	path := make([]pixel.Vec, len(cells))
+	for i, cell := range cells {
+		path[i] = p.CellCenter(cell)
+	}
+	return path
+}
+
+// FindCells is FindPath working directly in cell coordinates
+func (p *Pathfinder) FindCells(from image.Point, to image.Point) []image.Point {
+	w, h := p.Grid.Size()
+	if !p.open(from, w, h) || !p.open(to, w, h) {
+		return nil
+	}
+
+	heur := p.Heuristic
+	if heur == nil {
+		heur = Manhattan
+		if p.Diagonal {
+			heur = Octile
+		}
+	}
+	costs, _ := p.Grid.(CostGrid)
+
+	index := func(pt image.Point) int { return pt.Y*w + pt.X }
+	estimate := func(pt image.Point) float64 {
+		return heur(math.Abs(float64(to.X-pt.X)), math.Abs(float64(to.Y-pt.Y)))
+	}
+
+	g := make(map[int]float64)
+	parent := make(map[int]image.Point)
+	closed := make(map[int]bool)
+
+	open := &pathHeap{}
+	g[index(from)] = 0
+	heap.Push(open, pathNode{pt: from, f: estimate(from)})
+
+	for open.Len() > 0 {
+		cur := heap.Pop(open).(pathNode)
+		ci := index(cur.pt)
+		if closed[ci] {
+			continue
+		}
+		if cur.pt == to {
+			return p.walkBack(parent, index, from, to)
+		}
+		closed[ci] = true
+
+		for _, d := range p.directions() {
+			next := cur.pt.Add(d)
+			if !p.open(next, w, h) {
+				continue
+			}
+			if d.X != 0 && d.Y != 0 && !p.CutCorner {
+				if !p.open(image.Pt(cur.pt.X+d.X, cur.pt.Y), w, h) || !p.open(image.Pt(cur.pt.X, cur.pt.Y+d.Y), w, h) {
+					continue
+				}
+			}
+
+			ni := index(next)
+			if closed[ni] {
+				continue
+			}
+
+			step := 1.0
+			if d.X != 0 && d.Y != 0 {
+				step = math.Sqrt2
+			}
+			if costs != nil {
+				step *= costs.Cost(next.X, next.Y)
+			}
+
+			ng := g[ci] + step
+			if old, seen := g[ni]; seen && ng >= old {
+				continue
+			}
+			g[ni] = ng
+			parent[ni] = cur.pt
+			heap.Push(open, pathNode{pt: next, f: ng + estimate(next)})
+		}
+	}
+
+	return nil
+}
+
+func (p *Pathfinder) walkBack(parent map[int]image.Point, index func(image.Point) int, from, to image.Point) []image.Point {
+	var cells []image.Point
+	for pt := to; ; pt = parent[index(pt)] {
+		cells = append(cells, pt)
+		if pt == from {
+			break
+		}
+	}
+	for i, j := 0, len(cells)-1; i < j; i, j = i+1, j-1 {
+		cells[i], cells[j] = cells[j], cells[i]
+	}
+	return cells
+}
+
+func (p *Pathfinder) open(pt image.Point, w, h int) bool {
+	return pt.X >= 0 && pt.Y >= 0 && pt.X < w && pt.Y < h && p.Grid.Walkable(pt.X, pt.Y)
+}
+
+var (
+	orthogonalDirs = []image.Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	diagonalDirs   = []image.Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+)
+
+func (p *Pathfinder) directions() []image.Point {
+	if p.Diagonal {
+		return diagonalDirs
+	}
+	return orthogonalDirs
+}
+
+func (p *Pathfinder) cellSize() pixel.Vec {
+	if p.CellSize.X == 0 || p.CellSize.Y == 0 {
+		return pixel.V(1, 1)
+	}
+	return p.CellSize
+}
+
+// pathHeap is the A* open set, ordered by estimated total cost
+type pathNode struct {
+	pt image.Point
+	f  float64
+}
+
+type pathHeap []pathNode
+
+func (h pathHeap) Len() int            { return len(h) }
+func (h pathHeap) Less(i, j int) bool  { return h[i].f < h[j].f }
+func (h pathHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *pathHeap) Push(x interface{}) { *h = append(*h, x.(pathNode)) }
+func (h *pathHeap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
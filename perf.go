@@ -0,0 +1,114 @@
+package pixelcanvas
+
+import (
+	"errors"
+	"strings"
+	"syscall/js"
+	"time"
+)
+
+// PerfEntry is a performance event reported back to Go by ObservePerformance
+type PerfEntry struct {
+	Kind     string        // "longtask", "measure" or "raf"
+	Name     string        // Measure name without the package prefix, e.g. "render"
+	Start    time.Duration // Since the page's time origin
+	Duration time.Duration // For "raf", the delay between the frame time and the callback running
+}
+
+// ErrNoPerformanceObserver is returned when the browser lacks PerformanceObserver
+var ErrNoPerformanceObserver = errors.New("pixelcanvas: PerformanceObserver not supported")
+
+type perfObserver struct {
+	fn       func(PerfEntry)
+	observer js.Value
+	callback js.Func
+}
+
+// ObservePerformance reports real-user performance data to fn: long tasks
+// (main thread blocked for 50ms+), this package's render/copy/present
+// measures (when SetTracing is on), and the latency of each
+// requestAnimationFrame callback. Calling it again replaces fn.
+func (c *Canvasp) ObservePerformance(fn func(PerfEntry)) error {
+	ctor := js.Global().Get("PerformanceObserver")
+	if ctor.IsUndefined() {
+		return ErrNoPerformanceObserver
+	}
+	c.StopObservingPerformance()
+	if c.perf.IsUndefined() {
+		c.perf = js.Global().Get("performance")
+	}
+
+	o := &c.observer
+	o.fn = fn
+	o.callback = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		entries := args[0].Call("getEntries")
+		for i := 0; i < entries.Length(); i++ {
+			c.deliverEntry(entries.Index(i))
+		}
+		return nil
+	})
+	o.observer = ctor.New(o.callback)
+
+	// Entry types must be observed one at a time to use 'buffered', and
+	// unsupported types (longtask outside Chromium) throw, so guard each.
+	supported := ctor.Get("supportedEntryTypes")
+	for _, typ := range []string{"longtask", "measure"} {
+		if !supported.IsUndefined() && !supported.Call("includes", typ).Bool() {
+			continue
+		}
+		opts := js.Global().Get("Object").New()
+		opts.Set("type", typ)
+		opts.Set("buffered", true)
+		o.observer.Call("observe", opts)
+	}
+
+	c.log().Debug("performance observer started")
+	return nil
+}
+
+// StopObservingPerformance disconnects the observer set up by ObservePerformance
+func (c *Canvasp) StopObservingPerformance() {
+	o := &c.observer
+	if o.fn == nil {
+		return
+	}
+	o.observer.Call("disconnect")
+	o.callback.Release()
+	*o = perfObserver{}
+}
+
+func (c *Canvasp) deliverEntry(e js.Value) {
+	kind := e.Get("entryType").String()
+	name := e.Get("name").String()
+	if kind == "measure" {
+		if !strings.HasPrefix(name, tracePrefix) {
+			return // Someone else's measure
+		}
+		name = strings.TrimPrefix(name, tracePrefix)
+	}
+
+	c.observer.fn(PerfEntry{
+		Kind:     kind,
+		Name:     name,
+		Start:    msToDuration(e.Get("startTime").Float()),
+		Duration: msToDuration(e.Get("duration").Float()),
+	})
+}
+
+// reportLatency is called at the top of each rAF callback with the frame timestamp
+func (c *Canvasp) reportLatency(timestamp float64) {
+	if c.observer.fn == nil {
+		return
+	}
+	now := c.perf.Call("now").Float()
+	c.observer.fn(PerfEntry{
+		Kind:     "raf",
+		Name:     "frame",
+		Start:    msToDuration(timestamp),
+		Duration: msToDuration(now - timestamp),
+	})
+}
+
+func msToDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -0,0 +1,327 @@
+package pixelcanvas
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// 2D physics
+//
+// Physics moves axis aligned boxes and circles under gravity and impulses,
+// and separates them when they collide. Bodies don't rotate, which keeps
+// sprites pixel aligned and is what most pixel art games want. A Physics is
+// a System, so adding it to a World steps it in the World's fixed timestep;
+// it can also be stepped by hand with Step. Candidate pairs come from a
+// SpatialHash, so only nearby bodies are tested against each other.
+
+// BodyShape is a Body's collision shape
+type BodyShape int
+
+// Body shapes
+const (
+	ShapeBox    BodyShape = iota // Axis aligned box of HalfSize around Pos
+	ShapeCircle                  // Circle of Radius around Pos
+)
+
+// Body is a rigid body. Pos is its centre in world coordinates.
+type Body struct {
+	Shape    BodyShape
+	Pos      pixel.Vec
+	Vel      pixel.Vec // Pixels per second
+	HalfSize pixel.Vec // For ShapeBox
+	Radius   float64   // For ShapeCircle
+
+	// Mass of zero makes the body static: it collides but never moves
+	Mass        float64
+	Restitution float64 // Bounciness, 0 to 1
+	Friction    float64 // 0 for ice
+	GravityMul  float64 // Scales the world's gravity for this body, e.g. 0 for a floating platform
+
+	Data interface{} // The app's own value, e.g. an Entity
+
+	prev  pixel.Vec // Pos before the last step, for Transform
+	force pixel.Vec
+	id    int
+}
+
+// NewBox creates a dynamic box body
+func NewBox(pos, size pixel.Vec, mass float64) *Body {
+	return &Body{Shape: ShapeBox, Pos: pos, prev: pos, HalfSize: size.Scaled(0.5), Mass: mass, GravityMul: 1, Friction: 0.2}
+}
+
+// NewCircle creates a dynamic circle body
+func NewCircle(pos pixel.Vec, radius, mass float64) *Body {
+	return &Body{Shape: ShapeCircle, Pos: pos, prev: pos, Radius: radius, Mass: mass, GravityMul: 1, Friction: 0.2}
+}
+
+// Static reports whether the body never moves
+func (b *Body) Static() bool {
+	return b.Mass <= 0
+}
+
+func (b *Body) invMass() float64 {
+	if b.Mass <= 0 {
+		return 0
+	}
+	return 1 / b.Mass
+}
+
+// Bounds returns the body's bounding rectangle
+func (b *Body) Bounds() pixel.Rect {
+	h := b.HalfSize
+	if b.Shape == ShapeCircle {
+		h = pixel.V(b.Radius, b.Radius)
+	}
+	return pixel.Rect{Min: b.Pos.Sub(h), Max: b.Pos.Add(h)}
+}
+
+// ApplyImpulse changes the velocity by an instant push, e.g. a jump
+func (b *Body) ApplyImpulse(j pixel.Vec) {
+	b.Vel = b.Vel.Add(j.Scaled(b.invMass()))
+}
+
+// ApplyForce pushes the body during the next step, e.g. thrust
+func (b *Body) ApplyForce(f pixel.Vec) {
+	b.force = b.force.Add(f)
+}
+
+// Transform returns the matrix placing a sprite at the body, blending the
+// last two steps by alpha (see World.Alpha) so motion stays smooth when
+// frames and steps don't line up
+func (b *Body) Transform(alpha float64) pixel.Matrix {
+	return pixel.IM.Moved(pixel.Lerp(b.prev, b.Pos, alpha))
+}
+
+// Contact describes a collision found during a step
+type Contact struct {
+	A, B   *Body
+	Normal pixel.Vec // From A towards B
+	Depth  float64
+}
+
+// Physics steps a set of bodies
+type Physics struct {
+	Gravity pixel.Vec // Pixels per second squared; y is up
+
+	// OnContact, if set, is called for each colliding pair before it is
+	// resolved. Returning false lets the bodies pass through each other,
+	// e.g. for triggers and one way platforms.
+	OnContact func(c Contact) bool
+
+	bodies []*Body
+	hash   *SpatialHash
+	nextID int
+	near   []interface{}
+}
+
+// NewPhysics creates an empty simulation with downward gravity. cellSize
+// is the broadphase cell size, roughly the size of a typical body.
+func NewPhysics(cellSize float64) *Physics {
+	return &Physics{
+		Gravity: pixel.V(0, -600),
+		hash:    NewSpatialHash(cellSize),
+	}
+}
+
+// Add adds bodies to the simulation
+func (p *Physics) Add(bodies ...*Body) {
+	for _, b := range bodies {
+		p.nextID++
+		b.id = p.nextID
+		b.prev = b.Pos
+		p.bodies = append(p.bodies, b)
+		p.hash.Insert(b, b.Bounds())
+	}
+}
+
+// Remove takes a body out of the simulation
+func (p *Physics) Remove(b *Body) {
+	for i, o := range p.bodies {
+		if o == b {
+			p.bodies = append(p.bodies[:i], p.bodies[i+1:]...)
+			p.hash.Remove(b)
+			return
+		}
+	}
+}
+
+// Bodies returns the bodies in the order they were added
+func (p *Physics) Bodies() []*Body {
+	return p.bodies
+}
+
+// Query returns the bodies whose bounds overlap r
+func (p *Physics) Query(r pixel.Rect) []*Body {
+	var out []*Body
+	for _, item := range p.hash.Query(r) {
+		out = append(out, item.(*Body))
+	}
+	return out
+}
+
+// Update implements System, stepping by the World's fixed timestep
+func (p *Physics) Update(w *World, dt float64) {
+	p.Step(dt)
+}
+
+// Step advances the simulation by dt seconds
+func (p *Physics) Step(dt float64) {
+	// Integrate velocities and positions
+	for _, b := range p.bodies {
+		b.prev = b.Pos
+		if b.Static() {
+			b.force = pixel.ZV
+			continue
+		}
+		acc := p.Gravity.Scaled(b.GravityMul).Add(b.force.Scaled(b.invMass()))
+		b.Vel = b.Vel.Add(acc.Scaled(dt))
+		b.Pos = b.Pos.Add(b.Vel.Scaled(dt))
+		b.force = pixel.ZV
+		p.hash.Update(b, b.Bounds())
+	}
+
+	// Find and resolve collisions. Each pair is handled once, from its
+	// moving body with the lower id, or its only moving body.
+	for _, a := range p.bodies {
+		if a.Static() {
+			continue
+		}
+		p.near = p.hash.QueryAppend(p.near[:0], a.Bounds())
+		for _, item := range p.near {
+			b := item.(*Body)
+			if b == a || !b.Static() && b.id < a.id {
+				continue
+			}
+			c, ok := collide(a, b)
+			if !ok {
+				continue
+			}
+			if p.OnContact != nil && !p.OnContact(c) {
+				continue
+			}
+			resolve(c)
+			p.hash.Update(a, a.Bounds())
+			if !b.Static() {
+				p.hash.Update(b, b.Bounds())
+			}
+		}
+	}
+}
+
+// collide tests two bodies for overlap
+func collide(a, b *Body) (Contact, bool) {
+	switch {
+	case a.Shape == ShapeCircle && b.Shape == ShapeCircle:
+		d := b.Pos.Sub(a.Pos)
+		r := a.Radius + b.Radius
+		dist := d.Len()
+		if dist >= r {
+			return Contact{}, false
+		}
+		n := pixel.V(0, 1)
+		if dist > 0 {
+			n = d.Scaled(1 / dist)
+		}
+		return Contact{a, b, n, r - dist}, true
+
+	case a.Shape == ShapeBox && b.Shape == ShapeBox:
+		d := b.Pos.Sub(a.Pos)
+		ox := a.HalfSize.X + b.HalfSize.X - math.Abs(d.X)
+		oy := a.HalfSize.Y + b.HalfSize.Y - math.Abs(d.Y)
+		if ox <= 0 || oy <= 0 {
+			return Contact{}, false
+		}
+		// Push apart along the axis of least overlap
+		if ox < oy {
+			return Contact{a, b, pixel.V(sign(d.X), 0), ox}, true
+		}
+		return Contact{a, b, pixel.V(0, sign(d.Y)), oy}, true
+
+	case a.Shape == ShapeBox:
+		c, ok := collideCircleBox(b, a)
+		c.A, c.B, c.Normal = a, b, c.Normal.Scaled(-1)
+		return c, ok
+	}
+	return collideCircleBox(a, b)
+}
+
+// collideCircleBox tests circle a against box b
+func collideCircleBox(a, b *Body) (Contact, bool) {
+	d := a.Pos.Sub(b.Pos)
+	h := b.HalfSize
+	closest := pixel.V(math.Max(-h.X, math.Min(h.X, d.X)), math.Max(-h.Y, math.Min(h.Y, d.Y)))
+	inside := closest == d
+	if inside {
+		// Centre inside the box: push out through the nearest face
+		if h.X-math.Abs(d.X) < h.Y-math.Abs(d.Y) {
+			closest.X = h.X * sign(d.X)
+		} else {
+			closest.Y = h.Y * sign(d.Y)
+		}
+	}
+	// Normal from the circle towards the box
+	v := closest.Sub(d)
+	dist := v.Len()
+	if !inside && dist >= a.Radius {
+		return Contact{}, false
+	}
+	n := pixel.V(0, -1)
+	if dist > 0 {
+		n = v.Scaled(1 / dist)
+	}
+	if inside {
+		return Contact{a, b, n.Scaled(-1), a.Radius + dist}, true
+	}
+	return Contact{a, b, n, a.Radius - dist}, true
+}
+
+// resolve applies the collision and friction impulses for a contact and
+// pushes the bodies apart
+func resolve(c Contact) {
+	a, b := c.A, c.B
+	ia, ib := a.invMass(), b.invMass()
+	if ia+ib == 0 {
+		return
+	}
+	rv := b.Vel.Sub(a.Vel)
+	vn := rv.Dot(c.Normal)
+	if vn < 0 {
+		e := math.Min(a.Restitution, b.Restitution)
+		j := -(1 + e) * vn / (ia + ib)
+		impulse := c.Normal.Scaled(j)
+		a.Vel = a.Vel.Sub(impulse.Scaled(ia))
+		b.Vel = b.Vel.Add(impulse.Scaled(ib))
+
+		// Coulomb friction along the surface
+		rv = b.Vel.Sub(a.Vel)
+		t := rv.Sub(c.Normal.Scaled(rv.Dot(c.Normal)))
+		if tl := t.Len(); tl > 1e-9 {
+			t = t.Scaled(1 / tl)
+			jt := -rv.Dot(t) / (ia + ib)
+			mu := math.Sqrt(a.Friction * b.Friction)
+			if limit := j * mu; math.Abs(jt) > limit {
+				jt = math.Copysign(limit, jt)
+			}
+			ft := t.Scaled(jt)
+			a.Vel = a.Vel.Sub(ft.Scaled(ia))
+			b.Vel = b.Vel.Add(ft.Scaled(ib))
+		}
+	}
+
+	// Positional correction, leaving a little slop so resting contacts
+	// don't jitter
+	const percent, slop = 0.8, 0.01
+	if depth := c.Depth - slop; depth > 0 {
+		corr := c.Normal.Scaled(depth / (ia + ib) * percent)
+		a.Pos = a.Pos.Sub(corr.Scaled(ia))
+		b.Pos = b.Pos.Add(corr.Scaled(ib))
+	}
+}
+
+func sign(v float64) float64 {
+	if v < 0 {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,76 @@
+package pixelcanvas
+
+import (
+	"errors"
+	"image/color"
+	"syscall/js"
+
+	"github.com/faiface/pixel"
+	"github.com/lwayneh/pixelcanvas/colors"
+)
+
+// Eyedropper errors
+var (
+	ErrNoEyeDropper     = errors.New("pixelcanvas: EyeDropper API not supported")
+	ErrEyeDropperCancel = errors.New("pixelcanvas: colour picking canceled")
+)
+
+// PickColor returns the colour of the shadow canvas pixel at at, in logical
+// coordinates (e.g. from FromClient), as straight alpha, converted from the
+// declared PixelFormat as it is for the browser. ok is false outside the
+// canvas.
+func (c *Canvasp) PickColor(at pixel.Vec) (col color.NRGBA, ok bool) {
+	p := c.canvasPixel(at)
+	if p.x < 0 || p.y < 0 || p.x >= c.width || p.y >= c.height {
+		return color.NRGBA{}, false
+	}
+	var px [4]uint8
+	raw := rgba8(c.image.Color(pixel.V(float64(p.x)+0.5, float64(p.y)+0.5)))
+	convertRow(px[:], raw[:], c.format)
+	return color.NRGBA{R: px[0], G: px[1], B: px[2], A: px[3]}, true
+}
+
+// PickToPalette picks the colour at at, as PickColor, and adds it to
+// palette if it isn't already there, returning its index. Fully transparent
+// pixels aren't added and give -1.
+func (c *Canvasp) PickToPalette(at pixel.Vec, palette *colors.Palette) int {
+	col, ok := c.PickColor(at)
+	if !ok || col.A == 0 {
+		return -1
+	}
+	return palette.Add(col)
+}
+
+// EyeDropper lets the user pick a colour from anywhere on screen, including
+// outside the canvas and the browser window, with the browser's EyeDropper
+// API (Chromium only; see Capabilities). The browser only opens it in
+// response to a user gesture, and the call blocks until the user picks, so
+// call it from a goroutine started in a click or key handler. Pressing
+// Escape gives ErrEyeDropperCancel.
+func (c *Canvasp) EyeDropper() (color.NRGBA, error) {
+	ctor := js.Global().Get("EyeDropper")
+	if ctor.IsUndefined() {
+		return color.NRGBA{}, ErrNoEyeDropper
+	}
+	opts := js.Global().Get("Object").New()
+	opts.Set("signal", c.abortSignal())
+	res, err := await(ctor.New().Call("open", opts))
+	if err != nil {
+		var jerr js.Error
+		if errors.As(err, &jerr) && jerr.Value.Get("name").String() == "AbortError" {
+			return color.NRGBA{}, ErrEyeDropperCancel
+		}
+		return color.NRGBA{}, err
+	}
+	return colors.ParseHex(res.Get("sRGBHex").String())
+}
+
+// EyeDropperToPalette picks a colour with EyeDropper and adds it to palette
+// if it isn't already there, returning its index
+func (c *Canvasp) EyeDropperToPalette(palette *colors.Palette) (int, error) {
+	col, err := c.EyeDropper()
+	if err != nil {
+		return -1, err
+	}
+	return palette.Add(col), nil
+}
@@ -1,15 +1,17 @@
 package pixelcanvas
 
 import (
+	"image/color"
 	"syscall/js"
+	"time"
 
 	"github.com/faiface/pixel"
-	"github.com/faiface/pixel/pixelgl"
 )
 
 // Canvasp is used to store all variables needed share info between js and go
 type Canvasp struct {
-	done chan struct{} // Used as part of 'run forever' in the render handler
+	done  chan struct{} // Closed by Stop to end the current run. Recreated by each Start
+	state State         // Lifecycle state, see State()
 
 	// DOM properties
 	window js.Value
@@ -17,6 +19,8 @@ type Canvasp struct {
 	body   js.Value
 
 	// Canvas properties
+	created bool // The canvas element was made by Create, so Destroy removes it
+	opaque  bool // Ask for a 2D context without alpha, see SetOpaque
 	canvas  js.Value
 	ctx     js.Value
 	imgData js.Value
@@ -24,15 +28,73 @@ type Canvasp struct {
 	height  int
 
 	// Drawing Context
-	image    *pixelgl.Canvas // The Shadow frame we actually draw on
-	reqID    js.Value        // Storage of the current annimationFrame requestID - For Cancel
-	timeStep float64         // Min Time delay between frames. - Calculated as   maxFPS/1000
+	image    *Canvas    // The Shadow frame we actually draw on
+	reqID    js.Value   // Storage of the current annimationFrame requestID - For Cancel
+	raf      js.Func    // The current run's annimationFrame callback, re-requested by Resume
+	rafCall  js.Value   // window.requestAnimationFrame, bound and cached
+	rafStop  js.Value   // window.cancelAnimationFrame, bound and cached
+	timeStep float64    // Min Time delay between frames. - Calculated as   maxFPS/1000
+	clock    frameClock // Decides which frames render and tracks simulation time, see SetCatchUp
+	shared   *Clock     // The shared Clock driving this canvas, if started with StartOn
+
+	clearColor   color.Color // What the clear policy and Clear wipe to. nil is transparent
+	clearPolicy  ClearPolicy // When the shadow canvas is wiped before the RenderFunc
+	clearPending bool        // RequestClear was called, for ClearOnDemand
 
 	copybuff js.Value
+	dataSet  js.Value      // imgData.data.set, bound and cached so the copy does no property lookups
+	putImage js.Value      // ctx.putImageData, bound and cached
+	format   PixelFormat   // Layout of the shadow canvas pixels, converted to ImageData's straight RGBA on copy
+	convbuff []uint8       // Go side scratch buffer for that conversion
+	flipY    bool          // Reverse row order on copy: pixelgl is bottom-up, ImageData top-down
+	coords   coordSystem   // Origin and units of the public API, see SetCoordinates
+	progress progressState // Banded copying for huge canvases, see SetProgressive
+
+	viewports []*Viewport // Secondary views (e.g. minimaps) composited over the frame as it is copied
+
+	textCtx js.Value    // Offscreen 2D context for browser text, see DrawText
+	theme   themeState  // UI theme, see SetTheme
+	locale  localeState // Translations, see SetLanguage
+
+	overlays       []*Overlay // Layers composited during the copy only, see AddOverlay
+	overlayRemoved bool       // An overlay was removed, so the frame needs copying again
+
+	passes []presentPass // Effects applied to each row as it is copied, see composeRow
+
+	watchdog watchdog // Frame timing and jank detection
+
+	// Diagnostics
+	logger  Logger   // Where the package logs to. nil is silent
+	tracing bool     // Emit performance marks/measures each frame
+	perf    js.Value // window.performance, looked up when tracing is enabled
+
+	observer perfObserver // PerformanceObserver bridge, see ObservePerformance
+
+	analytics *AnalyticsTracker // Event batching for the app's backend, see StartAnalytics
+	crash     *CrashReporter    // Panic recovery and reports, see ReportCrashes
+	unload    unloadState       // Page hide and unload handling, see OnUnload
+	offline   offlineState      // Install prompt, see Install
+	announce  announceState     // Screen reader live regions, see Announce
+	partial   partialCopy       // Changed-cells copying, see SetPartialCopy
+	world     *WorldView        // Region-of-interest viewing, see ViewWorld
+	profiler  *Profiler         // Render pass timing, see Profiler
+
+	// Teardown tracking
+	listeners []*listener            // DOM event handlers to remove and release on Destroy
+	abort     js.Value               // AbortController for in-flight fetches
+	idle      map[*idleTask]struct{} // Pending ScheduleIdle callbacks
+	streams   map[*Stream]struct{}   // Open Subscribe and StreamFetch streams
+
+	resizeMode  ResizeMode                            // What Resize does with the existing contents
+	resizeHooks map[*func(width, height int)]struct{} // See OnResize
+
+	sensors sensorState // Latest device orientation/motion readings
+	wake    wakeLock    // Screen wake lock, see KeepAwake
+	system  systemWatch // Battery/network watchers, see WatchSystemInfo
 }
 
 // RenderFunc passes canvas drawing calls to/from go
-type RenderFunc func(gc *pixelgl.Canvas) bool
+type RenderFunc func(gc *Canvas) bool
 
 // NewCanvasp Creates a new Canvasp
 func NewCanvasp(create bool) (*Canvasp, error) {
@@ -40,8 +102,11 @@ func NewCanvasp(create bool) (*Canvasp, error) {
 	var c Canvasp
 
 	c.window = js.Global()
+	c.rafCall = bound(c.window, "requestAnimationFrame")
+	c.rafStop = bound(c.window, "cancelAnimationFrame")
 	c.doc = c.window.Get("document")
 	c.body = c.doc.Get("body")
+	c.flipY = true
 
 	// If create, make a canvas that fills the windows
 	if create {
@@ -61,6 +126,7 @@ func (c *Canvasp) Create(width int, height int) {
 	canvas.Set("height", height)
 	canvas.Set("width", width)
 	c.body.Call("appendChild", canvas)
+	c.created = true
 
 	c.Set(canvas, width, height)
 }
@@ -68,30 +134,113 @@ func (c *Canvasp) Create(width int, height int) {
 // Set is used to setup with an existing Canvas element which was obtained from JS
 func (c *Canvasp) Set(canvas js.Value, width int, height int) {
 	c.canvas = canvas
+
+	// Setup the 2D Drawing context
+	c.ctx = c.canvas.Call("getContext", "2d", c.contextOptions())
+	c.putImage = bound(c.ctx, "putImageData")
+	c.setSize(width, height)
+
+	c.log().Debug("canvas set", "width", width, "height", height)
+}
+
+// setSize (re)creates the size dependent buffers: ImageData, the shadow canvas and the copy buffer
+func (c *Canvasp) setSize(width int, height int) {
 	c.height = height
 	c.width = width
 
-	// Setup the 2D Drawing context
-	c.ctx = c.canvas.Call("getContext", "2d")
 	c.imgData = c.ctx.Call("createImageData", width, height) // Note Width, then Height
-	c.image = pixelgl.NewCanvas(pixel.R(0, 0, float64(width), float64(height)))
-	c.copybuff = js.Global().Get("Uint8Array").New(len(c.image.Pixels())) // Static JS buffer for copying data out to JS. Defined once and re-used to save on un-needed allocations
+	c.image = newCanvas(pixel.R(0, 0, float64(width), float64(height)))
+	c.copybuff = c.window.Get("Uint8Array").New(width * height * 4) // Static JS buffer for copying data out to JS. Defined once and re-used to save on un-needed allocations
+	c.dataSet = bound(c.imgData.Get("data"), "set")
+	c.progress = progressState{opts: c.progress.opts} // A pass in progress was for the old size
+	c.invalidateCopy()
+	for _, o := range c.overlays {
+		o.resize(width, height)
+	}
+}
 
+// bound returns obj[method].bind(obj), so it can be Invoked directly
+// without looking the method up again on every call
+func bound(obj js.Value, method string) js.Value {
+	return obj.Get(method).Call("bind", obj)
 }
 
 // Start starts the annimationFrame callbacks running.
+// Starting a canvas that is already running (or paused) restarts it with
+// the new settings, and a stopped canvas can be started again.
 func (c *Canvasp) Start(maxFPS float64, rf RenderFunc) {
+	if c.state == StateRunning || c.state == StatePaused {
+		c.Stop()
+	}
 	c.SetFPS(maxFPS)
+	c.done = make(chan struct{})
+	c.clock.reset()
+	c.shared = nil
+	c.state = StateRunning
 	c.initFrameUpdate(rf)
+	c.watchUnload()
+	c.log().Info("render loop started", "maxFPS", maxFPS)
 }
 
-// Stop needs to be called on an 'beforeUnload' trigger,
-// to properly close out the render callback, and prevent
-// browser errors on page Refresh
+// Stop ends the render loop. A started canvas stops itself when the page
+// unloads (see OnUnload), so the callback is closed out properly on a
+// refresh. Stopping a canvas that is not running is a no-op.
 func (c *Canvasp) Stop() {
-	c.window.Call("cancelAnimationFrame", c.reqID)
-	c.done <- struct{}{}
+	if c.state != StateRunning && c.state != StatePaused {
+		return
+	}
+	if c.shared == nil { // A shared Clock drops the canvas on its next tick
+		c.rafStop.Invoke(c.reqID)
+	}
+	c.state = StateStopped
 	close(c.done)
+	c.log().Info("render loop stopped")
+}
+
+// Destroy stops the render loop and releases everything the package holds in
+// the browser: event listeners, observers, in-flight fetches and, if it was
+// made by Create, the canvas element itself. The Canvasp must not be used
+// afterwards. Use this when unmounting the canvas in a long-lived page.
+func (c *Canvasp) Destroy() {
+	c.shutdown()
+
+	if c.created && !c.canvas.IsUndefined() {
+		c.canvas.Call("remove")
+	}
+	c.created = false
+	c.viewports = nil
+	c.image = nil
+
+	c.canvas = js.Undefined()
+	c.ctx = js.Undefined()
+	c.imgData = js.Undefined()
+	c.copybuff = js.Undefined()
+	c.dataSet = js.Undefined()
+	c.putImage = js.Undefined()
+	c.reqID = js.Undefined()
+	c.perf = js.Undefined()
+	c.textCtx = js.Undefined()
+	c.log().Info("canvas destroyed")
+}
+
+// shutdown stops the loop and releases listeners, observers and fetches,
+// leaving the canvas and its contents in place. Pending idle work is canceled.
+func (c *Canvasp) shutdown() {
+	c.Stop()
+	c.StopObservingPerformance()
+	if c.analytics != nil {
+		c.analytics.Stop() // Ends the session and sends what is left
+	}
+	c.releaseListeners()
+	c.unload.listeners = nil
+	c.abortFetches()
+	c.closeStreams()
+	c.cancelAllIdle()
+	c.wake.want = false
+	c.wake.visibility = nil
+	c.releaseWakeLock()
+	c.system = systemWatch{}
+	c.removeLiveRegions()
 }
 
 // SetFPS Sets the maximum FPS (Frames per Second).  This can be changed
@@ -112,39 +261,214 @@ func (c *Canvasp) Width() int {
 
 // initFrameUpdate copies the image over to the browser
 func (c *Canvasp) initFrameUpdate(rf RenderFunc) {
-	// Hold the callbacks without blocking
+	done := c.done // This run's channel. A later Start makes a new one
+	var renderFrame js.Func
+
+	renderFrame = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if !c.animationFrame(done, args[0].Float(), rf) {
+			return nil
+		}
+		if c.state == StateRunning { // The RenderFunc may have paused or stopped the loop
+			c.reqID = c.rafCall.Invoke(renderFrame) // Captures the requestID to be used in Close / Cancel
+		}
+		return nil
+	})
+	c.raf = renderFrame
+	c.reqID = c.rafCall.Invoke(renderFrame)
+
+	// Hold the callback without blocking, and release it once this run ends
 	go func() {
-		var renderFrame js.Func
-		var lastTimestamp float64
+		<-done
+		renderFrame.Release()
+	}()
+}
 
-		renderFrame = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+// animationFrame handles one animation frame for the run that owns done,
+// rendering if the clock says so. It reports false, doing nothing, if that
+// run has ended or is paused.
+func (c *Canvasp) animationFrame(done chan struct{}, timestamp float64, rf RenderFunc) bool {
+	defer c.catchPanic("frame")
+	select {
+	case <-done: // A stale frame from a run that has since been stopped
+		return false
+	default:
+	}
+	if c.state != StateRunning {
+		return false
+	}
 
-			timestamp := args[0].Float()
-			if timestamp-lastTimestamp >= c.timeStep { // Constrain FPS
+	c.reportLatency(timestamp)
+	half := c.clock.half
+	if c.clock.tick(timestamp, c.timeStep, c.watchdog.stats.Render+c.watchdog.stats.Copy) { // Constrain FPS
+		c.frame(rf)
+	}
+	if c.clock.half != half {
+		c.log().Info("catch-up rate changed", "halfRate", c.clock.half)
+	}
+	return true
+}
 
-				if rf != nil { // If required, call the requested render function, before copying the frame
-					if rf(c.image) { // Only copy the image back if RenderFunction returns TRUE. (i.e. stuff has changed.)
-						c.imgCopy()
-					}
-				} else { // Just do the copy, rendering must be being done elsewhere
-					c.imgCopy()
-				}
+// frame renders and copies a single frame, timing each stage for the watchdog
+func (c *Canvasp) frame(rf RenderFunc) {
+	mallocs := c.watchdog.mallocs()
+	start := time.Now()
+	c.mark("render-start")
+
+	c.applyClearPolicy()
+	worldMoved := c.presentWorld(rf != nil) // Under whatever the RenderFunc draws
+	changed := true
+	if rf != nil { // If required, call the requested render function, before copying the frame
+		changed = rf(c.image) // Only copy the image back if RenderFunction returns TRUE. (i.e. stuff has changed.)
+	} // Otherwise just do the copy, rendering must be being done elsewhere
+	if worldMoved {
+		changed = true
+	}
 
-				lastTimestamp = timestamp
-			}
+	rendered := time.Now()
+	c.mark("render-end")
+	c.measure("render", "render-start", "render-end")
 
-			c.reqID = js.Global().Call("requestAnimationFrame", renderFrame) // Captures the requestID to be used in Close / Cancel
-			return nil
-		})
-		defer renderFrame.Release()
-		js.Global().Call("requestAnimationFrame", renderFrame)
-		<-c.done
-	}()
+	if c.refreshOverlays() {
+		changed = true
+	}
+	if c.preparePasses() {
+		changed = true
+	}
+
+	if c.progress.opts != nil {
+		if changed {
+			c.renderViewports()
+		}
+		c.progressiveCopy(changed) // Carries on with a pass in progress even if nothing changed
+	} else if changed {
+		c.renderViewports()
+		c.imgCopy()
+	}
+
+	c.watch(rendered.Sub(start), time.Since(rendered), c.watchdog.mallocs()-mallocs)
+}
+
+// convert converts the shadow canvas pixels to ImageData's layout, returning
+// src itself when no conversion is needed
+func (c *Canvasp) convert(src []uint8) []uint8 {
+	if c.format == FormatRGBA && !c.flipY && !c.composing() {
+		return src
+	}
+	if len(c.convbuff) != len(src) {
+		c.convbuff = make([]uint8, len(src))
+	}
+	c.convertTo(c.convbuff, src, true)
+	return c.convbuff
+}
+
+// convertTo converts shadow canvas pixels src to straight RGBA in dst, in
+// the browser's row order, composing the overlays and present passes over
+// each row if compose is set
+func (c *Canvasp) convertTo(dst []uint8, src []uint8, compose bool) {
+	stride := c.width * 4
+	for y := 0; y < c.height; y++ {
+		dy := y
+		if c.flipY {
+			dy = c.height - 1 - y
+		}
+		row := dst[dy*stride : (dy+1)*stride]
+		convertRow(row, src[y*stride:(y+1)*stride], c.format)
+		if compose {
+			c.composeRow(row, y)
+		}
+	}
+}
+
+// presentPass is an effect applied to the frame as it is copied to the
+// browser, like an overlay but free to change the pixels below it (e.g.
+// lighting or a screen transition). Nothing reaches the shadow canvas.
+type presentPass interface {
+	// prepare runs before each frame is copied, reporting whether the
+	// pass has changed so the frame must be copied again
+	prepare(c *Canvasp) bool
+	active() bool
+	overOverlays() bool // Applied after the overlays rather than before
+	row(dst []uint8, y int)
+}
+
+// addPass adds a pass, once
+func (c *Canvasp) addPass(p presentPass) {
+	for _, q := range c.passes {
+		if q == p {
+			return
+		}
+	}
+	c.passes = append(c.passes, p)
+}
+
+// removePass removes a pass, copying the frame again without it
+func (c *Canvasp) removePass(p presentPass) {
+	for i, q := range c.passes {
+		if q == p {
+			c.passes = append(c.passes[:i], c.passes[i+1:]...)
+			c.overlayRemoved = true
+			return
+		}
+	}
+}
+
+// preparePasses readies the passes for a copy, reporting whether any changed.
+// It runs backwards, so a pass may remove itself.
+func (c *Canvasp) preparePasses() bool {
+	changed := false
+	for i := len(c.passes) - 1; i >= 0; i-- {
+		if c.passes[i].prepare(c) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// composing reports whether copied rows need anything beyond conversion
+func (c *Canvasp) composing() bool {
+	if c.hasOverlays() || c.hasViewports() {
+		return true
+	}
+	for _, p := range c.passes {
+		if p.active() {
+			return true
+		}
+	}
+	return false
+}
+
+// composeRow applies the viewports, the passes below the overlays, the
+// overlays, then the passes above them to row y (shadow canvas rows),
+// already converted to ImageData's straight RGBA
+func (c *Canvasp) composeRow(dst []uint8, y int) {
+	c.blendViewports(dst, y)
+	for _, p := range c.passes {
+		if p.active() && !p.overOverlays() {
+			p.row(dst, y)
+		}
+	}
+	c.blendOverlays(dst, y)
+	for _, p := range c.passes {
+		if p.active() && p.overOverlays() {
+			p.row(dst, y)
+		}
+	}
 }
 
 // imgCopy Does the actuall copy over of the image data for the 'render' call.
 func (c *Canvasp) imgCopy() {
-	js.CopyBytesToJS(c.copybuff, c.image.Pixels())
-	c.imgData.Get("data").Call("set", c.copybuff)
-	c.ctx.Call("putImageData", c.imgData, 0, 0)
+	if c.partial.cell > 0 {
+		c.partialImgCopy()
+		return
+	}
+	c.mark("copy-start")
+	js.CopyBytesToJS(c.copybuff, c.convert(c.pixels()))
+	c.dataSet.Invoke(c.copybuff)
+	c.mark("copy-end")
+	c.measure("copy", "copy-start", "copy-end")
+
+	c.mark("present-start")
+	c.putImage.Invoke(c.imgData, 0, 0)
+	c.mark("present-end")
+	c.measure("present", "present-start", "present-end")
 }
@@ -0,0 +1,145 @@
+package pixelcanvas
+
+import (
+	"math"
+	"strconv"
+	"syscall/js"
+)
+
+// DefaultMaxPixelRatio caps how far a PixelRatioWatch raises the canvas
+// resolution, as pinch zoom can reach 5x or more and the canvas area grows
+// with its square
+const DefaultMaxPixelRatio = 3
+
+// PixelRatioWatch follows the effective number of device pixels per CSS
+// pixel: devicePixelRatio, which changes with browser zoom and when the
+// window moves between screens, times the visual viewport scale from pinch
+// zoom. See WatchPixelRatio.
+type PixelRatioWatch struct {
+	// MaxRatio caps the ratio used to re-rasterize. Defaults to
+	// DefaultMaxPixelRatio
+	MaxRatio float64
+
+	// OnChange, if set, is called with the new effective ratio after any
+	// re-rasterizing, e.g. to update Camera sizes or redraw at the new
+	// resolution
+	OnChange func(ratio float64)
+
+	c          *Canvasp
+	reraster   bool
+	cssW, cssH float64 // Display size the canvas keeps while its resolution changes
+	ratio      float64
+
+	query    js.Value // The MediaQueryList matching the current devicePixelRatio
+	media    *listener
+	viewport *listener
+}
+
+// WatchPixelRatio starts following browser zoom, screen changes and pinch
+// zoom. With reraster set the canvas keeps its current display size and its
+// resolution is changed to match the effective ratio, using the current
+// ResizeMode, so output stays sharp instead of being scaled up by the
+// browser; otherwise only OnChange is told and the app decides.
+//
+// Set OnChange on the result before the next change arrives. Re-rasterizing
+// is applied once straight away.
+func (c *Canvasp) WatchPixelRatio(reraster bool) *PixelRatioWatch {
+	p := &PixelRatioWatch{
+		MaxRatio: DefaultMaxPixelRatio,
+		c:        c,
+		reraster: reraster,
+	}
+	p.ratio = p.current()
+
+	if reraster {
+		// The CSS size before any re-rasterizing is the size to hold on to
+		p.cssW = c.canvas.Get("clientWidth").Float()
+		p.cssH = c.canvas.Get("clientHeight").Float()
+		if p.cssW <= 0 || p.cssH <= 0 {
+			p.cssW, p.cssH = float64(c.width), float64(c.height)
+		}
+		c.SetDisplaySize(p.cssW, p.cssH)
+		p.apply()
+	}
+
+	p.watchMedia()
+	if vv := c.window.Get("visualViewport"); !vv.IsUndefined() {
+		p.viewport = c.listen(vv, "resize", func(js.Value) { p.update() })
+	}
+	return p
+}
+
+// Ratio returns the effective device pixels per CSS pixel
+func (p *PixelRatioWatch) Ratio() float64 {
+	return p.ratio
+}
+
+// Stop ends the watch. The canvas keeps its current resolution and display
+// size.
+func (p *PixelRatioWatch) Stop() {
+	if p.media != nil {
+		p.c.unlisten(p.media)
+		p.media = nil
+	}
+	if p.viewport != nil {
+		p.c.unlisten(p.viewport)
+		p.viewport = nil
+	}
+}
+
+// current returns devicePixelRatio times the pinch zoom scale
+func (p *PixelRatioWatch) current() float64 {
+	ratio := jsFloat(p.c.window.Get("devicePixelRatio"), 1)
+	if vv := p.c.window.Get("visualViewport"); !vv.IsUndefined() {
+		ratio *= jsFloat(vv.Get("scale"), 1)
+	}
+	return ratio
+}
+
+// watchMedia listens for devicePixelRatio leaving its current value. A
+// resolution media query only matches one ratio, so it is replaced after
+// every change.
+func (p *PixelRatioWatch) watchMedia() {
+	if p.media != nil {
+		p.c.unlisten(p.media)
+		p.media = nil
+	}
+	if p.c.window.Get("matchMedia").IsUndefined() {
+		return
+	}
+	dpr := jsFloat(p.c.window.Get("devicePixelRatio"), 1)
+	p.query = p.c.window.Call("matchMedia", "(resolution: "+strconv.FormatFloat(dpr, 'f', -1, 64)+"dppx)")
+	p.media = p.c.listen(p.query, "change", func(js.Value) {
+		p.watchMedia()
+		p.update()
+	})
+}
+
+// update handles a possible change of ratio
+func (p *PixelRatioWatch) update() {
+	ratio := p.current()
+	if math.Abs(ratio-p.ratio) < 0.01 {
+		return
+	}
+	p.ratio = ratio
+	p.c.log().Debug("pixel ratio changed", "ratio", ratio)
+	if p.reraster {
+		p.apply()
+	}
+	if p.OnChange != nil {
+		p.OnChange(ratio)
+	}
+}
+
+// apply resizes the canvas to its display size at the effective ratio
+func (p *PixelRatioWatch) apply() {
+	ratio := p.ratio
+	if p.MaxRatio > 0 && ratio > p.MaxRatio {
+		ratio = p.MaxRatio
+	}
+	w := int(math.Round(p.cssW * ratio))
+	h := int(math.Round(p.cssH * ratio))
+	if w > 0 && h > 0 {
+		p.c.Resize(w, h)
+	}
+}
@@ -0,0 +1,65 @@
+package plot
+
+import "image/color"
+
+// Glyph size of the built in label font
+const (
+	glyphW = 3
+	glyphH = 5
+)
+
+// glyphs holds the label font: 5 rows of 3 bits, top row in the high bits
+var glyphs = map[rune]uint16{
+	'0': 0x7B6F, // 111 101 101 101 111
+	'1': 0x2C97, // 010 110 010 010 111
+	'2': 0x73E7, // 111 001 111 100 111
+	'3': 0x73CF, // 111 001 111 001 111
+	'4': 0x5BC9, // 101 101 111 001 001
+	'5': 0x79CF, // 111 100 111 001 111
+	'6': 0x79EF, // 111 100 111 101 111
+	'7': 0x7249, // 111 001 001 001 001
+	'8': 0x7BEF, // 111 101 111 101 111
+	'9': 0x7BCF, // 111 101 111 001 111
+	'.': 0x0002, // 000 000 000 000 010
+	'-': 0x01C0, // 000 000 111 000 000
+	'+': 0x05D0, // 000 010 111 010 000
+	'e': 0x07E3, // 000 011 111 100 011
+	'%': 0x42A1, // 100 001 010 100 001
+	' ': 0x0000,
+}
+
+// TextWidth returns the width in pixels of s in the label font
+func TextWidth(s string) int {
+	n := len([]rune(s))
+	if n == 0 {
+		return 0
+	}
+	return n*(glyphW+1) - 1
+}
+
+// Text draws s with its bottom left corner at x, y in the 3x5 label font,
+// which covers digits and the characters of formatted numbers (". - + e %").
+// Other characters are drawn as a solid box.
+func (b *Buffer) Text(x, y int, s string, col color.Color) {
+	p := premul(col)
+	for _, r := range s {
+		g, ok := glyphs[r]
+		if !ok {
+			g = 0x7FFF // Solid box
+		}
+		for row := 0; row < glyphH; row++ {
+			bits := g >> uint((glyphH-1-row)*glyphW)
+			py := y + glyphH - 1 - row // Rows bottom-up
+			for cx := 0; cx < glyphW; cx++ {
+				if bits&(1<<uint(glyphW-1-cx)) == 0 {
+					continue
+				}
+				px := x + cx
+				if px >= 0 && py >= 0 && px < b.Width && py < b.Height {
+					blend(b.Pix[(py*b.Width+px)*4:], p)
+				}
+			}
+		}
+		x += glyphW + 1
+	}
+}
@@ -0,0 +1,106 @@
+package plot
+
+import (
+	"image/color"
+	"math"
+)
+
+// Colormap maps 0-1 to a colour
+type Colormap func(t float64) color.RGBA
+
+// Stops builds a Colormap interpolating evenly spaced colours
+func Stops(colors ...color.RGBA) Colormap {
+	return func(t float64) color.RGBA {
+		if len(colors) == 0 {
+			return color.RGBA{}
+		}
+		if len(colors) == 1 || t <= 0 || math.IsNaN(t) {
+			return colors[0]
+		}
+		if t >= 1 {
+			return colors[len(colors)-1]
+		}
+		f := t * float64(len(colors)-1)
+		i := int(f)
+		f -= float64(i)
+		a, b := colors[i], colors[i+1]
+		mix := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*f + 0.5) }
+		return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), mix(a.A, b.A)}
+	}
+}
+
+// Standard colormaps
+var (
+	Gray    = Stops(color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255})
+	Heat    = Stops(color.RGBA{0, 0, 0, 255}, color.RGBA{190, 0, 0, 255}, color.RGBA{255, 160, 0, 255}, color.RGBA{255, 255, 210, 255})
+	Viridis = Stops(
+		color.RGBA{68, 1, 84, 255}, color.RGBA{59, 82, 139, 255}, color.RGBA{33, 145, 140, 255},
+		color.RGBA{94, 201, 98, 255}, color.RGBA{253, 231, 37, 255},
+	)
+)
+
+// Heatmap draws a cols x rows grid of values, row 0 at the bottom, scaled
+// to fill area, colouring each cell by where its value falls in [min, max].
+// NaN cells are left untouched, for gaps in the data.
+func (b *Buffer) Heatmap(area Area, data []float64, cols, rows int, min, max float64, cmap Colormap) {
+	if cols <= 0 || rows <= 0 || len(data) < cols*rows {
+		return
+	}
+	a := b.clip(area)
+	w, h := area.Dx(), area.Dy()
+	if w <= 0 || h <= 0 {
+		return
+	}
+	span := max - min
+	if span == 0 {
+		span = 1
+	}
+
+	// Colour each cell once, then fill pixels from the table
+	lut := make([][4]uint8, cols*rows)
+	valid := make([]bool, cols*rows)
+	for i, v := range data[:cols*rows] {
+		if math.IsNaN(v) {
+			continue
+		}
+		lut[i], valid[i] = premul(cmap((v-min)/span)), true
+	}
+	colOf := make([]int, a.Dx())
+	for x := range colOf {
+		colOf[x] = (a.X0 + x - area.X0) * cols / w
+	}
+	for y := a.Y0; y < a.Y1; y++ {
+		r := (y - area.Y0) * rows / h
+		row := b.Pix[(y*b.Width+a.X0)*4:]
+		for x, c := range colOf {
+			if k := r*cols + c; valid[k] {
+				blend(row[x*4:], lut[k])
+			}
+		}
+	}
+}
+
+// Colorbar draws cmap across area, from its 0 end at the bottom (or left,
+// when the area is wider than tall) to its 1 end
+func (b *Buffer) Colorbar(area Area, cmap Colormap) {
+	a := b.clip(area)
+	vertical := area.Dy() >= area.Dx()
+	for y := a.Y0; y < a.Y1; y++ {
+		for x := a.X0; x < a.X1; x++ {
+			var t float64
+			if vertical {
+				t = float64(y-area.Y0) / float64(maxInt(area.Dy()-1, 1))
+			} else {
+				t = float64(x-area.X0) / float64(maxInt(area.Dx()-1, 1))
+			}
+			blend(b.Pix[(y*b.Width+x)*4:], premul(cmap(t)))
+		}
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,210 @@
+package plot
+
+import (
+	"image/color"
+	"math"
+)
+
+// Series draws a line chart of points (xs[i], ys[i]) inside area, clipped
+// to it. NaN values break the line.
+func (b *Buffer) Series(area Area, xs, ys []float64, x, y Axis, col color.Color) {
+	n := len(xs)
+	if len(ys) < n {
+		n = len(ys)
+	}
+	b.polyline(area, n, func(i int) (float64, float64) {
+		return x.Pos(xs[i], area.Dx()-1), y.Pos(ys[i], area.Dy()-1)
+	}, col)
+}
+
+// Values draws ys as a line chart with evenly spaced x, e.g. the samples of
+// a signal, stretched across area
+func (b *Buffer) Values(area Area, ys []float64, y Axis, col color.Color) {
+	step := 0.0
+	if len(ys) > 1 {
+		step = float64(area.Dx()-1) / float64(len(ys)-1)
+	}
+	b.polyline(area, len(ys), func(i int) (float64, float64) {
+		return float64(i) * step, y.Pos(ys[i], area.Dy()-1)
+	}, col)
+}
+
+// polyline joins n points given in pixels relative to area's corner
+func (b *Buffer) polyline(area Area, n int, at func(i int) (float64, float64), col color.Color) {
+	havePrev := false
+	var px, py int
+	for i := 0; i < n; i++ {
+		fx, fy := at(i)
+		if math.IsNaN(fx) || math.IsNaN(fy) || math.IsInf(fx, 0) || math.IsInf(fy, 0) {
+			havePrev = false
+			continue
+		}
+		x := area.X0 + int(math.Round(fx))
+		y := area.Y0 + int(math.Round(fy))
+		if havePrev {
+			b.lineIn(area, px, py, x, y, col)
+		} else if n == 1 {
+			b.lineIn(area, x, y, x, y, col)
+		}
+		px, py, havePrev = x, y, true
+	}
+}
+
+// lineIn draws a line, keeping only the pixels inside area
+func (b *Buffer) lineIn(area Area, x0, y0, x1, y1 int, col color.Color) {
+	a := b.clip(area)
+	p := premul(col)
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		if x0 >= a.X0 && y0 >= a.Y0 && x0 < a.X1 && y0 < a.Y1 {
+			blend(b.Pix[(y0*b.Width+x0)*4:], p)
+		}
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * e; e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 := 2 * e; e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// Trace is a scrolling oscilloscope style trace: samples are pushed as they
+// arrive and Draw shows the most recent ones, newest at the right. When
+// there are more samples per pixel column than one, each column shows the
+// range of its samples as a vertical bar, so spikes are never lost to
+// decimation. NaN samples leave a gap, as do samples at or below zero on a
+// log axis.
+type Trace struct {
+	Y     Axis
+	Color color.Color
+	Fill  color.Color // Drawn under the trace down to the axis minimum. nil for none
+
+	// Auto, when set, rescales Y to the visible samples on every Draw
+	Auto bool
+
+	ring  []float64
+	start int // Index of the oldest sample
+	n     int
+}
+
+// NewTrace keeps up to capacity samples
+func NewTrace(capacity int, y Axis, col color.Color) *Trace {
+	return &Trace{Y: y, Color: col, ring: make([]float64, capacity)}
+}
+
+// Push appends samples, dropping the oldest beyond the capacity
+func (t *Trace) Push(samples ...float64) {
+	c := len(t.ring)
+	if c == 0 {
+		return
+	}
+	for _, v := range samples {
+		if t.n < c {
+			t.ring[(t.start+t.n)%c] = v
+			t.n++
+			continue
+		}
+		t.ring[t.start] = v
+		t.start = (t.start + 1) % c
+	}
+}
+
+// Len returns the number of samples held
+func (t *Trace) Len() int {
+	return t.n
+}
+
+// At returns sample i, 0 being the oldest held
+func (t *Trace) At(i int) float64 {
+	return t.ring[(t.start+i)%len(t.ring)]
+}
+
+// Draw clears area and draws the most recent samples across it, perPixel
+// samples to a pixel column (at least 1)
+func (t *Trace) Draw(b *Buffer, area Area, perPixel int) {
+	b.Clear(area)
+	w, h := area.Dx(), area.Dy()
+	if w <= 0 || h <= 0 || t.n == 0 {
+		return
+	}
+	if perPixel < 1 {
+		perPixel = 1
+	}
+	cols := (t.n + perPixel - 1) / perPixel
+	if cols > w {
+		cols = w
+	}
+	first := t.n - cols*perPixel // May be negative: the oldest column is partial
+
+	y := t.Y
+	skip := func(v float64) bool { return math.IsNaN(v) || y.Log && v <= 0 }
+	if t.Auto {
+		y.Min, y.Max = math.Inf(1), math.Inf(-1)
+		for i := maxInt(first, 0); i < t.n; i++ {
+			v := t.At(i)
+			if !skip(v) {
+				y.Min, y.Max = math.Min(y.Min, v), math.Max(y.Max, v)
+			}
+		}
+		if math.IsInf(y.Min, 0) {
+			return
+		}
+		t.Y = y
+	}
+	// Rows just outside the area are enough to draw off its edge
+	row := func(v float64) int {
+		p := y.Pos(v, h-1)
+		if math.IsNaN(p) {
+			p = -1
+		}
+		return area.Y0 + int(math.Round(math.Max(-1, math.Min(p, float64(h)))))
+	}
+
+	prevLo, prevHi, have := 0, 0, false
+	for c := 0; c < cols; c++ {
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for i := first + c*perPixel; i < first+(c+1)*perPixel; i++ {
+			if i < 0 {
+				continue
+			}
+			if v := t.At(i); !skip(v) {
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+		}
+		if math.IsInf(lo, 0) {
+			have = false
+			continue
+		}
+		x := area.X1 - cols + c
+		ylo, yhi := row(lo), row(hi)
+		if t.Fill != nil {
+			b.lineIn(area, x, area.Y0, x, ylo, t.Fill)
+		}
+		colLo, colHi := ylo, yhi
+
+		// Join to the previous column so steep edges stay connected
+		if have {
+			if ylo > prevHi {
+				ylo = prevHi
+			}
+			if yhi < prevLo {
+				yhi = prevLo
+			}
+		}
+		b.lineIn(area, x, ylo, x, yhi, t.Color)
+		prevLo, prevHi, have = colLo, colHi, true
+	}
+}
@@ -0,0 +1,290 @@
+// Package plot renders heatmaps, line charts and scrolling oscilloscope
+// style traces, with axes, ticks and labels, straight into pixel buffers,
+// for monitoring dashboards that redraw many times a second.
+//
+// Buffers use the pixelcanvas shadow canvas layout (premultiplied RGBA,
+// rows bottom-up), so y grows upwards as on a chart: pass gc.Pixels() or a
+// Region's Pix, draw, and hand the pixels back with SetPixels. Labels use a
+// built in 3x5 pixel font, so no browser text rendering is involved.
+package plot
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+)
+
+// Buffer is a pixel buffer to draw into
+type Buffer struct {
+	Pix           []uint8
+	Width, Height int
+}
+
+// NewBuffer allocates a transparent width x height buffer
+func NewBuffer(width, height int) *Buffer {
+	return &Buffer{Pix: make([]uint8, width*height*4), Width: width, Height: height}
+}
+
+// Area is a rectangle of pixels, [X0, X1) x [Y0, Y1), with Y0 at the bottom
+type Area struct {
+	X0, Y0, X1, Y1 int
+}
+
+// Dx returns the area's width
+func (a Area) Dx() int { return a.X1 - a.X0 }
+
+// Dy returns the area's height
+func (a Area) Dy() int { return a.Y1 - a.Y0 }
+
+// Inset shrinks the area by left, bottom, right and top pixels, e.g. to
+// leave room for axes
+func (a Area) Inset(left, bottom, right, top int) Area {
+	return Area{a.X0 + left, a.Y0 + bottom, a.X1 - right, a.Y1 - top}
+}
+
+// Bounds returns the whole buffer as an Area
+func (b *Buffer) Bounds() Area {
+	return Area{0, 0, b.Width, b.Height}
+}
+
+// Blend draws a pixel over the buffer, ignoring positions outside it
+func (b *Buffer) Blend(x, y int, col color.Color) {
+	if x < 0 || y < 0 || x >= b.Width || y >= b.Height {
+		return
+	}
+	blend(b.Pix[(y*b.Width+x)*4:], premul(col))
+}
+
+// Fill fills an area, blending col over what is there
+func (b *Buffer) Fill(a Area, col color.Color) {
+	a = b.clip(a)
+	p := premul(col)
+	for y := a.Y0; y < a.Y1; y++ {
+		row := b.Pix[(y*b.Width+a.X0)*4 : (y*b.Width+a.X1)*4]
+		for i := 0; i < len(row); i += 4 {
+			blend(row[i:], p)
+		}
+	}
+}
+
+// Clear makes an area transparent
+func (b *Buffer) Clear(a Area) {
+	a = b.clip(a)
+	for y := a.Y0; y < a.Y1; y++ {
+		row := b.Pix[(y*b.Width+a.X0)*4 : (y*b.Width+a.X1)*4]
+		for i := range row {
+			row[i] = 0
+		}
+	}
+}
+
+// Line draws a 1 pixel line between two points, both inclusive
+func (b *Buffer) Line(x0, y0, x1, y1 int, col color.Color) {
+	p := premul(col)
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		if x0 >= 0 && y0 >= 0 && x0 < b.Width && y0 < b.Height {
+			blend(b.Pix[(y0*b.Width+x0)*4:], p)
+		}
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * e; e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 := 2 * e; e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func (b *Buffer) clip(a Area) Area {
+	return Area{clamp(a.X0, 0, b.Width), clamp(a.Y0, 0, b.Height), clamp(a.X1, 0, b.Width), clamp(a.Y1, 0, b.Height)}
+}
+
+// Axis maps a range of data values onto pixels
+type Axis struct {
+	Min, Max float64
+	Log      bool // Logarithmic; Min and Max must be above zero
+}
+
+// Pos returns where v falls along n pixels, from 0 at Min to n at Max.
+// Values outside the range map outside [0, n].
+func (a Axis) Pos(v float64, n int) float64 {
+	lo, hi := a.Min, a.Max
+	if a.Log {
+		v, lo, hi = math.Log10(v), math.Log10(lo), math.Log10(hi)
+	}
+	if hi == lo {
+		return float64(n) / 2
+	}
+	return (v - lo) / (hi - lo) * float64(n)
+}
+
+// maxTicks bounds Ticks, whatever n asks for
+const maxTicks = 1000
+
+// Ticks returns tick values at a "nice" step (1, 2 or 5 times a power of
+// ten) giving about n ticks across the range; powers of ten for a log axis.
+// It returns nil for a range it can't tick: empty, infinite, or not above
+// zero on a log axis.
+func (a Axis) Ticks(n int) []float64 {
+	lo, hi := math.Min(a.Min, a.Max), math.Max(a.Min, a.Max)
+	if a.Log {
+		if !(lo > 0) || math.IsInf(hi, 0) {
+			return nil
+		}
+		var ticks []float64
+		for e := math.Ceil(math.Log10(lo)); e <= math.Log10(hi); e++ {
+			ticks = append(ticks, math.Pow(10, e))
+		}
+		return ticks
+	}
+	if n < 1 || hi == lo || math.IsInf(hi-lo, 0) || math.IsNaN(hi-lo) {
+		return nil
+	}
+	step := NiceStep((hi - lo) / float64(n))
+	start := math.Ceil(lo/step) * step
+	var ticks []float64
+	for i := 0; i < maxTicks; i++ {
+		v := start + float64(i)*step
+		if v > hi+step*1e-9 {
+			break
+		}
+		if i > 0 && v == start+float64(i-1)*step {
+			break // The step is below v's precision
+		}
+		if math.Abs(v) < step*1e-9 {
+			v = 0 // Avoid -0 and 1e-17 labels
+		}
+		ticks = append(ticks, v)
+	}
+	return ticks
+}
+
+// NiceStep rounds a raw step up to 1, 2 or 5 times a power of ten
+func NiceStep(raw float64) float64 {
+	if raw <= 0 {
+		return 1
+	}
+	p := math.Pow(10, math.Floor(math.Log10(raw)))
+	switch f := raw / p; {
+	case f <= 1:
+		return p
+	case f <= 2:
+		return 2 * p
+	case f <= 5:
+		return 5 * p
+	}
+	return 10 * p
+}
+
+// Label formats a tick value compactly
+func Label(v float64) string {
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
+
+// AxesStyle sets how Axes draws
+type AxesStyle struct {
+	Line  color.Color // Axis lines and ticks
+	Grid  color.Color // Grid lines across the plot at each tick. nil for none
+	Text  color.Color
+	Ticks int // About how many ticks per axis. 0 for 5
+}
+
+// DefaultAxesStyle is light grey on a dark dashboard
+var DefaultAxesStyle = AxesStyle{
+	Line:  color.RGBA{160, 160, 160, 255},
+	Grid:  color.RGBA{255, 255, 255, 24},
+	Text:  color.RGBA{200, 200, 200, 255},
+	Ticks: 5,
+}
+
+// AxesMargin is the room Axes needs left of and below a plot area for its
+// ticks and labels, given the longest label in characters
+func AxesMargin(labelChars int) (left, bottom int) {
+	return labelChars*(glyphW+1) + 5, glyphH + 6
+}
+
+// Axes draws x and y axes along the left and bottom edges of plot, with
+// ticks, labels outside the area, and optionally a grid inside it. Leave
+// room for the labels with AxesMargin; either Axis may be nil to skip it.
+func (b *Buffer) Axes(plot Area, x, y *Axis, style AxesStyle) {
+	n := style.Ticks
+	if n <= 0 {
+		n = 5
+	}
+	if x != nil {
+		b.Line(plot.X0, plot.Y0-1, plot.X1-1, plot.Y0-1, style.Line)
+		for _, v := range x.Ticks(n) {
+			px := plot.X0 + int(math.Round(x.Pos(v, plot.Dx()-1)))
+			if style.Grid != nil {
+				b.Line(px, plot.Y0, px, plot.Y1-1, style.Grid)
+			}
+			b.Line(px, plot.Y0-1, px, plot.Y0-3, style.Line)
+			s := Label(v)
+			b.Text(px-TextWidth(s)/2, plot.Y0-4-glyphH, s, style.Text)
+		}
+	}
+	if y != nil {
+		b.Line(plot.X0-1, plot.Y0, plot.X0-1, plot.Y1-1, style.Line)
+		for _, v := range y.Ticks(n) {
+			py := plot.Y0 + int(math.Round(y.Pos(v, plot.Dy()-1)))
+			if style.Grid != nil {
+				b.Line(plot.X0, py, plot.X1-1, py, style.Grid)
+			}
+			b.Line(plot.X0-3, py, plot.X0-1, py, style.Line)
+			s := Label(v)
+			b.Text(plot.X0-4-TextWidth(s), py-glyphH/2, s, style.Text)
+		}
+	}
+}
+
+// premul converts a colour to premultiplied bytes
+func premul(col color.Color) [4]uint8 {
+	r, g, b, a := col.RGBA()
+	return [4]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+}
+
+// blend draws premultiplied src over the pixel at dst
+func blend(dst []uint8, src [4]uint8) {
+	switch src[3] {
+	case 0:
+		return
+	case 255:
+		copy(dst[:4], src[:])
+		return
+	}
+	inv := 255 - uint32(src[3])
+	dst[0] = uint8(uint32(src[0]) + uint32(dst[0])*inv/255)
+	dst[1] = uint8(uint32(src[1]) + uint32(dst[1])*inv/255)
+	dst[2] = uint8(uint32(src[2]) + uint32(dst[2])*inv/255)
+	dst[3] = uint8(uint32(src[3]) + uint32(dst[3])*inv/255)
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,166 @@
+package plot
+
+import (
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNiceStep(t *testing.T) {
+	tests := []struct{ raw, want float64 }{
+		{1, 1},
+		{1.2, 2},
+		{2, 2},
+		{3, 5},
+		{7, 10},
+		{0.03, 0.05},
+		{450, 500},
+		{0, 1},
+		{-5, 1},
+	}
+	for _, tt := range tests {
+		if got := NiceStep(tt.raw); math.Abs(got-tt.want) > tt.want*1e-12 {
+			t.Errorf("NiceStep(%v) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestTicks(t *testing.T) {
+	tests := []struct {
+		name string
+		axis Axis
+		n    int
+		want []float64
+	}{
+		{"simple", Axis{Min: 0, Max: 10}, 5, []float64{0, 2, 4, 6, 8, 10}},
+		{"reversed", Axis{Min: 10, Max: 0}, 5, []float64{0, 2, 4, 6, 8, 10}},
+		{"offset", Axis{Min: 3, Max: 17}, 3, []float64{5, 10, 15}},
+		{"across zero", Axis{Min: -1, Max: 1}, 4, []float64{-1, -0.5, 0, 0.5, 1}},
+		{"log", Axis{Min: 0.5, Max: 2000, Log: true}, 5, []float64{1, 10, 100, 1000}},
+		{"empty", Axis{Min: 4, Max: 4}, 5, nil},
+		{"no ticks asked", Axis{Min: 0, Max: 1}, 0, nil},
+		{"infinite", Axis{Min: 0, Max: math.Inf(1)}, 5, nil},
+		{"NaN", Axis{Min: math.NaN(), Max: 1}, 5, nil},
+		{"log from zero", Axis{Min: 0, Max: 100, Log: true}, 5, nil},
+		{"log negative", Axis{Min: -10, Max: 100, Log: true}, 5, nil},
+		{"log infinite", Axis{Min: 1, Max: math.Inf(1), Log: true}, 5, nil},
+		{"below precision", Axis{Min: 1e17, Max: 1e17 + 20}, 5, []float64{1e17}},
+	}
+	for _, tt := range tests {
+		got := tt.axis.Ticks(tt.n)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: Ticks(%d) = %v, want %v", tt.name, tt.n, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-9*math.Max(1, math.Abs(tt.want[i])) {
+				t.Errorf("%s: Ticks(%d) = %v, want %v", tt.name, tt.n, got, tt.want)
+				break
+			}
+		}
+	}
+	if got := (Axis{Min: 0, Max: 1}).Ticks(1 << 30); len(got) > maxTicks {
+		t.Errorf("Ticks(1<<30) gave %d ticks", len(got))
+	}
+}
+
+func TestPos(t *testing.T) {
+	tests := []struct {
+		axis Axis
+		v    float64
+		n    int
+		want float64
+	}{
+		{Axis{Min: 0, Max: 10}, 0, 100, 0},
+		{Axis{Min: 0, Max: 10}, 10, 100, 100},
+		{Axis{Min: 0, Max: 10}, 2.5, 100, 25},
+		{Axis{Min: 0, Max: 10}, -5, 100, -50},
+		{Axis{Min: 10, Max: 0}, 10, 100, 0}, // Reversed axes run backwards
+		{Axis{Min: 1, Max: 1000, Log: true}, 10, 90, 30},
+		{Axis{Min: 1, Max: 1000, Log: true}, 1000, 90, 90},
+		{Axis{Min: 5, Max: 5}, 123, 40, 20},
+	}
+	for _, tt := range tests {
+		if got := tt.axis.Pos(tt.v, tt.n); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%+v.Pos(%v, %d) = %v, want %v", tt.axis, tt.v, tt.n, got, tt.want)
+		}
+	}
+}
+
+// column returns which rows of column x are drawn, bottom first
+func column(b *Buffer, x int) []bool {
+	rows := make([]bool, b.Height)
+	for y := range rows {
+		rows[y] = b.Pix[(y*b.Width+x)*4+3] != 0
+	}
+	return rows
+}
+
+func TestTraceDraw(t *testing.T) {
+	white := color.RGBA{255, 255, 255, 255}
+	rows := func(set ...int) []bool {
+		r := make([]bool, 8)
+		for _, y := range set {
+			r[y] = true
+		}
+		return r
+	}
+	tests := []struct {
+		name     string
+		y        Axis
+		auto     bool
+		samples  []float64
+		perPixel int
+		want     map[int][]bool // Columns to check
+	}{
+		{
+			name:     "ramp",
+			y:        Axis{Min: 0, Max: 7},
+			samples:  []float64{0, 1, 2, 3},
+			perPixel: 1,
+			want:     map[int][]bool{4: rows(0), 5: rows(0, 1), 6: rows(1, 2), 7: rows(2, 3), 3: rows()},
+		},
+		{
+			name:     "spike survives decimation",
+			y:        Axis{Min: 0, Max: 7},
+			samples:  []float64{0, 0, 7, 0},
+			perPixel: 4,
+			want:     map[int][]bool{7: rows(0, 1, 2, 3, 4, 5, 6, 7), 6: rows()},
+		},
+		{
+			name:     "NaN leaves a gap",
+			y:        Axis{Min: 0, Max: 7},
+			samples:  []float64{3, math.NaN(), 3},
+			perPixel: 1,
+			want:     map[int][]bool{5: rows(3), 6: rows(), 7: rows(3)},
+		},
+		{
+			name:     "out of range is clipped",
+			y:        Axis{Min: 0, Max: 7},
+			samples:  []float64{1e300, -1e300},
+			perPixel: 1,
+			want:     map[int][]bool{6: rows(), 7: rows(0, 1, 2, 3, 4, 5, 6, 7)},
+		},
+		{
+			name:     "auto log skips zero",
+			y:        Axis{Log: true},
+			auto:     true,
+			samples:  []float64{1, 0, 10},
+			perPixel: 1,
+			want:     map[int][]bool{5: rows(0), 6: rows(), 7: rows(7)},
+		},
+	}
+	for _, tt := range tests {
+		b := NewBuffer(8, 8)
+		tr := NewTrace(16, tt.y, white)
+		tr.Auto = tt.auto
+		tr.Push(tt.samples...)
+		tr.Draw(b, b.Bounds(), tt.perPixel)
+		for x, want := range tt.want {
+			if got := column(b, x); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: column %d = %v, want %v", tt.name, x, got, want)
+			}
+		}
+	}
+}
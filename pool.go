@@ -0,0 +1,17 @@
+package pixelcanvas
+
+import "sync"
+
+// Scratch buffers for filters come from these pools
+// rather than being allocated per call, so effects run every frame don't
+// produce garbage. Buffers only grow, and are shared by every canvas.
+
+var scratchPool = sync.Pool{New: func() interface{} { return new(filterScratch) }}
+
+func getScratch() *filterScratch {
+	return scratchPool.Get().(*filterScratch)
+}
+
+func putScratch(s *filterScratch) {
+	scratchPool.Put(s)
+}
@@ -0,0 +1,196 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Pixel exact primitives drawn straight into the shadow canvas buffer, for
+// pixel art where antialiased pixel geometry isn't wanted. Coordinates
+// are logical (see SetCoordinates) and name the pixel they fall in, and
+// colours blend over the existing contents. Each returns the area it
+// changed.
+
+// DrawLine draws a one pixel wide line from a to b, both ends included
+func (c *Canvasp) DrawLine(a, b pixel.Vec, col color.Color) pixel.Rect {
+	return c.FromCanvasRect(c.plotShape(col, func(plot func(x, y int)) {
+		rasterLine(c.canvasPixel(a), c.canvasPixel(b), plot)
+	}))
+}
+
+// DrawRect draws the rectangle with corners a and b, outlined or filled
+func (c *Canvasp) DrawRect(a, b pixel.Vec, col color.Color, fill bool) pixel.Rect {
+	return c.FromCanvasRect(c.plotShape(col, func(plot func(x, y int)) {
+		rasterRect(c.canvasPixel(a), c.canvasPixel(b), fill, plot)
+	}))
+}
+
+// DrawEllipse draws the ellipse fitting the rectangle with corners a and b,
+// outlined or filled
+func (c *Canvasp) DrawEllipse(a, b pixel.Vec, col color.Color, fill bool) pixel.Rect {
+	return c.FromCanvasRect(c.plotShape(col, func(plot func(x, y int)) {
+		rasterEllipse(c.canvasPixel(a), c.canvasPixel(b), fill, plot)
+	}))
+}
+
+// plotShape blends col into every pixel raster plots. The rasterizers plot
+// each pixel once, so translucent colours don't build up where parts of a
+// shape meet.
+func (c *Canvasp) plotShape(col color.Color, raster func(plot func(x, y int))) pixel.Rect {
+	pix := c.pixels()
+	src := rgba8(col)
+	x0, y0, x1, y1 := c.width, c.height, 0, 0
+
+	raster(func(x, y int) {
+		if x < 0 || y < 0 || x >= c.width || y >= c.height {
+			return
+		}
+		n := y*c.width + x
+		blendOver(pix[n*4:n*4+4], src[:])
+		x0, y0 = minInt(x0, x), minInt(y0, y)
+		x1, y1 = maxInt(x1, x+1), maxInt(y1, y+1)
+	})
+
+	if x0 >= x1 {
+		return pixel.Rect{}
+	}
+	c.pixelsChanged(pix)
+	return intRect(x0, y0, x1, y1)
+}
+
+// point is a whole pixel position
+type point struct{ x, y int }
+
+// pixelPoint is the pixel containing v
+func pixelPoint(v pixel.Vec) point {
+	return point{int(math.Floor(v.X)), int(math.Floor(v.Y))}
+}
+
+// rasterLine plots a Bresenham line from a to b inclusive, each pixel once
+func rasterLine(a, b point, plot func(x, y int)) {
+	dx, dy := absInt(b.x-a.x), -absInt(b.y-a.y)
+	sx, sy := 1, 1
+	if a.x > b.x {
+		sx = -1
+	}
+	if a.y > b.y {
+		sy = -1
+	}
+	err := dx + dy
+	x, y := a.x, a.y
+	for {
+		plot(x, y)
+		if x == b.x && y == b.y {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y += sy
+		}
+	}
+}
+
+// rasterRect plots the rectangle with corners a and b inclusive, each pixel
+// once
+func rasterRect(a, b point, fill bool, plot func(x, y int)) {
+	x0, x1 := minInt(a.x, b.x), maxInt(a.x, b.x)
+	y0, y1 := minInt(a.y, b.y), maxInt(a.y, b.y)
+	for y := y0; y <= y1; y++ {
+		if fill || y == y0 || y == y1 {
+			for x := x0; x <= x1; x++ {
+				plot(x, y)
+			}
+			continue
+		}
+		plot(x0, y)
+		if x1 != x0 {
+			plot(x1, y)
+		}
+	}
+}
+
+// rasterEllipse plots the ellipse inscribed in the rectangle with corners a
+// and b inclusive, using Zingl's integer bounding box algorithm. The
+// algorithm reaches some pixels more than once (the middle row, the ends of
+// a narrow row, a row again as it narrows, the last row again at the tips)
+// so those are skipped, leaving each pixel plotted once.
+func rasterEllipse(a, b point, fill bool, plot func(x, y int)) {
+	x0, x1 := minInt(a.x, b.x), maxInt(a.x, b.x)
+	y0, y1 := minInt(a.y, b.y), maxInt(a.y, b.y)
+	w, h := x1-x0, y1-y0
+	if w == 0 || h == 0 { // A line, which the algorithm doesn't reach the ends of
+		rasterLine(point{x0, y0}, point{x1, y1}, plot)
+		return
+	}
+	b1 := h & 1
+
+	dx := 4 * (1 - w) * h * h
+	dy := 4 * (b1 + 1) * w * w
+	err := dx + dy + b1*w*w
+	y0 += (h + 1) / 2
+	y1 = y0 - b1
+
+	span := func(xa, xb, y int) {
+		if fill {
+			for x := xa; x <= xb; x++ {
+				plot(x, y)
+			}
+			return
+		}
+		plot(xa, y)
+		if xb != xa {
+			plot(xb, y)
+		}
+	}
+
+	// A row is widest when first reached, so filled rows are spanned then
+	// only
+	last := y0 - 1 // Row spanned last
+	for x0 <= x1 {
+		if !fill || y0 != last {
+			span(x0, x1, y0)
+			if y1 != y0 {
+				span(x0, x1, y1)
+			}
+			last = y0
+		}
+		e2 := 2 * err
+		if e2 <= dy {
+			y0++
+			y1--
+			dy += 8 * w * w
+			err += dy
+		}
+		if e2 >= dx || 2*err > dy {
+			x0++
+			x1--
+			dx += 8 * h * h
+			err += dx
+		}
+	}
+	for y0-y1 < h { // Flat ellipses stop early, finish their tips
+		tip := y0 > last // Else the loop above ended on this row, with these ends
+		if tip {
+			span(x0-1, x1+1, y0)
+		}
+		y0++
+		if tip {
+			span(x0-1, x1+1, y1)
+		}
+		y1--
+	}
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,169 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/faiface/pixel"
+	"github.com/lwayneh/pixelcanvas/noise"
+)
+
+// Pattern gives the colour of pixel x, y (shadow canvas pixels, bottom left
+// origin) of a procedural fill. color.RGBA is premultiplied like the shadow canvas, and a
+// value rather than an interface, so filling doesn't allocate per pixel.
+type Pattern func(x, y int) color.RGBA
+
+// FillPattern fills area r of the shadow canvas with p, replacing the
+// existing contents, and returns the area changed
+func (c *Canvasp) FillPattern(r pixel.Rect, p Pattern) pixel.Rect {
+	x0, y0, x1, y1 := c.pixelRect(c.ToCanvasRect(r))
+	if x0 >= x1 || y0 >= y1 {
+		return pixel.Rect{}
+	}
+	pix := c.pixels()
+	fillPattern(pix, c.width, x0, y0, x1, y1, p)
+	c.pixelsChanged(pix)
+	return c.FromCanvasRect(intRect(x0, y0, x1, y1))
+}
+
+// FillCanvas fills a whole Canvas with p, e.g. a RenderTarget (in
+// its Cache function, to build a background once) or a Layer in Edit
+func FillCanvas(gc *Canvas, p Pattern) {
+	b := gc.Bounds()
+	w, h := int(b.W()), int(b.H())
+	pix := make([]uint8, w*h*4)
+	fillPattern(pix, w, 0, 0, w, h, p)
+	gc.SetPixels(pix)
+}
+
+// PatternRegion renders p into a new width x height Region
+func PatternRegion(width int, height int, p Pattern) *Region {
+	reg := NewRegion(width, height)
+	fillPattern(reg.Pix, width, 0, 0, width, height, p)
+	return reg
+}
+
+func fillPattern(pix []uint8, stride int, x0, y0, x1, y1 int, p Pattern) {
+	for y := y0; y < y1; y++ {
+		i := (y*stride + x0) * 4
+		for x := x0; x < x1; x++ {
+			col := p(x, y)
+			pix[i], pix[i+1], pix[i+2], pix[i+3] = col.R, col.G, col.B, col.A
+			i += 4
+		}
+	}
+}
+
+// Checkerboard alternates a and b in size x size squares
+func Checkerboard(size int, a color.Color, b color.Color) Pattern {
+	ca, cb := rgbaColor(a), rgbaColor(b)
+	if size < 1 {
+		size = 1
+	}
+	return func(x, y int) color.RGBA {
+		if (floorDiv(x, size)+floorDiv(y, size))&1 == 0 {
+			return ca
+		}
+		return cb
+	}
+}
+
+// GradientStop is a colour at a position 0-1 along a gradient
+type GradientStop struct {
+	Pos   float64
+	Color color.Color
+}
+
+// Gradient maps 0-1 to colours, interpolating between stops. Positions
+// outside the stops take the nearest end colour.
+type Gradient []GradientStop
+
+// At returns the gradient's colour at t
+func (g Gradient) At(t float64) color.RGBA {
+	return g.ramp()(t)
+}
+
+// ramp sorts the stops and converts their colours once, returning a lookup
+func (g Gradient) ramp() func(t float64) color.RGBA {
+	stops := append(Gradient(nil), g...)
+	sort.SliceStable(stops, func(i, j int) bool { return stops[i].Pos < stops[j].Pos })
+	cols := make([]color.RGBA, len(stops))
+	for i, s := range stops {
+		cols[i] = rgbaColor(s.Color)
+	}
+	return func(t float64) color.RGBA {
+		if len(stops) == 0 {
+			return color.RGBA{}
+		}
+		if t <= stops[0].Pos {
+			return cols[0]
+		}
+		for i := 1; i < len(stops); i++ {
+			if t <= stops[i].Pos {
+				span := stops[i].Pos - stops[i-1].Pos
+				if span <= 0 {
+					return cols[i]
+				}
+				return mixRGBA(cols[i-1], cols[i], (t-stops[i-1].Pos)/span)
+			}
+		}
+		return cols[len(cols)-1]
+	}
+}
+
+// LinearGradient shades along the line from a to b: a is position 0 and b
+// position 1, constant across the line
+func LinearGradient(a pixel.Vec, b pixel.Vec, g Gradient) Pattern {
+	ramp := g.ramp()
+	d := b.Sub(a)
+	l2 := d.Dot(d)
+	return func(x, y int) color.RGBA {
+		if l2 == 0 {
+			return ramp(0)
+		}
+		p := pixel.V(float64(x)+0.5, float64(y)+0.5).Sub(a)
+		return ramp(p.Dot(d) / l2)
+	}
+}
+
+// RadialGradient shades outwards from centre, position 1 at radius
+func RadialGradient(centre pixel.Vec, radius float64, g Gradient) Pattern {
+	ramp := g.ramp()
+	return func(x, y int) color.RGBA {
+		if radius <= 0 {
+			return ramp(1)
+		}
+		return ramp(pixel.V(float64(x)+0.5, float64(y)+0.5).Sub(centre).Len() / radius)
+	}
+}
+
+// NoisePattern shades each pixel by fn sampled at its position divided by
+// scale (the feature size in pixels), mapping fn's -1 to 1 range onto g.
+// Worley noise, which ranges 0-1, reads the upper half of g; wrap it to
+// use the whole gradient.
+func NoisePattern(fn noise.Func, scale float64, g Gradient) Pattern {
+	ramp := g.ramp()
+	if scale <= 0 {
+		scale = 1
+	}
+	return func(x, y int) color.RGBA {
+		v := fn((float64(x)+0.5)/scale, (float64(y)+0.5)/scale)
+		return ramp(v*0.5 + 0.5)
+	}
+}
+
+// rgbaColor converts a colour to 8 bit premultiplied, nil as transparent
+func rgbaColor(col color.Color) color.RGBA {
+	if col == nil {
+		return color.RGBA{}
+	}
+	return color.RGBAModel.Convert(col).(color.RGBA)
+}
+
+func mixRGBA(a color.RGBA, b color.RGBA, t float64) color.RGBA {
+	mix := func(p, q uint8) uint8 {
+		return uint8(math.Round(float64(p) + (float64(q)-float64(p))*t))
+	}
+	return color.RGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: mix(a.A, b.A)}
+}
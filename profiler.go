@@ -0,0 +1,292 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Render pass profiling
+//
+// FrameStats splits a frame into the RenderFunc and the copy. A Profiler
+// splits the RenderFunc further, into passes the app names by wrapping
+// them in Begin and End:
+//
+//	prof := c.Profiler()
+//	prof.Begin("tiles")
+//	drawTiles(gc)
+//	prof.End()
+//
+// Passes can nest, and are kept by their path ("world/tiles"), so the same
+// name under different parents counts separately. Timings are summed per
+// frame and smoothed across frames, and are included in Stats. With
+// tracing on (SetTracing) each pass is also a performance measure, so it
+// shows in the browser's profiler; ShowBreakdown draws a flame-style bar on
+// the canvas itself, for devices without developer tools.
+
+// profilerRefresh is how often the breakdown bar is redrawn, slow enough to
+// read the numbers
+const profilerRefresh = 250 * time.Millisecond
+
+// PassStats are one profiled pass's timings
+type PassStats struct {
+	Name  string // Path of the pass, its parents' names first, e.g. "world/tiles"
+	Depth int    // Number of parents
+
+	Calls int           // Times begun in the last frame
+	Last  time.Duration // Time spent in the last frame
+	Avg   time.Duration // Smoothed time per frame
+	Self  time.Duration // Avg less the nested passes' Avg
+	Max   time.Duration // Longest frame since the stats were reset
+}
+
+// Profiler times named passes of the RenderFunc, see Canvasp.Profiler
+type Profiler struct {
+	c      *Canvasp
+	roots  map[string]*profPass // Passes begun outside any other, by name
+	order  []*profPass          // First begun first, for a steady layout
+	stack  []*profPass          // Passes begun and not yet ended
+	frames uint64               // Frames aggregated
+
+	bar   *Overlay
+	drawn time.Time // When the bar was last drawn
+}
+
+type profPass struct {
+	PassStats
+	parent   *profPass
+	children map[string]*profPass // By name
+	index    int                  // Position in first-begun order
+	start    time.Time
+	frame    time.Duration // Summed over this frame so far
+	calls    int
+	label    *Region // Name rendered for the breakdown
+}
+
+// Profiler returns the canvas's profiler, creating it on first use
+func (c *Canvasp) Profiler() *Profiler {
+	if c.profiler == nil {
+		c.profiler = &Profiler{c: c, roots: make(map[string]*profPass)}
+	}
+	return c.profiler
+}
+
+// Begin starts timing a pass, nested inside the pass begun before it if that
+// hasn't ended
+func (p *Profiler) Begin(name string) {
+	var parent *profPass
+	siblings := p.roots
+	if len(p.stack) > 0 {
+		parent = p.stack[len(p.stack)-1]
+		if parent.children == nil {
+			parent.children = make(map[string]*profPass)
+		}
+		siblings = parent.children
+	}
+	pass := siblings[name]
+	if pass == nil {
+		path := name
+		if parent != nil {
+			path = parent.Name + "/" + name
+		}
+		pass = &profPass{PassStats: PassStats{Name: path, Depth: len(p.stack)}, parent: parent, index: len(p.order)}
+		siblings[name] = pass
+		p.order = append(p.order, pass)
+	}
+	p.stack = append(p.stack, pass)
+	if p.c.tracing {
+		p.c.mark("pass-" + pass.Name + "-start")
+	}
+	pass.start = time.Now()
+}
+
+// End stops timing the pass begun last. Unmatched Ends are ignored.
+func (p *Profiler) End() {
+	if len(p.stack) == 0 {
+		return
+	}
+	pass := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+	pass.frame += time.Since(pass.start)
+	pass.calls++
+	if p.c.tracing { // Only build the names when they are used
+		p.c.mark("pass-" + pass.Name + "-end")
+		p.c.measure("pass:"+pass.Name, "pass-"+pass.Name+"-start", "pass-"+pass.Name+"-end")
+	}
+}
+
+// Time runs fn as a pass
+func (p *Profiler) Time(name string, fn func()) {
+	p.Begin(name)
+	defer p.End()
+	fn()
+}
+
+// Passes returns every pass seen since the last Reset, parents before
+// their nested passes
+func (p *Profiler) Passes() []PassStats {
+	tree := p.tree()
+	out := make([]PassStats, len(tree))
+	for i, pass := range tree {
+		out[i] = pass.PassStats
+	}
+	return out
+}
+
+// tree returns the passes depth first, each parent's in first-begun order
+func (p *Profiler) tree() []*profPass {
+	out := make([]*profPass, 0, len(p.order))
+	var add func(parent *profPass)
+	add = func(parent *profPass) {
+		for _, pass := range p.order {
+			if pass.parent == parent {
+				out = append(out, pass)
+				add(pass)
+			}
+		}
+	}
+	add(nil)
+	return out
+}
+
+// Reset forgets every pass and its timings
+func (p *Profiler) Reset() {
+	p.roots = make(map[string]*profPass)
+	p.order, p.stack = nil, nil
+	p.frames = 0
+}
+
+// endFrame folds the frame's timings into the stats, ending any pass left
+// open
+func (p *Profiler) endFrame() {
+	if n := len(p.stack); n > 0 {
+		p.c.log().Warn("profiler pass not ended", "pass", p.stack[n-1].Name)
+		for len(p.stack) > 0 {
+			p.End()
+		}
+	}
+	p.frames++
+	for _, pass := range p.order {
+		s := &pass.PassStats
+		s.Calls, s.Last = pass.calls, pass.frame
+		if p.frames == 1 {
+			s.Avg = s.Last
+		} else {
+			s.Avg += time.Duration(statsSmoothing * float64(s.Last-s.Avg))
+		}
+		if s.Last > s.Max {
+			s.Max = s.Last
+		}
+		pass.calls, pass.frame = 0, 0
+	}
+	for _, pass := range p.order {
+		pass.Self = pass.Avg
+	}
+	for _, pass := range p.order {
+		if pass.parent != nil {
+			pass.parent.Self -= pass.Avg
+		}
+	}
+}
+
+// Breakdown bar
+
+// profColors tell passes apart in the breakdown, by order first begun
+var profColors = []color.RGBA{
+	{0xe6, 0x55, 0x3a, 0xff}, {0xf2, 0xa5, 0x3a, 0xff}, {0xe8, 0xd4, 0x4d, 0xff},
+	{0x7c, 0xc6, 0x4f, 0xff}, {0x3a, 0xb7, 0xa8, 0xff}, {0x4a, 0x8f, 0xe0, 0xff},
+	{0x8e, 0x6b, 0xd6, 0xff}, {0xd6, 0x6b, 0xb5, 0xff},
+}
+
+// Breakdown layout, in canvas pixels
+const (
+	profMargin = 4
+	profRow    = 6  // Height of each depth of the bar
+	profLine   = 12 // Height of a legend line
+)
+
+var profLabelStyle = TextStyle{Font: "10px monospace", Color: color.White}
+
+// ShowBreakdown turns the on-canvas breakdown on or off: a bar along the top
+// of the canvas, its full width the frame budget (see SetFrameBudget; 60fps
+// if none is set) or the RenderFunc's time if that is longer, with each
+// pass's share of it. Nested passes sit below their parent, flame graph
+// style, and a legend lists each pass's smoothed time in milliseconds. It
+// is drawn on an overlay, so it never reaches the shadow canvas.
+func (p *Profiler) ShowBreakdown(on bool) {
+	if !on {
+		if p.bar != nil {
+			p.c.RemoveOverlay(p.bar)
+			p.bar = nil
+		}
+		return
+	}
+	if p.bar != nil {
+		return
+	}
+	p.bar = p.c.AddOverlay(p.drawBreakdown)
+	p.bar.Stale = func() bool { return time.Since(p.drawn) >= profilerRefresh }
+}
+
+// fromTop converts rows counted down from the top of the picture as
+// displayed to overlay rows [y0, y1)
+func (p *Profiler) fromTop(from, to int) (y0, y1 int) {
+	if p.c.flipY {
+		return p.bar.Height - to, p.bar.Height - from
+	}
+	return from, to
+}
+
+func (p *Profiler) drawBreakdown(o *Overlay) {
+	p.drawn = time.Now()
+	o.Clear()
+	passes := p.tree()
+	if len(passes) == 0 {
+		return
+	}
+
+	full := p.c.watchdog.budget
+	if full <= 0 {
+		full = time.Second / 60
+	}
+	if avg := p.c.watchdog.stats.AvgRender; avg > full {
+		full = avg
+	}
+	width := o.Width - 2*profMargin
+	depth := 0
+	for _, s := range passes {
+		depth = maxInt(depth, s.Depth+1)
+	}
+	lines := len(passes)
+	y0, y1 := p.fromTop(0, 2*profMargin+depth*profRow+lines*profLine)
+	o.FillRect(0, y0, o.Width, y1, color.RGBA{0, 0, 0, 0xb0})
+
+	// Each pass starts where its parent's earlier children ended
+	next := map[*profPass]int{nil: profMargin} // By parent
+	for i, pass := range passes {
+		s := &pass.PassStats
+		col := profColors[pass.index%len(profColors)]
+		x := next[pass.parent]
+		w := int(float64(width) * float64(s.Avg) / float64(full))
+		next[pass.parent] = x + w
+		next[pass] = x
+		top := profMargin + s.Depth*profRow
+		ry0, ry1 := p.fromTop(top, top+profRow-1)
+		o.FillRect(x, ry0, minInt(x+w, profMargin+width), ry1, col)
+
+		// Legend: swatch, name, milliseconds
+		line := profMargin + depth*profRow + i*profLine
+		sy0, sy1 := p.fromTop(line+3, line+9)
+		indent := profMargin + s.Depth*8
+		o.FillRect(indent, sy0, indent+6, sy1, col)
+		if pass.label == nil {
+			pass.label, _ = p.c.RenderText(s.Name[strings.LastIndexByte(s.Name, '/')+1:], profLabelStyle)
+		}
+		ly, _ := p.fromTop(line, line+profLine)
+		o.DrawRegion(pass.label, indent+10, ly+1)
+		ms := strconv.FormatFloat(s.Avg.Seconds()*1000, 'f', 2, 64)
+		dy, _ := p.fromTop(line+3, line+4)
+		drawDigits(o, indent+14+pass.label.Width, dy, p.c.flipY, ms, color.White)
+	}
+}
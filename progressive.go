@@ -0,0 +1,142 @@
+package pixelcanvas
+
+import (
+	"syscall/js"
+	"time"
+
+	"github.com/faiface/pixel"
+)
+
+// DefaultBandRows is the band height used when Progressive.Rows is 0
+const DefaultBandRows = 64
+
+// Progressive configures progressive presentation for very large canvases.
+// Instead of copying the whole frame to the browser at once, each changed
+// frame is copied in horizontal bands, top to bottom, spread over as many
+// animation frames as it takes, so a huge buffer never stalls a frame.
+type Progressive struct {
+	Rows   int           // Band height in pixels. 0 for DefaultBandRows
+	Budget time.Duration // Time per animation frame to spend on bands, at least one band is always copied. 0 for one band per frame
+
+	// Band, if set, renders a single band just before it is copied, so the
+	// rendering is spread across frames too. r is in shadow canvas (pixel)
+	// coordinates. Each band then reads the shadow canvas back separately.
+	// When nil, the frame is snapshotted when a pass starts and the
+	// RenderFunc keeps running while the pass is copied.
+	Band func(gc *Canvas, r pixel.Rect)
+
+	// OnProgress, if set, is called after each band with the rows copied so
+	// far in the current pass and the total
+	OnProgress func(done, total int)
+}
+
+// progressState tracks the pass in progress
+type progressState struct {
+	opts    *Progressive
+	active  bool    // A pass is being copied
+	pending bool    // The frame changed during the pass; start another when done
+	next    int     // Next DOM row to copy
+	src     []uint8 // Snapshot being copied, when Band is nil
+}
+
+// SetProgressive enables progressive presentation, or disables it if p is
+// nil. Any pass in progress is abandoned.
+func (c *Canvasp) SetProgressive(p *Progressive) {
+	c.progress = progressState{opts: p}
+}
+
+// Progress reports how far through the current pass presentation is, in
+// rows, and whether a pass is in progress
+func (c *Canvasp) Progress() (done, total int, active bool) {
+	return c.progress.next, c.height, c.progress.active
+}
+
+// progressiveCopy copies the next bands of the current pass, starting a new
+// pass if the frame has changed
+func (c *Canvasp) progressiveCopy(changed bool) {
+	p := &c.progress
+	if changed || p.pending {
+		if p.active {
+			p.pending = true
+		} else {
+			c.startPass()
+		}
+	}
+	if !p.active {
+		return
+	}
+
+	rows := p.opts.Rows
+	if rows <= 0 {
+		rows = DefaultBandRows
+	}
+
+	c.mark("copy-start")
+	start := time.Now()
+	for {
+		d0 := p.next
+		d1 := clampInt(d0+rows, 0, c.height)
+
+		src := p.src
+		if p.opts.Band != nil {
+			p.opts.Band(c.image, c.bandRect(d0, d1))
+			src = c.image.Pixels()
+		}
+		c.copyBand(src, d0, d1)
+		p.next = d1
+		if p.opts.OnProgress != nil {
+			p.opts.OnProgress(d1, c.height)
+		}
+
+		if d1 >= c.height {
+			p.active = false
+			p.src = nil
+			break
+		}
+		if p.opts.Budget <= 0 || time.Since(start) >= p.opts.Budget {
+			break
+		}
+	}
+	c.mark("copy-end")
+	c.measure("copy", "copy-start", "copy-end")
+}
+
+func (c *Canvasp) startPass() {
+	p := &c.progress
+	p.active = true
+	p.pending = false
+	p.next = 0
+	if p.opts.Band == nil {
+		p.src = c.image.Pixels()
+	}
+}
+
+// bandRect converts DOM rows [d0, d1) to a shadow canvas rectangle
+func (c *Canvasp) bandRect(d0, d1 int) pixel.Rect {
+	if c.flipY {
+		d0, d1 = c.height-d1, c.height-d0
+	}
+	return pixel.R(0, float64(d0), float64(c.width), float64(d1))
+}
+
+// copyBand converts and presents DOM rows [d0, d1) from src
+func (c *Canvasp) copyBand(src []uint8, d0, d1 int) {
+	if len(c.convbuff) != len(src) {
+		c.convbuff = make([]uint8, len(src))
+	}
+	stride := c.width * 4
+	for dy := d0; dy < d1; dy++ {
+		y := dy
+		if c.flipY {
+			y = c.height - 1 - dy
+		}
+		convertRow(c.convbuff[dy*stride:(dy+1)*stride], src[y*stride:(y+1)*stride], c.format)
+		c.composeRow(c.convbuff[dy*stride:(dy+1)*stride], y)
+	}
+
+	band := c.copybuff.Call("subarray", d0*stride, d1*stride)
+	js.CopyBytesToJS(band, c.convbuff[d0*stride:d1*stride])
+	c.dataSet.Invoke(band, d0*stride)
+	c.putImage.Invoke(c.imgData, 0, 0, 0, d0, c.width, d1-d0) // Only the band's dirty rectangle
+	c.invalidateCopy()
+}
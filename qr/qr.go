@@ -0,0 +1,525 @@
+// Package qr encodes QR codes (ISO/IEC 18004, model 2, versions 1-40) in
+// byte mode, for drawing share links and the like with pixelcanvas.DrawQR.
+package qr
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+// Level is the error correction level: how much of the code can be damaged
+// or covered and still read
+type Level int
+
+// Error correction levels
+const (
+	L Level = iota // Recovers about 7% of the code
+	M              // About 15%
+	Q              // About 25%
+	H              // About 30%
+)
+
+// ErrTooLong is returned when the data doesn't fit in a version 40 code at
+// the level asked for
+var ErrTooLong = errors.New("qr: data too long")
+
+// Code is an encoded QR code: a square of Size x Size modules
+type Code struct {
+	Size    int
+	Version int // 1-40
+	Level   Level
+	Mask    int // 0-7
+
+	modules  []bool // Dark modules, row by row from the top
+	function []bool // Modules belonging to the fixed patterns
+}
+
+// Black reports whether module x, y (origin top left) is dark. Positions
+// outside the code, i.e. in the quiet zone, are light.
+func (q *Code) Black(x, y int) bool {
+	if x < 0 || y < 0 || x >= q.Size || y >= q.Size {
+		return false
+	}
+	return q.modules[y*q.Size+x]
+}
+
+// Image renders the code with scale pixels per module and a quiet zone of
+// quiet modules around it. The standard asks for at least 4.
+func (q *Code) Image(scale int, quiet int) *image.Gray {
+	n := (q.Size + 2*quiet) * scale
+	img := image.NewGray(image.Rect(0, 0, n, n))
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			v := color.Gray{Y: 255}
+			if q.Black(x/scale-quiet, y/scale-quiet) {
+				v.Y = 0
+			}
+			img.SetGray(x, y, v)
+		}
+	}
+	return img
+}
+
+// EncodeString encodes s as UTF-8 bytes
+func EncodeString(s string, level Level) (*Code, error) {
+	return Encode([]byte(s), level)
+}
+
+// Encode encodes data in the smallest version that holds it at level,
+// choosing the mask with the lowest penalty
+func Encode(data []byte, level Level) (*Code, error) {
+	version := 0
+	for v := 1; v <= 40; v++ {
+		if 4+countBits(v)+8*len(data) <= dataCodewords(v, level)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	// Mode, count and data, then a terminator, padding to a byte and the
+	// alternating pad codewords
+	var b bitBuffer
+	b.append(0x4, 4) // Byte mode
+	b.append(len(data), countBits(version))
+	for _, d := range data {
+		b.append(int(d), 8)
+	}
+	capacity := dataCodewords(version, level) * 8
+	b.append(0, minInt(4, capacity-b.len()))
+	b.append(0, (8-b.len()%8)%8)
+	for pad := 0xEC; b.len() < capacity; pad ^= 0xEC ^ 0x11 {
+		b.append(pad, 8)
+	}
+
+	q := &Code{Size: version*4 + 17, Version: version, Level: level}
+	q.modules = make([]bool, q.Size*q.Size)
+	q.function = make([]bool, q.Size*q.Size)
+	q.drawFunctionPatterns()
+	q.drawCodewords(addECC(b.bytes(), version, level))
+
+	best, bestScore := 0, -1
+	for m := 0; m < 8; m++ {
+		q.applyMask(m)
+		q.drawFormatBits(m)
+		if s := q.penalty(); bestScore < 0 || s < bestScore {
+			best, bestScore = m, s
+		}
+		q.applyMask(m) // Masks are XOR, so this undoes it
+	}
+	q.Mask = best
+	q.applyMask(best)
+	q.drawFormatBits(best)
+	return q, nil
+}
+
+// Error correction tables, indexed by level then version (0 unused)
+var eccPerBlock = [4][41]int{
+	{0, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{0, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var eccBlocks = [4][41]int{
+	{0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{0, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{0, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// formatLevel is each level's 2 bit code in the format information
+var formatLevel = [4]int{1, 0, 3, 2}
+
+// countBits is the width of the byte mode character count
+func countBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// rawModules is the number of modules available for data and error
+// correction, after the function patterns
+func rawModules(version int) int {
+	n := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		n -= (25*align-10)*align - 55
+		if version >= 7 {
+			n -= 36
+		}
+	}
+	return n
+}
+
+// dataCodewords is the number of 8 bit data codewords a version holds
+func dataCodewords(version int, level Level) int {
+	return rawModules(version)/8 - eccPerBlock[level][version]*eccBlocks[level][version]
+}
+
+// alignmentPositions lists the centre coordinates of the alignment patterns
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	n := version/7 + 2
+	step := (version*8 + n*3 + 5) / (n*4 - 4) * 2
+	pos := make([]int, n)
+	pos[0] = 6
+	for i, p := n-1, version*4+10; i >= 1; i, p = i-1, p-step {
+		pos[i] = p
+	}
+	return pos
+}
+
+// addECC splits data into blocks, appends each block's Reed-Solomon
+// codewords and interleaves the result
+func addECC(data []byte, version int, level Level) []byte {
+	blocks := eccBlocks[level][version]
+	eccLen := eccPerBlock[level][version]
+	raw := rawModules(version) / 8
+	short := blocks - raw%blocks
+	shortLen := raw / blocks
+
+	divisor := rsDivisor(eccLen)
+	out := make([][]byte, blocks)
+	k := 0
+	for i := range out {
+		n := shortLen - eccLen
+		if i >= short {
+			n++
+		}
+		dat := append([]byte(nil), data[k:k+n]...)
+		k += n
+		ecc := rsRemainder(dat, divisor)
+		if i < short {
+			dat = append(dat, 0) // Placeholder so all blocks line up, skipped below
+		}
+		out[i] = append(dat, ecc...)
+	}
+
+	result := make([]byte, 0, raw)
+	for i := range out[0] {
+		for j, blk := range out {
+			if i != shortLen-eccLen || j >= short {
+				result = append(result, blk[i])
+			}
+		}
+	}
+	return result
+}
+
+// rsDivisor is the Reed-Solomon generator polynomial of a degree, highest
+// power first with the leading 1 omitted
+func rsDivisor(degree int) []byte {
+	poly := make([]byte, degree)
+	poly[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range poly {
+			poly[j] = gfMul(poly[j], root)
+			if j+1 < len(poly) {
+				poly[j] ^= poly[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	return poly
+}
+
+// rsRemainder is the error correction codewords for data
+func rsRemainder(data []byte, divisor []byte) []byte {
+	rem := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ rem[0]
+		copy(rem, rem[1:])
+		rem[len(rem)-1] = 0
+		for i, d := range divisor {
+			rem[i] ^= gfMul(d, factor)
+		}
+	}
+	return rem
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMul(x byte, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+func (q *Code) set(x int, y int, dark bool) {
+	q.modules[y*q.Size+x] = dark
+	q.function[y*q.Size+x] = true
+}
+
+func (q *Code) drawFunctionPatterns() {
+	for i := 0; i < q.Size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+
+	q.drawFinder(3, 3)
+	q.drawFinder(q.Size-4, 3)
+	q.drawFinder(3, q.Size-4)
+
+	align := alignmentPositions(q.Version)
+	last := len(align) - 1
+	for i, ay := range align {
+		for j, ax := range align {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue // Overlaps a finder
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(ax+dx, ay+dy, maxInt(absInt(dx), absInt(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	q.drawFormatBits(0) // Reserve the area, redrawn once the mask is chosen
+	q.drawVersion()
+}
+
+// drawFinder draws a finder pattern and its separator centred on x, y
+func (q *Code) drawFinder(x int, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= q.Size || yy >= q.Size {
+				continue
+			}
+			d := maxInt(absInt(dx), absInt(dy))
+			q.set(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+func (q *Code) drawFormatBits(mask int) {
+	data := formatLevel[q.Level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>uint(i)&1 != 0 }
+
+	// Around the top left finder
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+
+	// Split between the other two
+	for i := 0; i < 8; i++ {
+		q.set(q.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.Size-15+i, bit(i))
+	}
+	q.set(8, q.Size-8, true) // Always dark
+}
+
+func (q *Code) drawVersion() {
+	if q.Version < 7 {
+		return
+	}
+	rem := q.Version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := q.Version<<12 | rem
+	for i := 0; i < 18; i++ {
+		dark := bits>>uint(i)&1 != 0
+		a, b := q.Size-11+i%3, i/3
+		q.set(a, b, dark)
+		q.set(b, a, dark)
+	}
+}
+
+// drawCodewords places the data in the zigzag of column pairs from the
+// bottom right, skipping the function patterns
+func (q *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // Skip the vertical timing pattern
+		}
+		for vert := 0; vert < q.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.Size - 1 - vert // Upwards
+				}
+				n := y*q.Size + x
+				if !q.function[n] && i < len(data)*8 {
+					q.modules[n] = data[i>>3]>>uint(7-i&7)&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (q *Code) applyMask(mask int) {
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			n := y*q.Size + x
+			if invert && !q.function[n] {
+				q.modules[n] = !q.modules[n]
+			}
+		}
+	}
+}
+
+// penalty scores the code by the standard's rules for patterns that confuse
+// readers: long runs, 2x2 blocks, finder lookalikes and unbalanced colour
+func (q *Code) penalty() int {
+	size := q.Size
+	score := 0
+	line := make([]bool, size)
+	for pass := 0; pass < 2; pass++ {
+		for a := 0; a < size; a++ {
+			for b := 0; b < size; b++ {
+				if pass == 0 {
+					line[b] = q.modules[a*size+b] // Rows
+				} else {
+					line[b] = q.modules[b*size+a] // Columns
+				}
+			}
+			score += linePenalty(line)
+		}
+	}
+
+	dark := 0
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			m := q.modules[y*size+x]
+			if m {
+				dark++
+			}
+			if x+1 < size && y+1 < size && m == q.modules[y*size+x+1] &&
+				m == q.modules[(y+1)*size+x] && m == q.modules[(y+1)*size+x+1] {
+				score += 3
+			}
+		}
+	}
+	percent := dark * 100 / (size * size)
+	score += absInt(percent-50) / 5 * 10
+	return score
+}
+
+// finderLike are the 1:1:3:1:1 patterns with 4 light modules to one side
+var finderLike = [2][11]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+func linePenalty(line []bool) int {
+	score := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			score += 3 + run - 5
+		}
+		run = 1
+	}
+	for i := 0; i+11 <= len(line); i++ {
+		for _, p := range finderLike {
+			match := true
+			for j, v := range p {
+				if line[i+j] != v {
+					match = false
+					break
+				}
+			}
+			if match {
+				score += 40
+			}
+		}
+	}
+	return score
+}
+
+// bitBuffer accumulates bits, most significant first
+type bitBuffer struct {
+	bits []bool
+}
+
+func (b *bitBuffer) append(v int, n int) {
+	for i := n - 1; i >= 0; i-- {
+		b.bits = append(b.bits, v>>uint(i)&1 != 0)
+	}
+}
+
+func (b *bitBuffer) len() int {
+	return len(b.bits)
+}
+
+func (b *bitBuffer) bytes() []byte {
+	out := make([]byte, len(b.bits)/8)
+	for i, v := range b.bits {
+		if v {
+			out[i>>3] |= 1 << uint(7-i&7)
+		}
+	}
+	return out
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
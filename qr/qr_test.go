@@ -0,0 +1,125 @@
+package qr
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRSRemainder(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{
+			// ISO/IEC 18004 Annex I: "01234567" in numeric mode at 1-M
+			name: "1-M",
+			data: []byte{0x10, 0x20, 0x0C, 0x56, 0x61, 0x80, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11},
+			want: []byte{0xA5, 0x24, 0xD4, 0xC1, 0xED, 0x36, 0xC7, 0x87, 0x2C, 0x55},
+		},
+		{
+			// "HELLO" in byte mode at 1-Q
+			name: "1-Q",
+			data: []byte{0x40, 0x54, 0x84, 0x54, 0xC4, 0xC4, 0xF0, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11},
+			want: []byte{0x53, 0xA2, 0xB2, 0x2E, 0xC8, 0x23, 0x47, 0xC7, 0x98, 0xB2, 0x1B, 0x70, 0x8E},
+		},
+	}
+	for _, tt := range tests {
+		if got := rsRemainder(tt.data, rsDivisor(len(tt.want))); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: rsRemainder = % X, want % X", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAddECCSingleBlock(t *testing.T) {
+	data := []byte{0x40, 0x54, 0x84, 0x54, 0xC4, 0xC4, 0xF0, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11}
+	got := addECC(data, 1, Q)
+	want := append(append([]byte(nil), data...), 0x53, 0xA2, 0xB2, 0x2E, 0xC8, 0x23, 0x47, 0xC7, 0x98, 0xB2, 0x1B, 0x70, 0x8E)
+	if !bytes.Equal(got, want) {
+		t.Errorf("addECC = % X, want % X", got, want)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	// Byte mode capacities from the standard's tables
+	tests := []struct {
+		version int
+		level   Level
+		bytes   int
+	}{
+		{1, L, 17},
+		{1, M, 14},
+		{1, Q, 11},
+		{1, H, 7},
+		{9, L, 230},
+		{10, L, 271}, // The character count grows to 16 bits here
+		{40, L, 2953},
+		{40, M, 2331},
+		{40, Q, 1663},
+		{40, H, 1273},
+	}
+	for _, tt := range tests {
+		q, err := Encode(make([]byte, tt.bytes), tt.level)
+		if err != nil {
+			t.Errorf("Encode(%d bytes, %d): %v", tt.bytes, tt.level, err)
+			continue
+		}
+		if q.Version != tt.version {
+			t.Errorf("Encode(%d bytes, %d) version = %d, want %d", tt.bytes, tt.level, q.Version, tt.version)
+		}
+
+		q, err = Encode(make([]byte, tt.bytes+1), tt.level)
+		switch {
+		case tt.version == 40 && err != ErrTooLong:
+			t.Errorf("Encode(%d bytes, %d) error = %v, want ErrTooLong", tt.bytes+1, tt.level, err)
+		case tt.version < 40 && (err != nil || q.Version != tt.version+1):
+			t.Errorf("Encode(%d bytes, %d) = %+v, %v, want version %d", tt.bytes+1, tt.level, q, err, tt.version+1)
+		}
+	}
+}
+
+func TestFunctionPatterns(t *testing.T) {
+	tests := []struct {
+		data  string
+		level Level
+		size  int
+	}{
+		{"HELLO", Q, 21},
+		{"https://example.com/share?id=0123456789", M, 29},
+		{strings.Repeat("x", 500), H, 113},
+	}
+	for _, tt := range tests {
+		q, err := EncodeString(tt.data, tt.level)
+		if err != nil {
+			t.Fatalf("EncodeString(%.16q): %v", tt.data, err)
+		}
+		if q.Size != tt.size || q.Size != q.Version*4+17 {
+			t.Errorf("%.16q: size %d (version %d), want %d", tt.data, q.Size, q.Version, tt.size)
+		}
+		// Finder rings in three corners, separated from the data by light modules
+		for _, c := range [][2]int{{0, 0}, {q.Size - 7, 0}, {0, q.Size - 7}} {
+			for i := 0; i < 7; i++ {
+				for j := 0; j < 7; j++ {
+					d := maxInt(absInt(i-3), absInt(j-3))
+					if want := d != 2; q.Black(c[0]+i, c[1]+j) != want {
+						t.Errorf("%.16q: finder at %v module %d,%d = %v", tt.data, c, i, j, !want)
+					}
+				}
+			}
+		}
+		// Timing patterns alternate along row and column 6
+		for i := 8; i < q.Size-8; i++ {
+			if q.Black(i, 6) != (i%2 == 0) || q.Black(6, i) != (i%2 == 0) {
+				t.Errorf("%.16q: timing pattern broken at %d", tt.data, i)
+			}
+		}
+		// The dark module
+		if !q.Black(8, q.Size-8) {
+			t.Errorf("%.16q: dark module is light", tt.data)
+		}
+		if q.Black(-1, 0) || q.Black(0, q.Size) {
+			t.Errorf("%.16q: quiet zone is dark", tt.data)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package pixelcanvas
+
+import (
+	"errors"
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+	"github.com/lwayneh/pixelcanvas/qr"
+)
+
+// ErrQRTooSmall is returned by DrawQR when the target can't fit the code at
+// one pixel per module
+var ErrQRTooSmall = errors.New("pixelcanvas: target too small for QR code")
+
+// QRQuietZone is the light border, in modules, DrawQR leaves around a code.
+// Readers need it to find the code against busy backgrounds.
+const QRQuietZone = 4
+
+// DrawQR draws a QR code for text centred in target, using the largest
+// whole number of pixels per module that fits with the quiet zone, so the
+// modules stay sharp. Dark modules are drawn in fg; bg fills the light
+// modules and quiet zone, or leaves them untouched if nil. It returns the
+// area covered, or qr.ErrTooLong if the text doesn't fit in a QR code.
+func (c *Canvasp) DrawQR(text string, target pixel.Rect, level qr.Level, fg color.Color, bg color.Color) (pixel.Rect, error) {
+	code, err := qr.EncodeString(text, level)
+	if err != nil {
+		return pixel.Rect{}, err
+	}
+	return c.DrawQRCode(code, target, fg, bg)
+}
+
+// DrawQRCode is DrawQR for an already encoded code
+func (c *Canvasp) DrawQRCode(code *qr.Code, target pixel.Rect, fg color.Color, bg color.Color) (pixel.Rect, error) {
+	target = c.ToCanvasRect(target).Norm()
+	n := code.Size + 2*QRQuietZone
+	scale := int(math.Min(target.W(), target.H())) / n
+	if scale < 1 {
+		return pixel.Rect{}, ErrQRTooSmall
+	}
+
+	side := n * scale
+	x0 := int(math.Floor(target.Center().X)) - side/2
+	y0 := int(math.Floor(target.Center().Y)) - side/2
+	top := y0 + side
+
+	dark := rgba8(fg)
+	var light [4]uint8
+	if bg != nil {
+		light = rgba8(bg)
+	}
+
+	pix := c.pixels()
+	for j := 0; j < side; j++ {
+		y := top - 1 - j // Module rows count down from the top
+		if y < 0 || y >= c.height {
+			continue
+		}
+		for i := 0; i < side; i++ {
+			x := x0 + i
+			if x < 0 || x >= c.width {
+				continue
+			}
+			px := pix[(y*c.width+x)*4:]
+			if code.Black(i/scale-QRQuietZone, j/scale-QRQuietZone) {
+				blendOver(px[:4], dark[:])
+			} else if bg != nil {
+				blendOver(px[:4], light[:])
+			}
+		}
+	}
+	c.pixelsChanged(pix)
+	return c.FromCanvasRect(intRect(x0, y0, x0+side, top)), nil
+}
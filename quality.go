@@ -0,0 +1,207 @@
+package pixelcanvas
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"syscall/js"
+)
+
+// Quality presets
+//
+// A Quality level bundles the settings that trade looks for speed (frame
+// rate, effects, particle counts) so an app can offer one low/medium/high
+// switch, change it while running, and remember the player's choice in
+// localStorage. The canvas applies what it owns (FPS, and the lighting
+// pass for Effects); everything else is read by the app from the preset,
+// e.g. in OnChange.
+
+// Quality is a preset level
+type Quality int
+
+// Quality levels
+const (
+	QualityLow Quality = iota
+	QualityMedium
+	QualityHigh
+)
+
+// String implements fmt.Stringer
+func (q Quality) String() string {
+	switch q {
+	case QualityLow:
+		return "low"
+	case QualityMedium:
+		return "medium"
+	case QualityHigh:
+		return "high"
+	}
+	return "unknown"
+}
+
+// ParseQuality parses a level as written by String
+func ParseQuality(s string) (Quality, error) {
+	for q := QualityLow; q <= QualityHigh; q++ {
+		if strings.EqualFold(s, q.String()) {
+			return q, nil
+		}
+	}
+	return 0, fmt.Errorf("pixelcanvas: unknown quality %q", s)
+}
+
+// QualityPreset is the settings for one level
+type QualityPreset struct {
+	FPS       float64 // Frame rate cap
+	Effects   bool    // Lighting and other full-screen effects
+	Particles int     // Particle budget, for the app's particle systems
+
+	// Backend names the renderer the app should prefer, e.g. "gl" or
+	// "software". The canvas doesn't switch renderers itself.
+	Backend string
+
+	// Flags are feature flags for the level, e.g. "bloom"; see Enabled
+	Flags map[string]bool
+}
+
+// DefaultQualityPresets are reasonable settings for a 2D game
+var DefaultQualityPresets = map[Quality]QualityPreset{
+	QualityLow:    {FPS: 30, Effects: false, Particles: 100, Backend: "software"},
+	QualityMedium: {FPS: 60, Effects: true, Particles: 500, Backend: "gl"},
+	QualityHigh:   {FPS: 120, Effects: true, Particles: 2000, Backend: "gl"},
+}
+
+// QualitySettings holds the current level and flag overrides
+type QualitySettings struct {
+	Presets map[Quality]QualityPreset
+
+	// OnChange is called after the level or a flag changes
+	OnChange func(q Quality, p QualityPreset)
+
+	c     *Canvasp
+	key   string // localStorage key, or "" not to persist
+	level Quality
+	flags map[string]bool // Overrides of the presets' flags
+}
+
+// qualityState is what is persisted
+type qualityState struct {
+	Level string          `json:"level"`
+	Flags map[string]bool `json:"flags,omitempty"`
+}
+
+// NewQualitySettings starts at the level and flags saved under key in
+// localStorage, or at def if nothing is saved (or key is ""), and applies
+// it. Changes are saved under key.
+func (c *Canvasp) NewQualitySettings(key string, def Quality) *QualitySettings {
+	presets := make(map[Quality]QualityPreset, len(DefaultQualityPresets))
+	for q, p := range DefaultQualityPresets {
+		presets[q] = p
+	}
+	s := &QualitySettings{Presets: presets, c: c, key: key, level: def, flags: make(map[string]bool)}
+	s.load()
+	s.apply()
+	return s
+}
+
+// Level returns the current level
+func (s *QualitySettings) Level() Quality {
+	return s.level
+}
+
+// Preset returns the current level's settings
+func (s *QualitySettings) Preset() QualityPreset {
+	return s.Presets[s.level]
+}
+
+// Set switches to level q, applying and saving it
+func (s *QualitySettings) Set(q Quality) {
+	if _, ok := s.Presets[q]; !ok {
+		return
+	}
+	s.level = q
+	s.changed()
+}
+
+// Enabled reports whether a feature flag is on: an override set with
+// SetFlag if there is one, otherwise the current preset's flag
+func (s *QualitySettings) Enabled(flag string) bool {
+	if on, ok := s.flags[flag]; ok {
+		return on
+	}
+	return s.Preset().Flags[flag]
+}
+
+// SetFlag overrides a feature flag at every level
+func (s *QualitySettings) SetFlag(flag string, on bool) {
+	s.flags[flag] = on
+	s.changed()
+}
+
+// ClearFlag removes an override, going back to the preset's flag
+func (s *QualitySettings) ClearFlag(flag string) {
+	delete(s.flags, flag)
+	s.changed()
+}
+
+func (s *QualitySettings) changed() {
+	s.apply()
+	s.save()
+	if s.OnChange != nil {
+		s.OnChange(s.level, s.Preset())
+	}
+}
+
+// apply sets what the canvas itself controls
+func (s *QualitySettings) apply() {
+	p := s.Preset()
+	if p.FPS > 0 {
+		s.c.SetFPS(p.FPS)
+	}
+	for _, pass := range s.c.passes {
+		if l, ok := pass.(*Lighting); ok {
+			l.Show(p.Effects)
+		}
+	}
+	s.c.log().Debug("quality applied", "level", s.level.String(), "fps", p.FPS, "effects", p.Effects)
+}
+
+func (s *QualitySettings) save() {
+	if s.key == "" {
+		return
+	}
+	store := js.Global().Get("localStorage")
+	if store.IsUndefined() || store.IsNull() {
+		return
+	}
+	data, err := json.Marshal(qualityState{Level: s.level.String(), Flags: s.flags})
+	if err != nil {
+		return
+	}
+	store.Call("setItem", s.key, string(data))
+}
+
+func (s *QualitySettings) load() {
+	if s.key == "" {
+		return
+	}
+	store := js.Global().Get("localStorage")
+	if store.IsUndefined() || store.IsNull() {
+		return
+	}
+	v := store.Call("getItem", s.key)
+	if v.IsNull() {
+		return
+	}
+	var st qualityState
+	if json.Unmarshal([]byte(v.String()), &st) != nil {
+		return
+	}
+	if q, err := ParseQuality(st.Level); err == nil {
+		if _, ok := s.Presets[q]; ok {
+			s.level = q
+		}
+	}
+	for f, on := range st.Flags {
+		s.flags[f] = on
+	}
+}
@@ -0,0 +1,404 @@
+// Package raster is a software renderer for github.com/faiface/pixel: a
+// Canvas that implements pixel.ComposeTarget and pixel.Picture on a plain
+// pixel buffer, drawing sprites, imdraw shapes, text and other canvases by
+// rasterizing their triangles on the CPU.
+//
+// It is a drop-in replacement for pixelgl.Canvas in the subset pixelcanvas
+// uses (the same bottom-up, premultiplied RGBA Pixels layout, matrices,
+// colour masks, Porter-Duff composition and smooth or nearest sampling),
+// without pixelgl's OpenGL and GLFW dependencies, which do nothing in the
+// browser but add to the WebAssembly binary. Shaders are not supported.
+//
+// Triangles are filled with the top-left rule, so the two halves of a quad
+// meet without gaps or double blending, and pixels are sampled at their
+// centres, like OpenGL.
+package raster
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Canvas is a software rendered pixel.ComposeTarget
+type Canvas struct {
+	bounds  pixel.Rect
+	w, h    int
+	pix     []uint8 // Premultiplied RGBA, rows bottom-up
+	matrix  pixel.Matrix
+	mask    pixel.RGBA
+	compose pixel.ComposeMethod
+	smooth  bool
+	sprite  *pixel.Sprite // For drawing the canvas itself, see Draw
+}
+
+var (
+	_ pixel.ComposeTarget = (*Canvas)(nil)
+	_ pixel.PictureColor  = (*Canvas)(nil)
+)
+
+// NewCanvas creates a transparent canvas covering bounds
+func NewCanvas(bounds pixel.Rect) *Canvas {
+	c := &Canvas{matrix: pixel.IM, mask: pixel.Alpha(1)}
+	c.SetBounds(bounds)
+	return c
+}
+
+// SetBounds resizes the canvas, keeping the contents where the old and new
+// bounds overlap
+func (c *Canvas) SetBounds(bounds pixel.Rect) {
+	bounds = bounds.Norm()
+	w, h := int(math.Round(bounds.W())), int(math.Round(bounds.H()))
+	pix := make([]uint8, w*h*4)
+	if c.pix != nil {
+		dx := int(math.Round(c.bounds.Min.X - bounds.Min.X))
+		dy := int(math.Round(c.bounds.Min.Y - bounds.Min.Y))
+		for y := 0; y < c.h; y++ {
+			ny := y + dy
+			if ny < 0 || ny >= h {
+				continue
+			}
+			x0, x1 := maxInt(0, -dx), minInt(c.w, w-dx)
+			if x0 < x1 {
+				copy(pix[(ny*w+x0+dx)*4:(ny*w+x1+dx)*4], c.pix[(y*c.w+x0)*4:(y*c.w+x1)*4])
+			}
+		}
+	}
+	c.bounds, c.w, c.h, c.pix = bounds, w, h, pix
+}
+
+// Bounds returns the area the canvas covers
+func (c *Canvas) Bounds() pixel.Rect {
+	return c.bounds
+}
+
+// SetMatrix sets the matrix every vertex is projected by
+func (c *Canvas) SetMatrix(m pixel.Matrix) {
+	c.matrix = m
+}
+
+// SetColorMask sets a colour every drawn colour is multiplied by. nil is
+// opaque white, no change.
+func (c *Canvas) SetColorMask(col color.Color) {
+	if col == nil {
+		col = pixel.Alpha(1)
+	}
+	c.mask = pixel.ToRGBA(col)
+}
+
+// SetComposeMethod sets how drawn pixels combine with the canvas
+func (c *Canvas) SetComposeMethod(cmp pixel.ComposeMethod) {
+	c.compose = cmp
+}
+
+// SetSmooth turns bilinear sampling of pictures on or off
+func (c *Canvas) SetSmooth(smooth bool) {
+	c.smooth = smooth
+}
+
+// Smooth reports whether pictures are sampled bilinearly
+func (c *Canvas) Smooth() bool {
+	return c.smooth
+}
+
+// Clear fills the canvas with col
+func (c *Canvas) Clear(col color.Color) {
+	p := premul(pixel.ToRGBA(col))
+	if len(c.pix) == 0 {
+		return
+	}
+	copy(c.pix, p[:])
+	for n := 4; n < len(c.pix); n *= 2 {
+		copy(c.pix[n:], c.pix[:n])
+	}
+}
+
+// Color returns the colour of the pixel at at, or transparent outside the
+// bounds
+func (c *Canvas) Color(at pixel.Vec) pixel.RGBA {
+	x, y := int(math.Floor(at.X-c.bounds.Min.X)), int(math.Floor(at.Y-c.bounds.Min.Y))
+	if x < 0 || y < 0 || x >= c.w || y >= c.h {
+		return pixel.RGBA{}
+	}
+	i := (y*c.w + x) * 4
+	return pixel.RGBA{
+		R: float64(c.pix[i]) / 255,
+		G: float64(c.pix[i+1]) / 255,
+		B: float64(c.pix[i+2]) / 255,
+		A: float64(c.pix[i+3]) / 255,
+	}
+}
+
+// Pixels returns a copy of the canvas's pixels: premultiplied RGBA, rows
+// bottom-up
+func (c *Canvas) Pixels() []uint8 {
+	return append([]uint8(nil), c.pix...)
+}
+
+// Pix returns the canvas's own pixels, in the layout Pixels returns, for
+// reading and writing in place without a copy. The slice is replaced by
+// SetBounds, so don't keep it across one.
+func (c *Canvas) Pix() []uint8 {
+	return c.pix
+}
+
+// SetPixels replaces the canvas's pixels, in the layout Pixels returns.
+// It panics if pixels is the wrong size.
+func (c *Canvas) SetPixels(pixels []uint8) {
+	if len(pixels) != len(c.pix) {
+		panic("raster: SetPixels: wrong number of pixels")
+	}
+	copy(c.pix, pixels)
+}
+
+// Draw draws the canvas onto t, transformed by matrix, like a sprite of
+// its whole bounds
+func (c *Canvas) Draw(t pixel.Target, matrix pixel.Matrix) {
+	c.DrawColorMask(t, matrix, nil)
+}
+
+// DrawColorMask is Draw with the colour multiplied by mask
+func (c *Canvas) DrawColorMask(t pixel.Target, matrix pixel.Matrix, mask color.Color) {
+	if c.sprite == nil {
+		c.sprite = pixel.NewSprite(c, c.bounds)
+	} else if c.sprite.Frame() != c.bounds {
+		c.sprite.Set(c, c.bounds)
+	}
+	c.sprite.DrawColorMask(t, matrix, mask)
+}
+
+// MakeTriangles implements pixel.Target
+func (c *Canvas) MakeTriangles(t pixel.Triangles) pixel.TargetTriangles {
+	td := pixel.MakeTrianglesData(t.Len())
+	td.Update(t)
+	return &triangles{TrianglesData: td, dst: c}
+}
+
+// MakePicture implements pixel.Target. Other raster canvases are read
+// live, even after they are resized; any other picture is copied.
+func (c *Canvas) MakePicture(p pixel.Picture) pixel.TargetPicture {
+	switch p := p.(type) {
+	case *Canvas:
+		return &picture{Picture: p, dst: c, src: p}
+	case *pixel.PictureData:
+		return &picture{Picture: p, dst: c, data: p}
+	}
+	pd := pixel.PictureDataFromPicture(p)
+	return &picture{Picture: p, dst: c, data: pd}
+}
+
+// triangles are vertices made by a Canvas
+type triangles struct {
+	*pixel.TrianglesData
+	dst *Canvas
+}
+
+// Slice implements pixel.Triangles, keeping the type
+func (t *triangles) Slice(i, j int) pixel.Triangles {
+	return &triangles{TrianglesData: t.TrianglesData.Slice(i, j).(*pixel.TrianglesData), dst: t.dst}
+}
+
+// Copy implements pixel.Triangles, keeping the type
+func (t *triangles) Copy() pixel.Triangles {
+	return &triangles{TrianglesData: t.TrianglesData.Copy().(*pixel.TrianglesData), dst: t.dst}
+}
+
+// Draw fills the triangles with their vertex colours
+func (t *triangles) Draw() {
+	t.dst.fill(*t.TrianglesData, nil)
+}
+
+// picture is a Picture prepared for drawing on a Canvas
+type picture struct {
+	pixel.Picture
+	dst *Canvas
+
+	// A raster canvas, read in place
+	src *Canvas
+
+	// Anything else, copied
+	data *pixel.PictureData
+}
+
+// Draw fills tt with the picture
+func (p *picture) Draw(tt pixel.TargetTriangles) {
+	t, ok := tt.(*triangles)
+	if !ok || t.dst != p.dst {
+		panic("raster: triangles drawn with a picture from another target")
+	}
+	p.dst.fill(*t.TrianglesData, p)
+}
+
+// texel returns the premultiplied texel at integer picture coordinates,
+// transparent outside
+func (p *picture) texel(x, y int) [4]float64 {
+	if p.data != nil {
+		r := p.data.Rect
+		x -= int(math.Floor(r.Min.X))
+		y -= int(math.Floor(r.Min.Y))
+		if x < 0 || y < 0 || x >= p.data.Stride || y*p.data.Stride+x >= len(p.data.Pix) {
+			return [4]float64{}
+		}
+		q := p.data.Pix[y*p.data.Stride+x]
+		return [4]float64{float64(q.R) / 255, float64(q.G) / 255, float64(q.B) / 255, float64(q.A) / 255}
+	}
+	s := p.src
+	x -= int(math.Floor(s.bounds.Min.X))
+	y -= int(math.Floor(s.bounds.Min.Y))
+	if x < 0 || y < 0 || x >= s.w || y >= s.h {
+		return [4]float64{}
+	}
+	i := (y*s.w + x) * 4
+	return [4]float64{float64(s.pix[i]) / 255, float64(s.pix[i+1]) / 255, float64(s.pix[i+2]) / 255, float64(s.pix[i+3]) / 255}
+}
+
+// sample returns the picture's colour at picture coordinates u, v
+func (p *picture) sample(u, v float64, smooth bool) [4]float64 {
+	if !smooth {
+		return p.texel(int(math.Floor(u)), int(math.Floor(v)))
+	}
+	u, v = u-0.5, v-0.5
+	x0, y0 := math.Floor(u), math.Floor(v)
+	fx, fy := u-x0, v-y0
+	ix, iy := int(x0), int(y0)
+	a, b := p.texel(ix, iy), p.texel(ix+1, iy)
+	c, d := p.texel(ix, iy+1), p.texel(ix+1, iy+1)
+	var out [4]float64
+	for k := range out {
+		out[k] = (a[k]*(1-fx)+b[k]*fx)*(1-fy) + (c[k]*(1-fx)+d[k]*fx)*fy
+	}
+	return out
+}
+
+// fill rasterizes td, three vertices per triangle, sampling pic if given
+func (c *Canvas) fill(td pixel.TrianglesData, pic *picture) {
+	for i := 0; i+2 < len(td); i += 3 {
+		c.triangle(&td[i], &td[i+1], &td[i+2], pic)
+	}
+}
+
+// vertex is a TrianglesData element
+type vertex = struct {
+	Position  pixel.Vec
+	Color     pixel.RGBA
+	Picture   pixel.Vec
+	Intensity float64
+}
+
+// triangle fills one triangle, interpolating colour, picture coordinates
+// and intensity across it
+func (c *Canvas) triangle(a, b, d *vertex, pic *picture) {
+	p0 := c.matrix.Project(a.Position).Sub(c.bounds.Min)
+	p1 := c.matrix.Project(b.Position).Sub(c.bounds.Min)
+	p2 := c.matrix.Project(d.Position).Sub(c.bounds.Min)
+	area := edge(p0, p1, p2)
+	if area == 0 || math.IsNaN(area) {
+		return
+	}
+	if area < 0 { // Make the winding counter-clockwise
+		p1, p2 = p2, p1
+		b, d = d, b
+		area = -area
+	}
+
+	x0 := maxInt(int(math.Floor(math.Min(p0.X, math.Min(p1.X, p2.X)))), 0)
+	x1 := minInt(int(math.Ceil(math.Max(p0.X, math.Max(p1.X, p2.X)))), c.w)
+	y0 := maxInt(int(math.Floor(math.Min(p0.Y, math.Min(p1.Y, p2.Y)))), 0)
+	y1 := minInt(int(math.Ceil(math.Max(p0.Y, math.Max(p1.Y, p2.Y)))), c.h)
+	if x0 >= x1 || y0 >= y1 {
+		return
+	}
+
+	// Top-left rule: pixels exactly on an edge belong to the triangle only
+	// for top or left edges
+	bias0, bias1, bias2 := topLeft(p1, p2), topLeft(p2, p0), topLeft(p0, p1)
+
+	mask := c.mask
+	for y := y0; y < y1; y++ {
+		py := float64(y) + 0.5
+		row := c.pix[y*c.w*4 : (y+1)*c.w*4]
+		for x := x0; x < x1; x++ {
+			pt := pixel.V(float64(x)+0.5, py)
+			w0, w1, w2 := edge(p1, p2, pt), edge(p2, p0, pt), edge(p0, p1, pt)
+			if w0 < 0 || w1 < 0 || w2 < 0 || w0 == 0 && !bias0 || w1 == 0 && !bias1 || w2 == 0 && !bias2 {
+				continue
+			}
+			w0, w1, w2 = w0/area, w1/area, w2/area
+
+			col := [4]float64{
+				(a.Color.R*w0 + b.Color.R*w1 + d.Color.R*w2) * mask.R,
+				(a.Color.G*w0 + b.Color.G*w1 + d.Color.G*w2) * mask.G,
+				(a.Color.B*w0 + b.Color.B*w1 + d.Color.B*w2) * mask.B,
+				(a.Color.A*w0 + b.Color.A*w1 + d.Color.A*w2) * mask.A,
+			}
+			if pic != nil {
+				intensity := a.Intensity*w0 + b.Intensity*w1 + d.Intensity*w2
+				if intensity != 0 {
+					u := a.Picture.X*w0 + b.Picture.X*w1 + d.Picture.X*w2
+					v := a.Picture.Y*w0 + b.Picture.Y*w1 + d.Picture.Y*w2
+					tex := pic.sample(u, v, c.smooth)
+					for k := range col {
+						col[k] = (1-intensity)*col[k] + intensity*col[k]*tex[k]
+					}
+				}
+			}
+			c.blend(row[x*4:x*4+4], col)
+		}
+	}
+}
+
+// blend composes a premultiplied colour onto a pixel
+func (c *Canvas) blend(dst []uint8, col [4]float64) {
+	if c.compose == pixel.ComposeOver {
+		inv := 1 - col[3]
+		for k := 0; k < 4; k++ {
+			dst[k] = clamp8(col[k]*255 + float64(dst[k])*inv)
+		}
+		return
+	}
+	src := pixel.RGBA{R: col[0], G: col[1], B: col[2], A: col[3]}
+	bg := pixel.RGBA{R: float64(dst[0]) / 255, G: float64(dst[1]) / 255, B: float64(dst[2]) / 255, A: float64(dst[3]) / 255}
+	out := c.compose.Compose(src, bg)
+	dst[0], dst[1], dst[2], dst[3] = clamp8(out.R*255), clamp8(out.G*255), clamp8(out.B*255), clamp8(out.A*255)
+}
+
+// edge is twice the signed area of a, b, p: positive when p is to the left
+// of a to b
+func edge(a, b, p pixel.Vec) float64 {
+	return (b.X-a.X)*(p.Y-a.Y) - (b.Y-a.Y)*(p.X-a.X)
+}
+
+// topLeft reports whether the edge a to b of a counter-clockwise triangle
+// (y up) is a top or left edge
+func topLeft(a, b pixel.Vec) bool {
+	return a.Y == b.Y && b.X < a.X || b.Y < a.Y
+}
+
+func premul(c pixel.RGBA) [4]uint8 {
+	return [4]uint8{clamp8(c.R * 255), clamp8(c.G * 255), clamp8(c.B * 255), clamp8(c.A * 255)}
+}
+
+func clamp8(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,163 @@
+package raster
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// fillTriangles draws triangles (three points each) in col
+func fillTriangles(c *Canvas, col pixel.RGBA, pts ...pixel.Vec) {
+	td := make(pixel.TrianglesData, len(pts))
+	for i, p := range pts {
+		td[i].Position = p
+		td[i].Color = col
+	}
+	c.MakeTriangles(&td).Draw()
+}
+
+// quad is a rectangle as two triangles sharing the diagonal
+func quad(x0, y0, x1, y1 float64) []pixel.Vec {
+	return []pixel.Vec{
+		pixel.V(x0, y0), pixel.V(x1, y0), pixel.V(x1, y1),
+		pixel.V(x0, y0), pixel.V(x1, y1), pixel.V(x0, y1),
+	}
+}
+
+func TestTopLeftRule(t *testing.T) {
+	// Translucent white shows how often each pixel was filled: 102 once, 163
+	// twice
+	tests := []struct {
+		name string
+		pts  []pixel.Vec
+		want [4]string // Alpha per pixel, top row first: . none, 1 once, 2 twice
+	}{
+		{
+			name: "whole canvas",
+			pts:  quad(0, 0, 4, 4),
+			want: [4]string{"1111", "1111", "1111", "1111"},
+		},
+		{
+			name: "edges through pixel centres",
+			pts:  quad(0.5, 0.5, 3.5, 3.5),
+			want: [4]string{"111.", "111.", "111.", "...."},
+		},
+		{
+			name: "neighbours sharing an edge through centres",
+			pts:  append(quad(0.5, 0.5, 2.5, 3.5), quad(2.5, 0.5, 3.5, 3.5)...),
+			want: [4]string{"111.", "111.", "111.", "...."},
+		},
+		{
+			name: "clockwise winding",
+			pts:  []pixel.Vec{pixel.V(0, 0), pixel.V(0, 4), pixel.V(4, 4), pixel.V(0, 0), pixel.V(4, 4), pixel.V(4, 0)},
+			want: [4]string{"1111", "1111", "1111", "1111"},
+		},
+		{
+			name: "fan around a shared vertex",
+			pts: []pixel.Vec{
+				pixel.V(2, 2), pixel.V(0, 0), pixel.V(4, 0),
+				pixel.V(2, 2), pixel.V(4, 0), pixel.V(4, 4),
+				pixel.V(2, 2), pixel.V(4, 4), pixel.V(0, 4),
+				pixel.V(2, 2), pixel.V(0, 4), pixel.V(0, 0),
+			},
+			want: [4]string{"1111", "1111", "1111", "1111"},
+		},
+		{
+			name: "overlap blends twice",
+			pts:  append(quad(0, 0, 2, 4), quad(1, 0, 3, 4)...),
+			want: [4]string{"121.", "121.", "121.", "121."},
+		},
+	}
+	alpha := map[byte]uint8{'.': 0, '1': 102, '2': 163}
+	for _, tt := range tests {
+		c := NewCanvas(pixel.R(0, 0, 4, 4))
+		fillTriangles(c, pixel.Alpha(0.4), tt.pts...)
+		for row, line := range tt.want {
+			y := 3 - row
+			for x := 0; x < 4; x++ {
+				if got, want := c.Pix()[(y*4+x)*4+3], alpha[line[x]]; got != want {
+					t.Errorf("%s: alpha at %d,%d = %d, want %d", tt.name, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestPixelsRoundTrip(t *testing.T) {
+	c := NewCanvas(pixel.R(-2, -1, 1, 1))
+	if b := c.Bounds(); b.W() != 3 || b.H() != 2 || len(c.Pixels()) != 3*2*4 {
+		t.Fatalf("canvas %v has %d bytes", b, len(c.Pixels()))
+	}
+	in := make([]uint8, 3*2*4)
+	for i := range in {
+		in[i] = uint8(i * 7)
+	}
+	c.SetPixels(in)
+	in[0] = 255 // SetPixels copies
+	got := c.Pixels()
+	for i := range got {
+		if want := uint8(i * 7); got[i] != want {
+			t.Fatalf("Pixels()[%d] = %d, want %d", i, got[i], want)
+		}
+	}
+	got[1] = 255 // Pixels is a copy
+	if c.Pix()[1] != 7 {
+		t.Errorf("changing the result of Pixels changed the canvas")
+	}
+	c.Pix()[2] = 99 // Pix is not
+	if c.Pixels()[2] != 99 {
+		t.Errorf("Pix does not alias the canvas")
+	}
+
+	// Bottom left pixel is the first, and Color reads it back unpremultiplied
+	if col := c.Color(pixel.V(-2, -1)); col.R != 0 || col.G != 7.0/255 {
+		t.Errorf("Color at the bottom left = %v", col)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("SetPixels with the wrong length did not panic")
+		}
+	}()
+	c.SetPixels(make([]uint8, 4))
+}
+
+func TestDrawColorMask(t *testing.T) {
+	src := NewCanvas(pixel.R(0, 0, 2, 2))
+	src.Clear(pixel.RGBA{R: 200.0 / 255, G: 100.0 / 255, B: 50.0 / 255, A: 1})
+	tests := []struct {
+		name string
+		bg   color.Color
+		mask color.Color
+		want [4]uint8
+	}{
+		{"no mask", color.Transparent, nil, [4]uint8{200, 100, 50, 255}},
+		{"half alpha", color.Transparent, pixel.Alpha(0.5), [4]uint8{100, 50, 25, 128}},
+		{"tint", color.Transparent, pixel.RGB(1, 0.5, 0), [4]uint8{200, 50, 0, 255}},
+		{"half alpha over blue", pixel.RGB(0, 0, 1), pixel.Alpha(0.5), [4]uint8{100, 50, 153, 255}},
+		{"opaque over blue", pixel.RGB(0, 0, 1), nil, [4]uint8{200, 100, 50, 255}},
+	}
+	for _, tt := range tests {
+		dst := NewCanvas(pixel.R(0, 0, 4, 4))
+		dst.Clear(tt.bg)
+		src.DrawColorMask(dst, pixel.IM.Moved(pixel.V(2, 2)), tt.mask) // Centred on the middle 2x2
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 4; x++ {
+				want := premul(pixel.ToRGBA(tt.bg))
+				if x >= 1 && x < 3 && y >= 1 && y < 3 {
+					want = tt.want
+				}
+				i := (y*4 + x) * 4
+				var got [4]uint8
+				copy(got[:], dst.Pix()[i:i+4])
+				for k := range got {
+					if d := int(got[k]) - int(want[k]); d < -1 || d > 1 {
+						t.Errorf("%s: pixel %d,%d = %v, want %v", tt.name, x, y, got, want)
+						break
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package pixelcanvas
+
+import "syscall/js"
+
+// Loader signals
+//
+// A page bootstrapped with package loader shows a progress bar while the
+// binary downloads and compiles. The app decides when it is actually ready
+// (assets loaded, first frame drawn), so the bar stays up until it calls
+// Ready, and it can report its own loading with Progress meanwhile. Without
+// the loader both just dispatch their "pixelcanvas:" events on the window.
+
+// Ready tells the page the app has started: the loader hides its progress
+// bar and resolves window.pixelcanvas.ready, and a "pixelcanvas:ready"
+// event is dispatched
+func Ready() {
+	if fn := loaderFunc("markReady"); fn.Truthy() {
+		fn.Invoke()
+		return
+	}
+	dispatchLoaderEvent("ready", map[string]interface{}{})
+}
+
+// Progress reports loading after the binary has started, e.g. while
+// fetching assets, to the loader's progress bar and as a
+// "pixelcanvas:progress" event. A total of 0 shows the bar as
+// indeterminate.
+func Progress(loaded, total int, stage string) {
+	if fn := loaderFunc("progress"); fn.Truthy() {
+		fn.Invoke(loaded, total, stage)
+		return
+	}
+	dispatchLoaderEvent("progress", map[string]interface{}{"loaded": loaded, "total": total, "stage": stage})
+}
+
+// loaderFunc returns the loader's window.pixelcanvas[name], or undefined
+func loaderFunc(name string) js.Value {
+	api := js.Global().Get("pixelcanvas")
+	if api.Type() != js.TypeObject || api.Get(name).Type() != js.TypeFunction {
+		return js.Undefined()
+	}
+	return api.Get(name)
+}
+
+func dispatchLoaderEvent(kind string, detail map[string]interface{}) {
+	ev := js.Global().Get("CustomEvent").New(embedPrefix+kind, map[string]interface{}{"detail": detail})
+	js.Global().Call("dispatchEvent", ev)
+}
@@ -0,0 +1,184 @@
+package pixelcanvas
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Region is a block of pixels lifted off the canvas, held in Go memory in the
+// same layout as the shadow canvas (premultiplied RGBA, row 0 at the bottom).
+type Region struct {
+	Width, Height int
+	Pix           []uint8
+}
+
+// NewRegion creates a transparent Region
+func NewRegion(width int, height int) *Region {
+	return &Region{Width: width, Height: height, Pix: make([]uint8, width*height*4)}
+}
+
+// Marquee returns the whole-pixel rectangle spanned by two drag points,
+// e.g. the pointer down and current pointer positions.
+func Marquee(a pixel.Vec, b pixel.Vec) pixel.Rect {
+	return pixel.R(
+		math.Floor(math.Min(a.X, b.X)), math.Floor(math.Min(a.Y, b.Y)),
+		math.Floor(math.Max(a.X, b.X))+1, math.Floor(math.Max(a.Y, b.Y))+1,
+	)
+}
+
+// Copy lifts the pixels in r (clamped to the canvas) into a Region
+func (c *Canvasp) Copy(r pixel.Rect) *Region {
+	x0, y0, x1, y1 := c.pixelRect(c.ToCanvasRect(r))
+	return &Region{Width: x1 - x0, Height: y1 - y0, Pix: cropRect(c.pixels(), c.width, x0, y0, x1, y1)}
+}
+
+// Cut is Copy that also clears the area to transparent
+func (c *Canvasp) Cut(r pixel.Rect) *Region {
+	x0, y0, x1, y1 := c.pixelRect(c.ToCanvasRect(r))
+	pix := c.pixels()
+	reg := &Region{Width: x1 - x0, Height: y1 - y0, Pix: cropRect(pix, c.width, x0, y0, x1, y1)}
+	pasteRect(pix, c.width, x0, y0, x1, y1, make([]uint8, len(reg.Pix)))
+	c.pixelsChanged(pix)
+	return reg
+}
+
+// CopyMask lifts the selected pixels of m. The Region covers the mask's
+// bounds, with unselected pixels left transparent.
+func (c *Canvasp) CopyMask(m *Mask) *Region {
+	if m.Empty() {
+		return NewRegion(0, 0)
+	}
+	x0, y0, x1, y1 := m.x0, m.y0, m.x1, m.y1
+	reg := &Region{Width: x1 - x0, Height: y1 - y0, Pix: cropRect(c.pixels(), c.width, x0, y0, x1, y1)}
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			if !m.Bits[y*m.Width+x] {
+				i := ((y-y0)*reg.Width + (x - x0)) * 4
+				reg.Pix[i], reg.Pix[i+1], reg.Pix[i+2], reg.Pix[i+3] = 0, 0, 0, 0
+			}
+		}
+	}
+	return reg
+}
+
+// Paste composites reg over the canvas with its bottom left corner (top
+// left with a top-left origin) at 'at'. Parts falling outside the canvas
+// are clipped. It returns the area changed.
+func (c *Canvasp) Paste(reg *Region, at pixel.Vec) pixel.Rect {
+	return c.FromCanvasRect(c.paste(reg, c.canvasCorner(at, float64(reg.Height)), true))
+}
+
+// PasteReplace is Paste that overwrites the destination, including with
+// transparent pixels, rather than blending.
+func (c *Canvasp) PasteReplace(reg *Region, at pixel.Vec) pixel.Rect {
+	return c.FromCanvasRect(c.paste(reg, c.canvasCorner(at, float64(reg.Height)), false))
+}
+
+// paste is Paste in shadow canvas pixels, at being the bottom left corner
+func (c *Canvasp) paste(reg *Region, at pixel.Vec, blend bool) pixel.Rect {
+	ox, oy := int(math.Floor(at.X)), int(math.Floor(at.Y))
+	x0, y0, x1, y1 := c.pixelRect(pixel.R(float64(ox), float64(oy), float64(ox+reg.Width), float64(oy+reg.Height)))
+	if x0 >= x1 || y0 >= y1 {
+		return pixel.Rect{}
+	}
+
+	pix := c.pixels()
+	for y := y0; y < y1; y++ {
+		src := reg.Pix[((y-oy)*reg.Width+(x0-ox))*4 : ((y-oy)*reg.Width+(x1-ox))*4]
+		dst := pix[(y*c.width+x0)*4 : (y*c.width+x1)*4]
+		if blend {
+			blendOver(dst, src)
+		} else {
+			copy(dst, src)
+		}
+	}
+	c.pixelsChanged(pix)
+	return intRect(x0, y0, x1, y1)
+}
+
+// FlipH returns a mirrored copy of the Region (left <-> right)
+func (r *Region) FlipH() *Region {
+	out := NewRegion(r.Width, r.Height)
+	for y := 0; y < r.Height; y++ {
+		for x := 0; x < r.Width; x++ {
+			copy(out.Pix[(y*r.Width+x)*4:], r.Pix[(y*r.Width+r.Width-1-x)*4:(y*r.Width+r.Width-x)*4])
+		}
+	}
+	return out
+}
+
+// FlipV returns an upside-down copy of the Region
+func (r *Region) FlipV() *Region {
+	out := NewRegion(r.Width, r.Height)
+	flipRows(out.Pix, r.Pix, r.Width*4, r.Height)
+	return out
+}
+
+// Rotate90 returns a copy rotated by 90 degrees, clockwise if cw is set.
+// Width and height are swapped.
+func (r *Region) Rotate90(cw bool) *Region {
+	out := NewRegion(r.Height, r.Width)
+	for y := 0; y < r.Height; y++ {
+		for x := 0; x < r.Width; x++ {
+			// y is up, so a clockwise turn takes (x, y) to (y, w-1-x)
+			nx, ny := y, r.Width-1-x
+			if !cw {
+				nx, ny = r.Height-1-y, x
+			}
+			copy(out.Pix[(ny*out.Width+nx)*4:], r.Pix[(y*r.Width+x)*4:(y*r.Width+x)*4+4])
+		}
+	}
+	return out
+}
+
+// Scale returns a copy resized to width x height with nearest-neighbour
+// sampling, which keeps pixel art crisp.
+func (r *Region) Scale(width int, height int) *Region {
+	out := NewRegion(width, height)
+	if r.Width == 0 || r.Height == 0 {
+		return out
+	}
+	for y := 0; y < height; y++ {
+		sy := y * r.Height / height
+		for x := 0; x < width; x++ {
+			sx := x * r.Width / width
+			copy(out.Pix[(y*width+x)*4:], r.Pix[(sy*r.Width+sx)*4:(sy*r.Width+sx)*4+4])
+		}
+	}
+	return out
+}
+
+// Image converts the Region to a top-down image.RGBA, e.g. for encoding or
+// handing to the system clipboard.
+func (r *Region) Image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, r.Width, r.Height))
+	flipRows(img.Pix, r.Pix, r.Width*4, r.Height)
+	return img
+}
+
+// RegionFromImage converts any image to a Region
+func RegionFromImage(img image.Image) *Region {
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	reg := NewRegion(b.Dx(), b.Dy())
+	flipRows(reg.Pix, rgba.Pix, reg.Width*4, reg.Height)
+	return reg
+}
+
+// regionFromStraight converts straight alpha, top-down RGBA (as in browser
+// ImageData) to a Region
+func regionFromStraight(raw []uint8, w int, h int) *Region {
+	reg := NewRegion(w, h)
+	flipRows(reg.Pix, raw, w*4, h)
+	for i := 0; i < len(reg.Pix); i += 4 {
+		a := uint32(reg.Pix[i+3])
+		reg.Pix[i] = uint8(uint32(reg.Pix[i]) * a / 255)
+		reg.Pix[i+1] = uint8(uint32(reg.Pix[i+1]) * a / 255)
+		reg.Pix[i+2] = uint8(uint32(reg.Pix[i+2]) * a / 255)
+	}
+	return reg
+}
@@ -0,0 +1,84 @@
+package pixelcanvas
+
+// ResizeMode controls what happens to the existing contents when the canvas
+// is resized
+type ResizeMode int
+
+// Resize modes
+const (
+	ResizeKeep  ResizeMode = iota // Keep contents at their size, anchored to the top left. The default
+	ResizeScale                   // Stretch contents to the new size (nearest neighbour)
+	ResizeClear                   // Start from a blank canvas
+)
+
+// SetResizeMode sets how Resize treats the existing contents
+func (c *Canvasp) SetResizeMode(mode ResizeMode) {
+	c.resizeMode = mode
+}
+
+// Resize changes the canvas resolution at runtime: the DOM canvas, its
+// ImageData, the copy buffer and the shadow canvas are all recreated, and
+// the old contents are carried over according to the ResizeMode.
+//
+// The RenderFunc receives the new shadow canvas from the next frame on.
+// Cameras created earlier need their size updated with Camera.SetSize.
+// Histories forget their steps, which no longer fit the canvas; other
+// state kept at the old size can follow with OnResize.
+func (c *Canvasp) Resize(width int, height int) {
+	if width == c.width && height == c.height {
+		return
+	}
+
+	ow, oh := c.width, c.height
+	var old []uint8
+	if c.resizeMode != ResizeClear && c.image != nil {
+		old = c.image.Pixels()
+	}
+
+	c.canvas.Set("width", width)
+	c.canvas.Set("height", height)
+	c.setSize(width, height)
+
+	if old != nil {
+		c.image.SetPixels(resizePixels(old, ow, oh, width, height, c.resizeMode))
+	}
+
+	for fn := range c.resizeHooks {
+		(*fn)(width, height)
+	}
+	c.log().Debug("canvas resized", "width", width, "height", height)
+}
+
+// resizePixels carries a buffer ow x oh pixels over to w x h, treating the
+// contents as Resize does the shadow canvas's in mode
+func resizePixels(pix []uint8, ow, oh, w, h int, mode ResizeMode) []uint8 {
+	switch mode {
+	case ResizeScale:
+		return (&Region{Width: ow, Height: oh, Pix: pix}).Scale(w, h).Pix
+	case ResizeClear:
+		return make([]uint8, w*h*4)
+	}
+	// Rows are stored bottom-up, so keeping the top edge in place means
+	// shifting every row by the change in height
+	out := make([]uint8, w*h*4)
+	n := minInt(ow, w) * 4
+	for y := 0; y < oh; y++ {
+		ny := y + h - oh
+		if ny < 0 || ny >= h {
+			continue
+		}
+		copy(out[ny*w*4:ny*w*4+n], pix[y*ow*4:y*ow*4+n])
+	}
+	return out
+}
+
+// OnResize calls fn with the new size after each Resize, once the contents
+// have been carried over. The returned func stops it.
+func (c *Canvasp) OnResize(fn func(width, height int)) func() {
+	if c.resizeHooks == nil {
+		c.resizeHooks = make(map[*func(width, height int)]struct{})
+	}
+	key := &fn
+	c.resizeHooks[key] = struct{}{}
+	return func() { delete(c.resizeHooks, key) }
+}
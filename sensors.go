@@ -0,0 +1,151 @@
+package pixelcanvas
+
+import (
+	"errors"
+	"syscall/js"
+)
+
+// Tilt is a DeviceOrientation reading in degrees
+type Tilt struct {
+	Alpha    float64 // Rotation around the z axis (compass heading), 0-360
+	Beta     float64 // Front/back tilt, -180-180
+	Gamma    float64 // Left/right tilt, -90-90
+	Absolute bool    // Alpha is relative to magnetic north rather than the start position
+}
+
+// Vec3 is a 3 axis sensor value
+type Vec3 struct {
+	X, Y, Z float64
+}
+
+// Motion is a DeviceMotion reading
+type Motion struct {
+	Acceleration Vec3    // m/s², excluding gravity (zero if the device can't separate it)
+	WithGravity  Vec3    // m/s², including gravity
+	RotationRate Tilt    // Degrees per second around each axis (Absolute unused)
+	Interval     float64 // Milliseconds between readings
+}
+
+// ErrMotionDenied is reported when the user refuses motion sensor access
+var ErrMotionDenied = errors.New("pixelcanvas: motion sensor permission denied")
+
+// NeedsMotionPermission reports whether the browser requires an explicit
+// permission request before delivering orientation or motion events (iOS 13+).
+func NeedsMotionPermission() bool {
+	doe := js.Global().Get("DeviceOrientationEvent")
+	return !doe.IsUndefined() && doe.Get("requestPermission").Type() == js.TypeFunction
+}
+
+// RequestMotionPermission asks for orientation and motion sensor access where
+// the browser requires it. Browsers only show the prompt in response to a
+// user gesture, so call this from a click or touch handler; done is called
+// later with the result. Where no permission is needed done gets nil at once.
+func (c *Canvasp) RequestMotionPermission(done func(err error)) {
+	if !NeedsMotionPermission() {
+		done(nil)
+		return
+	}
+
+	// The request itself must happen synchronously inside the gesture
+	orient := js.Global().Get("DeviceOrientationEvent").Call("requestPermission")
+	var motion js.Value
+	if dme := js.Global().Get("DeviceMotionEvent"); !dme.IsUndefined() && dme.Get("requestPermission").Type() == js.TypeFunction {
+		motion = dme.Call("requestPermission")
+	}
+
+	go func() {
+		err := permissionResult(orient)
+		if err == nil && !motion.IsUndefined() {
+			err = permissionResult(motion)
+		}
+		if err != nil {
+			c.log().Warn("motion permission not granted", "err", err)
+		}
+		done(err)
+	}()
+}
+
+func permissionResult(p js.Value) error {
+	state, err := await(p)
+	if err != nil {
+		return err
+	}
+	if state.String() != "granted" {
+		return ErrMotionDenied
+	}
+	return nil
+}
+
+// WatchTilt delivers DeviceOrientation readings to fn (which may be nil) and
+// keeps the latest for Tilt(). Prefers absolute orientation where available.
+func (c *Canvasp) WatchTilt(fn func(Tilt)) {
+	handle := func(e js.Value) {
+		t := Tilt{
+			Alpha:    jsFloat(e.Get("alpha"), 0),
+			Beta:     jsFloat(e.Get("beta"), 0),
+			Gamma:    jsFloat(e.Get("gamma"), 0),
+			Absolute: e.Get("absolute").Truthy(),
+		}
+		c.sensors.tilt = t
+		c.sensors.hasTilt = true
+		if fn != nil {
+			fn(t)
+		}
+	}
+
+	event := "deviceorientation"
+	if c.window.Get("ondeviceorientationabsolute").Type() != js.TypeUndefined { // Handler property is null when supported
+		event = "deviceorientationabsolute"
+	}
+	c.listen(c.window, event, handle)
+}
+
+// WatchMotion delivers DeviceMotion readings to fn (which may be nil) and
+// keeps the latest for Motion()
+func (c *Canvasp) WatchMotion(fn func(Motion)) {
+	c.listen(c.window, "devicemotion", func(e js.Value) {
+		m := Motion{
+			Acceleration: jsVec3(e.Get("acceleration")),
+			WithGravity:  jsVec3(e.Get("accelerationIncludingGravity")),
+			Interval:     jsFloat(e.Get("interval"), 0),
+		}
+		if rr := e.Get("rotationRate"); rr.Truthy() {
+			m.RotationRate = Tilt{
+				Alpha: jsFloat(rr.Get("alpha"), 0),
+				Beta:  jsFloat(rr.Get("beta"), 0),
+				Gamma: jsFloat(rr.Get("gamma"), 0),
+			}
+		}
+		c.sensors.motion = m
+		c.sensors.hasMotion = true
+		if fn != nil {
+			fn(m)
+		}
+	})
+}
+
+// Tilt returns the latest orientation reading, and false if none has arrived
+// yet (no sensor, no permission, or WatchTilt not called).
+func (c *Canvasp) Tilt() (Tilt, bool) {
+	return c.sensors.tilt, c.sensors.hasTilt
+}
+
+// Motion returns the latest motion reading, and false if none has arrived yet
+func (c *Canvasp) Motion() (Motion, bool) {
+	return c.sensors.motion, c.sensors.hasMotion
+}
+
+// sensorState holds the latest readings for polling from a RenderFunc
+type sensorState struct {
+	tilt      Tilt
+	hasTilt   bool
+	motion    Motion
+	hasMotion bool
+}
+
+func jsVec3(v js.Value) Vec3 {
+	if !v.Truthy() {
+		return Vec3{}
+	}
+	return Vec3{X: jsFloat(v.Get("x"), 0), Y: jsFloat(v.Get("y"), 0), Z: jsFloat(v.Get("z"), 0)}
+}
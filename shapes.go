@@ -0,0 +1,209 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"math"
+	"syscall/js"
+
+	"github.com/faiface/pixel"
+)
+
+// ShapeKind selects what a ShapeTool draws
+type ShapeKind int
+
+// Shape kinds
+const (
+	ShapeLine ShapeKind = iota
+	ShapeRect
+	ShapeEllipse
+)
+
+// Shape is a line, rectangle or ellipse between two points, in shadow
+// canvas (document) pixels
+type Shape struct {
+	Kind     ShapeKind
+	From, To pixel.Vec
+	Color    color.Color
+	Fill     bool
+}
+
+// Raster plots every pixel of the shape
+func (s Shape) Raster(plot func(x, y int)) {
+	a, b := pixelPoint(s.From), pixelPoint(s.To)
+	switch s.Kind {
+	case ShapeRect:
+		rasterRect(a, b, s.Fill, plot)
+	case ShapeEllipse:
+		rasterEllipse(a, b, s.Fill, plot)
+	default:
+		rasterLine(a, b, plot)
+	}
+}
+
+// ShapeTool draws shapes by dragging: a live preview is shown on an overlay
+// while the pointer is down, and the shape is committed to the shadow
+// canvas on release. Holding shift constrains lines to 45 degree steps and
+// rectangles and ellipses to squares and circles.
+type ShapeTool struct {
+	Kind  ShapeKind
+	Color color.Color
+	Fill  bool
+
+	// Camera, if set, is how the document is shown on the canvas: pointer
+	// positions are unprojected through it and the preview projected back.
+	Camera *Camera
+
+	History *History // If set, each committed shape is one undoable step
+
+	// OnCommit, if set, receives finished shapes instead of them being drawn
+	// on the shadow canvas, e.g. to draw onto a document layer
+	OnCommit func(s Shape)
+
+	c         *Canvasp
+	overlay   *Overlay
+	shape     Shape
+	active    bool
+	listeners []*listener
+}
+
+// NewShapeTool creates a ShapeTool with its preview overlay. Call Enable to
+// attach pointer handling, or drive it with Begin, Move and End.
+func (c *Canvasp) NewShapeTool(kind ShapeKind, col color.Color) *ShapeTool {
+	t := &ShapeTool{Kind: kind, Color: col, c: c}
+	t.overlay = c.AddOverlay(t.drawPreview)
+	return t
+}
+
+// Begin starts a shape at document point at
+func (t *ShapeTool) Begin(at pixel.Vec) {
+	t.active = true
+	t.shape = Shape{Kind: t.Kind, From: at, To: at, Color: t.Color, Fill: t.Fill}
+	t.overlay.Invalidate()
+}
+
+// Move drags the shape's end to document point at, constrained if asked
+func (t *ShapeTool) Move(at pixel.Vec, constrain bool) {
+	if !t.active {
+		return
+	}
+	if constrain {
+		at = constrainShape(t.Kind, t.shape.From, at)
+	}
+	t.shape.To = at
+	t.overlay.Invalidate()
+}
+
+// End commits the shape and clears the preview. It returns the shadow
+// canvas area changed, which is empty when OnCommit is set.
+func (t *ShapeTool) End() pixel.Rect {
+	if !t.active {
+		return pixel.Rect{}
+	}
+	t.active = false
+	t.overlay.Invalidate()
+
+	s := t.shape
+	if t.OnCommit != nil {
+		t.OnCommit(s)
+		return pixel.Rect{}
+	}
+	if t.History != nil {
+		t.History.Begin("shape")
+	}
+	r := t.c.plotShape(s.Color, s.Raster)
+	if t.History != nil {
+		t.History.CommitRect(r)
+	}
+	return r
+}
+
+// Cancel abandons the shape being dragged
+func (t *ShapeTool) Cancel() {
+	t.active = false
+	t.overlay.Invalidate()
+}
+
+// Active reports whether a shape is being dragged
+func (t *ShapeTool) Active() bool {
+	return t.active
+}
+
+// Enable attaches or detaches pointer handling on the canvas element
+func (t *ShapeTool) Enable(on bool) {
+	for _, l := range t.listeners {
+		t.c.unlisten(l)
+	}
+	t.listeners = nil
+	if !on {
+		t.Cancel()
+		return
+	}
+
+	c := t.c
+	t.listeners = append(t.listeners,
+		c.listen(c.canvas, "pointerdown", func(e js.Value) {
+			if e.Get("button").Int() != 0 {
+				return
+			}
+			e.Call("preventDefault")
+			c.canvas.Call("setPointerCapture", e.Get("pointerId"))
+			t.Begin(t.docPoint(e))
+		}),
+		c.listen(c.canvas, "pointermove", func(e js.Value) {
+			t.Move(t.docPoint(e), e.Get("shiftKey").Bool())
+		}),
+		c.listen(c.canvas, "pointerup", func(e js.Value) {
+			t.Move(t.docPoint(e), e.Get("shiftKey").Bool())
+			t.End()
+		}),
+		c.listen(c.canvas, "pointercancel", func(js.Value) {
+			t.Cancel()
+		}),
+	)
+}
+
+// Remove detaches the tool and its overlay
+func (t *ShapeTool) Remove() {
+	t.Enable(false)
+	t.c.RemoveOverlay(t.overlay)
+}
+
+// docPoint converts a pointer event to document coordinates: shadow canvas
+// pixels, or the camera's world
+func (t *ShapeTool) docPoint(e js.Value) pixel.Vec {
+	x, y := e.Get("clientX").Float(), e.Get("clientY").Float()
+	if t.Camera != nil {
+		return t.Camera.Unproject(t.c.FromClient(x, y))
+	}
+	return t.c.clientToCanvas(x, y)
+}
+
+// drawPreview paints the shape being dragged, each document pixel scaled
+// through the camera
+func (t *ShapeTool) drawPreview(o *Overlay) {
+	if !t.active {
+		return
+	}
+	col := t.shape.Color
+	t.shape.Raster(func(x, y int) {
+		if t.Camera == nil {
+			o.Set(x, y, col)
+			return
+		}
+		a := t.c.ToCanvas(t.Camera.Project(pixel.V(float64(x), float64(y))))
+		b := t.c.ToCanvas(t.Camera.Project(pixel.V(float64(x+1), float64(y+1))))
+		r := pixel.Rect{Min: a, Max: b}.Norm()
+		o.FillRect(int(math.Floor(r.Min.X)), int(math.Floor(r.Min.Y)), int(math.Ceil(r.Max.X)), int(math.Ceil(r.Max.Y)), col)
+	})
+}
+
+// constrainShape snaps lines to 45 degree steps and makes boxes square
+func constrainShape(kind ShapeKind, from, to pixel.Vec) pixel.Vec {
+	d := to.Sub(from)
+	if kind == ShapeLine {
+		angle := math.Round(d.Angle()/(math.Pi/4)) * (math.Pi / 4)
+		return from.Add(pixel.Unit(angle).Scaled(d.Len()))
+	}
+	side := math.Max(math.Abs(d.X), math.Abs(d.Y))
+	return from.Add(pixel.V(math.Copysign(side, d.X), math.Copysign(side, d.Y)))
+}
@@ -0,0 +1,272 @@
+package pixelcanvas
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"syscall/js"
+)
+
+// Compression selects how Serialize packs the pixel buffer
+type Compression uint8
+
+// Compression modes. RLE is cheap and works well on flat pixel art, Deflate is
+// slower but much smaller for photographic or noisy content.
+const (
+	CompressNone Compression = iota
+	CompressRLE
+	CompressDeflate
+)
+
+// Snapshot errors
+var (
+	ErrBadSnapshot  = errors.New("pixelcanvas: invalid or corrupt snapshot")
+	ErrSnapshotSize = errors.New("pixelcanvas: snapshot dimensions do not match the canvas")
+)
+
+var snapshotMagic = [4]byte{'P', 'X', 'C', '1'}
+
+// snapshot header: magic, compression, width, height
+const snapshotHeaderLen = 4 + 1 + 4 + 4
+
+// maxSnapshotPixels bounds the size a snapshot may claim before anything is
+// allocated for it: 16384 x 16384, the largest canvas browsers allow
+const maxSnapshotPixels = 1 << 28
+
+// Serialize snapshots the full shadow canvas together with its dimensions.
+// The result can be stored (e.g. in IndexedDB) or sent over the network, and
+// restored later with Deserialize.
+func (c *Canvasp) Serialize(comp Compression) ([]byte, error) {
+	return encodeSnapshot(c.pixels(), c.width, c.height, comp)
+}
+
+// SerializeJS is Serialize returning a JS Uint8Array, ready to hand to
+// IndexedDB, postMessage or WebSocket.send without further conversion.
+func (c *Canvasp) SerializeJS(comp Compression) (js.Value, error) {
+	data, err := c.Serialize(comp)
+	if err != nil {
+		return js.Undefined(), err
+	}
+	arr := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(arr, data)
+	return arr, nil
+}
+
+// Deserialize restores a snapshot made by Serialize onto the shadow canvas.
+// The snapshot must have the same dimensions as the canvas.
+func (c *Canvasp) Deserialize(data []byte) error {
+	pix, _, _, err := decodeSnapshot(data, c.width, c.height)
+	if err != nil {
+		return err
+	}
+	c.image.SetPixels(pix)
+	return nil
+}
+
+// layerDocMagic starts a serialized LayerDocument
+var layerDocMagic = [4]byte{'P', 'X', 'L', '1'}
+
+// MarshalBinary serializes the document's layers, their settings and the
+// active layer, each layer's pixels as an RLE snapshot. It implements
+// encoding.BinaryMarshaler, so a document can be autosaved directly.
+func (d *LayerDocument) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(layerDocMagic[:])
+	var u32 [4]byte
+	put := func(v int) {
+		binary.LittleEndian.PutUint32(u32[:], uint32(v))
+		buf.Write(u32[:])
+	}
+	put(len(d.Layers))
+	put(d.active)
+	for _, l := range d.Layers {
+		snap, err := encodeSnapshot(l.Pixels(), d.c.width, d.c.height, CompressRLE)
+		if err != nil {
+			return nil, err
+		}
+		put(len(l.Name))
+		buf.WriteString(l.Name)
+		var opacity [8]byte
+		binary.LittleEndian.PutUint64(opacity[:], math.Float64bits(l.Opacity))
+		buf.Write(opacity[:])
+		var flags byte
+		if l.Hidden {
+			flags |= 1
+		}
+		if l.Locked {
+			flags |= 2
+		}
+		buf.WriteByte(byte(l.Blend))
+		buf.WriteByte(flags)
+		put(len(snap))
+		buf.Write(snap)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary replaces the document's layers with those serialized by
+// MarshalBinary. The layers must be the size of the canvas.
+func (d *LayerDocument) UnmarshalBinary(data []byte) error {
+	if len(data) < 12 || !bytes.Equal(data[:4], layerDocMagic[:]) {
+		return ErrBadSnapshot
+	}
+	r := data[4:]
+	get := func() (int, bool) {
+		if len(r) < 4 {
+			return 0, false
+		}
+		v := int(binary.LittleEndian.Uint32(r))
+		r = r[4:]
+		return v, true
+	}
+	n, _ := get()
+	active, _ := get()
+	// Each layer takes at least its name length, settings, snapshot length
+	// and snapshot header, so a count the data can't hold is rejected
+	// before allocating for it
+	if n < 0 || n > len(r)/(4+10+4+snapshotHeaderLen) {
+		return ErrBadSnapshot
+	}
+	layers := make([]*Layer, 0, n)
+	for i := 0; i < n; i++ {
+		nameLen, ok := get()
+		if !ok || len(r) < nameLen+10 {
+			return ErrBadSnapshot
+		}
+		l := d.newLayer(string(r[:nameLen]))
+		r = r[nameLen:]
+		l.Opacity = math.Float64frombits(binary.LittleEndian.Uint64(r))
+		l.Blend = BlendMode(r[8])
+		flags := r[9]
+		r = r[10:]
+		size, ok := get()
+		if !ok || len(r) < size {
+			return ErrBadSnapshot
+		}
+		pix, _, _, err := decodeSnapshot(r[:size], d.c.width, d.c.height)
+		if err != nil {
+			return err
+		}
+		r = r[size:]
+		l.SetPixels(pix)
+		l.Hidden, l.Locked = flags&1 != 0, flags&2 != 0
+		layers = append(layers, l)
+	}
+	if len(layers) == 0 {
+		return ErrBadSnapshot
+	}
+	d.Layers = layers
+	d.active = clampInt(active, 0, len(layers)-1)
+	d.changed = true
+	return nil
+}
+
+// SnapshotSize returns the dimensions stored in a snapshot without decoding the pixels
+func SnapshotSize(data []byte) (width int, height int, err error) {
+	if len(data) < snapshotHeaderLen || !bytes.Equal(data[:4], snapshotMagic[:]) {
+		return 0, 0, ErrBadSnapshot
+	}
+	return int(binary.LittleEndian.Uint32(data[5:])), int(binary.LittleEndian.Uint32(data[9:])), nil
+}
+
+func encodeSnapshot(pix []uint8, width, height int, comp Compression) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(snapshotMagic[:])
+	buf.WriteByte(byte(comp))
+	var dims [8]byte
+	binary.LittleEndian.PutUint32(dims[0:], uint32(width))
+	binary.LittleEndian.PutUint32(dims[4:], uint32(height))
+	buf.Write(dims[:])
+
+	switch comp {
+	case CompressNone:
+		buf.Write(pix)
+	case CompressRLE:
+		rleEncode(&buf, pix)
+	case CompressDeflate:
+		fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fw.Write(pix); err != nil {
+			return nil, err
+		}
+		if err := fw.Close(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("pixelcanvas: unknown compression mode")
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeSnapshot decodes a snapshot, which must be wantW x wantH pixels, or
+// any size up to maxSnapshotPixels if both are 0. The size is checked before
+// anything is decoded.
+func decodeSnapshot(data []byte, wantW, wantH int) (pix []uint8, width int, height int, err error) {
+	width, height, err = SnapshotSize(data)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if wantW != 0 || wantH != 0 {
+		if width != wantW || height != wantH {
+			return nil, 0, 0, ErrSnapshotSize
+		}
+	} else if width < 0 || height < 0 || height != 0 && width > maxSnapshotPixels/height {
+		return nil, 0, 0, ErrBadSnapshot
+	}
+	size := width * height * 4
+	body := data[snapshotHeaderLen:]
+
+	switch Compression(data[4]) {
+	case CompressNone:
+		pix = body
+	case CompressRLE:
+		pix, err = rleDecode(body, size)
+	case CompressDeflate:
+		// One byte more than fits, so a body that inflates too far is
+		// caught below without inflating all of it
+		pix, err = ioutil.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(body)), int64(size)+1))
+	default:
+		err = ErrBadSnapshot
+	}
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if len(pix) != size {
+		return nil, 0, 0, ErrBadSnapshot
+	}
+	return pix, width, height, nil
+}
+
+// rleEncode writes runs of identical 4-byte pixels as a count byte (1-255)
+// followed by the pixel value.
+func rleEncode(buf *bytes.Buffer, pix []uint8) {
+	for i := 0; i+4 <= len(pix); {
+		run := 1
+		for run < 255 && i+run*4+4 <= len(pix) && bytes.Equal(pix[i:i+4], pix[i+run*4:i+run*4+4]) {
+			run++
+		}
+		buf.WriteByte(byte(run))
+		buf.Write(pix[i : i+4])
+		i += run * 4
+	}
+}
+
+func rleDecode(body []byte, size int) ([]uint8, error) {
+	pix := make([]uint8, 0, minInt(size, len(body)/5*255*4))
+	for i := 0; i+5 <= len(body); i += 5 {
+		run := int(body[i])
+		if run == 0 || len(pix)+run*4 > size {
+			return nil, ErrBadSnapshot
+		}
+		for r := 0; r < run; r++ {
+			pix = append(pix, body[i+1:i+5]...)
+		}
+	}
+	return pix, nil
+}
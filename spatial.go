@@ -0,0 +1,244 @@
+package pixelcanvas
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// SpatialHash buckets items into a uniform grid of square cells keyed to world
+// coordinates, so region and visibility queries only look at nearby items
+// instead of the whole scene.
+//
+// Items are used as map keys, so they must be comparable (pointers are ideal).
+type SpatialHash struct {
+	cellSize float64
+	cells    map[cellKey][]*spatialEntry
+	items    map[interface{}]*spatialEntry
+	large    []*spatialEntry // Items too big to link cell by cell, checked by every query
+	stamp    uint32          // Incremented per query, used to de-duplicate items spanning several cells
+}
+
+// Limits on linking an item into cells. Bigger items, or ones beyond the
+// cell coordinates an int can safely hold, go in the large list instead.
+const (
+	maxEntryCells = 1024
+	maxCellCoord  = 1 << 40
+)
+
+type cellKey struct {
+	x, y int
+}
+
+type spatialEntry struct {
+	item   interface{}
+	bounds pixel.Rect
+	stamp  uint32
+	large  bool // In SpatialHash.large rather than the cells
+}
+
+// NewSpatialHash creates a SpatialHash. cellSize should be roughly the size of
+// a typical item; much smaller wastes memory, much larger degrades queries.
+func NewSpatialHash(cellSize float64) *SpatialHash {
+	if cellSize <= 0 {
+		cellSize = 64
+	}
+	return &SpatialHash{
+		cellSize: cellSize,
+		cells:    make(map[cellKey][]*spatialEntry),
+		items:    make(map[interface{}]*spatialEntry),
+	}
+}
+
+// Len returns the number of items stored
+func (s *SpatialHash) Len() int {
+	return len(s.items)
+}
+
+// Insert adds item with the given world bounds. Inserting an item that is
+// already present moves it to the new bounds. Items spanning a great many
+// cells, or with infinite bounds, are kept aside and checked by every query.
+func (s *SpatialHash) Insert(item interface{}, bounds pixel.Rect) {
+	if e, ok := s.items[item]; ok {
+		s.unlink(e)
+		e.bounds = bounds.Norm()
+		s.link(e)
+		return
+	}
+
+	e := &spatialEntry{item: item, bounds: bounds.Norm()}
+	s.items[item] = e
+	s.link(e)
+}
+
+// Update moves an item to new bounds. It is an alias for Insert, kept for
+// readability in per-frame movement code.
+func (s *SpatialHash) Update(item interface{}, bounds pixel.Rect) {
+	s.Insert(item, bounds)
+}
+
+// Remove deletes item, returning false if it was not present
+func (s *SpatialHash) Remove(item interface{}) bool {
+	e, ok := s.items[item]
+	if !ok {
+		return false
+	}
+	s.unlink(e)
+	delete(s.items, item)
+	return true
+}
+
+// Bounds returns the bounds item was stored with
+func (s *SpatialHash) Bounds(item interface{}) (pixel.Rect, bool) {
+	e, ok := s.items[item]
+	if !ok {
+		return pixel.Rect{}, false
+	}
+	return e.bounds, true
+}
+
+// Clear removes every item
+func (s *SpatialHash) Clear() {
+	s.cells = make(map[cellKey][]*spatialEntry)
+	s.items = make(map[interface{}]*spatialEntry)
+	s.large = nil
+}
+
+// Query returns all items whose bounds intersect r
+func (s *SpatialHash) Query(r pixel.Rect) []interface{} {
+	return s.QueryAppend(nil, r)
+}
+
+// QueryAppend is Query that appends to dst, so per-frame callers can reuse a
+// slice. A rectangle covering more cells than are occupied, such as the view
+// of a camera zoomed far out, is answered by scanning the occupied cells
+// instead, so the cost is bounded by the items stored.
+func (s *SpatialHash) QueryAppend(dst []interface{}, r pixel.Rect) []interface{} {
+	r = r.Norm()
+	s.stamp++
+
+	visit := func(cell []*spatialEntry) {
+		for _, e := range cell {
+			if e.stamp == s.stamp {
+				continue
+			}
+			e.stamp = s.stamp
+			if overlaps(e.bounds, r) {
+				dst = append(dst, e.item)
+			}
+		}
+	}
+
+	visit(s.large)
+	x0, y0, x1, y1, n := s.cellSpan(r)
+	if n > float64(len(s.cells)) {
+		for _, cell := range s.cells {
+			visit(cell)
+		}
+		return dst
+	}
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			visit(s.cells[cellKey{x, y}])
+		}
+	}
+	return dst
+}
+
+// QueryPoint returns all items whose bounds contain v
+func (s *SpatialHash) QueryPoint(v pixel.Vec) []interface{} {
+	var dst []interface{}
+	find := func(cell []*spatialEntry) {
+		for _, e := range cell {
+			if e.bounds.Contains(v) {
+				dst = append(dst, e.item)
+			}
+		}
+	}
+	find(s.large)
+	if x, y, _, _, n := s.cellSpan(pixel.Rect{Min: v, Max: v}); n == 1 {
+		find(s.cells[cellKey{x, y}])
+	}
+	return dst
+}
+
+// Visible returns the items inside the camera's current view, for culling
+// sprites and tiles before drawing them. At zoom 0 the whole world is in
+// view, so every item is returned.
+func (s *SpatialHash) Visible(cam *Camera) []interface{} {
+	if cam.Zoom == 0 { // View would divide by zero
+		dst := make([]interface{}, 0, len(s.items))
+		for item := range s.items {
+			dst = append(dst, item)
+		}
+		return dst
+	}
+	return s.Query(cam.View())
+}
+
+func (s *SpatialHash) link(e *spatialEntry) {
+	x0, y0, x1, y1, n := s.cellSpan(e.bounds)
+	if e.large = n > maxEntryCells; e.large {
+		s.large = append(s.large, e)
+		return
+	}
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			k := cellKey{x, y}
+			s.cells[k] = append(s.cells[k], e)
+		}
+	}
+}
+
+func (s *SpatialHash) unlink(e *spatialEntry) {
+	if e.large {
+		for i, o := range s.large {
+			if o == e {
+				s.large[i] = s.large[len(s.large)-1]
+				s.large[len(s.large)-1] = nil
+				s.large = s.large[:len(s.large)-1]
+				break
+			}
+		}
+		return
+	}
+	x0, y0, x1, y1, _ := s.cellSpan(e.bounds)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			k := cellKey{x, y}
+			cell := s.cells[k]
+			for i, o := range cell {
+				if o == e {
+					cell[i] = cell[len(cell)-1]
+					cell[len(cell)-1] = nil
+					cell = cell[:len(cell)-1]
+					break
+				}
+			}
+			if len(cell) == 0 {
+				delete(s.cells, k)
+			} else {
+				s.cells[k] = cell
+			}
+		}
+	}
+}
+
+// cellSpan returns the range of cells r covers and how many there are. A
+// rectangle that is NaN, infinite or reaches beyond maxCellCoord counts as
+// infinitely many cells, and its range is left zero.
+func (s *SpatialHash) cellSpan(r pixel.Rect) (x0, y0, x1, y1 int, n float64) {
+	// In floats first, as a huge or infinite rectangle has cells beyond int
+	fx0, fy0 := math.Floor(r.Min.X/s.cellSize), math.Floor(r.Min.Y/s.cellSize)
+	fx1, fy1 := math.Floor(r.Max.X/s.cellSize), math.Floor(r.Max.Y/s.cellSize)
+	if !(fx0 >= -maxCellCoord && fy0 >= -maxCellCoord && fx1 <= maxCellCoord && fy1 <= maxCellCoord) {
+		return 0, 0, 0, 0, math.Inf(1)
+	}
+	return int(fx0), int(fy0), int(fx1), int(fy1), (fx1 - fx0 + 1) * (fy1 - fy0 + 1)
+}
+
+// overlaps is like pixel.Rect.Intersects but counts touching edges, so
+// zero-size items (points) are still found.
+func overlaps(a, b pixel.Rect) bool {
+	return a.Min.X <= b.Max.X && a.Max.X >= b.Min.X && a.Min.Y <= b.Max.Y && a.Max.Y >= b.Min.Y
+}
@@ -0,0 +1,240 @@
+package pixelcanvas
+
+import (
+	"image"
+	"time"
+
+	"github.com/faiface/pixel"
+)
+
+// SpriteSheet is a picture holding many sprite frames
+type SpriteSheet struct {
+	Picture pixel.Picture
+	Frames  []pixel.Rect
+}
+
+// NewSpriteSheet creates a sheet from an image and the rectangle of each
+// frame on it, in image coordinates (top-down), as returned by
+// AnimationDocument.SpriteSheet
+func NewSpriteSheet(img image.Image, rects []image.Rectangle) *SpriteSheet {
+	pic := pixel.PictureDataFromImage(img)
+	b := img.Bounds()
+	s := &SpriteSheet{Picture: pic, Frames: make([]pixel.Rect, len(rects))}
+	for i, r := range rects {
+		// Pictures are bottom-up
+		s.Frames[i] = pixel.R(
+			float64(r.Min.X), float64(b.Min.Y+b.Max.Y-r.Max.Y),
+			float64(r.Max.X), float64(b.Min.Y+b.Max.Y-r.Min.Y),
+		)
+	}
+	return s
+}
+
+// GridSpriteSheet cuts an image into frames of w x h pixels, left to right
+// then top to bottom, as sprite sheets are usually drawn
+func GridSpriteSheet(img image.Image, w, h int) *SpriteSheet {
+	b := img.Bounds()
+	var rects []image.Rectangle
+	for y := b.Min.Y; y+h <= b.Max.Y; y += h {
+		for x := b.Min.X; x+w <= b.Max.X; x += w {
+			rects = append(rects, image.Rect(x, y, x+w, y+h))
+		}
+	}
+	return NewSpriteSheet(img, rects)
+}
+
+// Sequence is a named run of sprite sheet frames
+type Sequence struct {
+	Name   string
+	Frames []int // Indexes into the sheet's Frames
+	FPS    float64
+	Loop   bool
+
+	// Next, if set, is the sequence to play when this one finishes, e.g.
+	// "attack" returning to "idle". Ignored for looping sequences.
+	Next string
+
+	// Events names frames to report through OnEvent when they are shown,
+	// e.g. a footstep sound on frame 2 of "walk"
+	Events map[int]string
+}
+
+// AnimationController plays named Sequences from a SpriteSheet. It is
+// advanced by the canvas's simulation time (see Delta), so animations keep
+// their speed however the frame rate is throttled and stand still while the
+// canvas is paused.
+type AnimationController struct {
+	Sheet *SpriteSheet
+	Speed float64 // Playback rate; 1 is normal, 0 freezes
+
+	// OnEvent is called with the sequence name and event when a frame with
+	// an event is shown
+	OnEvent func(seq, event string)
+
+	// OnFinish is called when a non-looping sequence reaches its end
+	OnFinish func(seq string)
+
+	c       *Canvasp
+	seqs    map[string]*Sequence
+	cur     *Sequence
+	queued  string
+	frame   int // Position in cur.Frames
+	elapsed time.Duration
+	done    bool
+	sprite  *pixel.Sprite
+}
+
+// NewAnimationController creates a controller for sheet, playing nothing
+// until Play is called
+func (c *Canvasp) NewAnimationController(sheet *SpriteSheet) *AnimationController {
+	return &AnimationController{
+		Sheet:  sheet,
+		Speed:  1,
+		c:      c,
+		seqs:   make(map[string]*Sequence),
+		sprite: pixel.NewSprite(sheet.Picture, pixel.Rect{}),
+	}
+}
+
+// Add registers sequences, replacing any with the same name
+func (a *AnimationController) Add(seqs ...Sequence) {
+	for i := range seqs {
+		s := seqs[i]
+		a.seqs[s.Name] = &s
+	}
+}
+
+// Play switches to the named sequence from its first frame. Playing the
+// sequence that is already playing carries on, so Play can be called every
+// frame from game state; use Restart to start over. It reports whether the
+// sequence exists.
+func (a *AnimationController) Play(name string) bool {
+	if a.cur != nil && a.cur.Name == name && !a.done {
+		return true
+	}
+	return a.Restart(name)
+}
+
+// Restart plays the named sequence from its first frame
+func (a *AnimationController) Restart(name string) bool {
+	s, ok := a.seqs[name]
+	if !ok || len(s.Frames) == 0 {
+		return false
+	}
+	a.cur, a.frame, a.elapsed, a.done, a.queued = s, 0, 0, false, ""
+	a.event()
+	return true
+}
+
+// Queue plays the named sequence when the current one next finishes or
+// completes a loop, for transitions that shouldn't cut an animation short
+func (a *AnimationController) Queue(name string) {
+	if a.cur == nil || a.done {
+		a.Play(name)
+		return
+	}
+	a.queued = name
+}
+
+// Current returns the name of the sequence playing, or "" for none
+func (a *AnimationController) Current() string {
+	if a.cur == nil {
+		return ""
+	}
+	return a.cur.Name
+}
+
+// Finished reports whether a non-looping sequence has reached its end
+func (a *AnimationController) Finished() bool {
+	return a.done
+}
+
+// Frame returns the sheet frame index being shown, or -1 for none
+func (a *AnimationController) Frame() int {
+	if a.cur == nil {
+		return -1
+	}
+	return a.cur.Frames[a.frame]
+}
+
+// Update advances the animation by the frame's Delta. Call it once per
+// frame from the RenderFunc.
+func (a *AnimationController) Update() {
+	a.Advance(time.Duration(float64(a.c.Delta()) * a.Speed))
+}
+
+// Advance moves the animation on by dt, firing the events of every frame
+// passed through
+func (a *AnimationController) Advance(dt time.Duration) {
+	if a.cur == nil || a.done || a.cur.FPS <= 0 || dt <= 0 {
+		return
+	}
+	a.elapsed += dt
+	for !a.done {
+		step := time.Duration(float64(time.Second) / a.cur.FPS)
+		if a.elapsed < step {
+			return
+		}
+		a.elapsed -= step
+		if a.frame+1 < len(a.cur.Frames) {
+			a.frame++
+			a.event()
+			continue
+		}
+		a.end()
+	}
+}
+
+// end handles reaching the last frame of the current sequence
+func (a *AnimationController) end() {
+	name := a.cur.Name
+	next := a.queued
+	if next == "" && !a.cur.Loop {
+		next = a.cur.Next
+	}
+	switch {
+	case next != "" && a.seqs[next] != nil:
+		elapsed := a.elapsed
+		if !a.cur.Loop && a.OnFinish != nil {
+			a.OnFinish(name)
+		}
+		a.Restart(next)
+		a.elapsed = elapsed
+	case a.cur.Loop:
+		a.frame = 0
+		a.event()
+	default:
+		a.done, a.elapsed = true, 0
+		if a.OnFinish != nil {
+			a.OnFinish(name)
+		}
+	}
+}
+
+// event reports the current frame's event, if it has one
+func (a *AnimationController) event() {
+	if a.OnEvent == nil {
+		return
+	}
+	if ev, ok := a.cur.Events[a.frame]; ok {
+		a.OnEvent(a.cur.Name, ev)
+	}
+}
+
+// Sprite returns a sprite showing the current frame. It is reused between
+// calls, so draw it before the animation next changes.
+func (a *AnimationController) Sprite() *pixel.Sprite {
+	if f := a.Frame(); f >= 0 && f < len(a.Sheet.Frames) {
+		a.sprite.Set(a.Sheet.Picture, a.Sheet.Frames[f])
+	}
+	return a.sprite
+}
+
+// Draw draws the current frame centred on the matrix origin, as sprites
+// are, e.g. onto the shadow canvas or a batch
+func (a *AnimationController) Draw(t pixel.Target, m pixel.Matrix) {
+	if a.Frame() < 0 {
+		return
+	}
+	a.Sprite().Draw(t, m)
+}
@@ -0,0 +1,257 @@
+package pixelcanvas
+
+import (
+	"math"
+	"sort"
+
+	"github.com/faiface/pixel"
+)
+
+// SpriteBatch blits many sprites from Region textures straight into the
+// shadow canvas buffer in one pass, instead of a pixelgl draw per sprite.
+// Sprites are queued with Add and drawn by Flush, sorted by layer and then
+// texture, with those entirely outside the view skipped. Each texture's
+// rows are classified once, so fully transparent rows cost nothing and
+// fully opaque rows are copied rather than blended.
+//
+// Positions go through Camera when it is set, as world coordinates;
+// otherwise they are shadow canvas pixels. Sprites are drawn centred on
+// their position, like pixel sprites, and scaled nearest neighbour by the
+// camera's zoom.
+type SpriteBatch struct {
+	Camera *Camera
+
+	c        *Canvasp
+	items    []batchSprite
+	textures map[*Region]*batchTexture
+
+	drawn, culled int
+}
+
+// batchSprite is a queued sprite
+type batchSprite struct {
+	tex            *batchTexture
+	x0, y0, x1, y1 int // Source rectangle on the texture
+	at             pixel.Vec
+	layer          int
+	seq            int // Add order, kept within a layer and texture
+}
+
+// batchTexture caches a texture's row classes
+type batchTexture struct {
+	reg  *Region
+	id   int
+	rows []rowClass
+}
+
+// rowClass describes a texture row's alpha
+type rowClass uint8
+
+const (
+	rowMixed rowClass = iota
+	rowClear
+	rowOpaque
+)
+
+// NewSpriteBatch creates an empty batch drawing onto c
+func (c *Canvasp) NewSpriteBatch(cam *Camera) *SpriteBatch {
+	return &SpriteBatch{Camera: cam, c: c, textures: make(map[*Region]*batchTexture)}
+}
+
+// Add queues the src rectangle of tex (the whole of it for an empty
+// rectangle), in the texture's pixels, to be drawn centred on at. Higher
+// layers draw over lower ones.
+func (b *SpriteBatch) Add(tex *Region, src pixel.Rect, at pixel.Vec, layer int) {
+	t := b.texture(tex)
+	s := batchSprite{tex: t, at: at, layer: layer, seq: len(b.items)}
+	if src.Area() == 0 {
+		s.x1, s.y1 = tex.Width, tex.Height
+	} else {
+		src = src.Norm()
+		s.x0, s.y0 = clampInt(int(src.Min.X), 0, tex.Width), clampInt(int(src.Min.Y), 0, tex.Height)
+		s.x1, s.y1 = clampInt(int(src.Max.X), 0, tex.Width), clampInt(int(src.Max.Y), 0, tex.Height)
+	}
+	if s.x0 < s.x1 && s.y0 < s.y1 {
+		b.items = append(b.items, s)
+	}
+}
+
+// Invalidate forgets what the batch knows about tex, after its pixels
+// have changed
+func (b *SpriteBatch) Invalidate(tex *Region) {
+	if t, ok := b.textures[tex]; ok {
+		t.rows = nil
+	}
+}
+
+// Len returns the number of sprites queued
+func (b *SpriteBatch) Len() int {
+	return len(b.items)
+}
+
+// Stats returns how many sprites the last Flush drew and culled
+func (b *SpriteBatch) Stats() (drawn, culled int) {
+	return b.drawn, b.culled
+}
+
+// Reset drops the queued sprites without drawing them
+func (b *SpriteBatch) Reset() {
+	b.items = b.items[:0]
+}
+
+// Flush draws the queued sprites and empties the queue, returning the area
+// of the canvas changed in shadow canvas pixels
+func (b *SpriteBatch) Flush() pixel.Rect {
+	defer b.Reset()
+	b.drawn, b.culled = 0, 0
+	if len(b.items) == 0 {
+		return pixel.Rect{}
+	}
+	sort.Slice(b.items, func(i, j int) bool {
+		p, q := &b.items[i], &b.items[j]
+		if p.layer != q.layer {
+			return p.layer < q.layer
+		}
+		if p.tex.id != q.tex.id {
+			return p.tex.id < q.tex.id
+		}
+		return p.seq < q.seq
+	})
+
+	w, h := b.c.width, b.c.height
+	m, zoom := pixel.IM, 1.0
+	if b.Camera != nil {
+		m, zoom = b.Camera.Matrix(), b.Camera.Zoom
+	}
+	pix := b.c.pixels()
+	dx0, dy0, dx1, dy1 := w, h, 0, 0
+	for i := range b.items {
+		s := &b.items[i]
+		sw, sh := float64(s.x1-s.x0)*zoom, float64(s.y1-s.y0)*zoom
+		centre := m.Project(s.at)
+		x0 := int(math.Floor(centre.X - sw/2 + 0.5))
+		y0 := int(math.Floor(centre.Y - sh/2 + 0.5))
+		x1, y1 := x0+int(math.Round(sw)), y0+int(math.Round(sh))
+		if x1 <= 0 || y1 <= 0 || x0 >= w || y0 >= h || x0 >= x1 || y0 >= y1 {
+			b.culled++
+			continue
+		}
+		if zoom == 1 {
+			b.blit(pix, s, x0, y0)
+		} else {
+			b.blitScaled(pix, s, x0, y0, x1, y1)
+		}
+		b.drawn++
+		dx0, dy0 = minInt(dx0, maxInt(x0, 0)), minInt(dy0, maxInt(y0, 0))
+		dx1, dy1 = maxInt(dx1, minInt(x1, w)), maxInt(dy1, minInt(y1, h))
+	}
+	if b.drawn == 0 {
+		return pixel.Rect{}
+	}
+	b.c.pixelsChanged(pix)
+	return intRect(dx0, dy0, dx1, dy1)
+}
+
+// blit draws a sprite at its own size with its bottom left at x0, y0
+func (b *SpriteBatch) blit(pix []uint8, s *batchSprite, x0, y0 int) {
+	w, h := b.c.width, b.c.height
+	tex := s.tex.reg
+
+	// Clip the source to the canvas
+	sx0, sx1 := s.x0, s.x1
+	if x0 < 0 {
+		sx0 -= x0
+		x0 = 0
+	}
+	if over := x0 + (sx1 - sx0) - w; over > 0 {
+		sx1 -= over
+	}
+	for sy := s.y0; sy < s.y1; sy++ {
+		y := y0 + sy - s.y0
+		if y < 0 || y >= h {
+			continue
+		}
+		class := s.tex.rows[sy]
+		if class == rowClear {
+			continue
+		}
+		src := tex.Pix[(sy*tex.Width+sx0)*4 : (sy*tex.Width+sx1)*4]
+		dst := pix[(y*w+x0)*4 : (y*w+x0)*4+len(src)]
+		if class == rowOpaque {
+			copy(dst, src)
+			continue
+		}
+		blendOver(dst, src)
+	}
+}
+
+// blitScaled draws a sprite stretched over [x0, x1) x [y0, y1), nearest
+// neighbour
+func (b *SpriteBatch) blitScaled(pix []uint8, s *batchSprite, x0, y0, x1, y1 int) {
+	w, h := b.c.width, b.c.height
+	tex := s.tex.reg
+	sw, sh := s.x1-s.x0, s.y1-s.y0
+	dw, dh := x1-x0, y1-y0
+	cx0, cx1 := maxInt(x0, 0), minInt(x1, w)
+	for y := maxInt(y0, 0); y < minInt(y1, h); y++ {
+		sy := s.y0 + (y-y0)*sh/dh
+		class := s.tex.rows[sy]
+		if class == rowClear {
+			continue
+		}
+		row := tex.Pix[sy*tex.Width*4 : (sy+1)*tex.Width*4]
+		dst := pix[y*w*4 : (y+1)*w*4]
+		for x := cx0; x < cx1; x++ {
+			sx := s.x0 + (x-x0)*sw/dw
+			p := row[sx*4 : sx*4+4]
+			if class == rowOpaque {
+				copy(dst[x*4:x*4+4], p)
+				continue
+			}
+			blendPixel(dst[x*4:x*4+4], p)
+		}
+	}
+}
+
+// texture returns the cached information for tex, classifying its rows if
+// needed
+func (b *SpriteBatch) texture(tex *Region) *batchTexture {
+	t, ok := b.textures[tex]
+	if !ok {
+		t = &batchTexture{reg: tex, id: len(b.textures)}
+		b.textures[tex] = t
+	}
+	if len(t.rows) != tex.Height {
+		t.rows = make([]rowClass, tex.Height)
+		for y := range t.rows {
+			t.rows[y] = classifyRow(tex.Pix[y*tex.Width*4 : (y+1)*tex.Width*4])
+		}
+	}
+	return t
+}
+
+// classifyRow reports whether a row is entirely transparent, entirely
+// opaque, or neither
+func classifyRow(row []uint8) rowClass {
+	clear, opaque := true, true
+	for i := 3; i < len(row); i += 4 {
+		switch row[i] {
+		case 0:
+			opaque = false
+		case 255:
+			clear = false
+		default:
+			return rowMixed
+		}
+		if !clear && !opaque {
+			return rowMixed
+		}
+	}
+	switch {
+	case clear:
+		return rowClear
+	case opaque:
+		return rowOpaque
+	}
+	return rowMixed
+}
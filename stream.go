@@ -0,0 +1,286 @@
+package pixelcanvas
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"syscall/js"
+)
+
+// Live data streams
+//
+// A Stream receives updates from a server-sent events endpoint
+// (EventSource) or a streaming fetch response, and queues them until the
+// render loop collects them with Drain, so handlers run between frames on
+// the loop's goroutine rather than inside browser callbacks. For dashboards
+// that redraw from the latest data the queue can be capped, dropping the
+// oldest updates when the loop falls behind.
+
+// DefaultStreamQueue is how many undrained events a Stream keeps
+const DefaultStreamQueue = 1024
+
+// StreamFormat is how a streaming fetch body is split into events
+type StreamFormat int
+
+// Stream formats
+const (
+	StreamLines StreamFormat = iota // One event per line, e.g. NDJSON
+	StreamSSE                       // The text/event-stream format EventSource uses
+)
+
+// StreamEvent is one update. For line streams only Data is set.
+type StreamEvent struct {
+	Event string // SSE event type, "message" by default
+	Data  string
+	ID    string
+}
+
+// Stream is an open event stream, see Subscribe and StreamFetch
+type Stream struct {
+	// MaxQueue caps the events waiting for Drain; older ones are dropped.
+	// Defaults to DefaultStreamQueue
+	MaxQueue int
+
+	// OnError, if set, is called (from the browser callback) when the
+	// connection fails. EventSource reconnects by itself afterwards; a
+	// streaming fetch does not.
+	OnError func(err error)
+
+	c       *Canvasp
+	mu      sync.Mutex
+	queue   []StreamEvent
+	dropped uint64
+	closed  bool
+
+	source    js.Value // EventSource
+	funcs     []js.Func
+	abort     js.Value // AbortController for a streaming fetch
+	lastEvent string   // Last SSE id, for resuming
+}
+
+// Subscribe opens an EventSource on url, queueing "message" events plus any
+// named event types given. The stream stays open until Close, or until the
+// canvas is destroyed.
+func (c *Canvasp) Subscribe(url string, events ...string) *Stream {
+	s := c.newStream()
+	s.source = js.Global().Get("EventSource").New(url)
+
+	on := func(kind string, fn func(e js.Value)) {
+		f := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			fn(args[0])
+			return nil
+		})
+		s.funcs = append(s.funcs, f)
+		s.source.Call("addEventListener", kind, f)
+	}
+	for _, kind := range append([]string{"message"}, events...) {
+		kind := kind
+		on(kind, func(e js.Value) {
+			s.push(StreamEvent{Event: kind, Data: e.Get("data").String(), ID: e.Get("lastEventId").String()})
+		})
+	}
+	on("error", func(js.Value) {
+		if s.OnError != nil {
+			s.OnError(fmt.Errorf("pixelcanvas: event stream %s failed", url))
+		}
+	})
+	c.log().Debug("subscribed", "url", url)
+	return s
+}
+
+// StreamFetch fetches url and reads the response body as it arrives,
+// splitting it into events by format. The stream ends when the response
+// does, or on Close or when the canvas is destroyed; Closed reports it.
+// Unlike EventSource this also works with plain line-delimited feeds.
+func (c *Canvasp) StreamFetch(url string, format StreamFormat) *Stream {
+	s := c.newStream()
+	s.abort = js.Global().Get("AbortController").New()
+
+	go func() {
+		defer s.finish()
+		opts := js.Global().Get("Object").New()
+		opts.Set("signal", s.abort.Get("signal"))
+		resp, err := await(js.Global().Call("fetch", url, opts))
+		if err == nil && !resp.Get("ok").Bool() {
+			err = fmt.Errorf("pixelcanvas: fetch %s: %d %s", url, resp.Get("status").Int(), resp.Get("statusText").String())
+		}
+		if err != nil {
+			s.fail(err)
+			return
+		}
+
+		reader := resp.Get("body").Call("getReader")
+		decoder := js.Global().Get("TextDecoder").New()
+		decodeOpts := js.Global().Get("Object").New()
+		decodeOpts.Set("stream", true)
+		var buf strings.Builder
+		var sse StreamEvent
+		for {
+			chunk, err := await(reader.Call("read"))
+			if err != nil {
+				s.fail(err)
+				return
+			}
+			if chunk.Get("done").Bool() {
+				break
+			}
+			buf.WriteString(decoder.Call("decode", chunk.Get("value"), decodeOpts).String())
+
+			// Hand over every complete line, keeping any partial one
+			text := buf.String()
+			end := strings.LastIndexByte(text, '\n')
+			if end < 0 {
+				continue
+			}
+			buf.Reset()
+			buf.WriteString(text[end+1:])
+			for _, line := range strings.Split(text[:end], "\n") {
+				line = strings.TrimSuffix(line, "\r")
+				if format == StreamLines {
+					if line != "" {
+						s.push(StreamEvent{Event: "message", Data: line})
+					}
+					continue
+				}
+				s.sseLine(line, &sse)
+			}
+		}
+		if rest := strings.TrimSpace(buf.String()); rest != "" && format == StreamLines {
+			s.push(StreamEvent{Event: "message", Data: rest})
+		}
+	}()
+	return s
+}
+
+// newStream creates a Stream tracked by the canvas, so shutdown closes it
+func (c *Canvasp) newStream() *Stream {
+	s := &Stream{MaxQueue: DefaultStreamQueue, c: c}
+	if c.streams == nil {
+		c.streams = make(map[*Stream]struct{})
+	}
+	c.streams[s] = struct{}{}
+	return s
+}
+
+// closeStreams closes every open stream
+func (c *Canvasp) closeStreams() {
+	for s := range c.streams {
+		s.Close()
+	}
+}
+
+// sseLine feeds one line of an event stream into ev, queueing the event
+// when a blank line ends it
+func (s *Stream) sseLine(line string, ev *StreamEvent) {
+	if line == "" {
+		if ev.Data != "" {
+			ev.Data = strings.TrimSuffix(ev.Data, "\n")
+			if ev.Event == "" {
+				ev.Event = "message"
+			}
+			if ev.ID == "" {
+				ev.ID = s.lastEvent
+			}
+			s.lastEvent = ev.ID
+			s.push(*ev)
+		}
+		*ev = StreamEvent{}
+		return
+	}
+	if line[0] == ':' {
+		return // Comment, often a keep-alive
+	}
+	field, value := line, ""
+	if i := strings.IndexByte(line, ':'); i >= 0 {
+		field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+	}
+	switch field {
+	case "event":
+		ev.Event = value
+	case "data":
+		ev.Data += value + "\n"
+	case "id":
+		ev.ID = value
+	}
+}
+
+// Drain calls fn with every queued event, oldest first, and empties the
+// queue. Call it from the RenderFunc.
+func (s *Stream) Drain(fn func(e StreamEvent)) {
+	s.mu.Lock()
+	q := s.queue
+	s.queue = nil
+	s.mu.Unlock()
+	for _, e := range q {
+		fn(e)
+	}
+}
+
+// Len returns the number of queued events
+func (s *Stream) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// Dropped returns how many events were dropped because the queue was full
+func (s *Stream) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Closed reports whether the stream has ended. Queued events can still be
+// drained.
+func (s *Stream) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// Close ends the stream and releases its callbacks
+func (s *Stream) Close() {
+	if !s.source.IsUndefined() {
+		s.source.Call("close")
+		for _, f := range s.funcs {
+			f.Release()
+		}
+		s.funcs = nil
+		s.source = js.Undefined()
+	}
+	if !s.abort.IsUndefined() {
+		s.abort.Call("abort")
+	}
+	s.finish()
+}
+
+func (s *Stream) push(e StreamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	max := s.MaxQueue
+	if max <= 0 {
+		max = DefaultStreamQueue
+	}
+	if len(s.queue) >= max {
+		n := len(s.queue) - max + 1
+		s.queue = append(s.queue[:0], s.queue[n:]...)
+		s.dropped += uint64(n)
+	}
+	s.queue = append(s.queue, e)
+}
+
+func (s *Stream) fail(err error) {
+	if s.Closed() {
+		return // Aborted by Close
+	}
+	if s.OnError != nil {
+		s.OnError(err)
+	}
+}
+
+func (s *Stream) finish() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	delete(s.c.streams, s)
+}
@@ -0,0 +1,123 @@
+package pixelcanvas
+
+import (
+	"errors"
+	"syscall/js"
+)
+
+// ErrNoElement is returned when a parent element to move the canvas into
+// can't be found
+var ErrNoElement = errors.New("pixelcanvas: element not found")
+
+// Element returns the canvas DOM element, for anything the styling helpers
+// don't cover
+func (c *Canvasp) Element() js.Value {
+	return c.canvas
+}
+
+// SetStyle sets a CSS property on the canvas element, by its CSS name (e.g.
+// "image-rendering"). An empty value removes the property.
+func (c *Canvasp) SetStyle(property string, value string) {
+	style := c.canvas.Get("style")
+	if value == "" {
+		style.Call("removeProperty", property)
+		return
+	}
+	style.Call("setProperty", property, value)
+}
+
+// Style returns the CSS property set on the canvas element, or "" if none
+// is set inline
+func (c *Canvasp) Style(property string) string {
+	return c.canvas.Get("style").Call("getPropertyValue", property).String()
+}
+
+// SetPosition positions the canvas with CSS: position is "absolute",
+// "fixed", "relative" or "sticky", and x, y are its left and top offsets in
+// CSS pixels. "static" (or "") puts it back in the normal flow and ignores
+// x and y.
+func (c *Canvasp) SetPosition(position string, x, y float64) {
+	if position == "" || position == "static" {
+		c.SetStyle("position", "")
+		c.SetStyle("left", "")
+		c.SetStyle("top", "")
+		return
+	}
+	c.SetStyle("position", position)
+	c.SetStyle("left", cssNum(x)+"px")
+	c.SetStyle("top", cssNum(y)+"px")
+}
+
+// SetZIndex sets the canvas's CSS stacking order. It only takes effect on a
+// positioned canvas, see SetPosition.
+func (c *Canvasp) SetZIndex(z int) {
+	c.canvas.Get("style").Set("zIndex", z)
+}
+
+// SetDisplaySize sets the size the canvas is shown at, in CSS pixels,
+// independently of its resolution: the browser scales the pixels to fit.
+// Zero for either dimension leaves it to follow the resolution (or the
+// other dimension, keeping the aspect ratio).
+func (c *Canvasp) SetDisplaySize(width, height float64) {
+	size := func(property string, v float64) {
+		if v <= 0 {
+			c.SetStyle(property, "")
+			return
+		}
+		c.SetStyle(property, cssNum(v)+"px")
+	}
+	size("width", width)
+	size("height", height)
+}
+
+// SetPixelated chooses nearest neighbour scaling when the canvas is shown
+// larger than its resolution, keeping pixel art crisp, rather than the
+// browser's default smoothing
+func (c *Canvasp) SetPixelated(on bool) {
+	if on {
+		c.SetStyle("image-rendering", "pixelated")
+		return
+	}
+	c.SetStyle("image-rendering", "")
+}
+
+// SetCSSFilter applies a CSS filter to the displayed canvas (e.g.
+// "blur(2px) grayscale(1)"). The browser applies it when compositing, so it
+// costs nothing on the copy path and doesn't touch the pixels. "" removes it.
+func (c *Canvasp) SetCSSFilter(filter string) {
+	c.SetStyle("filter", filter)
+}
+
+// SetPointerEvents chooses whether the canvas receives pointer events. With
+// on false clicks and touches pass through to the page beneath, e.g. for a
+// decorative overlay.
+func (c *Canvasp) SetPointerEvents(on bool) {
+	if on {
+		c.SetStyle("pointer-events", "")
+		return
+	}
+	c.SetStyle("pointer-events", "none")
+}
+
+// SetClass replaces the canvas element's CSS classes, so it can be styled
+// from the page's stylesheet instead
+func (c *Canvasp) SetClass(class string) {
+	c.canvas.Set("className", class)
+}
+
+// MoveTo moves the canvas element into parent, as its last child. Event
+// listeners, contents and the running loop are unaffected.
+func (c *Canvasp) MoveTo(parent js.Value) error {
+	if parent.IsUndefined() || parent.IsNull() {
+		return ErrNoElement
+	}
+	parent.Call("appendChild", c.canvas)
+	c.log().Debug("canvas moved")
+	return nil
+}
+
+// MoveToSelector moves the canvas element into the first element matching
+// a CSS selector, e.g. "#game"
+func (c *Canvasp) MoveToSelector(selector string) error {
+	return c.MoveTo(c.doc.Call("querySelector", selector))
+}
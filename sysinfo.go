@@ -0,0 +1,136 @@
+package pixelcanvas
+
+import (
+	"syscall/js"
+)
+
+// SystemInfo reports device conditions an app can use to pick quality
+// settings. Fields the browser doesn't expose are left at their zero value
+// with the matching Has flag false.
+type SystemInfo struct {
+	HasBattery   bool
+	BatteryLevel float64 // 0-1
+	Charging     bool
+
+	HasNetwork bool
+	Network    string  // Effective connection type: "slow-2g", "2g", "3g" or "4g"
+	Downlink   float64 // Estimated bandwidth in Mbit/s
+	RTT        float64 // Estimated round trip in ms
+	SaveData   bool    // User has asked for reduced data usage
+
+	HasMemory   bool
+	MemoryUsed  float64 // JS heap in use, bytes (Chromium only)
+	MemoryLimit float64 // JS heap limit, bytes
+
+	Cores        int     // navigator.hardwareConcurrency
+	DeviceMemory float64 // Approximate device RAM in GB, 0 if unknown
+}
+
+// LowPower reports whether the device is running on a low battery
+func (s SystemInfo) LowPower() bool {
+	return s.HasBattery && !s.Charging && s.BatteryLevel < 0.2
+}
+
+// SuggestedFPS scales maxFPS down for constrained devices: halved on low
+// battery, and capped at 30 when data saving is on or the device has very
+// few cores or little memory.
+func (s SystemInfo) SuggestedFPS(maxFPS float64) float64 {
+	fps := maxFPS
+	if s.LowPower() {
+		fps /= 2
+	}
+	constrained := s.SaveData || (s.Cores > 0 && s.Cores <= 2) || (s.DeviceMemory > 0 && s.DeviceMemory < 2)
+	if constrained && fps > 30 {
+		fps = 30
+	}
+	return fps
+}
+
+type systemWatch struct {
+	battery js.Value // BatteryManager once getBattery resolves
+	fns     []func(SystemInfo)
+	started bool
+}
+
+// SystemInfo returns the current device conditions. Battery information
+// becomes available once WatchSystemInfo has been called and the browser
+// has answered the (asynchronous) battery query.
+func (c *Canvasp) SystemInfo() SystemInfo {
+	var s SystemInfo
+	nav := js.Global().Get("navigator")
+
+	if b := c.system.battery; !b.IsUndefined() {
+		s.HasBattery = true
+		s.BatteryLevel = jsFloat(b.Get("level"), 1)
+		s.Charging = b.Get("charging").Truthy()
+	}
+
+	if conn := nav.Get("connection"); !conn.IsUndefined() {
+		s.HasNetwork = true
+		if et := conn.Get("effectiveType"); et.Type() == js.TypeString {
+			s.Network = et.String()
+		}
+		s.Downlink = jsFloat(conn.Get("downlink"), 0)
+		s.RTT = jsFloat(conn.Get("rtt"), 0)
+		s.SaveData = conn.Get("saveData").Truthy()
+	}
+
+	if mem := js.Global().Get("performance").Get("memory"); !mem.IsUndefined() {
+		s.HasMemory = true
+		s.MemoryUsed = jsFloat(mem.Get("usedJSHeapSize"), 0)
+		s.MemoryLimit = jsFloat(mem.Get("jsHeapSizeLimit"), 0)
+	}
+
+	s.Cores = int(jsFloat(nav.Get("hardwareConcurrency"), 0))
+	s.DeviceMemory = jsFloat(nav.Get("deviceMemory"), 0)
+	return s
+}
+
+// WatchSystemInfo calls fn with fresh SystemInfo whenever the battery or
+// network conditions change, and once battery information first arrives.
+func (c *Canvasp) WatchSystemInfo(fn func(SystemInfo)) {
+	c.system.fns = append(c.system.fns, fn)
+	if c.system.started {
+		return
+	}
+	c.system.started = true
+
+	notify := func(js.Value) {
+		info := c.SystemInfo()
+		for _, f := range c.system.fns {
+			f(info)
+		}
+	}
+
+	if conn := js.Global().Get("navigator").Get("connection"); !conn.IsUndefined() {
+		c.listen(conn, "change", notify)
+	}
+
+	getBattery := js.Global().Get("navigator").Get("getBattery")
+	if getBattery.Type() != js.TypeFunction {
+		return
+	}
+	p := js.Global().Get("navigator").Call("getBattery")
+	go func() {
+		b, err := await(p)
+		if err != nil {
+			c.log().Debug("battery status unavailable", "err", err)
+			return
+		}
+		c.system.battery = b
+		c.listen(b, "levelchange", notify)
+		c.listen(b, "chargingchange", notify)
+		notify(js.Undefined())
+	}()
+}
+
+// AdaptFPS keeps the frame rate at SystemInfo.SuggestedFPS(maxFPS), updating
+// it as battery and network conditions change.
+func (c *Canvasp) AdaptFPS(maxFPS float64) {
+	c.SetFPS(c.SystemInfo().SuggestedFPS(maxFPS))
+	c.WatchSystemInfo(func(s SystemInfo) {
+		fps := s.SuggestedFPS(maxFPS)
+		c.log().Debug("adapting frame rate", "fps", fps)
+		c.SetFPS(fps)
+	})
+}
@@ -0,0 +1,73 @@
+package pixelcanvas
+
+import (
+	"image/color"
+
+	"github.com/faiface/pixel"
+)
+
+// RenderTarget is an offscreen Canvas, used to cache expensive static
+// layers (backgrounds, tile layers, UI chrome) that are then composited into
+// the frame cheaply. All Canvas methods are available on it.
+type RenderTarget struct {
+	*Canvas
+
+	redraw func(gc *Canvas)
+	valid  bool
+}
+
+// NewRenderTarget creates a transparent RenderTarget of the given size
+func NewRenderTarget(width int, height int) *RenderTarget {
+	return &RenderTarget{Canvas: newCanvas(pixel.R(0, 0, float64(width), float64(height)))}
+}
+
+// NewRenderTarget creates a RenderTarget the same size as the canvas
+func (c *Canvasp) NewRenderTarget() *RenderTarget {
+	return NewRenderTarget(c.width, c.height)
+}
+
+// Cache sets the function that paints this target. It is run lazily, the
+// first time the target is drawn and again after each Invalidate.
+func (t *RenderTarget) Cache(redraw func(gc *Canvas)) {
+	t.redraw = redraw
+	t.valid = false
+}
+
+// Invalidate marks the cached contents as stale, so they are repainted
+// before the next draw.
+func (t *RenderTarget) Invalidate() {
+	t.valid = false
+}
+
+// Refresh repaints the target now if it has been invalidated
+func (t *RenderTarget) Refresh() {
+	if t.valid || t.redraw == nil {
+		return
+	}
+	t.Clear(color.Transparent)
+	t.redraw(t.Canvas)
+	t.valid = true
+}
+
+// DrawAt draws the target onto dst with its bottom left corner at 'at',
+// scaled by 'scale' and multiplied by 'tint' (nil for no tint).
+func (t *RenderTarget) DrawAt(dst pixel.Target, at pixel.Vec, scale float64, tint color.Color) {
+	t.Refresh()
+	size := t.Bounds().Size().Scaled(scale)
+	t.DrawColorMask(dst, pixel.IM.Scaled(pixel.ZV, scale).Moved(at.Add(size.Scaled(0.5))), tint)
+}
+
+// DrawInto draws the target stretched to fill rect r of dst, tinted by 'tint'
+// (nil for no tint).
+func (t *RenderTarget) DrawInto(dst pixel.Target, r pixel.Rect, tint color.Color) {
+	t.Refresh()
+	b := t.Bounds()
+	m := pixel.IM.ScaledXY(pixel.ZV, pixel.V(r.W()/b.W(), r.H()/b.H())).Moved(r.Center())
+	t.DrawColorMask(dst, m, tint)
+}
+
+// Composite draws a RenderTarget onto the shadow canvas at 'at', scaled and
+// tinted, placing it as Paste does
+func (c *Canvasp) Composite(t *RenderTarget, at pixel.Vec, scale float64, tint color.Color) {
+	t.DrawAt(c.image, c.canvasCorner(at, t.Bounds().H()*scale), scale, tint)
+}
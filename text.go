@@ -0,0 +1,151 @@
+package pixelcanvas
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"syscall/js"
+
+	"github.com/faiface/pixel"
+)
+
+// Browser text
+//
+// Bitmap fonts only cover the glyphs they ship with and can't do complex
+// shaping. These helpers hand text to the browser's own text engine instead,
+// drawing it with fillText on an offscreen 2D canvas and importing the result
+// as pixels, so Arabic, Indic scripts, CJK, emoji and anything else the
+// browser's fonts cover come out shaped and ordered correctly.
+
+// DefaultFont is the CSS font used when a TextStyle doesn't give one
+const DefaultFont = "16px sans-serif"
+
+// TextStyle describes how browser text is drawn
+type TextStyle struct {
+	Font      string      // CSS font shorthand, e.g. "bold 24px 'Noto Sans JP'". "" for DefaultFont
+	Color     color.Color // nil for black
+	Direction string      // "ltr", "rtl" or "" to let the browser decide from the text
+	Smooth    bool        // Keep antialiasing. Off snaps glyph edges to whole pixels, for pixel art
+}
+
+// TextMetrics is the size of a run of text, in pixels, relative to the pen
+// position on the baseline at its start
+type TextMetrics struct {
+	Advance float64 // Distance to move the pen for the next run
+	Left    float64 // Ink extent left of the pen (positive for overhanging glyphs)
+	Right   float64 // Ink extent right of the pen
+	Ascent  float64 // Ink extent above the baseline
+	Descent float64 // Ink extent below the baseline
+
+	LineAscent  float64 // The font's ascent, the same for any text
+	LineDescent float64 // The font's descent
+}
+
+// LineHeight is the font's ascent plus descent, the spacing for lines of text
+func (m TextMetrics) LineHeight() float64 {
+	return m.LineAscent + m.LineDescent
+}
+
+// MeasureText measures s as it would be drawn in style
+func (c *Canvasp) MeasureText(s string, style TextStyle) TextMetrics {
+	ctx := c.textContext(style)
+	m := ctx.Call("measureText", s)
+	tm := TextMetrics{
+		Advance: m.Get("width").Float(),
+		Left:    jsFloat(m.Get("actualBoundingBoxLeft"), 0),
+		Right:   jsFloat(m.Get("actualBoundingBoxRight"), m.Get("width").Float()),
+		Ascent:  jsFloat(m.Get("actualBoundingBoxAscent"), 0),
+		Descent: jsFloat(m.Get("actualBoundingBoxDescent"), 0),
+	}
+	tm.LineAscent = jsFloat(m.Get("fontBoundingBoxAscent"), tm.Ascent)
+	tm.LineDescent = jsFloat(m.Get("fontBoundingBoxDescent"), tm.Descent)
+	return tm
+}
+
+// RenderText draws s with the browser into a new Region just big enough for
+// its ink. offset is where the Region's bottom left corner goes relative to
+// the pen position, so the text sits on the baseline when pasted at
+// pen.Add(offset). An empty or invisible string gives an empty Region.
+func (c *Canvasp) RenderText(s string, style TextStyle) (reg *Region, offset pixel.Vec) {
+	m := c.MeasureText(s, style)
+	left := int(math.Ceil(m.Left))
+	w := left + int(math.Ceil(m.Right))
+	asc := int(math.Ceil(m.Ascent))
+	h := asc + int(math.Ceil(m.Descent))
+	if w <= 0 || h <= 0 {
+		return NewRegion(0, 0), pixel.ZV
+	}
+
+	canvas := c.textCtx.Get("canvas")
+	if canvas.Get("width").Int() < w || canvas.Get("height").Int() < h {
+		canvas.Set("width", maxInt(w, canvas.Get("width").Int()))
+		canvas.Set("height", maxInt(h, canvas.Get("height").Int()))
+	}
+	ctx := c.textContext(style) // Resizing reset the context state
+	ctx.Call("clearRect", 0, 0, w, h)
+	ctx.Call("fillText", s, left, asc)
+
+	raw := contextPixels(ctx, w, h)
+	if !style.Smooth {
+		for i := 3; i < len(raw); i += 4 {
+			if raw[i] < 128 {
+				raw[i] = 0
+			} else {
+				raw[i] = 255
+			}
+		}
+	}
+	return regionFromStraight(raw, w, h), pixel.V(float64(-left), float64(asc-h))
+}
+
+// DrawText draws s onto the shadow canvas with the pen at 'at' on the
+// baseline, blending over the existing contents. It returns the area
+// changed; the pen's next position is at plus MeasureText's Advance.
+func (c *Canvasp) DrawText(s string, at pixel.Vec, style TextStyle) pixel.Rect {
+	reg, offset := c.RenderText(s, style)
+	if reg.Width == 0 {
+		return pixel.Rect{}
+	}
+	pen := c.ToCanvas(at)
+	return c.FromCanvasRect(c.paste(reg, pixel.V(math.Floor(pen.X), math.Floor(pen.Y)).Add(offset), true))
+}
+
+// textContext returns the offscreen 2D context used for browser text, set
+// up for style
+func (c *Canvasp) textContext(style TextStyle) js.Value {
+	if c.textCtx.IsUndefined() {
+		canvas := c.scratchCanvas(64, 64)
+		opts := js.Global().Get("Object").New()
+		opts.Set("willReadFrequently", true)
+		c.textCtx = canvas.Call("getContext", "2d", opts)
+	}
+	ctx := c.textCtx
+
+	font := style.Font
+	if font == "" {
+		font = DefaultFont
+	}
+	ctx.Set("font", font)
+	ctx.Set("fillStyle", cssColor(style.Color))
+	dir := style.Direction
+	if dir == "" {
+		dir = "inherit"
+	}
+	ctx.Set("direction", dir)
+	ctx.Set("textAlign", "left")
+	ctx.Set("textBaseline", "alphabetic")
+	return ctx
+}
+
+// cssColor formats a colour for CSS and canvas styles. nil is black.
+func cssColor(col color.Color) string {
+	if col == nil {
+		return "#000"
+	}
+	r, g, b, a := col.RGBA()
+	if a == 0 {
+		return "rgba(0,0,0,0)"
+	}
+	// RGBA is premultiplied; CSS wants straight values
+	return fmt.Sprintf("rgba(%d,%d,%d,%s)", r*255/a, g*255/a, b*255/a, cssNum(float64(a)/0xffff))
+}
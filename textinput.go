@@ -0,0 +1,244 @@
+package pixelcanvas
+
+import (
+	"syscall/js"
+
+	"github.com/faiface/pixel"
+)
+
+// TextInput collects typed text through a hidden <input> element laid over
+// the canvas. Keydown events alone can't do this: IMEs for Chinese, Japanese
+// and Korean (and dead keys, dictation and on-screen keyboards) only deliver
+// their text through the composition and input events of a focused text
+// field. The app draws the text field itself from Text, Caret and Preedit.
+//
+// All callbacks run inside the browser's event handlers.
+type TextInput struct {
+	// OnChange is called when the committed text or caret changes
+	OnChange func(text string, caret int)
+
+	// OnCompose is called as an IME composition changes. preedit is the
+	// uncommitted text to show at the caret, "" when composition ends.
+	OnCompose func(preedit string)
+
+	// OnSubmit is called when Enter is pressed outside a composition
+	OnSubmit func(text string)
+
+	// OnCancel is called when Escape is pressed outside a composition
+	OnCancel func()
+
+	c         *Canvasp
+	el        js.Value
+	composing bool
+	preedit   string
+	listeners []*listener
+}
+
+// NewTextInput creates a TextInput. It collects nothing until focused.
+func (c *Canvasp) NewTextInput() *TextInput {
+	t := &TextInput{c: c}
+
+	el := c.doc.Call("createElement", "input")
+	el.Set("type", "text")
+	el.Set("autocomplete", "off")
+	el.Set("spellcheck", false)
+	el.Call("setAttribute", "autocapitalize", "off")
+	el.Call("setAttribute", "aria-hidden", "true")
+
+	// Invisible but still focusable and positioned, so the IME candidate
+	// window appears next to the caret
+	style := el.Get("style")
+	style.Set("position", "fixed")
+	style.Set("left", "0px")
+	style.Set("top", "0px")
+	style.Set("width", "1px")
+	style.Set("height", "1em")
+	style.Set("opacity", "0")
+	style.Set("border", "0")
+	style.Set("padding", "0")
+	style.Set("pointerEvents", "none")
+	c.body.Call("appendChild", el)
+	t.el = el
+
+	t.listeners = []*listener{
+		c.listen(el, "compositionstart", func(js.Value) {
+			t.composing = true
+		}),
+		c.listen(el, "compositionupdate", func(e js.Value) {
+			t.compose(e.Get("data").String())
+		}),
+		c.listen(el, "compositionend", func(js.Value) {
+			t.composing = false
+			t.compose("")
+			t.changed() // Some browsers send the final input event before compositionend
+		}),
+		c.listen(el, "input", func(e js.Value) {
+			if t.composing || e.Get("isComposing").Truthy() {
+				return
+			}
+			t.changed()
+		}),
+		c.listen(el, "keydown", func(e js.Value) {
+			// keyCode 229 is a keydown the IME is handling
+			if t.composing || e.Get("isComposing").Truthy() || e.Get("keyCode").Int() == 229 {
+				return
+			}
+			switch e.Get("key").String() {
+			case "Enter":
+				e.Call("preventDefault")
+				if t.OnSubmit != nil {
+					t.OnSubmit(t.Text())
+				}
+			case "Escape":
+				if t.OnCancel != nil {
+					t.OnCancel()
+				}
+			}
+		}),
+		c.listen(el, "keyup", func(e js.Value) {
+			switch e.Get("key").String() {
+			case "ArrowLeft", "ArrowRight", "Home", "End":
+				if !t.composing {
+					t.changed() // Report the caret once the key has moved it
+				}
+			}
+		}),
+	}
+	return t
+}
+
+// Focus starts collecting text, bringing up the on-screen keyboard on touch
+// devices. Call it from a user gesture (e.g. a pointerdown handler) for
+// that to work on mobile.
+func (t *TextInput) Focus() {
+	t.el.Call("focus", map[string]interface{}{"preventScroll": true})
+}
+
+// Blur stops collecting text, ending any composition
+func (t *TextInput) Blur() {
+	t.el.Call("blur")
+}
+
+// Focused reports whether the input has the keyboard focus
+func (t *TextInput) Focused() bool {
+	return t.c.doc.Get("activeElement").Equal(t.el)
+}
+
+// Text returns the committed text
+func (t *TextInput) Text() string {
+	return t.el.Get("value").String()
+}
+
+// SetText replaces the text and puts the caret at the end
+func (t *TextInput) SetText(s string) {
+	t.el.Set("value", s)
+	t.SetCaret(len([]rune(s)))
+}
+
+// Caret returns the caret position in runes
+func (t *TextInput) Caret() int {
+	return runeIndex(t.Text(), t.el.Get("selectionEnd").Int())
+}
+
+// Selection returns the selected range in runes. start == end when nothing
+// is selected.
+func (t *TextInput) Selection() (start, end int) {
+	s := t.Text()
+	return runeIndex(s, t.el.Get("selectionStart").Int()), runeIndex(s, t.el.Get("selectionEnd").Int())
+}
+
+// SetCaret moves the caret to rune position i
+func (t *TextInput) SetCaret(i int) {
+	u := utf16Index(t.Text(), i)
+	t.el.Call("setSelectionRange", u, u)
+}
+
+// Composing reports whether an IME composition is in progress
+func (t *TextInput) Composing() bool {
+	return t.composing
+}
+
+// Preedit returns the uncommitted composition text, to draw at the caret
+func (t *TextInput) Preedit() string {
+	return t.preedit
+}
+
+// SetMaxLength limits the text to n UTF-16 code units. 0 is unlimited.
+func (t *TextInput) SetMaxLength(n int) {
+	if n <= 0 {
+		t.el.Call("removeAttribute", "maxlength")
+		return
+	}
+	t.el.Set("maxLength", n)
+}
+
+// SetCaretPos tells the browser where the caret is drawn (shadow canvas
+// pixels whatever SetCoordinates chose, bottom of the line), so the IME
+// candidate window and mobile keyboards position themselves next to it
+func (t *TextInput) SetCaretPos(p pixel.Vec, lineHeight float64) {
+	d := t.c.canvasToDOM(p)
+	r := t.c.canvas.Call("getBoundingClientRect")
+	sx, sy := 1.0, 1.0
+	if t.c.width > 0 && t.c.height > 0 {
+		sx = r.Get("width").Float() / float64(t.c.width)
+		sy = r.Get("height").Float() / float64(t.c.height)
+	}
+	style := t.el.Get("style")
+	style.Set("left", cssNum(r.Get("left").Float()+d.X*sx)+"px")
+	style.Set("top", cssNum(r.Get("top").Float()+(d.Y-lineHeight)*sy)+"px")
+	style.Set("height", cssNum(lineHeight*sy)+"px")
+}
+
+// Remove detaches the input from the page
+func (t *TextInput) Remove() {
+	for _, l := range t.listeners {
+		t.c.unlisten(l)
+	}
+	t.listeners = nil
+	t.el.Call("remove")
+}
+
+func (t *TextInput) compose(preedit string) {
+	t.preedit = preedit
+	if t.OnCompose != nil {
+		t.OnCompose(preedit)
+	}
+}
+
+func (t *TextInput) changed() {
+	if t.OnChange != nil {
+		t.OnChange(t.Text(), t.Caret())
+	}
+}
+
+// runeIndex converts a UTF-16 offset, as used by the DOM, to a rune offset
+func runeIndex(s string, u int) int {
+	n := 0
+	for _, r := range s {
+		if u <= 0 {
+			break
+		}
+		u--
+		if r >= 0x10000 {
+			u--
+		}
+		n++
+	}
+	return n
+}
+
+// utf16Index converts a rune offset to a UTF-16 offset
+func utf16Index(s string, i int) int {
+	u := 0
+	for _, r := range s {
+		if i <= 0 {
+			break
+		}
+		i--
+		u++
+		if r >= 0x10000 {
+			u++
+		}
+	}
+	return u
+}
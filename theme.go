@@ -0,0 +1,242 @@
+package pixelcanvas
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"syscall/js"
+
+	"github.com/lwayneh/pixelcanvas/colors"
+)
+
+// Themes
+//
+// A Theme is the look shared by UI drawn with the package (tooltips, and
+// the app's own panels): colours, font, spacing and nine-slice skins. The
+// canvas holds a current theme that UI components read when they draw and
+// follow when it changes, so switching between light and dark, or loading
+// a skin pack from JSON, restyles everything at runtime:
+//
+//	{
+//	  "name": "parchment",
+//	  "text": "#3b2a1a", "background": "#f4e4c1f0", "border": "#8a6a3a",
+//	  "accent": "#c0392b", "hover": "#fff3d6", "disabled": "#a89a80",
+//	  "font": "13px serif", "padding": 5, "spacing": 4,
+//	  "skins": {"panel": {"image": "ui/panel.png", "left": 6, "bottom": 6, "right": 6, "top": 6}}
+//	}
+
+// Theme is a UI style
+type Theme struct {
+	Name string
+
+	Text       color.RGBA
+	Background color.RGBA
+	Border     color.RGBA
+	Accent     color.RGBA // Focus, selection and active controls
+	Hover      color.RGBA
+	Disabled   color.RGBA
+
+	Font    string // CSS font shorthand
+	Padding int    // Inside panels
+	Spacing int    // Between controls
+
+	// Skins are nine-slice images by name, e.g. "panel", "button". Those
+	// given in JSON are loaded by LoadSkins.
+	Skins map[string]*NineSlice
+
+	skinSources map[string]SkinSource
+}
+
+// SkinSource is where a skin comes from in a theme's JSON
+type SkinSource struct {
+	Image                    string `json:"image"` // URL of a PNG, JPEG or GIF
+	Left, Bottom, Right, Top int
+	TileEdges                bool `json:"tileEdges,omitempty"`
+	TileCentre               bool `json:"tileCentre,omitempty"`
+}
+
+// Built in themes
+var (
+	DarkTheme = Theme{
+		Name:       "dark",
+		Text:       color.RGBA{235, 235, 240, 255},
+		Background: color.RGBA{24, 24, 32, 230},
+		Border:     color.RGBA{120, 120, 140, 255},
+		Accent:     color.RGBA{90, 160, 255, 255},
+		Hover:      color.RGBA{48, 48, 64, 255},
+		Disabled:   color.RGBA{110, 110, 120, 255},
+		Font:       "12px sans-serif",
+		Padding:    4,
+		Spacing:    4,
+	}
+	LightTheme = Theme{
+		Name:       "light",
+		Text:       color.RGBA{20, 20, 28, 255},
+		Background: color.RGBA{248, 248, 250, 240},
+		Border:     color.RGBA{160, 160, 175, 255},
+		Accent:     color.RGBA{30, 110, 230, 255},
+		Hover:      color.RGBA{225, 230, 240, 255},
+		Disabled:   color.RGBA{150, 150, 160, 255},
+		Font:       "12px sans-serif",
+		Padding:    4,
+		Spacing:    4,
+	}
+)
+
+// themeJSON is a Theme as written in JSON, colours as hex strings
+type themeJSON struct {
+	Name       string                `json:"name"`
+	Text       string                `json:"text,omitempty"`
+	Background string                `json:"background,omitempty"`
+	Border     string                `json:"border,omitempty"`
+	Accent     string                `json:"accent,omitempty"`
+	Hover      string                `json:"hover,omitempty"`
+	Disabled   string                `json:"disabled,omitempty"`
+	Font       string                `json:"font,omitempty"`
+	Padding    *int                  `json:"padding,omitempty"`
+	Spacing    *int                  `json:"spacing,omitempty"`
+	Skins      map[string]SkinSource `json:"skins,omitempty"`
+}
+
+// ParseTheme decodes a theme from JSON. Settings it leaves out are taken
+// from base (e.g. DarkTheme), so a theme file need only give what differs.
+func ParseTheme(data []byte, base Theme) (*Theme, error) {
+	var in themeJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, fmt.Errorf("pixelcanvas: theme: %v", err)
+	}
+	t := base
+	t.Skins = make(map[string]*NineSlice, len(base.Skins))
+	for k, v := range base.Skins {
+		t.Skins[k] = v
+	}
+	if in.Name != "" {
+		t.Name = in.Name
+	}
+	for _, f := range []struct {
+		s   string
+		dst *color.RGBA
+	}{
+		{in.Text, &t.Text}, {in.Background, &t.Background}, {in.Border, &t.Border},
+		{in.Accent, &t.Accent}, {in.Hover, &t.Hover}, {in.Disabled, &t.Disabled},
+	} {
+		if f.s == "" {
+			continue
+		}
+		col, err := colors.ParseHex(f.s)
+		if err != nil {
+			return nil, fmt.Errorf("pixelcanvas: theme: %v", err)
+		}
+		*f.dst = color.RGBAModel.Convert(col).(color.RGBA)
+	}
+	if in.Font != "" {
+		t.Font = in.Font
+	}
+	if in.Padding != nil {
+		t.Padding = *in.Padding
+	}
+	if in.Spacing != nil {
+		t.Spacing = *in.Spacing
+	}
+	t.skinSources = in.Skins
+	return &t, nil
+}
+
+// LoadSkins fetches the skin images named in the theme's JSON. It blocks,
+// so call it from a goroutine, before SetTheme.
+func (t *Theme) LoadSkins(c *Canvasp) error {
+	for name, src := range t.skinSources {
+		data, err := c.FetchBytes(src.Image)
+		if err != nil {
+			return fmt.Errorf("pixelcanvas: theme skin %s: %v", name, err)
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("pixelcanvas: theme skin %s: %v", name, err)
+		}
+		if t.Skins == nil {
+			t.Skins = make(map[string]*NineSlice)
+		}
+		t.Skins[name] = &NineSlice{
+			Image: RegionFromImage(img),
+			Left:  src.Left, Bottom: src.Bottom, Right: src.Right, Top: src.Top,
+			TileEdges: src.TileEdges, TileCentre: src.TileCentre,
+		}
+	}
+	return nil
+}
+
+// Skin returns the named skin, or nil if the theme has none
+func (t *Theme) Skin(name string) *NineSlice {
+	return t.Skins[name]
+}
+
+// TextStyle returns the theme's text style
+func (t *Theme) TextStyle() TextStyle {
+	return TextStyle{Font: t.Font, Color: t.Text}
+}
+
+// themeState is a canvas's current theme and who follows it
+type themeState struct {
+	current  *Theme
+	watchers map[*func(*Theme)]struct{}
+}
+
+// Theme returns the canvas's current theme, DarkTheme until SetTheme
+func (c *Canvasp) Theme() *Theme {
+	if c.theme.current == nil {
+		t := DarkTheme
+		c.theme.current = &t
+	}
+	return c.theme.current
+}
+
+// SetTheme switches the canvas's theme, restyling the UI following it
+func (c *Canvasp) SetTheme(t *Theme) {
+	c.theme.current = t
+	for fn := range c.theme.watchers {
+		(*fn)(t)
+	}
+	c.log().Debug("theme changed", "theme", t.Name)
+}
+
+// OnThemeChange calls fn with the new theme whenever SetTheme is called.
+// The returned func stops it.
+func (c *Canvasp) OnThemeChange(fn func(*Theme)) func() {
+	if c.theme.watchers == nil {
+		c.theme.watchers = make(map[*func(*Theme)]struct{})
+	}
+	key := &fn
+	c.theme.watchers[key] = struct{}{}
+	return func() { delete(c.theme.watchers, key) }
+}
+
+// PrefersDark reports whether the user's system asks for a dark colour
+// scheme
+func (c *Canvasp) PrefersDark() bool {
+	if c.window.Get("matchMedia").IsUndefined() {
+		return false
+	}
+	return c.window.Call("matchMedia", "(prefers-color-scheme: dark)").Get("matches").Bool()
+}
+
+// FollowSystemTheme sets light or dark to match the system colour scheme,
+// now and whenever it changes. The returned func stops following.
+func (c *Canvasp) FollowSystemTheme(light, dark *Theme) func() {
+	pick := func() {
+		if c.PrefersDark() {
+			c.SetTheme(dark)
+		} else {
+			c.SetTheme(light)
+		}
+	}
+	pick()
+	if c.window.Get("matchMedia").IsUndefined() {
+		return func() {}
+	}
+	query := c.window.Call("matchMedia", "(prefers-color-scheme: dark)")
+	l := c.listen(query, "change", func(js.Value) { pick() })
+	return func() { c.unlisten(l) }
+}
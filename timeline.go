@@ -0,0 +1,246 @@
+package pixelcanvas
+
+import (
+	"sort"
+	"time"
+)
+
+// Timelines
+//
+// A Timeline schedules callbacks, tweens and named events along simulation
+// time (see SimTime), for demos, cutscenes and data driven animation. Cues
+// are placed at absolute times, or relative to the end of the last cue
+// added with After, so a sequence reads in order:
+//
+//	tl := c.NewTimeline()
+//	tl.At(0, showTitle)
+//	tl.Tween(tl.After(0), time.Second, EaseOutQuad, func(v float64) { alpha = v })
+//	tl.Event(tl.After(500*time.Millisecond), "start")
+//	tl.Play()
+//
+// Call Update once per frame from the RenderFunc. Time follows the canvas,
+// so a paused canvas or a hit-stop holds the timeline still.
+
+// Timeline is a schedule of cues
+type Timeline struct {
+	Speed float64 // Playback rate; 1 is normal
+	Loop  bool
+
+	// OnEvent is called for cues added with Event
+	OnEvent func(name string)
+
+	// OnDone is called when playback reaches the end, unless looping
+	OnDone func()
+
+	c       *Canvasp
+	cues    []*cue
+	sorted  bool
+	labels  map[string]time.Duration
+	cursor  time.Duration // End of the last cue added, for After
+	at      time.Duration
+	last    time.Duration
+	playing bool
+}
+
+// cue is one scheduled item. Callbacks have no duration; tweens call fn
+// with eased progress on every update while they run.
+type cue struct {
+	at, dur time.Duration
+	fn      func()
+	tween   func(v float64)
+	ease    Easing
+	fired   bool // The callback has run, or the tween has finished
+	started bool // The tween has been updated at least once
+}
+
+// NewTimeline creates an empty, stopped timeline
+func (c *Canvasp) NewTimeline() *Timeline {
+	return &Timeline{Speed: 1, c: c, labels: make(map[string]time.Duration)}
+}
+
+// After returns d past the end of the last cue added, for scheduling
+// relative to it
+func (tl *Timeline) After(d time.Duration) time.Duration {
+	return tl.cursor + d
+}
+
+// At schedules fn to run at time t
+func (tl *Timeline) At(t time.Duration, fn func()) {
+	tl.add(&cue{at: t, fn: fn})
+}
+
+// Event schedules OnEvent(name) at time t, and labels t with name for
+// SeekLabel
+func (tl *Timeline) Event(t time.Duration, name string) {
+	tl.labels[name] = t
+	tl.add(&cue{at: t, fn: func() {
+		if tl.OnEvent != nil {
+			tl.OnEvent(name)
+		}
+	}})
+}
+
+// Label names time t for SeekLabel without scheduling anything
+func (tl *Timeline) Label(name string, t time.Duration) {
+	tl.labels[name] = t
+}
+
+// Tween calls fn with progress from 0 to 1, shaped by ease (nil for
+// linear), on every update from t for dur. It always ends on exactly 1,
+// however the frames fall.
+func (tl *Timeline) Tween(t, dur time.Duration, ease Easing, fn func(v float64)) {
+	if ease == nil {
+		ease = EaseLinear
+	}
+	tl.add(&cue{at: t, dur: dur, tween: fn, ease: ease})
+}
+
+// TweenFloat tweens *v from from to to
+func (tl *Timeline) TweenFloat(t, dur time.Duration, ease Easing, v *float64, from, to float64) {
+	tl.Tween(t, dur, ease, func(p float64) {
+		*v = from + (to-from)*p
+	})
+}
+
+func (tl *Timeline) add(k *cue) {
+	tl.cues = append(tl.cues, k)
+	tl.sorted = false
+	tl.cursor = k.at + k.dur
+	if k.at < tl.at {
+		k.fired = true // Already passed: don't fire until seeked back
+	}
+}
+
+// Duration returns the end of the last cue
+func (tl *Timeline) Duration() time.Duration {
+	var end time.Duration
+	for _, k := range tl.cues {
+		if e := k.at + k.dur; e > end {
+			end = e
+		}
+	}
+	return end
+}
+
+// Position returns the current time on the timeline
+func (tl *Timeline) Position() time.Duration {
+	return tl.at
+}
+
+// Play starts or resumes playback
+func (tl *Timeline) Play() {
+	if tl.playing {
+		return
+	}
+	tl.playing = true
+	tl.last = tl.c.SimTime()
+}
+
+// Pause stops playback where it is
+func (tl *Timeline) Pause() {
+	tl.playing = false
+}
+
+// Playing reports whether the timeline is playing
+func (tl *Timeline) Playing() bool {
+	return tl.playing
+}
+
+// Seek jumps to time t. Callbacks and events between the old and new
+// positions are skipped, not run; tweens are set to their value at t.
+func (tl *Timeline) Seek(t time.Duration) {
+	if t < 0 {
+		t = 0
+	}
+	tl.at = t
+	tl.sort()
+	for _, k := range tl.cues {
+		switch {
+		case k.tween == nil:
+			k.fired = k.at < t
+		case t >= k.at+k.dur:
+			k.fired, k.started = true, true
+			k.tween(1)
+		case t >= k.at:
+			k.fired, k.started = false, true
+			k.tween(k.ease(float64(t-k.at) / float64(k.dur)))
+		default:
+			if k.started {
+				k.tween(0)
+			}
+			k.fired, k.started = false, false
+		}
+	}
+}
+
+// SeekLabel jumps to a labelled time, reporting whether the label exists
+func (tl *Timeline) SeekLabel(name string) bool {
+	t, ok := tl.labels[name]
+	if ok {
+		tl.Seek(t)
+	}
+	return ok
+}
+
+// Update advances the timeline by the simulation time since the last
+// update, running every cue that has come due
+func (tl *Timeline) Update() {
+	if !tl.playing {
+		return
+	}
+	now := tl.c.SimTime()
+	tl.at += time.Duration(float64(now-tl.last) * tl.Speed)
+	tl.last = now
+	tl.run()
+
+	end := tl.Duration()
+	if tl.at < end {
+		return
+	}
+	if tl.Loop && end > 0 {
+		over := tl.at - end
+		tl.Seek(0)
+		tl.at = over % end
+		tl.run()
+		return
+	}
+	tl.playing = false
+	if tl.OnDone != nil {
+		tl.OnDone()
+	}
+}
+
+// run fires the cues due at the current position, in time order
+func (tl *Timeline) run() {
+	tl.sort()
+	for _, k := range tl.cues {
+		if k.at > tl.at {
+			break
+		}
+		if k.fired {
+			continue
+		}
+		if k.tween == nil {
+			k.fired = true
+			if k.fn != nil {
+				k.fn()
+			}
+			continue
+		}
+		k.started = true
+		if tl.at >= k.at+k.dur || k.dur <= 0 {
+			k.fired = true
+			k.tween(1)
+			continue
+		}
+		k.tween(k.ease(float64(tl.at-k.at) / float64(k.dur)))
+	}
+}
+
+func (tl *Timeline) sort() {
+	if tl.sorted {
+		return
+	}
+	sort.SliceStable(tl.cues, func(i, j int) bool { return tl.cues[i].at < tl.cues[j].at })
+	tl.sorted = true
+}
@@ -0,0 +1,308 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Package tiny is a reduced pixelcanvas for TinyGo, where a standard Go
+// WebAssembly binary is too large to embed in a production page.
+//
+// It keeps the core of the full package (a shadow pixel buffer with a
+// bottom-left origin, a requestAnimationFrame loop with an FPS cap, pixel
+// exact drawing and pointer and keyboard input) and leaves out everything
+// that needs reflection, goroutines or faiface/pixel: no JSON, no
+// cameras, layers or effects. The buffer is straight (not premultiplied)
+// RGBA, so it can go to the browser without conversion.
+//
+// It builds with the standard Go toolchain too, so code can be written
+// and debugged there and shipped with TinyGo.
+package tiny
+
+import (
+	"image/color"
+	"syscall/js"
+)
+
+// Canvas is a canvas element and its shadow buffer
+type Canvas struct {
+	Width, Height int
+
+	// Pix is the shadow buffer: straight RGBA, 4 bytes per pixel, rows
+	// bottom-up. Draw into it directly or with the methods below.
+	Pix []uint8
+
+	FPS float64 // Frame rate cap, 0 for the display's rate
+
+	el      js.Value
+	ctx     js.Value
+	imgData js.Value
+	buf     js.Value // Uint8Array the buffer is copied through
+	flipped []uint8  // Pix with rows top-down, for ImageData
+
+	render  func(c *Canvas, dt float64) bool
+	raf     js.Func
+	reqID   js.Value
+	running bool
+	last    float64 // Timestamp of the last rendered frame, ms
+	funcs   []js.Func
+}
+
+// New creates a canvas element of width x height pixels and appends it to
+// the page's body
+func New(width, height int) *Canvas {
+	doc := js.Global().Get("document")
+	el := doc.Call("createElement", "canvas")
+	doc.Get("body").Call("appendChild", el)
+	return Attach(el, width, height)
+}
+
+// Attach uses an existing canvas element, resizing it to width x height
+func Attach(el js.Value, width, height int) *Canvas {
+	el.Set("width", width)
+	el.Set("height", height)
+	c := &Canvas{Width: width, Height: height, el: el}
+	c.ctx = el.Call("getContext", "2d")
+	c.imgData = c.ctx.Call("createImageData", width, height)
+	c.buf = js.Global().Get("Uint8Array").New(width * height * 4)
+	c.Pix = make([]uint8, width*height*4)
+	c.flipped = make([]uint8, width*height*4)
+	return c
+}
+
+// Element returns the canvas element
+func (c *Canvas) Element() js.Value {
+	return c.el
+}
+
+// Start runs render every frame, with the seconds since the last frame,
+// presenting the buffer whenever render returns true. Starting a running
+// canvas replaces render.
+func (c *Canvas) Start(render func(c *Canvas, dt float64) bool) {
+	c.render = render
+	if c.running {
+		return
+	}
+	c.running = true
+	c.last = 0
+	if c.raf.IsUndefined() {
+		c.raf = js.FuncOf(c.frame)
+	}
+	c.reqID = js.Global().Call("requestAnimationFrame", c.raf)
+}
+
+// Stop ends the loop
+func (c *Canvas) Stop() {
+	if !c.running {
+		return
+	}
+	c.running = false
+	js.Global().Call("cancelAnimationFrame", c.reqID)
+}
+
+// Release stops the loop and removes every handler, freeing their
+// callbacks
+func (c *Canvas) Release() {
+	c.Stop()
+	if !c.raf.IsUndefined() {
+		c.raf.Release()
+		c.raf = js.Func{}
+	}
+	for _, fn := range c.funcs {
+		fn.Release()
+	}
+	c.funcs = nil
+}
+
+func (c *Canvas) frame(this js.Value, args []js.Value) interface{} {
+	if !c.running {
+		return nil
+	}
+	c.reqID = js.Global().Call("requestAnimationFrame", c.raf)
+	now := args[0].Float()
+	if c.last == 0 {
+		c.last = now
+	}
+	elapsed := now - c.last
+	if c.FPS > 0 && elapsed < 1000/c.FPS-1 { // 1ms slack for timer jitter
+		return nil
+	}
+	c.last = now
+	if c.render(c, elapsed/1000) {
+		c.Present()
+	}
+	return nil
+}
+
+// Present copies the buffer to the canvas element now
+func (c *Canvas) Present() {
+	stride := c.Width * 4
+	for y := 0; y < c.Height; y++ {
+		copy(c.flipped[(c.Height-1-y)*stride:(c.Height-y)*stride], c.Pix[y*stride:(y+1)*stride])
+	}
+	js.CopyBytesToJS(c.buf, c.flipped)
+	c.imgData.Get("data").Call("set", c.buf)
+	c.ctx.Call("putImageData", c.imgData, 0, 0)
+}
+
+// Clear fills the buffer with col
+func (c *Canvas) Clear(col color.RGBA) {
+	for i := 0; i < len(c.Pix); i += 4 {
+		c.Pix[i], c.Pix[i+1], c.Pix[i+2], c.Pix[i+3] = col.R, col.G, col.B, col.A
+	}
+}
+
+// At returns the pixel at x, y, transparent outside the canvas
+func (c *Canvas) At(x, y int) color.RGBA {
+	if x < 0 || y < 0 || x >= c.Width || y >= c.Height {
+		return color.RGBA{}
+	}
+	i := (y*c.Width + x) * 4
+	return color.RGBA{R: c.Pix[i], G: c.Pix[i+1], B: c.Pix[i+2], A: c.Pix[i+3]}
+}
+
+// Set blends col over the pixel at x, y
+func (c *Canvas) Set(x, y int, col color.RGBA) {
+	if x < 0 || y < 0 || x >= c.Width || y >= c.Height {
+		return
+	}
+	i := (y*c.Width + x) * 4
+	blend(c.Pix[i:i+4], col)
+}
+
+// FillRect fills the pixels in [x0, x1) x [y0, y1) with col, blended
+func (c *Canvas) FillRect(x0, y0, x1, y1 int, col color.RGBA) {
+	if x0 > x1 {
+		x0, x1 = x1, x0
+	}
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
+	x0, y0 = clamp(x0, 0, c.Width), clamp(y0, 0, c.Height)
+	x1, y1 = clamp(x1, 0, c.Width), clamp(y1, 0, c.Height)
+	for y := y0; y < y1; y++ {
+		row := c.Pix[(y*c.Width+x0)*4 : (y*c.Width+x1)*4]
+		for i := 0; i < len(row); i += 4 {
+			blend(row[i:i+4], col)
+		}
+	}
+}
+
+// Line draws a one pixel wide line from x0, y0 to x1, y1, both ends
+// included
+func (c *Canvas) Line(x0, y0, x1, y1 int, col color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		c.Set(x0, y0, col)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// Blit draws src, a w x h buffer in the same layout as Pix, with its
+// bottom left corner at x, y, blended
+func (c *Canvas) Blit(src []uint8, w, h, x, y int) {
+	for sy := 0; sy < h; sy++ {
+		dy := y + sy
+		if dy < 0 || dy >= c.Height {
+			continue
+		}
+		for sx := 0; sx < w; sx++ {
+			dx := x + sx
+			if dx < 0 || dx >= c.Width {
+				continue
+			}
+			i := (sy*w + sx) * 4
+			j := (dy*c.Width + dx) * 4
+			blend(c.Pix[j:j+4], color.RGBA{R: src[i], G: src[i+1], B: src[i+2], A: src[i+3]})
+		}
+	}
+}
+
+// OnPointer calls fn for pointer events on the canvas ("pointerdown",
+// "pointermove" or "pointerup"), with the position in canvas pixels
+// (bottom-left origin) and the buttons held
+func (c *Canvas) OnPointer(fn func(kind string, x, y int, buttons int)) {
+	for _, kind := range []string{"pointerdown", "pointermove", "pointerup"} {
+		kind := kind
+		c.on(c.el, kind, func(e js.Value) {
+			r := c.el.Call("getBoundingClientRect")
+			w, h := r.Get("width").Float(), r.Get("height").Float()
+			if w == 0 || h == 0 {
+				return
+			}
+			x := (e.Get("clientX").Float() - r.Get("left").Float()) * float64(c.Width) / w
+			y := (e.Get("clientY").Float() - r.Get("top").Float()) * float64(c.Height) / h
+			fn(kind, int(x), c.Height-1-int(y), e.Get("buttons").Int())
+		})
+	}
+}
+
+// OnKey calls fn when a key goes down or up, with its KeyboardEvent.code
+// (e.g. "ArrowLeft", "KeyA")
+func (c *Canvas) OnKey(fn func(code string, down bool)) {
+	win := js.Global()
+	c.on(win, "keydown", func(e js.Value) { fn(e.Get("code").String(), true) })
+	c.on(win, "keyup", func(e js.Value) { fn(e.Get("code").String(), false) })
+}
+
+func (c *Canvas) on(target js.Value, event string, handler func(e js.Value)) {
+	fn := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		handler(args[0])
+		return nil
+	})
+	target.Call("addEventListener", event, fn)
+	c.funcs = append(c.funcs, fn)
+}
+
+// blend draws src over the straight alpha pixel dst
+func blend(dst []uint8, src color.RGBA) {
+	switch src.A {
+	case 0:
+		return
+	case 255:
+		dst[0], dst[1], dst[2], dst[3] = src.R, src.G, src.B, 255
+		return
+	}
+	sa := uint32(src.A)
+	da := uint32(dst[3]) * (255 - sa) / 255
+	oa := sa + da
+	if oa == 0 {
+		return
+	}
+	dst[0] = uint8((uint32(src.R)*sa + uint32(dst[0])*da) / oa)
+	dst[1] = uint8((uint32(src.G)*sa + uint32(dst[1])*da) / oa)
+	dst[2] = uint8((uint32(src.B)*sa + uint32(dst[2])*da) / oa)
+	dst[3] = uint8(oa)
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,10 @@
+//go:build tinygo
+// +build tinygo
+
+package pixelcanvas
+
+// The full package relies on reflection (encoding/json, sort.Slice),
+// goroutines blocking on promises and faiface/pixel, which TinyGo can't
+// build or runs badly. Building it with TinyGo stops at the undefined name
+// below, which says what to do instead.
+var _ = useGithubComLwaynehPixelcanvasTinyWithTinyGo
@@ -0,0 +1,212 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"syscall/js"
+	"time"
+
+	"github.com/faiface/pixel"
+)
+
+// Tooltips
+//
+// Tooltips shows a short label in a panel when the pointer rests over a
+// registered area of the canvas. The label appears after Delay, next to
+// the pointer, and flips to the other side of it (then is clamped) rather
+// than running off the canvas edge. It is drawn on an overlay, so it never
+// reaches the drawing. The delay is wall clock time, so it is the same at
+// any frame rate, though the tip only appears on a rendered frame.
+
+// DefaultTooltipDelay is how long the pointer must rest before a tooltip
+// appears
+const DefaultTooltipDelay = 500 * time.Millisecond
+
+// Tooltips manages the tooltips of a canvas
+type Tooltips struct {
+	Delay   time.Duration
+	Style   TextStyle  // Text style. Smooth off keeps the text crisp
+	Panel   *NineSlice // Panel skin. nil draws a plain box in Background and Border
+	Padding int        // Space between the text and the panel's edge
+
+	Background color.Color
+	Border     color.Color
+	Offset     pixel.Vec // From the pointer to the panel's nearest corner, in canvas pixels
+
+	c         *Canvasp
+	overlay   *Overlay
+	listeners []*listener
+	unwatch   func() // Stops following the canvas theme
+	tips      []*Tooltip
+
+	pointer pixel.Vec
+	inside  bool
+	hover   *Tooltip  // The tip under the pointer
+	since   time.Time // When the pointer came to rest on hover
+	shown   *Tooltip  // The tip on screen
+}
+
+// Tooltip is a registered hover area
+type Tooltip struct {
+	Area pixel.Rect // Logical coordinates, as FromClient
+	Text string
+
+	// TextFunc, if set, supplies the text when the tooltip is shown, for
+	// labels that change (e.g. a colour value)
+	TextFunc func() string
+
+	rendered string  // Text the cached label was rendered from
+	label    *Region // Cached text
+}
+
+// NewTooltips starts tracking the pointer over the canvas. It is styled by
+// the canvas theme, and restyled when the theme changes.
+func (c *Canvasp) NewTooltips() *Tooltips {
+	t := &Tooltips{
+		Delay:  DefaultTooltipDelay,
+		Offset: pixel.V(12, -12),
+		c:      c,
+	}
+	t.ApplyTheme(c.Theme())
+	t.unwatch = c.OnThemeChange(t.ApplyTheme)
+	t.overlay = c.AddOverlay(t.draw)
+	t.overlay.Stale = t.stale
+	t.listeners = []*listener{
+		c.listen(c.canvas, "pointermove", t.pointerMove),
+		c.listen(c.canvas, "pointerleave", func(js.Value) { t.inside = false }),
+		c.listen(c.canvas, "pointerdown", func(js.Value) { t.since = time.Now() }), // Clicking hides the tip until the pointer rests again
+	}
+	return t
+}
+
+// ApplyTheme takes the text style, padding, colours and "tooltip" skin
+// (or "panel", failing that) from th
+func (t *Tooltips) ApplyTheme(th *Theme) {
+	t.Style = th.TextStyle()
+	t.Padding = th.Padding
+	t.Background, t.Border = th.Background, th.Border
+	t.Panel = th.Skin("tooltip")
+	if t.Panel == nil {
+		t.Panel = th.Skin("panel")
+	}
+	for _, tip := range t.tips {
+		tip.label = nil // Re-render in the new style
+	}
+	if t.overlay != nil {
+		t.overlay.Invalidate()
+	}
+}
+
+// Add registers a tooltip for area
+func (t *Tooltips) Add(area pixel.Rect, text string) *Tooltip {
+	tip := &Tooltip{Area: area.Norm(), Text: text}
+	t.tips = append(t.tips, tip)
+	return tip
+}
+
+// Remove unregisters a tooltip
+func (t *Tooltips) Remove(tip *Tooltip) {
+	for i, o := range t.tips {
+		if o == tip {
+			t.tips = append(t.tips[:i], t.tips[i+1:]...)
+			break
+		}
+	}
+	if t.hover == tip {
+		t.hover = nil
+	}
+}
+
+// Clear unregisters every tooltip
+func (t *Tooltips) Clear() {
+	t.tips = nil
+	t.hover = nil
+}
+
+// Close removes the overlay and stops tracking the pointer
+func (t *Tooltips) Close() {
+	for _, l := range t.listeners {
+		t.c.unlisten(l)
+	}
+	t.listeners = nil
+	t.unwatch()
+	t.c.RemoveOverlay(t.overlay)
+}
+
+func (t *Tooltips) pointerMove(e js.Value) {
+	t.pointer = t.c.FromClient(e.Get("clientX").Float(), e.Get("clientY").Float())
+	t.inside = true
+
+	// Topmost (last added) tooltip wins
+	var hover *Tooltip
+	for i := len(t.tips) - 1; i >= 0; i-- {
+		if t.tips[i].Area.Contains(t.pointer) {
+			hover = t.tips[i]
+			break
+		}
+	}
+	if hover != t.hover {
+		t.hover = hover
+		t.since = time.Now()
+	}
+}
+
+// stale reports whether the tooltip shown should change
+func (t *Tooltips) stale() bool {
+	var want *Tooltip
+	if t.inside && t.hover != nil && time.Since(t.since) >= t.Delay {
+		want = t.hover
+	}
+	return want != t.shown || want != nil && want.TextFunc != nil && want.text() != want.rendered
+}
+
+func (tip *Tooltip) text() string {
+	if tip.TextFunc != nil {
+		return tip.TextFunc()
+	}
+	return tip.Text
+}
+
+// draw places and draws the tooltip for the hovered area, if it is due
+func (t *Tooltips) draw(o *Overlay) {
+	t.shown = nil
+	if !t.inside || t.hover == nil || time.Since(t.since) < t.Delay {
+		return
+	}
+	tip := t.hover
+	t.shown = tip
+	if s := tip.text(); tip.label == nil || s != tip.rendered {
+		tip.rendered = s
+		tip.label, _ = t.c.RenderText(s, t.Style)
+	}
+	if tip.label == nil {
+		return
+	}
+
+	// Place the panel below right of the pointer, flipping to the other
+	// side where it would leave the canvas, then clamping
+	pad := t.Padding
+	w, h := tip.label.Width+2*pad, tip.label.Height+2*pad
+	p := t.c.ToCanvas(t.pointer)
+	x := int(p.X + t.Offset.X)
+	y := int(p.Y+t.Offset.Y) - h
+	if x+w > o.Width {
+		x = int(p.X-t.Offset.X) - w
+	}
+	if y < 0 {
+		y = int(p.Y - t.Offset.Y)
+	}
+	x, y = clampInt(x, 0, maxInt(o.Width-w, 0)), clampInt(y, 0, maxInt(o.Height-h, 0))
+
+	if t.Panel != nil {
+		o.DrawRegion(t.Panel.Render(w, h), x, y)
+	} else {
+		if t.Border != nil {
+			o.FillRect(x, y, x+w, y+h, t.Border)
+			o.ClearRect(x+1, y+1, x+w-1, y+h-1)
+		}
+		if t.Background != nil {
+			o.FillRect(x+1, y+1, x+w-1, y+h-1, t.Background)
+		}
+	}
+	o.DrawRegion(tip.label, x+pad, y+pad)
+}
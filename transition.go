@@ -0,0 +1,238 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"time"
+)
+
+// Screen transitions
+//
+// A transition covers the frame as it is copied to the browser, then
+// uncovers it: it runs out over the first half of its duration, calls swap
+// at the midpoint so the app can change scenes while the screen is hidden,
+// and runs back in over the second half. Like lighting it works on the
+// copied frame only, above the overlays, so any RenderFunc can be
+// transitioned without drawing code knowing. Progress follows simulation
+// time (see SimTime), so a paused canvas holds the transition where it is.
+
+// Easing maps linear progress in [0, 1] to eased progress
+type Easing func(t float64) float64
+
+// Standard easings
+var (
+	EaseLinear    Easing = func(t float64) float64 { return t }
+	EaseInQuad    Easing = func(t float64) float64 { return t * t }
+	EaseOutQuad   Easing = func(t float64) float64 { return t * (2 - t) }
+	EaseInOutQuad Easing = func(t float64) float64 {
+		if t < 0.5 {
+			return 2 * t * t
+		}
+		return -1 + (4-2*t)*t
+	}
+	EaseInOutCubic Easing = func(t float64) float64 {
+		if t < 0.5 {
+			return 4 * t * t * t
+		}
+		u := 2*t - 2
+		return 1 + u*u*u/2
+	}
+)
+
+// TransitionKind is how a transition covers the screen
+type TransitionKind int
+
+// Transition kinds
+const (
+	TransitionFade     TransitionKind = iota // Fade to Color and back
+	TransitionWipe                           // Sweep Color across from the left and off to the right
+	TransitionPixelate                       // Blocks grow to MaxBlock pixels and shrink back
+	TransitionDissolve                       // Pixels turn to Color, and back, in a random order
+)
+
+// Transition describes a screen transition
+type Transition struct {
+	Kind     TransitionKind
+	Duration time.Duration // Both halves together
+	Ease     Easing        // Applied to each half. nil for EaseInOutQuad
+	Color    color.RGBA    // What the screen is covered with, except for pixelate
+	MaxBlock int           // Largest pixelate block. 0 for 32
+}
+
+// transitionPass runs a Transition
+type transitionPass struct {
+	t       Transition
+	start   time.Duration
+	swap    func()
+	swapped bool
+	amount  float64 // How covered the screen is, 0 to 1
+	done    bool
+
+	// Pixelate keeps the first row it sees of each band of rows, so the
+	// rest of the band can copy it
+	block    int
+	band     int
+	bandRow  []uint8
+	haveBand bool
+}
+
+// StartTransition runs t, calling swap (which may be nil) when the screen
+// is fully covered. Starting a transition while one runs finishes the
+// first at once, calling its swap if it hasn't been called yet.
+func (c *Canvasp) StartTransition(t Transition, swap func()) {
+	if p := c.transition(); p != nil {
+		p.finish(c)
+	}
+	if t.Ease == nil {
+		t.Ease = EaseInOutQuad
+	}
+	if t.MaxBlock <= 0 {
+		t.MaxBlock = 32
+	}
+	c.addPass(&transitionPass{t: t, start: c.SimTime(), swap: swap})
+}
+
+// Transitioning reports whether a transition is running
+func (c *Canvasp) Transitioning() bool {
+	return c.transition() != nil
+}
+
+// transition returns the running transition, if any
+func (c *Canvasp) transition() *transitionPass {
+	for _, p := range c.passes {
+		if t, ok := p.(*transitionPass); ok {
+			return t
+		}
+	}
+	return nil
+}
+
+// finish ends the transition, swapping if it hasn't yet
+func (p *transitionPass) finish(c *Canvasp) {
+	if !p.swapped {
+		p.swapped = true
+		if p.swap != nil {
+			p.swap()
+		}
+	}
+	p.done = true
+	c.removePass(p)
+}
+
+func (p *transitionPass) prepare(c *Canvasp) bool {
+	if p.done {
+		return false
+	}
+	half := p.t.Duration / 2
+	at := c.SimTime() - p.start
+	if half <= 0 || at >= p.t.Duration {
+		p.finish(c)
+		return true
+	}
+	if at < half {
+		p.amount = p.t.Ease(float64(at) / float64(half))
+	} else {
+		if !p.swapped {
+			p.swapped = true
+			if p.swap != nil {
+				p.swap()
+			}
+		}
+		p.amount = p.t.Ease(1 - float64(at-half)/float64(half))
+	}
+	p.block = 1 + int(p.amount*float64(p.t.MaxBlock-1)+0.5)
+	p.haveBand = false
+	return true
+}
+
+func (p *transitionPass) active() bool { return !p.done }
+
+func (p *transitionPass) overOverlays() bool { return true }
+
+func (p *transitionPass) row(dst []uint8, y int) {
+	col := p.t.Color
+	switch p.t.Kind {
+	case TransitionFade:
+		a := uint32(p.amount*255 + 0.5)
+		for i := 0; i+3 < len(dst); i += 4 {
+			mixStraight(dst[i:i+4], col, a)
+		}
+
+	case TransitionWipe:
+		// Covers from the left going out, uncovers from the left coming in
+		w := len(dst) / 4
+		x0, x1 := 0, int(p.amount*float64(w)+0.5)
+		if p.swapped {
+			x0, x1 = w-x1, w
+		}
+		for x := x0; x < x1; x++ {
+			mixStraight(dst[x*4:x*4+4], col, 255)
+		}
+
+	case TransitionDissolve:
+		threshold := uint32(p.amount * 256)
+		w := len(dst) / 4
+		for x := 0; x < w; x++ {
+			if dissolveRank(x, y) < threshold {
+				mixStraight(dst[x*4:x*4+4], col, 255)
+			}
+		}
+
+	case TransitionPixelate:
+		p.pixelate(dst, y)
+	}
+}
+
+// pixelate averages blocks along the row, then copies the band's first
+// processed row to the rest of the band. Rows of a band are copied one after
+// another, in either direction, so the first seen stands for the band.
+func (p *transitionPass) pixelate(dst []uint8, y int) {
+	b := p.block
+	if b <= 1 {
+		return
+	}
+	band := y / b
+	if p.haveBand && band == p.band && len(p.bandRow) == len(dst) {
+		copy(dst, p.bandRow)
+		return
+	}
+	w := len(dst) / 4
+	for x0 := 0; x0 < w; x0 += b {
+		x1 := minInt(x0+b, w)
+		var sum [4]int
+		for x := x0; x < x1; x++ {
+			for k := 0; k < 4; k++ {
+				sum[k] += int(dst[x*4+k])
+			}
+		}
+		n := x1 - x0
+		for x := x0; x < x1; x++ {
+			for k := 0; k < 4; k++ {
+				dst[x*4+k] = uint8(sum[k] / n)
+			}
+		}
+	}
+	if len(p.bandRow) != len(dst) {
+		p.bandRow = make([]uint8, len(dst))
+	}
+	copy(p.bandRow, dst)
+	p.band, p.haveBand = band, true
+}
+
+// mixStraight mixes col over a straight RGBA pixel with weight a (0-255)
+func mixStraight(px []uint8, col color.RGBA, a uint32) {
+	inv := 255 - a
+	px[0] = uint8((uint32(px[0])*inv + uint32(col.R)*a) / 255)
+	px[1] = uint8((uint32(px[1])*inv + uint32(col.G)*a) / 255)
+	px[2] = uint8((uint32(px[2])*inv + uint32(col.B)*a) / 255)
+	px[3] = uint8((uint32(px[3])*inv + uint32(col.A)*a) / 255)
+}
+
+// dissolveRank gives each pixel a fixed pseudo-random rank from 0 to 255,
+// the order in which it dissolves
+func dissolveRank(x, y int) uint32 {
+	h := uint32(x)*0x9E3779B1 ^ uint32(y)*0x85EBCA77
+	h ^= h >> 15
+	h *= 0x2C1B3C6D
+	h ^= h >> 12
+	return h & 0xFF
+}
@@ -0,0 +1,73 @@
+package pixelcanvas
+
+import (
+	"syscall/js"
+)
+
+// Transparency
+//
+// The canvas's 2D context has an alpha channel by default, and the copy
+// un-premultiplies the shadow canvas into ImageData's straight alpha, so
+// transparent pixels show the page behind the canvas. For effects drawn over
+// an ordinary web page, OverlayPage lays the canvas over the viewport and
+// keeps it from blocking the page. Canvases that always fill every pixel can
+// instead be made opaque, which lets the browser skip blending them with the
+// page.
+
+// SetOpaque chooses an opaque 2D context: faster to composite, but
+// transparent pixels show black. Browsers fix a context's attributes when
+// it is created, so call this before Create or Set; it re-creates nothing
+// on a canvas already set up.
+func (c *Canvasp) SetOpaque(on bool) {
+	c.opaque = on
+}
+
+// Transparent reports whether the canvas's context has an alpha channel,
+// as the browser actually created it
+func (c *Canvasp) Transparent() bool {
+	if c.ctx.IsUndefined() {
+		return !c.opaque
+	}
+	attrs := c.ctx.Call("getContextAttributes")
+	if attrs.IsUndefined() || attrs.IsNull() {
+		return !c.opaque
+	}
+	return attrs.Get("alpha").Bool()
+}
+
+// OverlayPage turns the canvas into a transparent layer fixed over the
+// whole viewport, above the page content, that lets pointer events through
+// to the page beneath; or, with on false, puts it back in the page flow. It
+// also sets the clear colour to transparent and clears every frame, so
+// only what each frame draws covers the page. Resize the canvas to the
+// viewport (e.g. with the resize helpers) to keep it pixel exact.
+func (c *Canvasp) OverlayPage(on bool, zIndex int) {
+	style := c.canvas.Get("style")
+	if !on {
+		for _, p := range []string{"position", "left", "top", "width", "height", "z-index", "pointer-events", "background"} {
+			style.Call("removeProperty", p)
+		}
+		return
+	}
+	if !c.Transparent() {
+		c.log().Warn("overlaying the page with an opaque canvas, it will hide the page")
+	}
+	style.Set("position", "fixed")
+	style.Set("left", "0")
+	style.Set("top", "0")
+	style.Set("width", "100vw")
+	style.Set("height", "100vh")
+	style.Set("zIndex", zIndex)
+	style.Set("pointerEvents", "none")
+	style.Set("background", "transparent")
+
+	c.SetClearColor(nil)
+	c.SetClearPolicy(ClearAlways)
+}
+
+// contextOptions are the 2D context attributes Set asks for
+func (c *Canvasp) contextOptions() js.Value {
+	opts := js.Global().Get("Object").New()
+	opts.Set("alpha", !c.opaque)
+	return opts
+}
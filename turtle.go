@@ -0,0 +1,248 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Turtle graphics
+//
+// A Turtle is a pen that moves around the shadow canvas under simple
+// commands (forward, turn, pen up and down), as in Logo, for teaching. It
+// works in shadow canvas pixels whatever SetCoordinates chose, so that 90
+// degrees is always up the screen. Lines are drawn with DrawLine's pixel
+// exact rasteriser. By default each command
+// draws immediately; with Speed set, commands queue up and Update draws
+// them a little at a time, so a class can watch the drawing happen.
+
+// DefaultTurtleSpeed is a comfortable watching speed for Turtle.Speed, in
+// pixels per second
+const DefaultTurtleSpeed = 120
+
+// Turtle draws on a canvas. Headings are in degrees, anticlockwise from
+// the positive x axis, so 90 points up the screen.
+type Turtle struct {
+	// Speed, when above zero, animates drawing at this many pixels per
+	// second of simulation time: call Update from the RenderFunc. Zero
+	// draws each command as it is given.
+	Speed float64
+
+	c       *Canvasp
+	pos     pixel.Vec
+	heading float64
+	down    bool
+	col     color.Color
+
+	queue   []turtleLine // Lines waiting to be drawn when animated
+	budget  float64      // Pixels the animation may still draw this frame
+	overlay *Overlay     // Marker showing the turtle, when shown
+}
+
+// turtleLine is a queued line, rasterised up front so it can be drawn
+// pixel by pixel
+type turtleLine struct {
+	pix  []point
+	col  color.Color
+	done int
+	dir  float64 // Heading when the line was queued, for the marker
+}
+
+// NewTurtle creates a turtle in the middle of the canvas, facing up with
+// its pen down in black
+func (c *Canvasp) NewTurtle() *Turtle {
+	t := &Turtle{c: c, col: color.Black, down: true}
+	t.Home()
+	return t
+}
+
+// Forward moves d pixels along the heading, drawing if the pen is down
+func (t *Turtle) Forward(d float64) {
+	s, cs := math.Sincos(t.heading * math.Pi / 180)
+	t.Goto(t.pos.Add(pixel.V(cs*d, s*d)))
+}
+
+// Back moves d pixels backwards without turning
+func (t *Turtle) Back(d float64) {
+	t.Forward(-d)
+}
+
+// Left turns anticlockwise by deg degrees
+func (t *Turtle) Left(deg float64) {
+	t.SetHeading(t.heading + deg)
+}
+
+// Right turns clockwise by deg degrees
+func (t *Turtle) Right(deg float64) {
+	t.SetHeading(t.heading - deg)
+}
+
+// SetHeading turns to face deg degrees anticlockwise from the positive x
+// axis
+func (t *Turtle) SetHeading(deg float64) {
+	t.heading = math.Mod(deg, 360)
+	if t.heading < 0 {
+		t.heading += 360
+	}
+	t.moved()
+}
+
+// Heading returns the direction the turtle faces
+func (t *Turtle) Heading() float64 {
+	return t.heading
+}
+
+// Goto moves straight to to, drawing if the pen is down, without turning
+func (t *Turtle) Goto(to pixel.Vec) {
+	from := t.pos
+	t.pos = to
+	if !t.down {
+		t.moved()
+		return
+	}
+	if t.Speed <= 0 && len(t.queue) == 0 {
+		t.c.plotShape(t.col, func(plot func(x, y int)) {
+			rasterLine(pixelPoint(from), pixelPoint(to), plot)
+		})
+		t.moved()
+		return
+	}
+	l := turtleLine{col: t.col, dir: t.heading}
+	rasterLine(pixelPoint(from), pixelPoint(to), func(x, y int) {
+		l.pix = append(l.pix, point{x, y})
+	})
+	t.queue = append(t.queue, l)
+}
+
+// Position returns where the turtle is, once all queued commands have been
+// drawn
+func (t *Turtle) Position() pixel.Vec {
+	return t.pos
+}
+
+// Home moves to the middle of the canvas without drawing and faces up
+func (t *Turtle) Home() {
+	down := t.down
+	t.down = false
+	t.Goto(pixel.V(float64(t.c.width/2), float64(t.c.height/2)))
+	t.down = down
+	t.SetHeading(90)
+}
+
+// PenUp stops the turtle drawing as it moves
+func (t *Turtle) PenUp() {
+	t.down = false
+}
+
+// PenDown starts the turtle drawing as it moves
+func (t *Turtle) PenDown() {
+	t.down = true
+}
+
+// IsDown reports whether the pen is down
+func (t *Turtle) IsDown() bool {
+	return t.down
+}
+
+// SetColor sets the colour of lines drawn from now on
+func (t *Turtle) SetColor(col color.Color) {
+	t.col = col
+}
+
+// Busy reports whether animated drawing is still queued
+func (t *Turtle) Busy() bool {
+	return len(t.queue) > 0
+}
+
+// Finish draws everything still queued at once
+func (t *Turtle) Finish() {
+	t.draw(math.Inf(1))
+}
+
+// Update draws the next stretch of queued lines, Speed pixels per second
+// of the frame's Delta. Call it from the RenderFunc.
+func (t *Turtle) Update() {
+	if len(t.queue) == 0 {
+		return
+	}
+	speed := t.Speed
+	if speed <= 0 {
+		speed = math.Inf(1)
+	}
+	t.draw(speed * t.c.Delta().Seconds())
+}
+
+// draw plots up to n more pixels from the queue. Fractions carry over, so
+// slow speeds still make progress frame to frame.
+func (t *Turtle) draw(n float64) {
+	t.budget += n
+	for len(t.queue) > 0 && t.budget >= 1 {
+		l := &t.queue[0]
+		end := len(l.pix)
+		if left := float64(end - l.done); t.budget < left {
+			end = l.done + int(t.budget)
+		}
+		pix := l.pix[l.done:end]
+		t.c.plotShape(l.col, func(plot func(x, y int)) {
+			for _, p := range pix {
+				plot(p.x, p.y)
+			}
+		})
+		t.budget -= float64(end - l.done)
+		l.done = end
+		if l.done < len(l.pix) {
+			break
+		}
+		t.queue = t.queue[1:]
+	}
+	if len(t.queue) == 0 {
+		t.budget = 0
+	}
+	t.moved()
+}
+
+// Show shows or hides a marker at the turtle's position pointing along
+// its heading. It is drawn on an overlay, so it never ends up in the
+// drawing.
+func (t *Turtle) Show(on bool) {
+	switch {
+	case on && t.overlay == nil:
+		t.overlay = t.c.AddOverlay(t.drawMarker)
+	case !on && t.overlay != nil:
+		t.c.RemoveOverlay(t.overlay)
+		t.overlay = nil
+	}
+}
+
+// moved redraws the marker after the turtle moves or turns
+func (t *Turtle) moved() {
+	if t.overlay != nil {
+		t.overlay.Invalidate()
+	}
+}
+
+// drawMarker draws the turtle as an arrowhead where drawing has reached
+func (t *Turtle) drawMarker(o *Overlay) {
+	o.Clear()
+	at, dir := t.pos, t.heading
+	if len(t.queue) > 0 {
+		l := t.queue[0]
+		dir = l.dir
+		if l.done > 0 {
+			p := l.pix[l.done-1]
+			at = pixel.V(float64(p.x), float64(p.y))
+		} else if len(l.pix) > 0 {
+			at = pixel.V(float64(l.pix[0].x), float64(l.pix[0].y))
+		}
+	}
+	corner := func(deg, r float64) point {
+		s, cs := math.Sincos((dir + deg) * math.Pi / 180)
+		return pixelPoint(at.Add(pixel.V(cs*r, s*r)))
+	}
+	tip, l, r := corner(0, 8), corner(140, 6), corner(-140, 6)
+	plot := func(x, y int) { o.Blend(x, y, t.col) }
+	rasterLine(tip, l, plot)
+	rasterLine(l, r, plot)
+	rasterLine(r, tip, plot)
+}
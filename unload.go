@@ -0,0 +1,112 @@
+package pixelcanvas
+
+import "syscall/js"
+
+// Page unload
+//
+// Once a canvas is started it handles the page going away itself. When the
+// page is hidden or unloaded (visibilitychange to hidden, and pagehide)
+// the OnUnload functions run, which is where autosaves and analytics flush:
+// it is the last point a page reliably gets to run, and mobile browsers
+// often discard hidden tabs without unloading them. On pagehide the render
+// loop is stopped, or just paused if the page is going into the back/forward
+// cache, and resumed when it comes back. With SetUnsavedChanges(true),
+// beforeunload asks the user before leaving.
+
+type unloadState struct {
+	disabled  bool
+	listeners []*listener
+	hooks     map[*func()]struct{}
+	unsaved   bool
+	bfcached  bool // Paused on pagehide into the back/forward cache
+}
+
+// SetUnloadHandling turns the package's unload handling on (the default)
+// or off, for apps that handle the page lifecycle themselves
+func (c *Canvasp) SetUnloadHandling(on bool) {
+	c.unload.disabled = !on
+	if on {
+		c.watchUnload()
+		return
+	}
+	for _, l := range c.unload.listeners {
+		c.unlisten(l)
+	}
+	c.unload.listeners = nil
+}
+
+// SetUnsavedChanges sets whether leaving the page should be confirmed, e.g.
+// while a document has changes that are not saved anywhere
+func (c *Canvasp) SetUnsavedChanges(unsaved bool) {
+	c.unload.unsaved = unsaved
+	c.watchUnload()
+}
+
+// UnsavedChanges reports what SetUnsavedChanges last set
+func (c *Canvasp) UnsavedChanges() bool {
+	return c.unload.unsaved
+}
+
+// OnUnload calls fn whenever the page is hidden or unloaded, to save or
+// send what would otherwise be lost. fn runs in the event handler, so it
+// must start its work without waiting on it (a beacon, or an IndexedDB
+// write left to finish by itself), and it may run several times in one
+// session. The returned func stops it.
+func (c *Canvasp) OnUnload(fn func()) func() {
+	if c.unload.hooks == nil {
+		c.unload.hooks = make(map[*func()]struct{})
+	}
+	key := &fn
+	c.unload.hooks[key] = struct{}{}
+	c.watchUnload()
+	return func() { delete(c.unload.hooks, key) }
+}
+
+// watchUnload registers the page lifecycle listeners if they aren't
+func (c *Canvasp) watchUnload() {
+	if c.unload.disabled || c.unload.listeners != nil {
+		return
+	}
+	c.unload.listeners = []*listener{
+		c.listen(c.doc, "visibilitychange", func(js.Value) {
+			if c.doc.Get("visibilityState").String() == "hidden" {
+				c.runUnloadHooks()
+			}
+		}),
+		c.listen(c.window, "pagehide", c.pageHide),
+		c.listen(c.window, "pageshow", c.pageShow),
+		c.listen(c.window, "beforeunload", func(e js.Value) {
+			if c.unload.unsaved {
+				e.Call("preventDefault")
+				e.Set("returnValue", "") // Older browsers want this set to prompt
+			}
+		}),
+	}
+}
+
+func (c *Canvasp) runUnloadHooks() {
+	for fn := range c.unload.hooks {
+		(*fn)()
+	}
+}
+
+func (c *Canvasp) pageHide(e js.Value) {
+	c.runUnloadHooks()
+	if e.Get("persisted").Bool() {
+		if c.state == StateRunning {
+			c.Pause()
+			c.unload.bfcached = true
+		}
+		return
+	}
+	c.Stop()
+}
+
+// pageShow resumes a loop paused by pagehide when the page comes back from
+// the back/forward cache
+func (c *Canvasp) pageShow(e js.Value) {
+	if c.unload.bfcached && e.Get("persisted").Bool() {
+		c.unload.bfcached = false
+		c.Resume()
+	}
+}
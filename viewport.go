@@ -0,0 +1,122 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// Viewport is an additional view of the world, e.g. a minimap, rendered each
+// frame through its own Camera and shown in a rectangle of the main canvas.
+// Like an overlay it is composited as the frame is copied to the browser,
+// so it never reaches the shadow canvas, Image or snapshots.
+type Viewport struct {
+	Screen     pixel.Rect       // Where on the main canvas the view is drawn, in shadow canvas pixels
+	Camera     *Camera          // Which part of the world is shown
+	Draw       func(gc *Canvas) // Draws the world. gc's matrix is already set from Camera
+	Background color.Color      // Cleared to this before Draw. nil leaves it transparent
+	Hidden     bool             // Skip this viewport without removing it
+
+	target *Canvas
+	pix    []uint8 // target's pixels, see canvasPixels
+	x0, y0 int     // Where target's bottom left pixel goes, in shadow canvas pixels
+}
+
+// AddViewport declares a viewport at 'screen' (logical coordinates) on the
+// canvas showing the world region 'view', scaled to fit. Viewports are drawn
+// after the RenderFunc and composited in the order added, below any
+// overlays, as the frame is copied to the browser.
+func (c *Canvasp) AddViewport(screen pixel.Rect, view pixel.Rect, draw func(gc *Canvas)) *Viewport {
+	screen = c.ToCanvasRect(screen).Norm()
+	v := &Viewport{
+		Screen: screen,
+		Camera: NewCamera(int(screen.W()), int(screen.H())),
+		Draw:   draw,
+	}
+	v.Show(view)
+	c.viewports = append(c.viewports, v)
+	return v
+}
+
+// RemoveViewport stops drawing v
+func (c *Canvasp) RemoveViewport(v *Viewport) {
+	for i, o := range c.viewports {
+		if o == v {
+			c.viewports = append(c.viewports[:i], c.viewports[i+1:]...)
+			c.overlayRemoved = true // Copy the frame again without it
+			return
+		}
+	}
+}
+
+// Show points the viewport's camera at a world region, zooming so the whole
+// region fits inside the viewport.
+func (v *Viewport) Show(view pixel.Rect) {
+	view = view.Norm()
+	v.Camera.Pos = view.Center()
+	if view.W() > 0 && view.H() > 0 {
+		v.Camera.Zoom = math.Min(v.Screen.W()/view.W(), v.Screen.H()/view.H())
+	}
+}
+
+// hasViewports reports whether any viewport is shown
+func (c *Canvasp) hasViewports() bool {
+	for _, v := range c.viewports {
+		if !v.Hidden && v.pix != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// renderViewports draws every visible viewport into its own target, ready
+// for blendViewports
+func (c *Canvasp) renderViewports() {
+	for _, v := range c.viewports {
+		if v.Hidden || v.Draw == nil {
+			continue
+		}
+
+		size := pixel.R(0, 0, math.Floor(v.Screen.W()), math.Floor(v.Screen.H()))
+		if v.target == nil || v.target.Bounds() != size {
+			v.target = newCanvas(size)
+			v.Camera.SetSize(int(size.W()), int(size.H()))
+		}
+
+		if v.Background != nil {
+			v.target.Clear(v.Background)
+		} else {
+			v.target.Clear(color.Transparent)
+		}
+		v.target.SetMatrix(v.Camera.Matrix())
+		v.Draw(v.target)
+		v.target.SetMatrix(pixel.IM)
+
+		v.pix = canvasPixels(v.target)
+		v.x0, v.y0 = int(math.Floor(v.Screen.Min.X)), int(math.Floor(v.Screen.Min.Y))
+	}
+}
+
+// blendViewports composites the visible viewports' parts of row y (shadow
+// canvas rows) over dst, a row already converted to ImageData's straight
+// RGBA
+func (c *Canvasp) blendViewports(dst []uint8, y int) {
+	for _, v := range c.viewports {
+		if v.Hidden || v.pix == nil {
+			continue
+		}
+		b := v.target.Bounds()
+		w, h := int(b.W()), int(b.H())
+		ty := y - v.y0
+		if ty < 0 || ty >= h || len(v.pix) != w*h*4 {
+			continue
+		}
+		x0, x1 := clampInt(v.x0, 0, c.width), clampInt(v.x0+w, 0, c.width)
+		if x0 >= x1 {
+			continue
+		}
+		row := v.pix[(ty*w+x0-v.x0)*4 : (ty*w+x1-v.x0)*4]
+		blendPremulRow(dst[x0*4:x1*4], row)
+	}
+}
@@ -0,0 +1,80 @@
+package pixelcanvas
+
+import (
+	"errors"
+	"syscall/js"
+)
+
+// ErrNoWakeLock is returned when the browser lacks the Screen Wake Lock API
+var ErrNoWakeLock = errors.New("pixelcanvas: Screen Wake Lock not supported")
+
+type wakeLock struct {
+	want       bool
+	sentinel   js.Value  // WakeLockSentinel while held
+	visibility *listener // Re-acquires the lock when the page becomes visible again
+}
+
+// KeepAwake stops the screen dimming or locking while on is set. Browsers
+// drop the lock whenever the page is hidden, so it is re-acquired each time
+// the page becomes visible again until KeepAwake(false) is called.
+func (c *Canvasp) KeepAwake(on bool) error {
+	wl := js.Global().Get("navigator").Get("wakeLock")
+	if wl.IsUndefined() {
+		return ErrNoWakeLock
+	}
+
+	c.wake.want = on
+	if !on {
+		if c.wake.visibility != nil {
+			c.unlisten(c.wake.visibility)
+			c.wake.visibility = nil
+		}
+		c.releaseWakeLock()
+		return nil
+	}
+
+	if c.wake.visibility == nil {
+		c.wake.visibility = c.listen(c.doc, "visibilitychange", func(js.Value) {
+			if c.wake.want && c.doc.Get("visibilityState").String() == "visible" {
+				c.acquireWakeLock(wl)
+			}
+		})
+	}
+	c.acquireWakeLock(wl)
+	return nil
+}
+
+// Awake reports whether a wake lock is currently held
+func (c *Canvasp) Awake() bool {
+	s := c.wake.sentinel
+	return !s.IsUndefined() && !s.Get("released").Bool()
+}
+
+func (c *Canvasp) acquireWakeLock(wl js.Value) {
+	if c.Awake() {
+		return
+	}
+	p := wl.Call("request", "screen")
+	go func() {
+		s, err := await(p)
+		if err != nil {
+			c.log().Warn("wake lock request failed", "err", err)
+			return
+		}
+		if !c.wake.want { // Turned off while the request was pending
+			s.Call("release")
+			return
+		}
+		c.wake.sentinel = s
+		c.log().Debug("wake lock acquired")
+	}()
+}
+
+func (c *Canvasp) releaseWakeLock() {
+	if c.wake.sentinel.IsUndefined() {
+		return
+	}
+	c.wake.sentinel.Call("release")
+	c.wake.sentinel = js.Undefined()
+	c.log().Debug("wake lock released")
+}
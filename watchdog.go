@@ -0,0 +1,203 @@
+package pixelcanvas
+
+import (
+	"runtime"
+	"time"
+)
+
+// FrameStats summarises frame timing, split into the time spent in the
+// RenderFunc and the time spent getting the result to the browser (rendering
+// the viewports plus imgCopy).
+type FrameStats struct {
+	Frames   uint64 // Frames run
+	Overruns uint64 // Frames that took longer than the budget
+
+	Render time.Duration // Last frame's RenderFunc time
+	Copy   time.Duration // Last frame's present/copy time
+
+	AvgRender time.Duration // Smoothed RenderFunc time
+	AvgCopy   time.Duration // Smoothed present/copy time
+
+	// Heap allocations made by the frame, only counted while TrackAllocs is
+	// on. Anything above zero is garbage the collector will have to pause
+	// for sooner or later.
+	Allocs         uint64  // Last frame's allocations
+	AllocsPerFrame float64 // Smoothed allocations per frame
+
+	Passes []PassStats // The RenderFunc's profiled passes, see Profiler
+}
+
+// Total returns the smoothed time of a whole frame
+func (s FrameStats) Total() time.Duration {
+	return s.AvgRender + s.AvgCopy
+}
+
+// JankReport is delivered when sustained overruns are detected
+type JankReport struct {
+	Budget    time.Duration // Configured frame budget
+	Overruns  int           // Overrunning frames in the window
+	Window    int           // Frames examined
+	AvgRender time.Duration // Smoothed RenderFunc time at the time of the report
+	AvgCopy   time.Duration // Smoothed present/copy time at the time of the report
+}
+
+// CopyBound reports whether the copy to the browser, rather than the user's
+// rendering, is the larger share of the frame.
+func (r JankReport) CopyBound() bool {
+	return r.AvgCopy > r.AvgRender
+}
+
+// Default watchdog settings
+const (
+	DefaultJankWindow    = 60 // Frames examined
+	DefaultJankThreshold = 10 // Overruns within the window that count as sustained jank
+)
+
+// smoothing factor for the moving averages
+const statsSmoothing = 0.1
+
+type watchdog struct {
+	stats FrameStats
+
+	budget    time.Duration
+	threshold int
+	onJank    func(JankReport)
+
+	recent  []bool // Ring of overrun flags for the last window frames
+	next    int
+	overrun int // Count of true entries in recent
+
+	allocs bool             // Count allocations per frame, see TrackAllocs
+	mem    runtime.MemStats // Kept here so reading it doesn't allocate
+}
+
+// SetFrameBudget starts monitoring frames against budget (e.g. 16ms). When
+// at least 'threshold' of the last 'window' frames overrun, onJank is called
+// (or a warning is logged if onJank is nil) and the window restarts. A
+// budget of 0 disables the watchdog; stats are still collected.
+func (c *Canvasp) SetFrameBudget(budget time.Duration, window int, threshold int, onJank func(JankReport)) {
+	if window <= 0 {
+		window = DefaultJankWindow
+	}
+	if threshold <= 0 || threshold > window {
+		threshold = DefaultJankThreshold
+		if threshold > window {
+			threshold = window
+		}
+	}
+
+	w := &c.watchdog
+	w.budget = budget
+	w.threshold = threshold
+	w.onJank = onJank
+	w.recent = make([]bool, window)
+	w.next = 0
+	w.overrun = 0
+}
+
+// Stats returns the frame timing statistics collected so far
+func (c *Canvasp) Stats() FrameStats {
+	s := c.watchdog.stats
+	if c.profiler != nil {
+		s.Passes = c.profiler.Passes()
+	}
+	return s
+}
+
+// ResetStats clears the collected statistics, including the profiler's
+func (c *Canvasp) ResetStats() {
+	c.watchdog.stats = FrameStats{}
+	if c.profiler != nil {
+		c.profiler.Reset()
+	}
+}
+
+// TrackAllocs turns on counting heap allocations per frame, reported in
+// Stats as Allocs and AllocsPerFrame. Reading the allocator's counters
+// briefly stops the world, twice a frame, so use it while profiling rather
+// than in production.
+func (c *Canvasp) TrackAllocs(on bool) {
+	c.watchdog.allocs = on
+}
+
+// mallocs is the allocator's running count when tracking allocations, or 0
+func (w *watchdog) mallocs() uint64 {
+	if !w.allocs {
+		return 0
+	}
+	runtime.ReadMemStats(&w.mem)
+	return w.mem.Mallocs
+}
+
+// watch records one frame's timings and allocations and checks it against
+// the budget
+func (c *Canvasp) watch(render time.Duration, present time.Duration, allocs uint64) {
+	w := &c.watchdog
+	s := &w.stats
+
+	s.Frames++
+	s.Render, s.Copy = render, present
+	if s.Frames == 1 {
+		s.AvgRender, s.AvgCopy = render, present
+	} else {
+		s.AvgRender += time.Duration(statsSmoothing * float64(render-s.AvgRender))
+		s.AvgCopy += time.Duration(statsSmoothing * float64(present-s.AvgCopy))
+	}
+	if w.allocs {
+		s.Allocs = allocs
+		s.AllocsPerFrame += statsSmoothing * (float64(allocs) - s.AllocsPerFrame)
+	}
+
+	if c.crash != nil {
+		c.crash.recordFrame(s.Frames, render, present)
+	}
+	if c.profiler != nil {
+		c.profiler.endFrame()
+	}
+
+	if w.budget <= 0 || len(w.recent) == 0 {
+		return
+	}
+
+	over := render+present > w.budget
+	if over {
+		s.Overruns++
+	}
+	if w.recent[w.next] {
+		w.overrun--
+	}
+	w.recent[w.next] = over
+	if over {
+		w.overrun++
+	}
+	w.next = (w.next + 1) % len(w.recent)
+
+	if w.overrun >= w.threshold {
+		report := JankReport{
+			Budget:    w.budget,
+			Overruns:  w.overrun,
+			Window:    len(w.recent),
+			AvgRender: s.AvgRender,
+			AvgCopy:   s.AvgCopy,
+		}
+		for i := range w.recent {
+			w.recent[i] = false
+		}
+		w.overrun = 0
+		if c.analytics != nil {
+			c.analytics.trackJank(report)
+		}
+
+		if w.onJank != nil {
+			w.onJank(report)
+		} else {
+			c.log().Warn("sustained frame overruns",
+				"overruns", report.Overruns,
+				"window", report.Window,
+				"budgetMs", report.Budget.Seconds()*1000,
+				"renderMs", report.AvgRender.Seconds()*1000,
+				"copyMs", report.AvgCopy.Seconds()*1000,
+			)
+		}
+	}
+}
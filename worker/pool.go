@@ -0,0 +1,193 @@
+package worker
+
+import (
+	"errors"
+	"syscall/js"
+)
+
+// Pool hands jobs to a fixed set of workers, one job per worker at a time,
+// queueing the rest. Methods must be called from the main thread; Call may
+// also be used from other goroutines.
+type Pool struct {
+	slots  []*slot
+	queue  []*job
+	jobs   map[int]*job // In flight, by id
+	nextID int
+	closed bool
+}
+
+type slot struct {
+	w         js.Value
+	job       *job // In flight on this worker, nil when idle
+	onMessage js.Func
+	onError   js.Func
+}
+
+type job struct {
+	id   int
+	kind string
+	data []byte
+	done func([]byte, error)
+}
+
+// NewPool starts n workers running script, normally a URL from BootstrapURL
+func NewPool(script string, n int) *Pool {
+	if n < 1 {
+		n = 1
+	}
+	p := &Pool{jobs: make(map[int]*job)}
+	ctor := js.Global().Get("Worker")
+	for i := 0; i < n; i++ {
+		s := &slot{w: ctor.New(script)}
+		s.onMessage = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			p.reply(s, args[0].Get("data"))
+			return nil
+		})
+		s.onError = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			msg := "worker: error"
+			if m := args[0].Get("message"); m.Type() == js.TypeString {
+				msg = "worker: " + m.String()
+			}
+			p.fail(s, errors.New(msg))
+			return nil
+		})
+		s.w.Call("addEventListener", "message", s.onMessage)
+		s.w.Call("addEventListener", "error", s.onError)
+		p.slots = append(p.slots, s)
+	}
+	return p
+}
+
+// DefaultSize returns a pool size suited to the device: one fewer than the
+// number of logical processors (leaving the main thread its own), at least 1
+func DefaultSize() int {
+	n := 2
+	if hc := js.Global().Get("navigator").Get("hardwareConcurrency"); hc.Type() == js.TypeNumber {
+		n = hc.Int()
+	}
+	if n > 1 {
+		n--
+	}
+	return n
+}
+
+// Submit queues a job. done is called on the main thread, between frames,
+// with the worker's result, so it may update canvas state directly. The
+// pool takes ownership of data.
+func (p *Pool) Submit(kind string, data []byte, done func(result []byte, err error)) {
+	if p.closed {
+		done(nil, ErrClosed)
+		return
+	}
+	p.nextID++
+	p.queue = append(p.queue, &job{id: p.nextID, kind: kind, data: data, done: done})
+	p.dispatch()
+}
+
+// Call submits a job and blocks until it completes. It must be called from
+// a goroutine, never from inside a JS callback or RenderFunc.
+func (p *Pool) Call(kind string, data []byte) ([]byte, error) {
+	type result struct {
+		data []byte
+		err  error
+	}
+	ch := make(chan result, 1)
+	p.Submit(kind, data, func(data []byte, err error) {
+		ch <- result{data, err}
+	})
+	r := <-ch
+	return r.data, r.err
+}
+
+// Pending returns the number of queued and in flight jobs
+func (p *Pool) Pending() int {
+	return len(p.queue) + len(p.jobs)
+}
+
+// Size returns the number of workers
+func (p *Pool) Size() int {
+	return len(p.slots)
+}
+
+// Close terminates the workers. Queued and in flight jobs complete with ErrClosed.
+func (p *Pool) Close() {
+	if p.closed {
+		return
+	}
+	p.closed = true
+	for _, s := range p.slots {
+		s.w.Call("terminate")
+		s.w.Call("removeEventListener", "message", s.onMessage)
+		s.w.Call("removeEventListener", "error", s.onError)
+		s.onMessage.Release()
+		s.onError.Release()
+		s.job = nil
+	}
+
+	var failed []*job
+	for _, j := range p.jobs {
+		failed = append(failed, j)
+	}
+	failed = append(failed, p.queue...)
+	p.jobs = make(map[int]*job)
+	p.queue = nil
+	for _, j := range failed {
+		j.done(nil, ErrClosed)
+	}
+}
+
+// dispatch posts queued jobs to idle workers
+func (p *Pool) dispatch() {
+	for _, s := range p.slots {
+		if len(p.queue) == 0 {
+			return
+		}
+		if s.job != nil {
+			continue
+		}
+		j := p.queue[0]
+		p.queue[0] = nil
+		p.queue = p.queue[1:]
+
+		s.job = j
+		p.jobs[j.id] = j
+		buf := bytesToJS(j.data)
+		j.data = nil
+		s.w.Call("postMessage", map[string]interface{}{
+			"id":   j.id,
+			"kind": j.kind,
+			"data": buf,
+		}, []interface{}{buf.Get("buffer")})
+	}
+}
+
+// reply completes the job a worker has answered
+func (p *Pool) reply(s *slot, msg js.Value) {
+	j, ok := p.jobs[msg.Get("id").Int()]
+	if !ok {
+		return
+	}
+	delete(p.jobs, j.id)
+	if s.job == j {
+		s.job = nil
+	}
+	p.dispatch()
+
+	if e := msg.Get("err"); e.Type() == js.TypeString {
+		j.done(nil, errors.New(e.String()))
+		return
+	}
+	j.done(bytesFromJS(msg.Get("data")), nil)
+}
+
+// fail completes a worker's in flight job with err, after an uncaught error
+func (p *Pool) fail(s *slot, err error) {
+	j := s.job
+	if j == nil {
+		return
+	}
+	s.job = nil
+	delete(p.jobs, j.id)
+	p.dispatch()
+	j.done(nil, err)
+}
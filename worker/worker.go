@@ -0,0 +1,94 @@
+// Package worker runs Go computation off the main thread in a pool of Web
+// Workers, each running its own WASM instance, so that heavy work such as
+// procedural generation or image filters doesn't stall the render loop.
+//
+// The page side creates a Pool and submits jobs: a kind string naming the
+// job and a byte payload. The worker side is a separate Go program (or the
+// same one, started in a different mode) that calls Serve with a handler for
+// those jobs. Payloads are transferred, not copied, between threads.
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"syscall/js"
+)
+
+// Handler processes one job in a worker, returning the result payload
+type Handler func(kind string, data []byte) ([]byte, error)
+
+// ErrClosed is returned for jobs submitted to, or still queued in, a closed Pool
+var ErrClosed = errors.New("worker: pool closed")
+
+// bootstrap is the worker script made by BootstrapURL. Messages arriving
+// before the Go program calls Serve are held and replayed.
+const bootstrap = `importScripts(%q);
+const pending = [];
+self.onmessage = (e) => pending.push(e);
+self.__pixelcanvasServe = (fn) => { self.onmessage = fn; pending.splice(0).forEach(fn); };
+const go = new Go();
+WebAssembly.instantiateStreaming(fetch(%q), go.importObject).then((r) => go.run(r.instance));
+`
+
+// BootstrapURL returns a blob: URL for a worker script which loads Go's
+// wasm_exec.js from execURL and runs the WASM binary at wasmURL. Relative
+// URLs are resolved against the page. Pass the result to NewPool.
+func BootstrapURL(execURL, wasmURL string) string {
+	g := js.Global()
+	base := g.Get("location").Get("href")
+	exec := g.Get("URL").New(execURL, base).Call("toString").String()
+	wasm := g.Get("URL").New(wasmURL, base).Call("toString").String()
+
+	src := fmt.Sprintf(bootstrap, exec, wasm)
+	blob := g.Get("Blob").New([]interface{}{src}, map[string]interface{}{"type": "text/javascript"})
+	return g.Get("URL").Call("createObjectURL", blob).String()
+}
+
+// Serve runs in a worker and answers jobs with h until the worker is
+// terminated. It never returns. Each job runs on its own goroutine, so h
+// may block on promises.
+func Serve(h Handler) {
+	self := js.Global()
+	onMessage := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		msg := args[0].Get("data")
+		id := msg.Get("id").Int()
+		kind := msg.Get("kind").String()
+		data := bytesFromJS(msg.Get("data"))
+
+		go func() {
+			result, err := h(kind, data)
+			reply := map[string]interface{}{"id": id}
+			if err != nil {
+				reply["err"] = err.Error()
+				self.Call("postMessage", reply)
+				return
+			}
+			buf := bytesToJS(result)
+			reply["data"] = buf
+			self.Call("postMessage", reply, []interface{}{buf.Get("buffer")})
+		}()
+		return nil
+	})
+
+	if serve := self.Get("__pixelcanvasServe"); serve.Type() == js.TypeFunction {
+		serve.Invoke(onMessage)
+	} else {
+		self.Set("onmessage", onMessage)
+	}
+	select {}
+}
+
+func bytesFromJS(v js.Value) []byte {
+	if v.IsUndefined() || v.IsNull() {
+		return nil
+	}
+	b := make([]byte, v.Get("length").Int())
+	js.CopyBytesToGo(b, v)
+	return b
+}
+
+func bytesToJS(b []byte) js.Value {
+	v := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(v, b)
+	return v
+}
@@ -0,0 +1,230 @@
+package pixelcanvas
+
+import (
+	"image/color"
+	"math"
+	"syscall/js"
+
+	"github.com/faiface/pixel"
+)
+
+// WorldView presents a window onto a pixel buffer far bigger than the
+// canvas, such as a huge map or a scanned document. The canvas (and so its
+// ImageData and the copy each frame) stays the size of the window: each
+// frame the visible part of the world is copied onto the shadow canvas
+// before the RenderFunc runs, which can draw a HUD over it. The user pans
+// by dragging or scrolling.
+//
+// Pix uses the shadow canvas layout (premultiplied RGBA, rows bottom-up),
+// and world coordinates are its pixels, origin bottom left. Write to Pix
+// directly and call Invalidate so the change is shown even when the
+// RenderFunc reports nothing changed.
+type WorldView struct {
+	Pix           []uint8
+	Width, Height int
+
+	Background color.Color // Shown beyond the world's edges. nil for transparent
+
+	// OnScroll, if set, is called after the view has moved, with the
+	// world rectangle now visible, e.g. to load what has come into view
+	OnScroll func(visible pixel.Rect)
+
+	c         *Canvasp
+	pos       pixel.Vec // World position of the canvas's bottom left corner, whole pixels
+	dirty     bool      // Moved or invalidated since last presented
+	listeners []*listener
+	drag      int       // Pointer ID dragging, -1 for none
+	last      pixel.Vec // Pointer position at the previous drag move, in canvas pixels
+	frame     []uint8   // Scratch buffer the window is composed into
+}
+
+// ViewWorld switches the canvas to viewing a width x height world buffer,
+// allocating it, with panning by pointer drag and the wheel. Size the canvas
+// to the area it is shown in rather than to the world. Close returns the
+// canvas to normal.
+func (c *Canvasp) ViewWorld(width int, height int) *WorldView {
+	v := &WorldView{
+		Pix:    make([]uint8, width*height*4),
+		Width:  width,
+		Height: height,
+		c:      c,
+		dirty:  true,
+		drag:   -1,
+	}
+	if c.world != nil {
+		c.world.Close()
+	}
+	c.world = v
+	v.Interactive(true)
+	return v
+}
+
+// Close stops viewing the world. The buffer is left as it is.
+func (v *WorldView) Close() {
+	v.Interactive(false)
+	if v.c.world == v {
+		v.c.world = nil
+	}
+}
+
+// Invalidate marks the world as changed, so the next frame presents it
+func (v *WorldView) Invalidate() {
+	v.dirty = true
+}
+
+// Visible returns the world rectangle shown on the canvas
+func (v *WorldView) Visible() pixel.Rect {
+	return pixel.Rect{Min: v.pos, Max: v.pos.Add(pixel.V(float64(v.c.width), float64(v.c.height)))}
+}
+
+// ScrollTo moves the view so world point pos is at the canvas's bottom left
+// corner, kept within the world
+func (v *WorldView) ScrollTo(pos pixel.Vec) {
+	pos = v.clamp(pos)
+	if pos == v.pos {
+		return
+	}
+	v.pos = pos
+	v.dirty = true
+	if v.OnScroll != nil {
+		v.OnScroll(v.Visible())
+	}
+}
+
+// ScrollBy moves the view by d world pixels
+func (v *WorldView) ScrollBy(d pixel.Vec) {
+	v.ScrollTo(v.pos.Add(d))
+}
+
+// CenterOn moves the view so world point p is in the middle of the canvas
+func (v *WorldView) CenterOn(p pixel.Vec) {
+	v.ScrollTo(p.Sub(pixel.V(float64(v.c.width), float64(v.c.height)).Scaled(0.5)))
+}
+
+// ToWorld converts a logical canvas position (e.g. from FromClient) to
+// world coordinates
+func (v *WorldView) ToWorld(p pixel.Vec) pixel.Vec {
+	return v.c.ToCanvas(p).Add(v.pos)
+}
+
+// clamp rounds a view position to whole pixels and keeps the view inside
+// the world, or the world in the middle of the view when it is the smaller
+func (v *WorldView) clamp(pos pixel.Vec) pixel.Vec {
+	axis := func(p float64, view, world int) float64 {
+		if world <= view {
+			return -float64((view - world) / 2)
+		}
+		return math.Max(0, math.Min(math.Round(p), float64(world-view)))
+	}
+	return pixel.V(axis(pos.X, v.c.width, v.Width), axis(pos.Y, v.c.height, v.Height))
+}
+
+// Interactive turns panning by pointer drag and the wheel on or off
+func (v *WorldView) Interactive(on bool) {
+	for _, l := range v.listeners {
+		v.c.unlisten(l)
+	}
+	v.listeners = nil
+	v.drag = -1
+	if !on {
+		return
+	}
+
+	c := v.c
+	v.listeners = append(v.listeners,
+		c.listen(c.canvas, "pointerdown", v.pointerDown),
+		c.listen(c.canvas, "pointermove", v.pointerMove),
+		c.listen(c.canvas, "pointerup", v.pointerUp),
+		c.listen(c.canvas, "pointercancel", v.pointerUp),
+		c.listen(c.canvas, "wheel", v.wheel),
+	)
+}
+
+func (v *WorldView) pointerDown(e js.Value) {
+	if v.drag >= 0 || (e.Get("pointerType").String() == "mouse" && e.Get("button").Int() != 0) {
+		return
+	}
+	v.drag = e.Get("pointerId").Int()
+	v.last = v.c.clientToCanvas(e.Get("clientX").Float(), e.Get("clientY").Float())
+	v.c.canvas.Call("setPointerCapture", e.Get("pointerId"))
+}
+
+func (v *WorldView) pointerMove(e js.Value) {
+	if v.drag < 0 || e.Get("pointerId").Int() != v.drag {
+		return
+	}
+	// The world follows the pointer. The view moves in whole pixels, so
+	// only what it moved is taken off, and slow drags aren't lost to
+	// rounding.
+	p := v.c.clientToCanvas(e.Get("clientX").Float(), e.Get("clientY").Float())
+	from := v.pos
+	v.ScrollBy(v.last.Sub(p))
+	v.last = v.last.Sub(v.pos.Sub(from))
+}
+
+func (v *WorldView) pointerUp(e js.Value) {
+	if e.Get("pointerId").Int() == v.drag {
+		v.drag = -1
+	}
+}
+
+func (v *WorldView) wheel(e js.Value) {
+	e.Call("preventDefault")
+	dx, dy := e.Get("deltaX").Float(), e.Get("deltaY").Float()
+	switch e.Get("deltaMode").Int() {
+	case 1:
+		dx, dy = dx*wheelLineHeight, dy*wheelLineHeight
+	case 2:
+		dx, dy = dx*wheelPageHeight, dy*wheelPageHeight
+	}
+	// Deltas are CSS pixels, down the page for positive y, which is down
+	// the world unless the canvas isn't flipped
+	kx, ky := 1.0, -1.0
+	if ow, oh := v.c.canvas.Get("offsetWidth").Float(), v.c.canvas.Get("offsetHeight").Float(); ow > 0 && oh > 0 {
+		kx, ky = float64(v.c.width)/ow, -float64(v.c.height)/oh
+	}
+	if !v.c.flipY {
+		ky = -ky
+	}
+	v.ScrollBy(pixel.V(dx*kx, dy*ky))
+}
+
+// presentWorld copies the visible part of the world onto the shadow canvas,
+// reporting whether it changed since the last frame. With always false it
+// only copies when it has changed.
+func (c *Canvasp) presentWorld(always bool) bool {
+	v := c.world
+	if v == nil {
+		return false
+	}
+	changed := v.dirty
+	if !changed && !always {
+		return false
+	}
+	v.dirty = false
+	v.pos = v.clamp(v.pos) // After a resize
+
+	w, h := c.width, c.height
+	if len(v.frame) != w*h*4 {
+		v.frame = make([]uint8, w*h*4)
+	}
+	var bg [4]uint8
+	if v.Background != nil {
+		bg = rgba8(v.Background)
+	}
+	ox, oy := int(v.pos.X), int(v.pos.Y)
+	x0, x1 := clampInt(-ox, 0, w), clampInt(v.Width-ox, 0, w) // Columns the world covers
+	for y := 0; y < h; y++ {
+		row := v.frame[y*w*4 : (y+1)*w*4]
+		wy := oy + y
+		if wy < 0 || wy >= v.Height || x0 >= x1 {
+			fillPixels(row, bg)
+			continue
+		}
+		fillPixels(row[:x0*4], bg)
+		copy(row[x0*4:x1*4], v.Pix[(wy*v.Width+ox+x0)*4:(wy*v.Width+ox+x1)*4])
+		fillPixels(row[x1*4:], bg)
+	}
+	c.image.SetPixels(v.frame)
+	return changed
+}
@@ -0,0 +1,172 @@
+package pixelcanvas
+
+import (
+	"math"
+	"strconv"
+	"syscall/js"
+	"time"
+
+	"github.com/faiface/pixel"
+)
+
+// Zoom limits and timing defaults
+const (
+	DefaultMinZoom    = 0.1 // 10%
+	DefaultMaxZoom    = 64  // 6400%
+	DefaultZoomSettle = 150 * time.Millisecond
+)
+
+// Wheel event scaling
+const (
+	wheelZoomSpeed  = 0.002 // Zoom factor per wheel pixel, as an exponent
+	wheelLineHeight = 16    // Pixels per line for line-mode wheel events
+	wheelPageHeight = 800   // Pixels per page for page-mode wheel events
+)
+
+// ZoomView zooms and pans a Camera in two stages. While the user is zooming
+// (every wheel tick) the canvas element is only scaled and moved with a CSS
+// transform, which costs nothing. Once input has settled the transform is
+// folded into the Camera and reset, so the next frame re-renders sharply at
+// the new scale.
+//
+// Pointer positions from FromClient stay correct throughout: during the CSS
+// stage they map onto the picture last rendered, which is still what the
+// Camera describes. The canvas's parent should clip its overflow.
+type ZoomView struct {
+	Min, Max float64       // Zoom limits. Defaults DefaultMinZoom and DefaultMaxZoom
+	Settle   time.Duration // Quiet time before re-rendering. Default DefaultZoomSettle
+
+	// OnRedraw, if set, is called after the Camera has been updated, for
+	// renderers that don't redraw every frame
+	OnRedraw func(cam *Camera)
+
+	c   *Canvasp
+	cam *Camera
+
+	scale float64   // Pending CSS scale
+	shift pixel.Vec // Pending CSS translation, in CSS pixels
+
+	timer  js.Value
+	settle js.Func
+	wheel  *listener
+}
+
+// ZoomView attaches wheel zooming to cam, about the pointer. The same
+// ZoomView also offers programmatic zooming and panning.
+func (c *Canvasp) ZoomView(cam *Camera) *ZoomView {
+	z := &ZoomView{
+		Min:    DefaultMinZoom,
+		Max:    DefaultMaxZoom,
+		Settle: DefaultZoomSettle,
+		c:      c,
+		cam:    cam,
+		scale:  1,
+	}
+	z.settle = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		z.timer = js.Undefined()
+		z.Commit()
+		return nil
+	})
+	c.canvas.Get("style").Set("transformOrigin", "0 0")
+
+	z.wheel = c.listen(c.canvas, "wheel", func(e js.Value) {
+		e.Call("preventDefault")
+		dy := e.Get("deltaY").Float()
+		switch e.Get("deltaMode").Int() {
+		case 1:
+			dy *= wheelLineHeight
+		case 2:
+			dy *= wheelPageHeight
+		}
+		z.ZoomAt(math.Exp(-dy*wheelZoomSpeed), e.Get("clientX").Float(), e.Get("clientY").Float())
+	})
+	return z
+}
+
+// Level returns the zoom being shown, including any pending CSS scaling
+func (z *ZoomView) Level() float64 {
+	return z.cam.Zoom * z.scale
+}
+
+// ZoomAt zooms by factor about a point given in client coordinates (as on
+// pointer events), limited to Min and Max
+func (z *ZoomView) ZoomAt(factor float64, clientX, clientY float64) {
+	level := z.Level()
+	target := math.Max(z.Min, math.Min(z.Max, level*factor))
+	if target == level {
+		return
+	}
+	factor = target / level
+
+	// The layout box is the transformed box less the pending translation,
+	// as the transform origin is its top left
+	r := z.c.canvas.Call("getBoundingClientRect")
+	q := pixel.V(clientX-(r.Get("left").Float()-z.shift.X), clientY-(r.Get("top").Float()-z.shift.Y))
+
+	z.scale *= factor
+	z.shift = z.shift.Sub(q).Scaled(factor).Add(q)
+	z.apply()
+}
+
+// SetLevel zooms about the centre of the canvas to level
+func (z *ZoomView) SetLevel(level float64) {
+	r := z.c.canvas.Call("getBoundingClientRect")
+	cx := r.Get("left").Float() + r.Get("width").Float()/2
+	cy := r.Get("top").Float() + r.Get("height").Float()/2
+	z.ZoomAt(level/z.Level(), cx, cy)
+}
+
+// PanBy moves the view by dx, dy CSS pixels, e.g. from a pointer drag
+func (z *ZoomView) PanBy(dx, dy float64) {
+	z.shift = z.shift.Add(pixel.V(dx, dy))
+	z.apply()
+}
+
+// apply shows the pending transform and restarts the settle timer
+func (z *ZoomView) apply() {
+	z.c.canvas.Get("style").Set("transform",
+		"translate("+cssNum(z.shift.X)+"px,"+cssNum(z.shift.Y)+"px) scale("+cssNum(z.scale)+")")
+	if !z.timer.IsUndefined() {
+		z.c.window.Call("clearTimeout", z.timer)
+	}
+	z.timer = z.c.window.Call("setTimeout", z.settle, z.Settle.Seconds()*1000)
+}
+
+// Commit folds any pending CSS transform into the Camera now, rather than
+// waiting for input to settle
+func (z *ZoomView) Commit() {
+	if z.scale == 1 && z.shift == pixel.ZV {
+		return
+	}
+	if !z.timer.IsUndefined() {
+		z.c.window.Call("clearTimeout", z.timer)
+		z.timer = js.Undefined()
+	}
+
+	// The canvas pixel now shown at the top left corner of the layout box
+	kx := float64(z.c.width) / z.c.canvas.Get("offsetWidth").Float()
+	ky := float64(z.c.height) / z.c.canvas.Get("offsetHeight").Float()
+	corner := pixel.V(-z.shift.X/z.scale*kx, -z.shift.Y/z.scale*ky)
+	world := z.cam.Unproject(z.c.FromDOM(corner))
+
+	z.cam.Zoom *= z.scale
+	z.cam.Pin(world, z.c.FromDOM(pixel.ZV))
+
+	z.scale, z.shift = 1, pixel.ZV
+	z.c.canvas.Get("style").Set("transform", "")
+	if z.OnRedraw != nil {
+		z.OnRedraw(z.cam)
+	}
+}
+
+// Close removes the wheel handler and any pending transform
+func (z *ZoomView) Close() {
+	z.Commit()
+	z.c.unlisten(z.wheel)
+	z.settle.Release()
+}
+
+// cssNum formats a number for CSS
+func cssNum(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}